@@ -17,7 +17,7 @@ echo -e "${GREEN}Building GoWhisper standalone binary...${NC}"
 cd "$(dirname "$0")"
 
 # Allow override via environment variables with sensible defaults
-: ${GOWHISPER_INSTALL_DIR:="$HOME/.go-whisper"}
+: ${GOWHISPER_INSTALL_DIR:="${GOWHISPER_HOME:-$HOME/.go-whisper}"}
 
 # Set up CGO environment for whisper.cpp
 export CGO_ENABLED=1
@@ -36,12 +36,20 @@ if [ ! -d "$GOWHISPER_INSTALL_DIR/whisper.cpp/build" ]; then
     exit 1
 fi
 
+# Built into the binary for the --doctor transcription check
+if [ ! -f src/samples/jfk.wav ] && [ -f "$GOWHISPER_INSTALL_DIR/whisper.cpp/samples/jfk.wav" ]; then
+    cp "$GOWHISPER_INSTALL_DIR/whisper.cpp/samples/jfk.wav" src/samples/jfk.wav
+fi
+
 # Create bin directory if it doesn't exist
 mkdir -p bin
 
 # Build the binary
 echo -e "${YELLOW}Compiling go-whisper...${NC}"
-go build -o bin/GoWhisper src/main.go
+VERSION=$(git describe --tags --always --dirty 2>/dev/null || echo dev)
+COMMIT=$(git rev-parse --short HEAD 2>/dev/null || echo unknown)
+BUILD_DATE=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+go build -ldflags "-X main.version=$VERSION -X main.commit=$COMMIT -X main.buildDate=$BUILD_DATE" -o bin/GoWhisper ./src
 
 # Check if build was successful
 if [ ! -f "bin/GoWhisper" ]; then
@@ -61,7 +69,7 @@ cat > bin/run.sh << 'LAUNCHER_EOF'
 # Sets up environment and launches the binary
 
 # Allow override via environment variables with sensible defaults
-: ${GOWHISPER_INSTALL_DIR:="$HOME/.go-whisper"}
+: ${GOWHISPER_INSTALL_DIR:="${GOWHISPER_HOME:-$HOME/.go-whisper}"}
 : ${GOWHISPER_MODEL:="$GOWHISPER_INSTALL_DIR/models/ggml-small.en.bin"}
 : ${GOWHISPER_LOG:="/tmp/go-whisper.log"}
 
@@ -7,7 +7,7 @@ import (
 	"os"
 
 	"github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
-	"github.com/go-audio/wav"
+	"github.com/stephanwesten/go-whisper/src/audio"
 )
 
 func main() {
@@ -44,22 +44,12 @@ func main() {
 	}
 	defer fh.Close()
 
-	// Decode the WAV file
-	dec := wav.NewDecoder(fh)
-	buf, err := dec.FullPCMBuffer()
+	// Decode the WAV file, downmixing to mono and resampling to 16kHz if needed
+	data, err := audio.DecodeWAV(fh)
 	if err != nil {
 		log.Fatalf("Failed to decode WAV: %v", err)
 	}
-
-	if dec.SampleRate != whisper.SampleRate {
-		log.Fatalf("Unsupported sample rate: %d (expected %d)", dec.SampleRate, whisper.SampleRate)
-	}
-	if dec.NumChans != 1 {
-		log.Fatalf("Unsupported number of channels: %d (expected 1)", dec.NumChans)
-	}
-
-	data := buf.AsFloat32Buffer().Data
-	log.Printf("Loaded %d samples at %dHz", len(data), dec.SampleRate)
+	log.Printf("Loaded %d samples at %dHz", len(data), whisper.SampleRate)
 
 	// Process the audio data
 	log.Println("Processing audio...")
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"log"
+	"sync"
+
+	"github.com/getlantern/systray"
+)
+
+// Without Accessibility permission (on Linux: without a working xdotool or
+// wtype) every key press GoWhisper sends fails. Instead of failing each
+// dictation with the same dialog, the app then runs in a degraded mode that
+// copies dictations to the clipboard, until a re-check finds the permission.
+var (
+	accessMu sync.Mutex
+	// accessDenied is set while running in the degraded mode
+	accessDenied bool
+	// mAccess is both the indicator of the degraded mode and the way out of it
+	mAccess *systray.MenuItem
+)
+
+// accessDeniedIcon is shown after the menu bar icon in the degraded mode
+const accessDeniedIcon = "⚠"
+
+// isAccessDenied reports whether typing is known to fail, so dictations are
+// copied to the clipboard instead (thread-safe)
+func isAccessDenied() bool {
+	accessMu.Lock()
+	defer accessMu.Unlock()
+	return accessDenied
+}
+
+// setAccessDenied enters or leaves the degraded mode, returning false if it
+// was already in that state (thread-safe)
+func setAccessDenied(denied bool) bool {
+	accessMu.Lock()
+	changed := accessDenied != denied
+	accessDenied = denied
+	accessMu.Unlock()
+
+	if changed {
+		ui.ShowAccessWarning(denied)
+	}
+	return changed
+}
+
+// addAccessMenu adds the menu item shown in the degraded mode, hidden until
+// it is needed
+func addAccessMenu() {
+	mAccess = systray.AddMenuItem("⚠ No Accessibility Permission - Check Again",
+		"Dictations are copied to the clipboard until GoWhisper may type. Click after granting the permission.")
+	mAccess.Hide()
+}
+
+// checkAccessAtStartup enters the degraded mode right away when typing is
+// not allowed, rather than after the first dictation failed
+func checkAccessAtStartup() {
+	if err := injector.CheckAccess(); err != nil {
+		log.Printf("Warning: Can't type, copying dictations to the clipboard: %v", err)
+		setAccessDenied(true)
+	}
+}
+
+// accessLost is called after typing failed. It checks the permission and
+// enters the degraded mode if it is missing, returning true if the user
+// should be told about it: only the first time, not on every dictation.
+func accessLost() bool {
+	if isAccessDenied() {
+		return false
+	}
+	err := injector.CheckAccess()
+	if err == nil {
+		// Typing failed for another reason, keep trying to type
+		return false
+	}
+	log.Printf("Warning: Accessibility permission missing, copying dictations to the clipboard: %v", err)
+	return setAccessDenied(true)
+}
+
+// recheckAccess leaves the degraded mode if typing is allowed again, from
+// the menu item. Only done while idle, so an indicator skipped while
+// degraded is never deleted after access returned.
+func recheckAccess() {
+	if state := getState(); state != StateIdle {
+		log.Printf("Cannot check Accessibility permission while %s, ignoring", state)
+		return
+	}
+	if err := injector.CheckAccess(); err != nil {
+		log.Printf("Accessibility permission still missing: %v", err)
+		showAccessDialog("GoWhisper still can't type. Dictations keep going to the clipboard.")
+		return
+	}
+	if setAccessDenied(false) {
+		log.Println("Accessibility permission granted, typing dictations again")
+	}
+	showStatusBriefly("Accessibility permission OK, typing dictations again")
+}
+
+// showAccessDialog explains how to grant the permission, after intro
+func showAccessDialog(intro string) {
+	showErrorDialog("Accessibility Permission Required",
+		intro+"\n\nTo let GoWhisper type, go to:\n"+
+			"System Settings → Privacy & Security → Accessibility\n\nAnd add your Terminal app to the allowed list. "+
+			"Then click \"Check Again\" in the menu.")
+}
@@ -0,0 +1,92 @@
+package audio
+
+import "math"
+
+// MixToMono averages interleaved multi-channel samples down to a single channel.
+// A trailing partial frame is dropped.
+func MixToMono(samples []float32, channels int) []float32 {
+	if channels <= 1 {
+		return samples
+	}
+
+	frames := len(samples) / channels
+	mono := make([]float32, frames)
+	for i := 0; i < frames; i++ {
+		var sum float32
+		for c := 0; c < channels; c++ {
+			sum += samples[i*channels+c]
+		}
+		mono[i] = sum / float32(channels)
+	}
+	return mono
+}
+
+// Resample converts mono samples from one sample rate to another using linear interpolation.
+// This is good enough for speech going into Whisper, not for high-fidelity audio.
+func Resample(samples []float32, fromRate, toRate int) []float32 {
+	if fromRate == toRate || fromRate <= 0 || toRate <= 0 || len(samples) == 0 {
+		return samples
+	}
+
+	outLen := int(int64(len(samples)) * int64(toRate) / int64(fromRate))
+	out := make([]float32, outLen)
+	step := float64(fromRate) / float64(toRate)
+	for i := range out {
+		pos := float64(i) * step
+		idx := int(pos)
+		if idx >= len(samples)-1 {
+			out[i] = samples[len(samples)-1]
+			continue
+		}
+		frac := float32(pos - float64(idx))
+		out[i] = samples[idx]*(1-frac) + samples[idx+1]*frac
+	}
+	return out
+}
+
+// RMS returns the root mean square level of the samples
+func RMS(samples []float32) float32 {
+	if len(samples) == 0 {
+		return 0
+	}
+	var sumSquared float64
+	for _, s := range samples {
+		sumSquared += float64(s) * float64(s)
+	}
+	return float32(math.Sqrt(sumSquared / float64(len(samples))))
+}
+
+// TrimSilence removes leading and trailing audio whose RMS level stays below
+// threshold, keeping a little padding around the speech so word edges survive.
+// Returns an empty slice if no window reaches the threshold.
+func TrimSilence(samples []float32, threshold float32) []float32 {
+	const window = SampleRate / 100 // 10ms
+	const padding = SampleRate / 10 // 100ms
+
+	first, last := -1, -1
+	for start := 0; start < len(samples); start += window {
+		end := start + window
+		if end > len(samples) {
+			end = len(samples)
+		}
+		if RMS(samples[start:end]) >= threshold {
+			if first < 0 {
+				first = start
+			}
+			last = end
+		}
+	}
+	if first < 0 {
+		return samples[:0]
+	}
+
+	first -= padding
+	if first < 0 {
+		first = 0
+	}
+	last += padding
+	if last > len(samples) {
+		last = len(samples)
+	}
+	return samples[first:last]
+}
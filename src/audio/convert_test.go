@@ -0,0 +1,175 @@
+package audio
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestMixToMono tests downmixing of interleaved multi-channel audio
+func TestMixToMono(t *testing.T) {
+	tests := []struct {
+		name     string
+		samples  []float32
+		channels int
+		want     []float32
+	}{
+		{"mono unchanged", []float32{0.1, 0.2, 0.3}, 1, []float32{0.1, 0.2, 0.3}},
+		{"stereo averaged", []float32{0.2, 0.4, -0.5, 0.5}, 2, []float32{0.3, 0}},
+		{"three channels", []float32{0.3, 0.3, 0.3, 0.6, 0, 0}, 3, []float32{0.3, 0.2}},
+		{"partial frame dropped", []float32{0.2, 0.4, 0.9}, 2, []float32{0.3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MixToMono(tt.samples, tt.channels)
+			if len(got) != len(tt.want) {
+				t.Fatalf("MixToMono() returned %d samples, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if diff := got[i] - tt.want[i]; diff > 1e-6 || diff < -1e-6 {
+					t.Errorf("MixToMono()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestResample tests sample rate conversion
+func TestResample(t *testing.T) {
+	t.Run("same rate unchanged", func(t *testing.T) {
+		in := []float32{0.1, 0.2}
+		if got := Resample(in, SampleRate, SampleRate); len(got) != 2 {
+			t.Errorf("Resample() length = %d, want 2", len(got))
+		}
+	})
+
+	t.Run("48kHz to 16kHz", func(t *testing.T) {
+		in := make([]float32, 48000)
+		if got := Resample(in, 48000, SampleRate); len(got) != SampleRate {
+			t.Errorf("Resample() length = %d, want %d", len(got), SampleRate)
+		}
+	})
+
+	t.Run("upsampling interpolates", func(t *testing.T) {
+		got := Resample([]float32{0, 1}, 8000, 16000)
+		want := []float32{0, 0.5, 1, 1}
+		if len(got) != len(want) {
+			t.Fatalf("Resample() length = %d, want %d", len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Resample()[%d] = %v, want %v", i, got[i], want[i])
+			}
+		}
+	})
+}
+
+// TestTrimSilence tests removal of leading and trailing silence
+func TestTrimSilence(t *testing.T) {
+	t.Run("all silence trims to empty", func(t *testing.T) {
+		if got := TrimSilence(make([]float32, SampleRate), 0.01); len(got) != 0 {
+			t.Errorf("TrimSilence(silence) length = %d, want 0", len(got))
+		}
+	})
+
+	t.Run("speech in the middle keeps padding", func(t *testing.T) {
+		// 1s silence, 0.5s speech, 1s silence
+		samples := make([]float32, SampleRate*5/2)
+		for i := SampleRate; i < SampleRate*3/2; i++ {
+			samples[i] = 0.5
+		}
+		got := TrimSilence(samples, 0.01)
+		want := SampleRate/2 + 2*(SampleRate/10)
+		if len(got) != want {
+			t.Errorf("TrimSilence() length = %d, want %d (speech + 100ms padding each side)", len(got), want)
+		}
+	})
+
+	t.Run("speech at the edges is kept", func(t *testing.T) {
+		samples := make([]float32, SampleRate)
+		samples[0] = 1
+		samples[len(samples)-1] = 1
+		if got := TrimSilence(samples, 0.01); len(got) != len(samples) {
+			t.Errorf("TrimSilence() length = %d, want %d", len(got), len(samples))
+		}
+	})
+}
+
+// benchmarkSeconds is the recording length the benchmarks use, a long but
+// realistic dictation
+const benchmarkSeconds = 60
+
+// speechLike returns seconds of audio at rate with channels interleaved: a
+// second of near-silence at both ends around a tone with noise on top, so
+// trimming and the level checks have something to find
+func speechLike(seconds, rate, channels int) []float32 {
+	rng := rand.New(rand.NewSource(1))
+	frames := seconds * rate
+	samples := make([]float32, frames*channels)
+	for i := 0; i < frames; i++ {
+		level := 0.3
+		if i < rate || i >= frames-rate {
+			level = 0.001
+		}
+		v := float32(level * (math.Sin(2*math.Pi*220*float64(i)/float64(rate)) + rng.NormFloat64()*0.1))
+		for c := 0; c < channels; c++ {
+			samples[i*channels+c] = v
+		}
+	}
+	return samples
+}
+
+// BenchmarkMixToMono measures downmixing a minute of 48kHz stereo
+func BenchmarkMixToMono(b *testing.B) {
+	in := speechLike(benchmarkSeconds, 48000, 2)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		MixToMono(in, 2)
+	}
+}
+
+// BenchmarkResample measures converting a minute of 48kHz mono to 16kHz
+func BenchmarkResample(b *testing.B) {
+	in := speechLike(benchmarkSeconds, 48000, 1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Resample(in, 48000, SampleRate)
+	}
+}
+
+// BenchmarkRMS measures the level of a minute of audio
+func BenchmarkRMS(b *testing.B) {
+	in := speechLike(benchmarkSeconds, SampleRate, 1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		RMS(in)
+	}
+}
+
+// BenchmarkTrimSilence measures trimming a minute of audio
+func BenchmarkTrimSilence(b *testing.B) {
+	in := speechLike(benchmarkSeconds, SampleRate, 1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		TrimSilence(in, 0.01)
+	}
+}
+
+// BenchmarkPipeline measures everything done to a minute of 48kHz stereo
+// after capture and before Whisper: downmix, resample, level, denoise, trim
+func BenchmarkPipeline(b *testing.B) {
+	in := speechLike(benchmarkSeconds, 48000, 2)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		samples := Resample(MixToMono(in, 2), 48000, SampleRate)
+		RMS(samples)
+		samples = Denoise(samples)
+		TrimSilence(samples, 0.01)
+	}
+}
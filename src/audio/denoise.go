@@ -0,0 +1,141 @@
+package audio
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+const (
+	// denoiseFrame is the FFT size (32ms at 16kHz); frames overlap by half
+	denoiseFrame = 512
+	denoiseHop   = denoiseFrame / 2
+
+	// NoiseProfileSamples is the leading part of a recording (200ms) taken to
+	// be background noise only
+	NoiseProfileSamples = SampleRate / 5
+
+	// overSubtraction removes a bit more than the estimated noise, which
+	// suppresses the "musical noise" left by plain subtraction
+	overSubtraction = 1.5
+	// spectralFloor keeps this fraction of each bin's magnitude so speech
+	// sharing a bin with the noise isn't cut to silence
+	spectralFloor = 0.05
+)
+
+// Denoise reduces steady background noise such as a fan or air conditioning
+// by spectral subtraction. The noise spectrum is estimated from the first
+// NoiseProfileSamples, so it works best when the recording starts before the
+// speech does. Recordings too short to hold a noise profile and some speech
+// are returned unchanged.
+//
+// Cost is two 512-point FFTs per 16ms of audio, roughly 0.1s of CPU per
+// minute of audio: small next to the transcription itself.
+func Denoise(samples []float32) []float32 {
+	if len(samples) < NoiseProfileSamples+denoiseFrame {
+		return samples
+	}
+
+	// Pad by one hop in front so every sample is covered by two frames, whose
+	// Hann windows then sum to exactly one
+	frames := (denoiseHop+len(samples)+denoiseHop-1)/denoiseHop + 1
+	padded := make([]float64, (frames-1)*denoiseHop+denoiseFrame)
+	for i, s := range samples {
+		padded[denoiseHop+i] = float64(s)
+	}
+
+	window := make([]float64, denoiseFrame)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/denoiseFrame) // Periodic Hann
+	}
+
+	// One allocation for all frames rather than one per 16ms of audio
+	backing := make([]complex128, frames*denoiseFrame)
+	spectra := make([][]complex128, frames)
+	for f := range spectra {
+		spectrum := backing[f*denoiseFrame : (f+1)*denoiseFrame]
+		start := f * denoiseHop
+		for i := range spectrum {
+			spectrum[i] = complex(padded[start+i]*window[i], 0)
+		}
+		fft(spectrum, false)
+		spectra[f] = spectrum
+	}
+
+	// Average magnitude of the frames that lie entirely within the noise profile
+	noise := make([]float64, denoiseFrame)
+	profileFrames := 0
+	for f := 1; f*denoiseHop+denoiseFrame <= denoiseHop+NoiseProfileSamples; f++ {
+		for i, c := range spectra[f] {
+			noise[i] += cmplx.Abs(c)
+		}
+		profileFrames++
+	}
+	for i := range noise {
+		noise[i] /= float64(profileFrames)
+	}
+
+	out := make([]float64, len(padded))
+	for f, spectrum := range spectra {
+		for i, c := range spectrum {
+			magnitude := cmplx.Abs(c)
+			if magnitude == 0 {
+				continue
+			}
+			reduced := math.Max(magnitude-overSubtraction*noise[i], spectralFloor*magnitude)
+			spectrum[i] = c * complex(reduced/magnitude, 0)
+		}
+		fft(spectrum, true)
+		start := f * denoiseHop
+		for i, c := range spectrum {
+			out[start+i] += real(c)
+		}
+	}
+
+	result := make([]float32, len(samples))
+	for i := range result {
+		result[i] = float32(out[denoiseHop+i])
+	}
+	return result
+}
+
+// fft computes the discrete Fourier transform of x in place, or its inverse
+// (scaled by 1/n) when inverse is set. len(x) must be a power of two.
+func fft(x []complex128, inverse bool) {
+	n := len(x)
+
+	// Bit-reversal permutation
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	sign := -1.0
+	if inverse {
+		sign = 1.0
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Rect(1, sign*2*math.Pi/float64(size))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even, odd := x[start+k], w*x[start+k+size/2]
+				x[start+k] = even + odd
+				x[start+k+size/2] = even - odd
+				w *= step
+			}
+		}
+	}
+
+	if inverse {
+		scale := complex(1/float64(n), 0)
+		for i := range x {
+			x[i] *= scale
+		}
+	}
+}
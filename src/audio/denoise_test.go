@@ -0,0 +1,78 @@
+package audio
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// tone returns n samples of a sine wave at freq Hz
+func tone(n int, freq, amplitude float64) []float32 {
+	out := make([]float32, n)
+	for i := range out {
+		out[i] = float32(amplitude * math.Sin(2*math.Pi*freq*float64(i)/SampleRate))
+	}
+	return out
+}
+
+// TestDenoise tests that steady noise is reduced while speech-band content survives
+func TestDenoise(t *testing.T) {
+	t.Run("too short is unchanged", func(t *testing.T) {
+		in := tone(NoiseProfileSamples, 440, 0.5)
+		out := Denoise(in)
+		if &out[0] != &in[0] {
+			t.Error("Denoise() copied a recording too short to denoise, want it returned as is")
+		}
+	})
+
+	t.Run("without noise the signal is reconstructed", func(t *testing.T) {
+		in := make([]float32, SampleRate)
+		copy(in[NoiseProfileSamples:], tone(SampleRate-NoiseProfileSamples, 440, 0.5))
+		out := Denoise(in)
+		if len(out) != len(in) {
+			t.Fatalf("Denoise() length = %d, want %d", len(out), len(in))
+		}
+		for i := range in {
+			if math.Abs(float64(out[i]-in[i])) > 1e-4 {
+				t.Fatalf("Denoise()[%d] = %f, want %f", i, out[i], in[i])
+			}
+		}
+	})
+
+	t.Run("steady noise is reduced", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(1))
+		n := 2 * SampleRate
+		speech := tone(n, 440, 0.3)
+		in := make([]float32, n)
+		for i := range in {
+			in[i] = float32(rng.NormFloat64() * 0.02)
+			if i >= SampleRate {
+				in[i] += speech[i]
+			}
+		}
+
+		out := Denoise(in)
+		noiseBefore, noiseAfter := RMS(in[:SampleRate]), RMS(out[:SampleRate])
+		if noiseAfter > noiseBefore/3 {
+			t.Errorf("noise RMS %.4f -> %.4f, want at least 3x lower", noiseBefore, noiseAfter)
+		}
+		speechBefore, speechAfter := RMS(speech[SampleRate:]), RMS(out[SampleRate:])
+		if ratio := speechAfter / speechBefore; ratio < 0.9 || ratio > 1.1 {
+			t.Errorf("speech RMS %.4f -> %.4f, want within 10%%", speechBefore, speechAfter)
+		}
+	})
+}
+
+// BenchmarkDenoise measures the cost of denoising a minute of audio
+func BenchmarkDenoise(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	in := make([]float32, benchmarkSeconds*SampleRate)
+	for i := range in {
+		in[i] = float32(rng.NormFloat64() * 0.1)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Denoise(in)
+	}
+}
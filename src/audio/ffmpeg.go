@@ -0,0 +1,59 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ErrNoFFmpeg is returned by DecodeFFmpeg when ffmpeg isn't installed
+var ErrNoFFmpeg = errors.New("ffmpeg not found")
+
+// DecodeFFmpeg decodes audio in any format ffmpeg reads, such as MP3, M4A,
+// Opus or FLAC, to mono samples at SampleRate. The data is written to a
+// temporary file first, since formats like M4A can't be read from a pipe.
+func DecodeFFmpeg(data []byte) ([]float32, error) {
+	path, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, ErrNoFFmpeg
+	}
+
+	input, err := os.CreateTemp("", "gowhisper-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to buffer audio: %w", err)
+	}
+	defer os.Remove(input.Name())
+	_, err = input.Write(data)
+	if closeErr := input.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to buffer audio: %w", err)
+	}
+
+	cmd := exec.Command(path, "-nostdin", "-hide_banner", "-loglevel", "error",
+		"-i", input.Name(), "-vn", "-ac", "1", "-ar", strconv.Itoa(SampleRate), "-f", "f32le", "pipe:1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return decodeFloat32LE(out), nil
+}
+
+// decodeFloat32LE converts raw little-endian 32-bit float PCM to samples,
+// ignoring a trailing partial sample
+func decodeFloat32LE(data []byte) []float32 {
+	samples := make([]float32, len(data)/4)
+	for i := range samples {
+		samples[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+	return samples
+}
@@ -0,0 +1,49 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"os/exec"
+	"testing"
+)
+
+// TestDecodeFloat32LE tests converting ffmpeg's raw output to samples
+func TestDecodeFloat32LE(t *testing.T) {
+	var data []byte
+	for _, s := range []float32{0, 0.5, -1} {
+		data = binary.LittleEndian.AppendUint32(data, math.Float32bits(s))
+	}
+	data = append(data, 0x12, 0x34) // Partial sample
+
+	got := decodeFloat32LE(data)
+	if len(got) != 3 || got[0] != 0 || got[1] != 0.5 || got[2] != -1 {
+		t.Errorf("decodeFloat32LE() = %v, want [0 0.5 -1]", got)
+	}
+}
+
+// TestDecodeFFmpeg tests decoding through ffmpeg, if it is installed
+func TestDecodeFFmpeg(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg not installed")
+	}
+
+	samples := make([]float32, SampleRate/2)
+	for i := range samples {
+		samples[i] = 0.5 * float32(math.Sin(2*math.Pi*440*float64(i)/SampleRate))
+	}
+	wav, err := EncodeWAV(samples)
+	if err != nil {
+		t.Fatalf("EncodeWAV() error = %v", err)
+	}
+	got, err := DecodeFFmpeg(wav)
+	if err != nil {
+		t.Fatalf("DecodeFFmpeg() error = %v", err)
+	}
+	if len(got) != len(samples) {
+		t.Errorf("DecodeFFmpeg() = %d samples, want %d", len(got), len(samples))
+	}
+
+	if _, err := DecodeFFmpeg([]byte("this is not audio")); err == nil {
+		t.Error("DecodeFFmpeg(text) succeeded, want an error")
+	}
+}
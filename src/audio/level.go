@@ -0,0 +1,46 @@
+package audio
+
+import "sync"
+
+// LevelMonitor tracks the RMS level of recent recordings to notice a muted or
+// badly gained microphone, which otherwise only shows up as empty transcriptions
+type LevelMonitor struct {
+	mu        sync.Mutex
+	window    int
+	threshold float32
+	recent    []float32 // RMS of the last window recordings, oldest first
+	warned    bool      // Already reported the current run of quiet recordings
+}
+
+// NewLevelMonitor creates a monitor that reports once the last window
+// recordings all had an RMS below threshold
+func NewLevelMonitor(window int, threshold float32) *LevelMonitor {
+	return &LevelMonitor{window: window, threshold: threshold}
+}
+
+// Add records the RMS of a recording. It returns true when this recording
+// completes a run of window quiet recordings; it then stays quiet until a
+// recording above the threshold resets the run.
+func (m *LevelMonitor) Add(rms float32) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.window <= 0 {
+		return false
+	}
+	if rms >= m.threshold {
+		m.recent = m.recent[:0]
+		m.warned = false
+		return false
+	}
+
+	m.recent = append(m.recent, rms)
+	if len(m.recent) > m.window {
+		m.recent = m.recent[len(m.recent)-m.window:]
+	}
+	if len(m.recent) < m.window || m.warned {
+		return false
+	}
+	m.warned = true
+	return true
+}
@@ -0,0 +1,59 @@
+package audio
+
+import "testing"
+
+// TestLevelMonitor tests warning once after a run of quiet recordings
+func TestLevelMonitor(t *testing.T) {
+	tests := []struct {
+		name   string
+		levels []float32
+		want   []bool
+	}{
+		{
+			name:   "normal levels never warn",
+			levels: []float32{0.05, 0.1, 0.03, 0.08},
+			want:   []bool{false, false, false, false},
+		},
+		{
+			name:   "warns on the third quiet recording",
+			levels: []float32{0.0001, 0.0002, 0},
+			want:   []bool{false, false, true},
+		},
+		{
+			name:   "warns only once per quiet run",
+			levels: []float32{0, 0, 0, 0, 0},
+			want:   []bool{false, false, true, false, false},
+		},
+		{
+			name:   "a loud recording resets the run",
+			levels: []float32{0, 0, 0.2, 0, 0, 0},
+			want:   []bool{false, false, false, false, false, true},
+		},
+		{
+			name:   "warns again after recovering",
+			levels: []float32{0, 0, 0, 0.2, 0, 0, 0},
+			want:   []bool{false, false, true, false, false, false, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewLevelMonitor(3, 0.001)
+			for i, level := range tt.levels {
+				if got := m.Add(level); got != tt.want[i] {
+					t.Errorf("Add(%v) #%d = %v, want %v", level, i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestLevelMonitorDisabled tests that a zero window never warns
+func TestLevelMonitorDisabled(t *testing.T) {
+	m := NewLevelMonitor(0, 0.001)
+	for i := 0; i < 10; i++ {
+		if m.Add(0) {
+			t.Fatal("Add() = true with monitoring disabled")
+		}
+	}
+}
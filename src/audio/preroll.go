@@ -0,0 +1,54 @@
+package audio
+
+// ringBuffer keeps the most recent samples, overwriting the oldest ones. It is
+// used for pre-roll: audio captured before Start so the first word isn't clipped.
+type ringBuffer struct {
+	data []float32
+	pos  int  // Next write position
+	full bool // Whether data has wrapped around at least once
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{data: make([]float32, size)}
+}
+
+// write appends samples, keeping only the last len(data)
+func (b *ringBuffer) write(in []float32) {
+	if len(b.data) == 0 {
+		return
+	}
+	// Only the tail of a chunk larger than the ring can survive
+	if len(in) >= len(b.data) {
+		copy(b.data, in[len(in)-len(b.data):])
+		b.pos = 0
+		b.full = true
+		return
+	}
+	n := copy(b.data[b.pos:], in)
+	if n < len(in) {
+		copy(b.data, in[n:])
+		b.full = true
+	}
+	b.pos = (b.pos + len(in)) % len(b.data)
+	if b.pos == 0 {
+		b.full = true
+	}
+}
+
+// contents returns a copy of the buffered samples, oldest first
+func (b *ringBuffer) contents() []float32 {
+	if !b.full {
+		result := make([]float32, b.pos)
+		copy(result, b.data[:b.pos])
+		return result
+	}
+	result := make([]float32, 0, len(b.data))
+	result = append(result, b.data[b.pos:]...)
+	return append(result, b.data[:b.pos]...)
+}
+
+// reset empties the buffer
+func (b *ringBuffer) reset() {
+	b.pos = 0
+	b.full = false
+}
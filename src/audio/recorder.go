@@ -1,8 +1,11 @@
 package audio
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"sync"
+	"time"
 
 	"github.com/gordonklaus/portaudio"
 )
@@ -12,12 +15,101 @@ const (
 	Channels   = 1     // Mono
 )
 
-// Recorder handles audio recording from microphone
+// Opening the stream fails intermittently right after waking from sleep while
+// the audio subsystem isn't ready yet, so Start retries a few times
+const startAttempts = 3
+
+var (
+	startRetryDelay = 200 * time.Millisecond
+
+	// A running stream calls back every few milliseconds. One kept open that
+	// has been silent for longer likely lost its device, e.g. over sleep or
+	// when a headset was unplugged, so Start reopens it.
+	quietStreamAfter = time.Second
+
+	// openInputStream and the device functions are the recorder's only use
+	// of PortAudio, so tests can swap them to run without hardware
+	openInputStream    = openPortAudioStream
+	defaultInputDevice = portaudio.DefaultInputDevice
+	reinitialize       = func() error {
+		portaudio.Terminate()
+		return portaudio.Initialize()
+	}
+)
+
+// audioStream is an input stream that calls back with each buffer of samples
+// between Start and Stop, see *portaudio.Stream
+type audioStream interface {
+	Start() error
+	Stop() error
+	Close() error
+}
+
+// streamCallback receives a buffer of mono samples and PortAudio's status flags
+type streamCallback func(in []float32, flags portaudio.StreamCallbackFlags)
+
+// openPortAudioStream opens the default input device at SampleRate
+func openPortAudioStream(callback streamCallback) (audioStream, error) {
+	stream, err := portaudio.OpenDefaultStream(Channels, 0, float64(SampleRate), 0, func(in []float32, _ portaudio.StreamCallbackTimeInfo, flags portaudio.StreamCallbackFlags) {
+		callback(in, flags)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// ErrNoInputDevice is returned by Start when the system has no microphone,
+// or all of them are disabled
+var ErrNoInputDevice = errors.New("no microphone detected")
+
+// ErrInputOverflow is returned by Stop (together with the recorded samples)
+// when PortAudio reported an input overflow during the recording, meaning
+// some audio was dropped and the transcription may be degraded.
+var ErrInputOverflow = errors.New("input overflow during recording, audio may be incomplete")
+
+// Recorder handles audio recording from microphone. All methods are safe
+// for concurrent use.
 type Recorder struct {
-	stream   *portaudio.Stream
-	buffer   []float32
-	mu       sync.Mutex
-	isActive bool
+	// streamMu serializes Start, Stop, SetPreRoll, SetMonitorWindow,
+	// SetKeepStreamOpen and Close, and guards
+	// stream. It is never taken by the stream callback, so the stream can be
+	// stopped while holding it: PortAudio's Stop waits for a running callback,
+	// which needs mu.
+	streamMu sync.Mutex
+	stream   audioStream
+
+	// mu guards the state shared with the stream callback
+	mu        sync.Mutex
+	buffer    []float32
+	isActive  bool
+	overflows int // Number of callbacks flagged with input overflow
+
+	// When the stream last called back, or was opened
+	lastCallback time.Time
+
+	// Optional fixed gain multiplying every sample, unused when 0 or 1
+	gain    float32
+	clipped int // Samples clamped to [-1, 1] after gain while recording
+
+	// Optional auto-stop on silence, disabled unless SetAutoStop is called
+	silenceThreshold float32
+	silenceDuration  time.Duration
+	silence          *silenceDetector
+	silenceCh        chan struct{}
+
+	// Optional pre-roll, disabled unless SetPreRoll or SetMonitorWindow is
+	// called. While enabled the stream stays open between recordings, feeding
+	// this ring buffer. Start prepends the last preRollLen samples of it, or
+	// all of it once after PrependMonitorWindow.
+	preRoll        *ringBuffer
+	preRollLen     int
+	monitorLen     int
+	prependMonitor bool
+
+	// Optional stream kept open between recordings without a pre-roll, set
+	// by SetKeepStreamOpen, so Start doesn't wait for the device
+	keepOpen bool
 }
 
 // NewRecorder creates a new audio recorder
@@ -26,67 +118,399 @@ func NewRecorder() (*Recorder, error) {
 		return nil, fmt.Errorf("failed to initialize PortAudio: %w", err)
 	}
 
+	// Not fatal: a microphone may be connected later, Start checks again
+	if !hasInputDevice() {
+		log.Printf("Warning: %v", ErrNoInputDevice)
+	}
+
 	return &Recorder{
-		buffer: make([]float32, 0),
+		buffer:    make([]float32, 0),
+		silenceCh: make(chan struct{}, 1),
 	}, nil
 }
 
-// Start begins recording audio
-func (r *Recorder) Start() error {
+// hasInputDevice reports whether PortAudio has a default input device
+func hasInputDevice() bool {
+	device, err := defaultInputDevice()
+	return err == nil && device != nil && device.MaxInputChannels > 0
+}
+
+// SetAutoStop enables firing SilenceDetected once the RMS level stays below
+// threshold for the given duration after speech was heard. A zero threshold or
+// duration disables it. Takes effect on the next Start.
+func (r *Recorder) SetAutoStop(threshold float32, silence time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.silenceThreshold = threshold
+	r.silenceDuration = silence
+}
+
+// SetInputGain multiplies every sample by gain as it is recorded, for a
+// microphone that is too quiet. Amplified samples are clamped to [-1, 1] and
+// Stop logs how many were. A gain of 1 leaves the audio unchanged.
+func (r *Recorder) SetInputGain(gain float32) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	r.gain = gain
+}
+
+// SilenceDetected returns a channel that receives a value when auto-stop
+// detects the end of speech during the current recording
+func (r *Recorder) SilenceDetected() <-chan struct{} {
+	return r.silenceCh
+}
+
+// SetPreRoll keeps the last d of audio from before Start and prepends it to
+// the recording, so speech that begins right as the hotkey is pressed isn't
+// clipped. This keeps the microphone open while idle. A zero d disables it.
+func (r *Recorder) SetPreRoll(d time.Duration) error {
+	r.streamMu.Lock()
+	defer r.streamMu.Unlock()
 
+	r.mu.Lock()
+	r.preRollLen = max(0, int(d.Seconds()*SampleRate))
+	r.mu.Unlock()
+	return r.resizePreRoll()
+}
+
+// SetMonitorWindow keeps the last d of audio while idle for PreRollSnapshot,
+// without prepending more than the pre-roll to recordings. Like pre-roll it
+// keeps the microphone open while idle. A zero d disables it.
+func (r *Recorder) SetMonitorWindow(d time.Duration) error {
+	r.streamMu.Lock()
+	defer r.streamMu.Unlock()
+
+	r.mu.Lock()
+	r.monitorLen = max(0, int(d.Seconds()*SampleRate))
+	r.mu.Unlock()
+	return r.resizePreRoll()
+}
+
+// PrependMonitorWindow makes the next Start prepend all audio kept for the
+// monitor window instead of only the pre-roll, for a recording started by
+// something heard in it
+func (r *Recorder) PrependMonitorWindow() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prependMonitor = true
+}
+
+// SetKeepStreamOpen keeps the input stream running between recordings, so
+// Start only clears the buffer instead of opening the device, which takes a
+// noticeable moment on some. Audio while idle is dropped unless pre-roll is
+// used. Like pre-roll this keeps the microphone open while idle.
+func (r *Recorder) SetKeepStreamOpen(keep bool) error {
+	r.streamMu.Lock()
+	defer r.streamMu.Unlock()
+
+	r.mu.Lock()
+	r.keepOpen = keep
+	r.mu.Unlock()
+	if err := r.updateIdleStream(); err != nil {
+		return fmt.Errorf("failed to keep audio stream open: %w", err)
+	}
+	return nil
+}
+
+// resizePreRoll sizes the ring buffer for the pre-roll and the monitor
+// window, opening the stream to feed it or closing it when nothing else
+// keeps it open. Called with streamMu held.
+func (r *Recorder) resizePreRoll() error {
+	r.mu.Lock()
+	if size := max(r.preRollLen, r.monitorLen); size > 0 {
+		r.preRoll = newRingBuffer(size)
+	} else {
+		r.preRoll = nil
+	}
+	r.mu.Unlock()
+
+	if err := r.updateIdleStream(); err != nil {
+		return fmt.Errorf("failed to start pre-roll monitoring: %w", err)
+	}
+	return nil
+}
+
+// keepsStreamOpen reports whether the stream runs between recordings, for
+// the pre-roll or SetKeepStreamOpen. Called with mu held.
+func (r *Recorder) keepsStreamOpen() bool {
+	return r.preRoll != nil || r.keepOpen
+}
+
+// updateIdleStream opens the stream if it should run between recordings, or
+// closes it if it shouldn't and no recording uses it. Called with streamMu
+// held.
+func (r *Recorder) updateIdleStream() error {
+	r.mu.Lock()
+	keep, active := r.keepsStreamOpen(), r.isActive
+	r.mu.Unlock()
+
+	if !keep {
+		if r.stream != nil && !active {
+			r.stream.Stop()
+			r.stream.Close()
+			r.stream = nil
+		}
+		return nil
+	}
+	if r.stream != nil {
+		return nil
+	}
+	stream, err := r.openStream()
+	if err != nil {
+		// Start opens the stream instead and keeps it open afterwards
+		return err
+	}
+	r.stream = stream
+	return nil
+}
+
+// Start begins recording audio. Of concurrent calls only one succeeds, the
+// others return an error.
+func (r *Recorder) Start() error {
+	r.streamMu.Lock()
+	defer r.streamMu.Unlock()
+
+	r.mu.Lock()
 	if r.isActive {
+		r.mu.Unlock()
 		return fmt.Errorf("already recording")
 	}
 
-	// Clear previous buffer
+	// Clear previous buffer and any stale silence signal
 	r.buffer = make([]float32, 0)
+	prependAll := r.prependMonitor
+	r.prependMonitor = false
+	r.overflows = 0
+	r.clipped = 0
+	r.silence = newSilenceDetector(r.silenceThreshold, r.silenceDuration)
+	select {
+	case <-r.silenceCh:
+	default:
+	}
+
+	// A stream kept open that stopped calling back is replaced, its pre-roll
+	// is stale. It is stopped without mu, which a late callback may need.
+	if quiet := time.Since(r.lastCallback); r.stream != nil && quiet > quietStreamAfter {
+		if r.preRoll != nil {
+			r.preRoll.reset()
+		}
+		r.mu.Unlock()
+		log.Printf("Warning: audio stream delivered nothing for %v, reopening it", quiet.Round(time.Millisecond))
+		r.stream.Stop()
+		r.stream.Close()
+		r.stream = nil
+		r.mu.Lock()
+	}
+
+	// With pre-roll or a stream kept open it is already running; recording
+	// starts with the audio captured just before now, if any
+	if r.stream != nil {
+		if r.preRoll != nil {
+			preRoll := r.preRoll.contents()
+			if !prependAll {
+				preRoll = preRoll[max(0, len(preRoll)-r.preRollLen):]
+			}
+			r.buffer = append(r.buffer, preRoll...)
+			r.preRoll.reset()
+		}
+		r.isActive = true
+		r.mu.Unlock()
+		return nil
+	}
 
-	// Create input stream
-	stream, err := portaudio.OpenDefaultStream(Channels, 0, float64(SampleRate), 0, func(in []float32) {
+	// Recording from the first callback of the new stream; undone if it can't
+	// be opened. Opening and the retries below run without mu, so Snapshot and
+	// IsRecording don't block on a slow device.
+	r.isActive = true
+	r.mu.Unlock()
+	if err := r.startNewStream(); err != nil {
 		r.mu.Lock()
-		defer r.mu.Unlock()
-		r.buffer = append(r.buffer, in...)
-	})
+		r.isActive = false
+		r.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// startNewStream opens the input stream for Start, retrying after wake from
+// sleep. Called with streamMu held.
+func (r *Recorder) startNewStream() error {
+	// PortAudio only sees the devices present when it was initialized, so
+	// look again before giving up on a microphone connected since
+	if !hasInputDevice() {
+		if err := reinitialize(); err != nil {
+			log.Printf("Warning: failed to reinitialize PortAudio: %v", err)
+		}
+		if !hasInputDevice() {
+			return ErrNoInputDevice
+		}
+	}
+
+	var err error
+	for attempt := 1; attempt <= startAttempts; attempt++ {
+		var stream audioStream
+		stream, err = r.openStream()
+		if err == nil {
+			r.stream = stream
+			return nil
+		}
+		log.Printf("Warning: starting audio stream failed (attempt %d/%d): %v", attempt, startAttempts, err)
+		if attempt < startAttempts {
+			time.Sleep(startRetryDelay)
+		}
+	}
+	return err
+}
+
+// openStream opens and starts the default input stream feeding onAudio
+func (r *Recorder) openStream() (audioStream, error) {
+	// Not quiet before its first callback
+	r.mu.Lock()
+	r.lastCallback = time.Now()
+	r.mu.Unlock()
+
+	stream, err := openInputStream(r.onAudio)
 	if err != nil {
-		return fmt.Errorf("failed to open stream: %w", err)
+		return nil, fmt.Errorf("failed to open stream: %w", err)
 	}
 
 	if err := stream.Start(); err != nil {
 		stream.Close()
-		return fmt.Errorf("failed to start stream: %w", err)
+		return nil, fmt.Errorf("failed to start stream: %w", err)
 	}
-
-	r.stream = stream
-	r.isActive = true
-	return nil
+	return stream, nil
 }
 
-// Stop stops recording and returns the audio buffer
-func (r *Recorder) Stop() ([]float32, error) {
+// onAudio is the PortAudio stream callback, called from the audio thread
+func (r *Recorder) onAudio(in []float32, flags portaudio.StreamCallbackFlags) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	r.lastCallback = time.Now()
+	if r.gain != 0 && r.gain != 1 {
+		in = r.amplify(in)
+	}
+	if !r.isActive && r.keepsStreamOpen() {
+		if r.preRoll != nil {
+			r.preRoll.write(in)
+		}
+		return
+	}
+	// Only count here, logging from the audio thread could cause more overflows
+	if flags&portaudio.InputOverflow != 0 {
+		r.overflows++
+	}
+	r.buffer = append(r.buffer, in...)
+
+	if r.silence.feed(in) {
+		select {
+		case r.silenceCh <- struct{}{}:
+		default:
+		}
+	}
+}
 
+// amplify returns in multiplied by the input gain, clamped to [-1, 1]. Called
+// with mu held; in belongs to PortAudio, so the result is a new slice.
+func (r *Recorder) amplify(in []float32) []float32 {
+	out := make([]float32, len(in))
+	for i, s := range in {
+		s *= r.gain
+		if s > 1 || s < -1 {
+			s = max(-1, min(1, s))
+			r.clipped++
+		}
+		out[i] = s
+	}
+	return out
+}
+
+// Stop stops recording and returns the audio buffer.
+// If PortAudio reported an input overflow while recording, the samples are
+// still returned together with ErrInputOverflow so the caller can warn the user.
+func (r *Recorder) Stop() ([]float32, error) {
+	r.streamMu.Lock()
+	defer r.streamMu.Unlock()
+
+	r.mu.Lock()
 	if !r.isActive {
+		r.mu.Unlock()
 		return nil, fmt.Errorf("not recording")
 	}
+	r.isActive = false
 
-	if err := r.stream.Stop(); err != nil {
-		return nil, fmt.Errorf("failed to stop stream: %w", err)
+	// Return copy of buffer; Start resets it before any later recording
+	result := make([]float32, len(r.buffer))
+	copy(result, r.buffer)
+	overflows := r.overflows
+	clipped, gain := r.clipped, r.gain
+	keepStream := r.keepsStreamOpen()
+	r.mu.Unlock()
+
+	// Counted by the callback, which must not log
+	if clipped > 0 {
+		log.Printf("Warning: input gain %.2g clipped %d of %d samples, consider lowering it", gain, clipped, len(result))
 	}
 
-	if err := r.stream.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close stream: %w", err)
+	// With pre-roll the stream keeps running to fill the ring buffer, or as
+	// asked with SetKeepStreamOpen
+	if !keepStream {
+		// Always release the stream and reset state, even if stopping fails,
+		// so a failing device doesn't leave the recorder stuck in "recording"
+		stream := r.stream
+		r.stream = nil
+
+		if err := stream.Stop(); err != nil {
+			stream.Close()
+			return nil, fmt.Errorf("failed to stop stream: %w", err)
+		}
+
+		if err := stream.Close(); err != nil {
+			return nil, fmt.Errorf("failed to close stream: %w", err)
+		}
 	}
 
-	r.stream = nil
-	r.isActive = false
+	if overflows > 0 {
+		log.Printf("Warning: PortAudio reported input overflow %d time(s) during recording (%d samples captured)", overflows, len(result))
+		return result, ErrInputOverflow
+	}
+	return result, nil
+}
 
-	// Return copy of buffer
+// Snapshot returns a copy of the samples recorded so far. It is safe to call
+// while the stream callback is appending, e.g. for live metering.
+func (r *Recorder) Snapshot() []float32 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	result := make([]float32, len(r.buffer))
 	copy(result, r.buffer)
-	return result, nil
+	return result
+}
+
+// PreRollSnapshot returns a copy of the audio kept for the pre-roll and the
+// monitor window, the moments right before now, while not recording. It
+// returns nil without either or while recording.
+func (r *Recorder) PreRollSnapshot() []float32 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.preRoll == nil || r.isActive {
+		return nil
+	}
+	return r.preRoll.contents()
+}
+
+// Len returns the number of samples recorded so far
+func (r *Recorder) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.buffer)
+}
+
+// Duration returns how much audio has been recorded so far, including any
+// pre-roll. Safe to call while recording.
+func (r *Recorder) Duration() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return time.Duration(len(r.buffer)) * time.Second / SampleRate
 }
 
 // IsRecording returns true if currently recording
@@ -98,15 +522,18 @@ func (r *Recorder) IsRecording() bool {
 
 // Close cleans up the recorder
 func (r *Recorder) Close() error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	r.streamMu.Lock()
+	defer r.streamMu.Unlock()
 
 	if r.stream != nil {
-		if r.isActive {
-			r.stream.Stop()
-		}
+		// Running while recording, or while idle to fill the pre-roll
+		r.stream.Stop()
 		r.stream.Close()
+		r.stream = nil
 	}
+	r.mu.Lock()
+	r.isActive = false
+	r.mu.Unlock()
 
 	return portaudio.Terminate()
 }
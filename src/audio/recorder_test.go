@@ -0,0 +1,818 @@
+package audio
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+// TestSnapshotConcurrentWithCallback hammers Snapshot and Len while a fake
+// stream callback appends. Run with -race to detect unsynchronized access.
+func TestSnapshotConcurrentWithCallback(t *testing.T) {
+	r := &Recorder{silenceCh: make(chan struct{}, 1)}
+	const chunks = 500
+	const chunkSize = 160
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		in := make([]float32, chunkSize)
+		for i := 0; i < chunks; i++ {
+			for j := range in {
+				in[j] = float32(i)
+			}
+			r.onAudio(in, 0)
+		}
+	}()
+
+	for reader := 0; reader < 4; reader++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < chunks; i++ {
+				snap := r.Snapshot()
+				if len(snap)%chunkSize != 0 {
+					t.Errorf("Snapshot() length %d is not a whole number of chunks", len(snap))
+					return
+				}
+				// Each chunk must be internally consistent, never half-written
+				for c := 0; c < len(snap); c += chunkSize {
+					if snap[c] != snap[c+chunkSize-1] {
+						t.Errorf("Snapshot() chunk at %d is torn", c)
+						return
+					}
+				}
+				_ = r.Len()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := r.Len(); got != chunks*chunkSize {
+		t.Errorf("Len() = %d, want %d", got, chunks*chunkSize)
+	}
+}
+
+// TestSnapshotIsCopy tests that callers can't mutate the recording buffer
+func TestSnapshotIsCopy(t *testing.T) {
+	r := &Recorder{silenceCh: make(chan struct{}, 1)}
+	r.onAudio([]float32{0.1, 0.2}, 0)
+
+	snap := r.Snapshot()
+	snap[0] = 1
+	if again := r.Snapshot(); again[0] != 0.1 {
+		t.Errorf("Snapshot() shares memory with the buffer, got %v", again[0])
+	}
+}
+
+// fakeInputDevices makes the device checks see count microphones and
+// counts reinitializations
+func fakeInputDevices(t *testing.T, count int) *int {
+	t.Helper()
+	originalDevice, originalReinit := defaultInputDevice, reinitialize
+	t.Cleanup(func() {
+		defaultInputDevice, reinitialize = originalDevice, originalReinit
+	})
+
+	defaultInputDevice = func() (*portaudio.DeviceInfo, error) {
+		if count == 0 {
+			return nil, errors.New("no device")
+		}
+		return &portaudio.DeviceInfo{Name: "Test Microphone", MaxInputChannels: 1}, nil
+	}
+	reinits := 0
+	reinitialize = func() error {
+		reinits++
+		return nil
+	}
+	return &reinits
+}
+
+// TestDuration tests that the recorded duration follows the sample count
+func TestDuration(t *testing.T) {
+	r := &Recorder{silenceCh: make(chan struct{}, 1), isActive: true}
+	if got := r.Duration(); got != 0 {
+		t.Errorf("Duration() = %v before any audio, want 0", got)
+	}
+
+	r.onAudio(make([]float32, SampleRate*3/2), 0)
+	if got := r.Duration(); got != 1500*time.Millisecond {
+		t.Errorf("Duration() = %v, want 1.5s", got)
+	}
+}
+
+// TestStartRetriesOpenFailure tests that Start retries a failing stream open a
+// bounded number of times before giving up
+func TestStartRetriesOpenFailure(t *testing.T) {
+	originalOpen := openInputStream
+	originalDelay := startRetryDelay
+	defer func() {
+		openInputStream = originalOpen
+		startRetryDelay = originalDelay
+	}()
+	fakeInputDevices(t, 1)
+
+	calls := 0
+	openInputStream = func(streamCallback) (audioStream, error) {
+		calls++
+		return nil, errors.New("device unavailable")
+	}
+	startRetryDelay = time.Millisecond
+
+	r := &Recorder{silenceCh: make(chan struct{}, 1)}
+	err := r.Start()
+	if err == nil || !strings.Contains(err.Error(), "device unavailable") {
+		t.Fatalf("Start() error = %v, want wrapped open failure", err)
+	}
+	if calls != startAttempts {
+		t.Errorf("stream opened %d times, want %d", calls, startAttempts)
+	}
+	if r.IsRecording() {
+		t.Error("IsRecording() = true after failed Start")
+	}
+}
+
+// TestStartWithoutInputDevice tests that a missing microphone is reported
+// as such, after looking for newly connected devices
+func TestStartWithoutInputDevice(t *testing.T) {
+	originalOpen := openInputStream
+	defer func() { openInputStream = originalOpen }()
+	reinits := fakeInputDevices(t, 0)
+
+	opened := false
+	openInputStream = func(streamCallback) (audioStream, error) {
+		opened = true
+		return nil, errors.New("invalid device")
+	}
+
+	r := &Recorder{silenceCh: make(chan struct{}, 1)}
+	if err := r.Start(); !errors.Is(err, ErrNoInputDevice) {
+		t.Errorf("Start() error = %v, want ErrNoInputDevice", err)
+	}
+	if *reinits != 1 {
+		t.Errorf("PortAudio reinitialized %d times, want 1", *reinits)
+	}
+	if opened {
+		t.Error("Start() opened a stream without an input device")
+	}
+}
+
+// TestInputGain tests amplifying recorded samples and clamping the ones that clip
+func TestInputGain(t *testing.T) {
+	tests := []struct {
+		name        string
+		gain        float32
+		want        []float32
+		wantClipped int
+	}{
+		{"unset", 0, []float32{0.1, -0.3, 0.6}, 0},
+		{"unity", 1, []float32{0.1, -0.3, 0.6}, 0},
+		{"doubled", 2, []float32{0.2, -0.6, 1}, 1},
+		{"clipped both ways", 4, []float32{0.4, -1, 1}, 2},
+		{"attenuated", 0.5, []float32{0.05, -0.15, 0.3}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Recorder{silenceCh: make(chan struct{}, 1), isActive: true}
+			r.SetInputGain(tt.gain)
+			in := []float32{0.1, -0.3, 0.6}
+			r.onAudio(in, 0)
+
+			got := r.Snapshot()
+			for i := range got {
+				if diff := got[i] - tt.want[i]; diff > 1e-6 || diff < -1e-6 {
+					t.Fatalf("Snapshot() = %v, want %v", got, tt.want)
+				}
+			}
+			if r.clipped != tt.wantClipped {
+				t.Errorf("clipped = %d, want %d", r.clipped, tt.wantClipped)
+			}
+			if in[2] != 0.6 {
+				t.Errorf("callback buffer modified to %v", in)
+			}
+		})
+	}
+}
+
+// TestPreRollFeedsRingWhileIdle tests that idle audio only goes to the
+// pre-roll ring, and recording audio to the buffer
+func TestPreRollFeedsRingWhileIdle(t *testing.T) {
+	r := &Recorder{silenceCh: make(chan struct{}, 1), preRoll: newRingBuffer(4)}
+
+	r.onAudio([]float32{1, 2, 3}, 0)
+	r.onAudio([]float32{4, 5, 6}, portaudio.InputOverflow)
+	if r.Len() != 0 {
+		t.Errorf("Len() = %d while idle, want 0", r.Len())
+	}
+	if r.overflows != 0 {
+		t.Errorf("overflows = %d while idle, want 0", r.overflows)
+	}
+
+	got := r.preRoll.contents()
+	want := []float32{3, 4, 5, 6}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pre-roll = %v, want %v", got, want)
+	}
+
+	r.isActive = true
+	r.onAudio([]float32{7}, 0)
+	if r.Len() != 1 {
+		t.Errorf("Len() = %d while recording, want 1", r.Len())
+	}
+}
+
+// TestRingBuffer tests that the ring keeps the newest samples in order
+func TestRingBuffer(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		writes [][]float32
+		want   []float32
+	}{
+		{name: "empty", size: 4, want: []float32{}},
+		{name: "partial", size: 4, writes: [][]float32{{1, 2}}, want: []float32{1, 2}},
+		{name: "exactly full", size: 4, writes: [][]float32{{1, 2}, {3, 4}}, want: []float32{1, 2, 3, 4}},
+		{name: "wraps", size: 4, writes: [][]float32{{1, 2, 3}, {4, 5}}, want: []float32{2, 3, 4, 5}},
+		{name: "wraps several times", size: 3, writes: [][]float32{{1, 2}, {3, 4}, {5, 6}, {7}}, want: []float32{5, 6, 7}},
+		{name: "chunk larger than ring", size: 3, writes: [][]float32{{1}, {2, 3, 4, 5, 6}}, want: []float32{4, 5, 6}},
+		{name: "zero size", size: 0, writes: [][]float32{{1, 2}}, want: []float32{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newRingBuffer(tt.size)
+			for _, w := range tt.writes {
+				b.write(w)
+			}
+			if got := b.contents(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("contents() = %v, want %v", got, tt.want)
+			}
+
+			b.reset()
+			if got := b.contents(); len(got) != 0 {
+				t.Errorf("contents() after reset = %v, want empty", got)
+			}
+		})
+	}
+}
+
+// BenchmarkOnAudio measures the stream callback recording a minute of audio,
+// which runs on the audio thread and must keep up with the device
+func BenchmarkOnAudio(b *testing.B) {
+	in := speechLike(benchmarkSeconds, SampleRate, 1)
+	const chunkSize = 512
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := &Recorder{silenceCh: make(chan struct{}, 1), isActive: true, silence: newSilenceDetector(0.01, time.Second)}
+		for start := 0; start+chunkSize <= len(in); start += chunkSize {
+			r.onAudio(in[start:start+chunkSize], 0)
+		}
+	}
+}
+
+// BenchmarkRingBuffer measures feeding a minute of idle audio to a 500ms
+// pre-roll ring
+func BenchmarkRingBuffer(b *testing.B) {
+	in := speechLike(benchmarkSeconds, SampleRate, 1)
+	const chunkSize = 512
+	ring := newRingBuffer(SampleRate / 2)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for start := 0; start+chunkSize <= len(in); start += chunkSize {
+			ring.write(in[start : start+chunkSize])
+		}
+	}
+}
+
+// fakeStream is an audioStream without hardware. Tests deliver audio with
+// feed; a continuous stream also calls back with silence in a loop while
+// started. Like PortAudio, Stop waits for a running callback to return.
+type fakeStream struct {
+	callback   streamCallback
+	continuous bool
+	startErr   error
+
+	mu      sync.Mutex
+	running bool
+	closed  bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func (s *fakeStream) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.startErr != nil {
+		return s.startErr
+	}
+	s.running = true
+	if s.continuous {
+		s.stop, s.done = make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(s.done)
+			for {
+				select {
+				case <-s.stop:
+					return
+				default:
+					s.callback(make([]float32, 160), 0)
+				}
+			}
+		}()
+	}
+	return nil
+}
+
+func (s *fakeStream) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running && s.continuous {
+		close(s.stop)
+		<-s.done
+	}
+	s.running = false
+	return nil
+}
+
+func (s *fakeStream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+// feed delivers samples to the callback if the stream is running, as the
+// audio thread would
+func (s *fakeStream) feed(samples []float32, flags portaudio.StreamCallbackFlags) {
+	s.mu.Lock()
+	running := s.running
+	s.mu.Unlock()
+	if running {
+		s.callback(samples, flags)
+	}
+}
+
+// fakeStreams hands out fakeStreams from openInputStream and keeps track of them
+type fakeStreams struct {
+	mu         sync.Mutex
+	opened     []*fakeStream
+	continuous bool
+	startErr   error
+}
+
+// useFakeStreams replaces PortAudio with fake streams and a single
+// microphone for the duration of a test
+func useFakeStreams(t *testing.T) *fakeStreams {
+	t.Helper()
+	fakeInputDevices(t, 1)
+	original := openInputStream
+	t.Cleanup(func() { openInputStream = original })
+
+	f := &fakeStreams{}
+	openInputStream = func(callback streamCallback) (audioStream, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		s := &fakeStream{callback: callback, continuous: f.continuous, startErr: f.startErr}
+		f.opened = append(f.opened, s)
+		return s, nil
+	}
+	return f
+}
+
+// last returns the most recently opened stream
+func (f *fakeStreams) last() *fakeStream {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.opened[len(f.opened)-1]
+}
+
+// open returns how many streams are open and running
+func (f *fakeStreams) open() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, s := range f.opened {
+		s.mu.Lock()
+		if !s.closed {
+			n++
+		}
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// TestStartStop tests that a recording returns exactly the audio delivered
+// between Start and Stop, on a stream of its own
+func TestStartStop(t *testing.T) {
+	streams := useFakeStreams(t)
+	r := &Recorder{silenceCh: make(chan struct{}, 1)}
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	first := streams.last()
+	first.feed([]float32{0.1, 0.2}, 0)
+	first.feed([]float32{0.3}, 0)
+	if !r.IsRecording() {
+		t.Error("IsRecording() = false while recording")
+	}
+
+	got, err := r.Stop()
+	if err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if want := []float32{0.1, 0.2, 0.3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Stop() = %v, want %v", got, want)
+	}
+	if !first.closed || streams.open() != 0 {
+		t.Errorf("stream closed = %v, %d open, want it closed", first.closed, streams.open())
+	}
+	first.feed([]float32{0.9}, 0) // A stopped stream delivers nothing
+
+	// The next recording starts empty on a new stream
+	if err := r.Start(); err != nil {
+		t.Fatalf("second Start() error = %v", err)
+	}
+	streams.last().feed([]float32{0.4}, 0)
+	got, err = r.Stop()
+	if err != nil {
+		t.Fatalf("second Stop() error = %v", err)
+	}
+	if want := []float32{0.4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("second Stop() = %v, want %v", got, want)
+	}
+	if len(streams.opened) != 2 {
+		t.Errorf("opened %d streams, want one per recording", len(streams.opened))
+	}
+}
+
+// TestStartStopTwice tests the errors for starting while recording and
+// stopping while not
+func TestStartStopTwice(t *testing.T) {
+	streams := useFakeStreams(t)
+	r := &Recorder{silenceCh: make(chan struct{}, 1)}
+
+	if _, err := r.Stop(); err == nil || !strings.Contains(err.Error(), "not recording") {
+		t.Errorf("Stop() before Start error = %v, want not recording", err)
+	}
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := r.Start(); err == nil || !strings.Contains(err.Error(), "already recording") {
+		t.Errorf("second Start() error = %v, want already recording", err)
+	}
+	if len(streams.opened) != 1 {
+		t.Errorf("opened %d streams, want 1", len(streams.opened))
+	}
+	if _, err := r.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if _, err := r.Stop(); err == nil {
+		t.Error("second Stop() error = nil, want not recording")
+	}
+}
+
+// TestStopAfterOverflow tests that dropped audio is reported along with the
+// samples that did arrive
+func TestStopAfterOverflow(t *testing.T) {
+	streams := useFakeStreams(t)
+	r := &Recorder{silenceCh: make(chan struct{}, 1)}
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	streams.last().feed([]float32{0.1}, portaudio.InputOverflow)
+	streams.last().feed([]float32{0.2}, 0)
+
+	got, err := r.Stop()
+	if !errors.Is(err, ErrInputOverflow) {
+		t.Errorf("Stop() error = %v, want ErrInputOverflow", err)
+	}
+	if want := []float32{0.1, 0.2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Stop() = %v, want %v", got, want)
+	}
+}
+
+// TestStartStreamFails tests that a stream that opens but won't start is
+// closed again before retrying
+func TestStartStreamFails(t *testing.T) {
+	originalDelay := startRetryDelay
+	defer func() { startRetryDelay = originalDelay }()
+	startRetryDelay = time.Millisecond
+	streams := useFakeStreams(t)
+	streams.startErr = errors.New("device busy")
+	r := &Recorder{silenceCh: make(chan struct{}, 1)}
+
+	if err := r.Start(); err == nil || !strings.Contains(err.Error(), "device busy") {
+		t.Fatalf("Start() error = %v, want the start failure", err)
+	}
+	if len(streams.opened) != startAttempts || streams.open() != 0 {
+		t.Errorf("opened %d streams with %d left open, want %d all closed", len(streams.opened), streams.open(), startAttempts)
+	}
+	if r.IsRecording() {
+		t.Error("IsRecording() = true after failed Start")
+	}
+}
+
+// TestPreRollStream tests that pre-roll keeps one stream open across
+// recordings and prepends the audio from before Start
+func TestPreRollStream(t *testing.T) {
+	streams := useFakeStreams(t)
+	r := &Recorder{silenceCh: make(chan struct{}, 1)}
+
+	if err := r.SetPreRoll(time.Second); err != nil {
+		t.Fatalf("SetPreRoll() error = %v", err)
+	}
+	stream := streams.last()
+	stream.feed([]float32{0.1, 0.2}, 0)
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	stream.feed([]float32{0.3}, 0)
+	got, err := r.Stop()
+	if err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if want := []float32{0.1, 0.2, 0.3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Stop() = %v, want pre-roll then recording %v", got, want)
+	}
+	if len(streams.opened) != 1 || streams.open() != 1 {
+		t.Errorf("opened %d streams with %d open, want the pre-roll stream kept open", len(streams.opened), streams.open())
+	}
+
+	stream.feed([]float32{0.4, 0.5}, 0)
+	if got, want := r.PreRollSnapshot(), []float32{0.4, 0.5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PreRollSnapshot() = %v, want the audio since the recording %v", got, want)
+	}
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if got := r.PreRollSnapshot(); got != nil {
+		t.Errorf("PreRollSnapshot() while recording = %v, want nil", got)
+	}
+	if _, err := r.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	if err := r.SetPreRoll(0); err != nil {
+		t.Fatalf("SetPreRoll(0) error = %v", err)
+	}
+	if streams.open() != 0 {
+		t.Error("pre-roll stream still open after disabling pre-roll")
+	}
+}
+
+// TestKeepStreamOpen tests that a stream kept open is reused by every
+// recording without recording the audio in between
+func TestKeepStreamOpen(t *testing.T) {
+	streams := useFakeStreams(t)
+	r := &Recorder{silenceCh: make(chan struct{}, 1)}
+
+	if err := r.SetKeepStreamOpen(true); err != nil {
+		t.Fatalf("SetKeepStreamOpen() error = %v", err)
+	}
+	stream := streams.last()
+	stream.feed([]float32{0.1}, 0) // Idle audio is dropped
+
+	for i, want := range [][]float32{{0.2, 0.3}, {0.5}} {
+		if err := r.Start(); err != nil {
+			t.Fatalf("Start() #%d error = %v", i+1, err)
+		}
+		stream.feed(want, 0)
+		got, err := r.Stop()
+		if err != nil {
+			t.Fatalf("Stop() #%d error = %v", i+1, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Stop() #%d = %v, want %v", i+1, got, want)
+		}
+		stream.feed([]float32{0.4}, 0)
+	}
+	if len(streams.opened) != 1 || streams.open() != 1 {
+		t.Errorf("opened %d streams with %d open, want one kept open", len(streams.opened), streams.open())
+	}
+	if got := r.Len(); got != 1 {
+		t.Errorf("Len() = %d after idle audio, want the last recording's 1", got)
+	}
+
+	// Turned off while recording, the stream is closed by Stop
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := r.SetKeepStreamOpen(false); err != nil {
+		t.Fatalf("SetKeepStreamOpen(false) error = %v", err)
+	}
+	if streams.open() != 1 {
+		t.Error("stream closed while recording")
+	}
+	if _, err := r.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if streams.open() != 0 {
+		t.Error("stream still open after SetKeepStreamOpen(false)")
+	}
+}
+
+// TestReopenQuietStream tests that Start replaces a stream kept open that
+// stopped calling back, and keeps one that still does
+func TestReopenQuietStream(t *testing.T) {
+	streams := useFakeStreams(t)
+	original := quietStreamAfter
+	quietStreamAfter = 20 * time.Millisecond
+	t.Cleanup(func() { quietStreamAfter = original })
+	r := &Recorder{silenceCh: make(chan struct{}, 1)}
+
+	if err := r.SetPreRoll(time.Second); err != nil {
+		t.Fatalf("SetPreRoll() error = %v", err)
+	}
+	stale := streams.last()
+	stale.feed([]float32{0.1}, 0)
+	time.Sleep(2 * quietStreamAfter)
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	fresh := streams.last()
+	if fresh == stale || streams.open() != 1 {
+		t.Fatalf("opened %d streams with %d open, want the quiet one replaced", len(streams.opened), streams.open())
+	}
+	fresh.feed([]float32{0.2}, 0)
+	got, err := r.Stop()
+	if err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if want := []float32{0.2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Stop() = %v, want %v without the stale pre-roll", got, want)
+	}
+
+	// Still calling back, the stream is reused
+	fresh.feed([]float32{0.3}, 0)
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if _, err := r.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if len(streams.opened) != 2 {
+		t.Errorf("opened %d streams, want the live one reused", len(streams.opened))
+	}
+}
+
+// TestMonitorWindow tests that audio kept for the monitor window is only
+// prepended after PrependMonitorWindow, otherwise just the pre-roll is
+func TestMonitorWindow(t *testing.T) {
+	streams := useFakeStreams(t)
+	r := &Recorder{silenceCh: make(chan struct{}, 1)}
+
+	if err := r.SetMonitorWindow(time.Second); err != nil {
+		t.Fatalf("SetMonitorWindow() error = %v", err)
+	}
+	if err := r.SetPreRoll(time.Millisecond); err != nil { // 16 samples
+		t.Fatalf("SetPreRoll() error = %v", err)
+	}
+	stream := streams.last()
+	monitored := make([]float32, 40)
+	for i := range monitored {
+		monitored[i] = float32(i) / 100
+	}
+
+	record := func(prependAll bool) []float32 {
+		t.Helper()
+		stream.feed(monitored, 0)
+		if got := r.PreRollSnapshot(); !reflect.DeepEqual(got, monitored) {
+			t.Errorf("PreRollSnapshot() = %v, want %v", got, monitored)
+		}
+		if prependAll {
+			r.PrependMonitorWindow()
+		}
+		if err := r.Start(); err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+		got, err := r.Stop()
+		if err != nil {
+			t.Fatalf("Stop() error = %v", err)
+		}
+		return got
+	}
+
+	if got := record(false); !reflect.DeepEqual(got, monitored[24:]) {
+		t.Errorf("recording = %v, want only the pre-roll %v", got, monitored[24:])
+	}
+	if got := record(true); !reflect.DeepEqual(got, monitored) {
+		t.Errorf("recording after PrependMonitorWindow = %v, want the monitor window %v", got, monitored)
+	}
+	if got := record(false); !reflect.DeepEqual(got, monitored[24:]) {
+		t.Errorf("next recording = %v, want only the pre-roll again", got)
+	}
+
+	if err := r.SetMonitorWindow(0); err != nil {
+		t.Fatalf("SetMonitorWindow(0) error = %v", err)
+	}
+	if streams.open() != 1 {
+		t.Error("stream closed while pre-roll still needs it")
+	}
+	if err := r.SetPreRoll(0); err != nil {
+		t.Fatalf("SetPreRoll(0) error = %v", err)
+	}
+	if streams.open() != 0 {
+		t.Error("stream still open without pre-roll or monitor window")
+	}
+}
+
+// TestRecordingWithGainAndTrim tests the recorded audio going through gain
+// and silence trimming like a dictation does
+func TestRecordingWithGainAndTrim(t *testing.T) {
+	streams := useFakeStreams(t)
+	r := &Recorder{silenceCh: make(chan struct{}, 1)}
+	r.SetInputGain(2)
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	silence := make([]float32, SampleRate/2)
+	speech := make([]float32, SampleRate/2)
+	for i := range speech {
+		speech[i] = 0.2
+	}
+	streams.last().feed(silence, 0)
+	streams.last().feed(speech, 0)
+	streams.last().feed(silence, 0)
+	samples, err := r.Stop()
+	if err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	trimmed := TrimSilence(samples, 0.01)
+	if len(trimmed) >= len(samples) || len(trimmed) < len(speech) {
+		t.Errorf("trimmed %d of %d samples, want the %d speech samples kept", len(samples)-len(trimmed), len(samples), len(speech))
+	}
+	if got := RMS(trimmed); got < 0.3 {
+		t.Errorf("RMS of trimmed speech = %.3f, want the gain applied (about 0.4)", got)
+	}
+}
+
+// TestConcurrentStartStop hammers Start, Stop and SetPreRoll from many
+// goroutines while the stream callback runs. Run with -race to detect
+// unsynchronized access; a deadlock fails the test by timing out.
+func TestConcurrentStartStop(t *testing.T) {
+	streams := useFakeStreams(t)
+	streams.continuous = true
+
+	r := &Recorder{silenceCh: make(chan struct{}, 1)}
+	var started atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			if r.Start() == nil {
+				started.Add(1)
+			}
+			if n := streams.open(); n > 1 {
+				t.Errorf("%d streams open at once, want at most 1", n)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := r.Stop(); err == nil {
+				started.Add(-1)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if i%2 == 0 {
+				r.SetPreRoll(100 * time.Millisecond)
+			} else {
+				r.SetPreRoll(0)
+			}
+			r.Snapshot()
+			r.IsRecording()
+		}()
+	}
+	wg.Wait()
+
+	// Every successful Start was matched by at most one successful Stop
+	if got := started.Load(); got != 0 && got != 1 {
+		t.Fatalf("successful Starts - Stops = %d, want 0 or 1", got)
+	}
+	if got := started.Load() == 1; r.IsRecording() != got {
+		t.Errorf("IsRecording() = %v, want %v", r.IsRecording(), got)
+	}
+
+	r.Stop()
+	r.SetPreRoll(0)
+	if got := streams.open(); got != 0 {
+		t.Errorf("%d streams still open after Stop, want 0", got)
+	}
+}
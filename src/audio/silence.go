@@ -0,0 +1,49 @@
+package audio
+
+import "time"
+
+// silenceDetector tracks how long the input has stayed below an RMS threshold.
+// It only reports silence after speech was heard, so a recording isn't stopped
+// before the user starts talking.
+type silenceDetector struct {
+	threshold      float32
+	limit          int // Samples of continuous silence that count as "done"
+	silentSamples  int
+	heardSpeech    bool
+	alreadyReached bool
+}
+
+// newSilenceDetector returns nil when auto-stop is disabled
+func newSilenceDetector(threshold float32, silence time.Duration) *silenceDetector {
+	if threshold <= 0 || silence <= 0 {
+		return nil
+	}
+	return &silenceDetector{
+		threshold: threshold,
+		limit:     int(silence.Seconds() * SampleRate),
+	}
+}
+
+// feed processes a chunk of samples and returns true exactly once, when the
+// configured amount of silence following speech has been reached
+func (d *silenceDetector) feed(in []float32) bool {
+	if d == nil || d.alreadyReached || len(in) == 0 {
+		return false
+	}
+
+	if RMS(in) >= d.threshold {
+		d.heardSpeech = true
+		d.silentSamples = 0
+		return false
+	}
+
+	if !d.heardSpeech {
+		return false
+	}
+	d.silentSamples += len(in)
+	if d.silentSamples >= d.limit {
+		d.alreadyReached = true
+		return true
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package audio
+
+import (
+	"testing"
+	"time"
+)
+
+// chunk returns 100ms of samples at a constant level
+func chunk(level float32) []float32 {
+	c := make([]float32, SampleRate/10)
+	for i := range c {
+		c[i] = level
+	}
+	return c
+}
+
+// TestSilenceDetector tests end-of-speech detection used by auto-stop
+func TestSilenceDetector(t *testing.T) {
+	t.Run("disabled when unconfigured", func(t *testing.T) {
+		if d := newSilenceDetector(0, time.Second); d != nil {
+			t.Error("newSilenceDetector(0, 1s) should be nil")
+		}
+		var d *silenceDetector
+		if d.feed(chunk(0)) {
+			t.Error("nil detector should never report silence")
+		}
+	})
+
+	t.Run("leading silence is ignored", func(t *testing.T) {
+		d := newSilenceDetector(0.05, 300*time.Millisecond)
+		for i := 0; i < 10; i++ {
+			if d.feed(chunk(0)) {
+				t.Fatalf("reported silence before any speech at chunk %d", i)
+			}
+		}
+	})
+
+	t.Run("fires once after speech then silence", func(t *testing.T) {
+		d := newSilenceDetector(0.05, 300*time.Millisecond)
+		d.feed(chunk(0.5))
+		fired := 0
+		for i := 0; i < 10; i++ {
+			if d.feed(chunk(0)) {
+				fired++
+				if i != 2 {
+					t.Errorf("fired at chunk %d, want 2 (after 300ms)", i)
+				}
+			}
+		}
+		if fired != 1 {
+			t.Errorf("fired %d times, want 1", fired)
+		}
+	})
+
+	t.Run("short pause does not fire", func(t *testing.T) {
+		d := newSilenceDetector(0.05, 300*time.Millisecond)
+		for i := 0; i < 5; i++ {
+			if d.feed(chunk(0.5)) || d.feed(chunk(0)) || d.feed(chunk(0)) {
+				t.Fatal("a 200ms pause between words should not stop the recording")
+			}
+		}
+	})
+}
+
+// TestRMS tests the RMS level computation
+func TestRMS(t *testing.T) {
+	if got := RMS(nil); got != 0 {
+		t.Errorf("RMS(nil) = %v, want 0", got)
+	}
+	if got := RMS([]float32{0.5, -0.5, 0.5, -0.5}); got != 0.5 {
+		t.Errorf("RMS(±0.5) = %v, want 0.5", got)
+	}
+}
+
+// BenchmarkSilenceDetector measures auto-stop detection over a minute of
+// audio fed in stream-callback sized chunks
+func BenchmarkSilenceDetector(b *testing.B) {
+	in := speechLike(benchmarkSeconds, SampleRate, 1)
+	const chunkSize = 512
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d := newSilenceDetector(0.01, time.Second)
+		for start := 0; start+chunkSize <= len(in); start += chunkSize {
+			d.feed(in[start : start+chunkSize])
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-audio/wav"
+)
+
+// WAVFormat is the sample format of a WAV file written by EncodeWAVFormat
+type WAVFormat int
+
+const (
+	// WAVInt16 is 16-bit PCM, the default and what Whisper works at anyway
+	WAVInt16 WAVFormat = iota
+	// WAVInt24 is 24-bit PCM
+	WAVInt24
+	// WAVFloat32 is 32-bit IEEE float, which keeps the samples exactly as
+	// they were recorded
+	WAVFloat32
+)
+
+// WAV format tags of the fmt chunk
+const (
+	wavFormatPCM   = 1
+	wavFormatFloat = 3
+)
+
+// ParseWAVFormat converts a config value ("int16", "int24" or "float32") to
+// a WAVFormat
+func ParseWAVFormat(name string) (WAVFormat, error) {
+	switch strings.ToLower(name) {
+	case "", "int16":
+		return WAVInt16, nil
+	case "int24":
+		return WAVInt24, nil
+	case "float32":
+		return WAVFloat32, nil
+	default:
+		return WAVInt16, fmt.Errorf("unknown WAV format %q (want \"int16\", \"int24\" or \"float32\")", name)
+	}
+}
+
+// bitsPerSample returns the size of one sample in bits
+func (f WAVFormat) bitsPerSample() int {
+	switch f {
+	case WAVInt24:
+		return 24
+	case WAVFloat32:
+		return 32
+	default:
+		return 16
+	}
+}
+
+// DecodeWAV reads a WAV stream and returns it as mono samples at SampleRate,
+// downmixing multi-channel audio and resampling other rates as needed.
+func DecodeWAV(r io.ReadSeeker) ([]float32, error) {
+	dec := wav.NewDecoder(r)
+	if !dec.IsValidFile() {
+		return nil, fmt.Errorf("not a valid WAV file")
+	}
+
+	buf, err := dec.FullPCMBuffer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode WAV: %w", err)
+	}
+	if dec.NumChans == 0 || dec.SampleRate == 0 {
+		return nil, fmt.Errorf("invalid WAV header: %d channels at %dHz", dec.NumChans, dec.SampleRate)
+	}
+
+	var samples []float32
+	if dec.WavAudioFormat == wavFormatFloat && dec.BitDepth == 32 {
+		// The decoder reads float samples as if they were 32-bit integers
+		samples = make([]float32, len(buf.Data))
+		for i, v := range buf.Data {
+			samples[i] = math.Float32frombits(uint32(int32(v)))
+		}
+	} else {
+		samples = buf.AsFloat32Buffer().Data
+	}
+	samples = MixToMono(samples, int(dec.NumChans))
+	return Resample(samples, int(dec.SampleRate), SampleRate), nil
+}
+
+// ErrWAVTooLarge is returned for audio that doesn't fit in a WAV file, whose
+// header holds sizes of at most 4GB. At 16kHz that is over 18 hours of 16-bit
+// audio, so only a runaway recording gets there.
+var ErrWAVTooLarge = errors.New("audio too long for a WAV file")
+
+// maxWAVSize is the largest RIFF size a WAV header can hold. A variable so
+// tests can hit it without gigabytes of samples.
+var maxWAVSize uint64 = math.MaxUint32
+
+// SaveWAV writes samples to path as a WAV file in format (see
+// EncodeWAVFormat), creating its directory if needed. Only the user can read
+// the file, it holds their voice.
+func SaveWAV(path string, samples []float32, format WAVFormat) error {
+	data, err := EncodeWAVFormat(samples, format)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// EncodeWAV encodes mono samples at SampleRate as a 16-bit PCM WAV file,
+// clipping samples outside [-1, 1]
+func EncodeWAV(samples []float32) ([]byte, error) {
+	return EncodeWAVFormat(samples, WAVInt16)
+}
+
+// EncodeWAVFormat encodes mono samples at SampleRate as a WAV file in format.
+// Integer formats clip samples outside [-1, 1]; float keeps them as they are.
+// Fails with ErrWAVTooLarge rather than writing sizes that overflow.
+func EncodeWAVFormat(samples []float32, format WAVFormat) ([]byte, error) {
+	buf, err := wavHeader(len(samples), format)
+	if err != nil {
+		return nil, err
+	}
+	buf = slices.Grow(buf, len(samples)*format.bitsPerSample()/8)
+
+	for _, s := range samples {
+		switch format {
+		case WAVFloat32:
+			buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(s))
+		case WAVInt24:
+			v := int32(math.Round(float64(max(-1, min(1, s))) * (1<<23 - 1)))
+			buf = append(buf, byte(v), byte(v>>8), byte(v>>16))
+		default:
+			s = max(-1, min(1, s))
+			buf = binary.LittleEndian.AppendUint16(buf, uint16(int16(math.Round(float64(s)*math.MaxInt16))))
+		}
+	}
+	return buf, nil
+}
+
+// wavHeader returns the header of a WAV file of numSamples mono samples in
+// format, everything up to the samples themselves. Float files carry the
+// cbSize field and fact chunk the WAV spec requires for formats other than PCM.
+func wavHeader(numSamples int, format WAVFormat) ([]byte, error) {
+	const channels = 1
+	bitsPerSample := format.bitsPerSample()
+	blockAlign := channels * bitsPerSample / 8
+	headerSize := 44
+	if format == WAVFloat32 {
+		headerSize = 58
+	}
+	// The RIFF size counts everything after itself and must fit in 32 bits.
+	// Every sample takes at least a byte, so larger counts can be refused
+	// before the sizes could overflow even 64 bits.
+	if numSamples < 0 || uint64(numSamples) > maxWAVSize {
+		return nil, fmt.Errorf("%w: %d samples, the limit is 4GB", ErrWAVTooLarge, numSamples)
+	}
+	dataSize := uint64(numSamples) * uint64(blockAlign)
+	riffSize := uint64(headerSize-8) + dataSize
+	if riffSize > maxWAVSize {
+		return nil, fmt.Errorf("%w: %d samples need %d bytes, the limit is 4GB", ErrWAVTooLarge, numSamples, riffSize+8)
+	}
+
+	buf := make([]byte, 0, headerSize)
+	buf = append(buf, "RIFF"...)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(riffSize))
+	buf = append(buf, "WAVEfmt "...)
+	if format == WAVFloat32 {
+		buf = binary.LittleEndian.AppendUint32(buf, 18) // fmt chunk size
+		buf = binary.LittleEndian.AppendUint16(buf, wavFormatFloat)
+	} else {
+		buf = binary.LittleEndian.AppendUint32(buf, 16)
+		buf = binary.LittleEndian.AppendUint16(buf, wavFormatPCM)
+	}
+	buf = binary.LittleEndian.AppendUint16(buf, channels)
+	buf = binary.LittleEndian.AppendUint32(buf, SampleRate)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(SampleRate*blockAlign))
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(blockAlign))
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(bitsPerSample))
+	if format == WAVFloat32 {
+		buf = binary.LittleEndian.AppendUint16(buf, 0) // cbSize, no extension
+		buf = append(buf, "fact"...)
+		buf = binary.LittleEndian.AppendUint32(buf, 4)
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(numSamples))
+	}
+	buf = append(buf, "data"...)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(dataSize))
+	return buf, nil
+}
@@ -0,0 +1,259 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestEncodeWAV tests the header and samples of an encoded WAV
+func TestEncodeWAV(t *testing.T) {
+	data, err := EncodeWAV([]float32{0, 1, -1, 2})
+	if err != nil {
+		t.Fatalf("EncodeWAV() error = %v", err)
+	}
+
+	if len(data) != 44+8 {
+		t.Fatalf("len = %d, want a 44 byte header and 8 bytes of samples", len(data))
+	}
+	if string(data[0:4]) != "RIFF" || string(data[8:16]) != "WAVEfmt " || string(data[36:40]) != "data" {
+		t.Errorf("header = %q, want RIFF/WAVE/data chunks", data[:44])
+	}
+	if got := binary.LittleEndian.Uint32(data[24:]); got != SampleRate {
+		t.Errorf("sample rate = %d, want %d", got, SampleRate)
+	}
+	if got := binary.LittleEndian.Uint32(data[40:]); got != 8 {
+		t.Errorf("data size = %d, want 8", got)
+	}
+
+	var samples []int16
+	for i := 44; i < len(data); i += 2 {
+		samples = append(samples, int16(binary.LittleEndian.Uint16(data[i:])))
+	}
+	want := []int16{0, 32767, -32767, 32767} // Out of range samples are clipped
+	if fmt.Sprint(samples) != fmt.Sprint(want) {
+		t.Errorf("samples = %v, want %v", samples, want)
+	}
+}
+
+// TestSaveWAV tests that SaveWAV creates the directory and writes the encoded WAV
+func TestSaveWAV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recordings", "a.wav")
+	samples := []float32{0, 0.5, -0.5}
+	if err := SaveWAV(path, samples, WAVFloat32); err != nil {
+		t.Fatalf("SaveWAV() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read the file: %v", err)
+	}
+	if want, _ := EncodeWAVFormat(samples, WAVFloat32); !bytes.Equal(data, want) {
+		t.Errorf("file holds %d bytes, want the %d of EncodeWAVFormat", len(data), len(want))
+	}
+}
+
+// testWAV is a WAV file decoded by readTestWAV
+type testWAV struct {
+	formatTag, channels, blockAlign, bits uint16
+	sampleRate, byteRate                  uint32
+	factSamples                           int // -1 without a fact chunk
+	samples                               []float64
+}
+
+// readTestWAV decodes a mono WAV file chunk by chunk, checking the sizes the
+// header gives as it goes
+func readTestWAV(t *testing.T, data []byte) testWAV {
+	t.Helper()
+	if string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		t.Fatalf("header = %q, want RIFF/WAVE", data[:12])
+	}
+	if got := binary.LittleEndian.Uint32(data[4:]); int(got) != len(data)-8 {
+		t.Fatalf("RIFF size = %d, want %d", got, len(data)-8)
+	}
+
+	w := testWAV{factSamples: -1}
+	for pos := 12; pos < len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		chunk := data[pos+8:]
+		if size > len(chunk) {
+			t.Fatalf("%s chunk size %d runs past the end", id, size)
+		}
+		chunk = chunk[:size]
+		switch id {
+		case "fmt ":
+			w.formatTag = binary.LittleEndian.Uint16(chunk[0:])
+			w.channels = binary.LittleEndian.Uint16(chunk[2:])
+			w.sampleRate = binary.LittleEndian.Uint32(chunk[4:])
+			w.byteRate = binary.LittleEndian.Uint32(chunk[8:])
+			w.blockAlign = binary.LittleEndian.Uint16(chunk[12:])
+			w.bits = binary.LittleEndian.Uint16(chunk[14:])
+			if size > 16 {
+				if extra := binary.LittleEndian.Uint16(chunk[16:]); int(extra) != size-18 {
+					t.Errorf("cbSize = %d, want %d", extra, size-18)
+				}
+			}
+		case "fact":
+			w.factSamples = int(binary.LittleEndian.Uint32(chunk))
+		case "data":
+			if w.blockAlign == 0 || size%int(w.blockAlign) != 0 {
+				t.Fatalf("data size %d isn't a multiple of block align %d", size, w.blockAlign)
+			}
+			for i := 0; i < size; i += int(w.blockAlign) {
+				switch {
+				case w.formatTag == 3 && w.bits == 32:
+					w.samples = append(w.samples, float64(math.Float32frombits(binary.LittleEndian.Uint32(chunk[i:]))))
+				case w.formatTag == 1 && w.bits == 24:
+					v := int32(uint32(chunk[i])<<8|uint32(chunk[i+1])<<16|uint32(chunk[i+2])<<24) >> 8
+					w.samples = append(w.samples, float64(v)/(1<<23-1))
+				case w.formatTag == 1 && w.bits == 16:
+					w.samples = append(w.samples, float64(int16(binary.LittleEndian.Uint16(chunk[i:])))/math.MaxInt16)
+				default:
+					t.Fatalf("unexpected format %d with %d bits", w.formatTag, w.bits)
+				}
+			}
+		}
+		pos += 8 + size + size%2
+	}
+	return w
+}
+
+// TestEncodeWAVFormat tests the headers and samples of each WAV format by
+// decoding the encoded file again
+func TestEncodeWAVFormat(t *testing.T) {
+	samples := []float32{0, 0.5, -0.25, 1, -1, 1.5}
+
+	tests := []struct {
+		name          string
+		format        WAVFormat
+		wantTag       uint16
+		wantBits      uint16
+		wantFact      int
+		wantLast      float64 // 1.5 after decoding
+		wantPrecision float64
+	}{
+		{"int16", WAVInt16, 1, 16, -1, 1, 1.0 / (1 << 15)},
+		{"int24", WAVInt24, 1, 24, -1, 1, 1.0 / (1 << 23)},
+		{"float32", WAVFloat32, 3, 32, len(samples), 1.5, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := EncodeWAVFormat(samples, tt.format)
+			if err != nil {
+				t.Fatalf("EncodeWAVFormat() error = %v", err)
+			}
+			w := readTestWAV(t, data)
+
+			if w.formatTag != tt.wantTag || w.bits != tt.wantBits {
+				t.Errorf("format tag %d with %d bits, want %d with %d", w.formatTag, w.bits, tt.wantTag, tt.wantBits)
+			}
+			if w.channels != 1 || w.sampleRate != SampleRate {
+				t.Errorf("%d channels at %dHz, want mono at %dHz", w.channels, w.sampleRate, SampleRate)
+			}
+			if w.blockAlign != tt.wantBits/8 || w.byteRate != SampleRate*uint32(tt.wantBits/8) {
+				t.Errorf("block align %d, byte rate %d, want %d, %d", w.blockAlign, w.byteRate, tt.wantBits/8, SampleRate*uint32(tt.wantBits/8))
+			}
+			if w.factSamples != tt.wantFact {
+				t.Errorf("fact chunk samples = %d, want %d", w.factSamples, tt.wantFact)
+			}
+
+			want := []float64{0, 0.5, -0.25, 1, -1, tt.wantLast}
+			if len(w.samples) != len(want) {
+				t.Fatalf("decoded %d samples, want %d", len(w.samples), len(want))
+			}
+			for i := range want {
+				if math.Abs(w.samples[i]-want[i]) > tt.wantPrecision {
+					t.Errorf("sample %d = %v, want %v", i, w.samples[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestWAVHeaderSizeLimit tests that sizes beyond what the header can hold
+// are refused rather than wrapped around. Only the declared sizes are
+// checked, no samples are allocated.
+func TestWAVHeaderSizeLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		numSamples int
+		format     WAVFormat
+		wantErr    bool
+	}{
+		{"largest int16", (math.MaxUint32 - 36) / 2, WAVInt16, false},
+		{"one int16 sample too many", (math.MaxUint32-36)/2 + 1, WAVInt16, true},
+		{"largest float32", (math.MaxUint32 - 50) / 4, WAVFloat32, false},
+		{"one float32 sample too many", (math.MaxUint32-50)/4 + 1, WAVFloat32, true},
+		{"int24 overflowing int32 sizes", math.MaxInt32, WAVInt24, true},
+		{"far beyond the limit", math.MaxInt64 / 8, WAVInt16, true},
+		{"would overflow 64 bits", math.MaxInt64, WAVFloat32, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, err := wavHeader(tt.numSamples, tt.format)
+			if tt.wantErr {
+				if !errors.Is(err, ErrWAVTooLarge) {
+					t.Errorf("wavHeader() error = %v, want ErrWAVTooLarge", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("wavHeader() error = %v", err)
+			}
+			riffSize := binary.LittleEndian.Uint32(header[4:])
+			dataSize := binary.LittleEndian.Uint32(header[len(header)-4:])
+			if uint64(riffSize) != uint64(len(header)-8)+uint64(dataSize) {
+				t.Errorf("RIFF size %d doesn't match header %d + data %d", riffSize, len(header)-8, dataSize)
+			}
+			if want := uint64(tt.numSamples) * uint64(tt.format.bitsPerSample()/8); uint64(dataSize) != want {
+				t.Errorf("data size = %d, want %d", dataSize, want)
+			}
+		})
+	}
+}
+
+// TestSaveWAVTooLarge tests that SaveWAV writes nothing when the header
+// would overflow
+func TestSaveWAVTooLarge(t *testing.T) {
+	orig := maxWAVSize
+	t.Cleanup(func() { maxWAVSize = orig })
+	maxWAVSize = 100
+
+	path := filepath.Join(t.TempDir(), "a.wav")
+	if err := SaveWAV(path, make([]float32, 100), WAVInt16); !errors.Is(err, ErrWAVTooLarge) {
+		t.Errorf("SaveWAV() error = %v, want ErrWAVTooLarge", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("file was written despite the error: %v", err)
+	}
+}
+
+// TestParseWAVFormat tests converting config values to formats
+func TestParseWAVFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    WAVFormat
+		wantErr bool
+	}{
+		{"", WAVInt16, false},
+		{"int16", WAVInt16, false},
+		{"INT24", WAVInt24, false},
+		{"float32", WAVFloat32, false},
+		{"mp3", WAVInt16, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseWAVFormat(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseWAVFormat(%q) = %v, %v, want %v, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/stephanwesten/go-whisper/src/audio"
+	"github.com/stephanwesten/go-whisper/src/whisper"
+)
+
+// runStdin transcribes audio read from stdin and prints the text to stdout,
+// for use in pipelines like `cat audio.wav | GoWhisper --stdin`. Formats
+// other than WAV are decoded with ffmpeg. Returns the process exit code.
+func runStdin() int {
+	samples, err := decodeAudioStream(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: can't decode the audio on stdin: %v\n", err)
+		return 1
+	}
+
+	text, err := transcribeSamples(samples)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	fmt.Println(text)
+	return 0
+}
+
+// decodeAudioStream decodes an audio stream that can't seek, such as a pipe.
+// The WAV decoder needs to seek between chunks, so the stream is buffered
+// first. Anything that isn't WAV, like MP3, M4A or FLAC, goes to ffmpeg.
+func decodeAudioStream(r io.Reader) ([]float32, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audio: %w", err)
+	}
+	return decodeAudioData(data)
+}
+
+// decodeAudioData decodes a complete audio file held in memory: WAV directly,
+// any other format through ffmpeg
+func decodeAudioData(data []byte) ([]float32, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no data received")
+	}
+	if isWAV(data) {
+		return audio.DecodeWAV(bytes.NewReader(data))
+	}
+
+	samples, err := audio.DecodeFFmpeg(data)
+	if errors.Is(err, audio.ErrNoFFmpeg) {
+		return nil, fmt.Errorf("not a WAV stream, and decoding other formats needs ffmpeg (brew install ffmpeg)")
+	}
+	return samples, err
+}
+
+// isWAV reports whether data starts with a RIFF WAVE header
+func isWAV(data []byte) bool {
+	return len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE"
+}
+
+// transcribeSamples loads the model and transcribes samples outside the menu
+// bar app
+func transcribeSamples(samples []float32) (string, error) {
+	t, err := whisper.NewTranscriber(getModelPath())
+	if err != nil {
+		return "", err
+	}
+	defer t.Close()
+
+	return t.Transcribe(samples, false)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atotto/clipboard"
+)
+
+// The clipboard is shared between three users: the user's own content, the
+// temporary text we paste (indicators and dictations), and the text the
+// "clipboard" keyword intentionally leaves behind. All writes go through the
+// helpers below so that a delayed restore can never overwrite newer content.
+var (
+	clipboardMu sync.Mutex
+	// clipboardGen is bumped on every write we make; a scheduled restore only
+	// runs if no other write happened in the meantime
+	clipboardGen int
+	// savedClipboard is the user's original content while we borrow the
+	// clipboard for pasting, nil when no restore is pending
+	savedClipboard *string
+
+	// Indirection so tests can run without a system clipboard. Writes only
+	// ever put plain text on the clipboard (pbcopy on macOS), replacing any
+	// rich text flavors an earlier copy left behind.
+	readClipboard  = clipboard.ReadAll
+	writeClipboard = clipboard.WriteAll
+)
+
+// borrowClipboard puts text on the clipboard for pasting, remembering the user's
+// content so it can be restored afterward. If an earlier borrow hasn't been
+// restored yet, the original saved content is kept rather than our own text.
+// Returns the generation to pass to restoreClipboardIfCurrent.
+func borrowClipboard(text string) (int, error) {
+	clipboardMu.Lock()
+	defer clipboardMu.Unlock()
+
+	savedHere := false
+	if savedClipboard == nil {
+		original, err := readClipboard()
+		if err != nil {
+			log.Printf("Warning: Could not read clipboard: %v", err)
+			original = ""
+		}
+		savedClipboard = &original
+		savedHere = true
+	}
+
+	if err := writeClipboard(text); err != nil {
+		// Nothing was borrowed, so there is nothing to restore later
+		if savedHere {
+			savedClipboard = nil
+		}
+		return clipboardGen, fmt.Errorf("failed to write to clipboard: %v", err)
+	}
+	clipboardGen++
+	return clipboardGen, nil
+}
+
+// restoreClipboardIfCurrent restores the user's saved clipboard, unless another
+// write happened since the borrow identified by gen
+func restoreClipboardIfCurrent(gen int) {
+	clipboardMu.Lock()
+	defer clipboardMu.Unlock()
+
+	if gen != clipboardGen || savedClipboard == nil {
+		logDebug("Skipping clipboard restore, clipboard changed since paste")
+		return
+	}
+	if err := writeClipboard(*savedClipboard); err != nil {
+		log.Printf("Warning: Failed to restore clipboard: %v", err)
+		return
+	}
+	savedClipboard = nil
+	clipboardGen++
+}
+
+// scheduleClipboardRestore restores the user's clipboard after delay, giving the
+// target app time to read the pasted text before the clipboard changes again
+func scheduleClipboardRestore(gen int, delay time.Duration) {
+	go func() {
+		time.Sleep(delay)
+		restoreClipboardIfCurrent(gen)
+	}()
+}
+
+// setClipboardContent intentionally leaves text on the clipboard (the "clipboard"
+// keyword). Any pending restore is cancelled so the user's intent wins.
+func setClipboardContent(text string) error {
+	clipboardMu.Lock()
+	defer clipboardMu.Unlock()
+
+	if err := writeClipboard(text); err != nil {
+		return err
+	}
+	savedClipboard = nil
+	clipboardGen++
+	return nil
+}
+
+// scheduleClipboardContent puts text on the clipboard after delay, like
+// setClipboardContent, unless another write happened in the meantime. It
+// gives the target app time to read a paste before the clipboard changes.
+func scheduleClipboardContent(text string, delay time.Duration) {
+	clipboardMu.Lock()
+	gen := clipboardGen
+	clipboardMu.Unlock()
+
+	go func() {
+		time.Sleep(delay)
+		clipboardMu.Lock()
+		defer clipboardMu.Unlock()
+		if gen != clipboardGen {
+			return
+		}
+		if err := writeClipboard(text); err != nil {
+			log.Printf("Warning: Failed to copy to clipboard: %v", err)
+			return
+		}
+		savedClipboard = nil
+		clipboardGen++
+	}()
+}
+
+// appendClipboardContent adds text on a new line after the user's clipboard
+// content (the "clipboard" keyword in accumulate mode). When maxChars > 0 the
+// oldest content is dropped to stay within it. Like setClipboardContent, any
+// pending restore is cancelled.
+func appendClipboardContent(text string, maxChars int) error {
+	clipboardMu.Lock()
+	defer clipboardMu.Unlock()
+
+	// While an indicator is pasted the clipboard holds our own text, and the
+	// user's content is the saved copy
+	var current string
+	if savedClipboard != nil {
+		current = *savedClipboard
+	} else if content, err := readClipboard(); err != nil {
+		log.Printf("Warning: Could not read clipboard, starting a new one: %v", err)
+	} else {
+		current = content
+	}
+
+	combined := text
+	if current != "" {
+		combined = current + "\n" + text
+	}
+	if err := writeClipboard(keepLastChars(combined, maxChars)); err != nil {
+		return err
+	}
+	savedClipboard = nil
+	clipboardGen++
+	return nil
+}
+
+// keepLastChars drops characters from the start of text so at most maxChars
+// remain, starting at a line where possible. maxChars <= 0 means no limit.
+func keepLastChars(text string, maxChars int) string {
+	runes := []rune(text)
+	if maxChars <= 0 || len(runes) <= maxChars {
+		return text
+	}
+	cut := len(runes) - maxChars
+	tail := string(runes[cut:])
+	if runes[cut-1] == '\n' {
+		return tail
+	}
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		return tail[i+1:]
+	}
+	return tail
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+
+	"github.com/stephanwesten/go-whisper/src/config"
+)
+
+// matchKeywordCommand returns the command whose keyword is the first word of
+// text, ignoring case and punctuation, together with the rest of the text
+func matchKeywordCommand(text string, commands []config.KeywordCommand) (config.KeywordCommand, string, bool) {
+	first, rest, _ := strings.Cut(strings.TrimSpace(text), " ")
+	word := stripPunctuation(first)
+	for _, c := range commands {
+		if strings.EqualFold(word, c.Keyword) {
+			return c, strings.TrimSpace(rest), true
+		}
+	}
+	return config.KeywordCommand{}, "", false
+}
+
+// runKeywordCommand runs command with text in place of "{text}" and returns
+// what it printed. The text is passed as an argument of sh and "{text}"
+// becomes a reference to it, so spoken text is never parsed as shell code,
+// even where the command puts "{text}" inside quotes. It gives up after the command's timeout, and kills the
+// command when ctx is cancelled.
+func runKeywordCommand(ctx context.Context, command config.KeywordCommand, text string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, command.Timeout())
+	defer cancel()
+
+	script := strings.ReplaceAll(command.Command, "{text}", `"$1"`)
+	// The word after the script is $0, the name sh reports errors under
+	cmd := exec.CommandContext(ctx, "sh", "-c", script, "sh", text)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("%q timed out after %v", command.Keyword, command.Timeout())
+	}
+	if err != nil {
+		log.Printf("Command for %q failed: %v, output: %s", command.Keyword, err, stderr.String())
+		return "", fmt.Errorf("%q command failed: %v", command.Keyword, err)
+	}
+
+	result := strings.TrimSpace(string(output))
+	if result == "" {
+		return "", fmt.Errorf("%q command printed nothing", command.Keyword)
+	}
+	return result, nil
+}
@@ -0,0 +1,678 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stephanwesten/go-whisper/src/paths"
+)
+
+// Injection modes for typing text into the active window
+const (
+	// InjectionModePaste pastes via the clipboard, restoring it afterward (default)
+	InjectionModePaste = "paste"
+	// InjectionModeKeystroke types character by character and never touches the clipboard
+	InjectionModeKeystroke = "keystroke"
+	// InjectionModeSlow types in chunks of SlowTypeChunkSize characters with a
+	// pause between them, for apps that drop characters typed too fast
+	InjectionModeSlow = "slow"
+)
+
+// Log formats for the application log
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Log levels, the least severe messages the application log keeps
+const (
+	LogLevelError = "error"
+	LogLevelWarn  = "warn"
+	LogLevelInfo  = "info"
+	LogLevelDebug = "debug"
+)
+
+// Claude output modes for what is typed after rephrasing
+const (
+	// ClaudeOutputReplace outputs only the rephrased text (default)
+	ClaudeOutputReplace = "replace"
+	// ClaudeOutputBelow outputs the original, the separator, then the rephrased text
+	ClaudeOutputBelow = "below"
+	// ClaudeOutputAbove outputs the rephrased text, the separator, then the original
+	ClaudeOutputAbove = "above"
+)
+
+// What "claude clipboard" types, the other text going to the clipboard
+const (
+	// ClaudeClipboardTypeNone types nothing and copies the rephrased text (default)
+	ClaudeClipboardTypeNone = "none"
+	// ClaudeClipboardTypeRephrased types the rephrased text and copies the original
+	ClaudeClipboardTypeRephrased = "rephrased"
+	// ClaudeClipboardTypeOriginal types the original and copies the rephrased text
+	ClaudeClipboardTypeOriginal = "original"
+)
+
+// Output targets for dictations that aren't copied or saved as a note
+const (
+	// OutputTargetWindow types into the focused window (default)
+	OutputTargetWindow = "window"
+	// OutputTargetURL opens OutputURLTemplate with the text, e.g. to create a draft
+	OutputTargetURL = "url"
+)
+
+// Transcription backends
+const (
+	// TranscribeBackendLocal runs whisper.cpp in process (default)
+	TranscribeBackendLocal = "local"
+	// TranscribeBackendRemote posts the audio to a whisper.cpp server at RemoteURL
+	TranscribeBackendRemote = "remote"
+)
+
+// Dictation actions a hotkey in ActionHotkeys can be bound to
+const (
+	// ActionPlain types the dictation, like the main hotkey
+	ActionPlain = "plain"
+	// ActionClipboard copies the dictation, as if "clipboard" was said
+	ActionClipboard = "clipboard"
+	// ActionRephrase rephrases the dictation with Claude, as if "claude" was said
+	ActionRephrase = "rephrase"
+	// ActionSession starts a continuous dictation session: each pause of
+	// SessionPauseMs outputs what was said and recording resumes, until a
+	// hotkey is pressed again
+	ActionSession = "session"
+)
+
+// ActionHotkey binds an extra global hotkey to a dictation action
+type ActionHotkey struct {
+	// Hotkey is modifiers and a key joined by "+", e.g. "cmd+shift+c" or
+	// "ctrl+option+space"
+	Hotkey string `json:"hotkey"`
+	// Action is ActionPlain, ActionClipboard, ActionRephrase or ActionSession
+	Action string `json:"action"`
+}
+
+// DefaultCommandTimeoutSec is how long a KeywordCommand may run when it sets
+// no TimeoutSec
+const DefaultCommandTimeoutSec = 10
+
+// KeywordCommand runs a shell command for dictations starting with Keyword
+// and outputs what it prints instead of the dictation
+type KeywordCommand struct {
+	// Keyword is the single word that starts the dictation, e.g. "search"
+	Keyword string `json:"keyword"`
+	// Command is run with "sh -c" after replacing "{text}" with "$1", which
+	// sh sets to the rest of the dictation
+	Command string `json:"command"`
+	// TimeoutSec stops a command that runs longer (0 = DefaultCommandTimeoutSec)
+	TimeoutSec int `json:"timeoutSec"`
+}
+
+// Timeout returns TimeoutSec as a duration, applying the default
+func (k KeywordCommand) Timeout() time.Duration {
+	if k.TimeoutSec == 0 {
+		return DefaultCommandTimeoutSec * time.Second
+	}
+	return time.Duration(k.TimeoutSec) * time.Second
+}
+
+// Config holds the user settings read from config.json.
+// Fields missing from the file keep their default values.
+type Config struct {
+	// InjectionDelayMs is the pause after the hotkey fires before any text is typed.
+	// Without it the Cmd+Shift modifiers may still be held down when AppleScript
+	// injects keystrokes, which produces wrong characters.
+	InjectionDelayMs int `json:"injectionDelayMs"`
+
+	// ClipboardRestoreDelayMs is the pause after pasting before the original clipboard
+	// is restored. The target app reads the clipboard asynchronously, so restoring
+	// too early pastes the old clipboard content instead of the dictation.
+	ClipboardRestoreDelayMs int `json:"clipboardRestoreDelayMs"`
+
+	// RestoreClipboard puts the user's clipboard back after pasting. When off
+	// the pasted text stays on the clipboard, which avoids the races of a
+	// delayed restore at the cost of losing what was copied before.
+	RestoreClipboard bool `json:"restoreClipboard"`
+
+	// TargetApp pins dictations to an application, such as "Notes": it is
+	// brought to the front before the text is typed, wherever the focus was.
+	// Empty (default) types into the frontmost app.
+	TargetApp string `json:"targetApp"`
+
+	// TargetAppReturnFocus switches back to the app that was in front after
+	// typing into TargetApp
+	TargetAppReturnFocus bool `json:"targetAppReturnFocus"`
+
+	// Verbose logs the input and output of every pipeline stage to a rotating
+	// log file under ~/.go-whisper/logs/, for debugging bad dictations
+	Verbose bool `json:"verbose"`
+
+	// AutoStopSilenceMs stops the recording automatically once this much silence
+	// follows speech. 0 disables auto-stop; keep it well above natural pauses.
+	AutoStopSilenceMs int `json:"autoStopSilenceMs"`
+
+	// AutoStopThreshold is the RMS level below which audio counts as silence
+	AutoStopThreshold float32 `json:"autoStopThreshold"`
+
+	// InputGain multiplies every recorded sample, for a microphone that is
+	// too quiet (1 leaves the audio unchanged). Amplified samples are clamped
+	// to [-1, 1]; thresholds such as AutoStopThreshold apply after the gain.
+	InputGain float32 `json:"inputGain"`
+
+	// SessionPauseMs is the silence after speech that outputs the text so far
+	// in a continuous dictation session, see ActionSession
+	SessionPauseMs int `json:"sessionPauseMs"`
+
+	// TrimSilence removes leading and trailing silence before transcription.
+	// The "too short" check then applies to the trimmed audio.
+	TrimSilence bool `json:"trimSilence"`
+
+	// TrimThreshold is the RMS level below which audio counts as silence for trimming
+	TrimThreshold float32 `json:"trimThreshold"`
+
+	// BlinkRecordingIcon alternates the menu bar icon while recording.
+	// When false, RecordingIconOn is shown without blinking.
+	BlinkRecordingIcon bool `json:"blinkRecordingIcon"`
+
+	// BlinkIntervalMs is how often the recording icon alternates
+	BlinkIntervalMs int `json:"blinkIntervalMs"`
+
+	// RecordingIconOn and RecordingIconOff are the two alternating recording icons
+	RecordingIconOn  string `json:"recordingIconOn"`
+	RecordingIconOff string `json:"recordingIconOff"`
+
+	// ClaudeMaxChars caps the length of Claude's rephrased output in characters (0 = no cap)
+	ClaudeMaxChars int `json:"claudeMaxChars"`
+
+	// ClaudeMaxRatio caps Claude's output relative to the input length (0 = no cap)
+	ClaudeMaxRatio float64 `json:"claudeMaxRatio"`
+
+	// ClaudeTruncateLongOutput truncates output over the cap instead of
+	// falling back to the original, un-rephrased text
+	ClaudeTruncateLongOutput bool `json:"claudeTruncateLongOutput"`
+
+	// InjectionMode selects how text is typed: InjectionModePaste, InjectionModeKeystroke
+	// or InjectionModeSlow.
+	// Keystroke mode is slower but keeps clipboard managers free of dictation entries.
+	InjectionMode string `json:"injectionMode"`
+
+	// SlowTypeChunkSize is how many characters InjectionModeSlow types at once,
+	// and SlowTypeDelayMs the pause after each chunk
+	SlowTypeChunkSize int `json:"slowTypeChunkSize"`
+	SlowTypeDelayMs   int `json:"slowTypeDelayMs"`
+
+	// VerifyPaste checks after typing that the focused element took the text.
+	// When it clearly didn't, the text stays on the clipboard and a
+	// notification tells the user to paste it themselves. Off by default, as
+	// the check waits for the app and adds a moment to every dictation.
+	VerifyPaste bool `json:"verifyPaste"`
+
+	// PreserveSelection doesn't type the "Recording"/"Processing" indicators
+	// into the window, only the menu bar shows progress. A selection in the
+	// window is then left alone until the dictation replaces it.
+	PreserveSelection bool `json:"preserveSelection"`
+
+	// OutputTarget is OutputTargetWindow or OutputTargetURL. With a URL, plain
+	// dictations open OutputURLTemplate with "{text}" replaced by the
+	// URL-encoded text, e.g. "drafts://create?text={text}", instead of typing.
+	OutputTarget      string `json:"outputTarget"`
+	OutputURLTemplate string `json:"outputURLTemplate"`
+
+	// MaxOutputChars asks before typing a dictation longer than this, offering
+	// to copy or discard it instead, so a recording left running doesn't type
+	// a wall of text into a chat (0 disables)
+	MaxOutputChars int `json:"maxOutputChars"`
+
+	// PreviewOutput shows every dictation about to be typed in an editable
+	// dialog, to type, copy or discard it
+	PreviewOutput bool `json:"previewOutput"`
+
+	// PasteShortcut is the key combination pressed to paste in paste mode,
+	// e.g. "cmd+shift+v" or "cmd+option+shift+v" for apps whose "paste and
+	// match style" shortcut keeps the formatting of the surrounding text
+	PasteShortcut string `json:"pasteShortcut"`
+
+	// HotkeyDebounceMs ignores hotkey triggers arriving this soon after a state
+	// change (0 disables), filtering duplicate key events
+	HotkeyDebounceMs int `json:"hotkeyDebounceMs"`
+
+	// ProcessingCooldownMs ignores hotkey triggers for this long after a
+	// transcription finishes (0 disables), so a stop press that repeated
+	// while processing can't start a new recording
+	ProcessingCooldownMs int `json:"processingCooldownMs"`
+
+	// AutoPunctuate capitalizes the first letter and adds a final period when
+	// Whisper left them out. Runs locally; skipped for code-like text.
+	AutoPunctuate bool `json:"autoPunctuate"`
+
+	// Denoise reduces steady background noise (fan, air conditioning) before
+	// transcription, using the first 200ms of each recording as noise profile
+	Denoise bool `json:"denoise"`
+
+	// RemoveFillers drops the words and phrases in FillerWords ("um", "you
+	// know", ...). Matching is per word, so "like" is removed even as a verb.
+	RemoveFillers bool     `json:"removeFillers"`
+	FillerWords   []string `json:"fillerWords"`
+
+	// CollapseRepeats merges a word Whisper wrote twice by accident ("the
+	// the"), except for the words in KeepRepeats that people repeat on purpose
+	CollapseRepeats bool     `json:"collapseRepeats"`
+	KeepRepeats     []string `json:"keepRepeats"`
+
+	// SpellCheck fixes obvious typos with hunspell or aspell in every
+	// dictation that isn't rephrased by Claude, as if "proofread" was said.
+	// SpellCheckDictionary names the dictionary, "" for the checker's default.
+	SpellCheck           bool   `json:"spellCheck"`
+	SpellCheckDictionary string `json:"spellCheckDictionary"`
+
+	// TimestampFormat is the Go time layout of the date and time the
+	// "timestamp" keyword puts before the text, e.g. "2006-01-02 15:04"
+	TimestampFormat string `json:"timestampFormat"`
+
+	// LogFormat is LogFormatText for human-readable logs (default) or LogFormatJSON
+	// for structured logs with event, state, duration and sample_count fields
+	LogFormat string `json:"logFormat"`
+
+	// LogLevel drops log messages less severe than it: LogLevelError,
+	// LogLevelWarn, LogLevelInfo (default) or LogLevelDebug, which adds state
+	// transitions, audio levels and other details for debugging
+	LogLevel string `json:"logLevel"`
+
+	// ModelIdleTimeoutMin closes the Whisper model after this many minutes idle
+	// to free memory; it is reloaded on the next dictation (0 keeps it loaded)
+	ModelIdleTimeoutMin int `json:"modelIdleTimeoutMin"`
+
+	// PreRollMs keeps this much audio from before the hotkey press and prepends
+	// it to the recording (0 disables). Keeps the microphone open while idle.
+	PreRollMs int `json:"preRollMs"`
+
+	// KeepMicOpen keeps the audio stream running between recordings so one
+	// starts without waiting for the microphone to open. Audio while idle is
+	// discarded (unless PreRollMs keeps some), but the microphone stays open.
+	KeepMicOpen bool `json:"keepMicOpen"`
+
+	// WakePhrase, e.g. "hey whisper", starts a recording when it is heard, for
+	// hands-free use. Every WakeIntervalMs the last WakeWindowMs of audio is
+	// transcribed, so this costs CPU the whole time. Empty disables it.
+	WakePhrase     string `json:"wakePhrase"`
+	WakeWindowMs   int    `json:"wakeWindowMs"`
+	WakeIntervalMs int    `json:"wakeIntervalMs"`
+
+	// TailCaptureMs keeps recording this long after the stop hotkey, so the
+	// end of the last word isn't clipped when the key is pressed right away.
+	// Time spent typing the processing indicator counts towards it.
+	TailCaptureMs int `json:"tailCaptureMs"`
+
+	// ShowTimings briefly shows the transcription speed in the menu after each
+	// dictation. It is always logged.
+	ShowTimings bool `json:"showTimings"`
+
+	// TranscribeBackend is TranscribeBackendLocal or TranscribeBackendRemote.
+	// The remote backend sends each recording to the inference endpoint of a
+	// whisper.cpp server, e.g. "http://desktop.local:8080/inference", and
+	// gives up after RemoteTimeoutSec.
+	TranscribeBackend string `json:"transcribeBackend"`
+	RemoteURL         string `json:"remoteURL"`
+	RemoteTimeoutSec  int    `json:"remoteTimeoutSec"`
+
+	// TranscribeRetry transcribes the recording once more when Whisper fails,
+	// to get past transient errors such as a busy remote server
+	TranscribeRetry bool `json:"transcribeRetry"`
+
+	// SaveFailedAudio keeps the recording of a failed transcription as a WAV
+	// file in ~/.go-whisper/failed/, to transcribe later with --stdin.
+	// FailedClipboardNote copies a note about the failure, with the path of
+	// the saved recording, to the clipboard.
+	SaveFailedAudio     bool `json:"saveFailedAudio"`
+	FailedClipboardNote bool `json:"failedClipboardNote"`
+
+	// Temperature is Whisper's sampling temperature, 0 for the most likely
+	// and repeatable text. TemperatureFallback is how much it is raised to
+	// retry a window that decoded badly; 0 turns the retries off.
+	Temperature         float32 `json:"temperature"`
+	TemperatureFallback float32 `json:"temperatureFallback"`
+
+	// NotesDir enables the "note" keyword, which appends the dictation to a
+	// daily NotesDir/YYYY-MM-DD.md file instead of typing it. Empty disables it.
+	NotesDir string `json:"notesDir"`
+
+	// RecordingHotkey starts and stops a recording, written as modifiers and a
+	// key joined by "+" like the ActionHotkeys, e.g. "ctrl+option+space". Cmd
+	// means Ctrl on Linux.
+	RecordingHotkey string `json:"recordingHotkey"`
+
+	// RephraseToggleHotkey registers Cmd+Shift+R (Ctrl+Shift+R on Linux) to
+	// toggle "Rephrase All Dictations" from the keyboard
+	RephraseToggleHotkey bool `json:"rephraseToggleHotkey"`
+
+	// History keeps every dictation in ~/.go-whisper/history.jsonl. With
+	// HistoryEncrypt it goes to history.enc instead, encrypted with a key
+	// derived from a random passphrase kept in the keychain.
+	History        bool `json:"history"`
+	HistoryEncrypt bool `json:"historyEncrypt"`
+
+	// KeepRecordings keeps the audio of the last this many dictations in
+	// ~/.go-whisper/recordings, deleting older ones, to look into a bad
+	// transcription. The history links each entry to its file. 0 disables it.
+	KeepRecordings int `json:"keepRecordings"`
+
+	// WAVFormat is the sample format of the recordings kept by
+	// KeepRecordings and SaveFailedAudio: "int16", "int24" or "float32".
+	// Float keeps the microphone's samples exactly, for debugging.
+	WAVFormat string `json:"wavFormat"`
+
+	// MetricsPort serves Prometheus-style dictation counters at
+	// http://127.0.0.1:MetricsPort/metrics. 0 disables the endpoint.
+	MetricsPort int `json:"metricsPort"`
+
+	// ActionHotkeys are extra hotkeys that start a dictation with a preset
+	// action, so e.g. Cmd+Shift+C copies without saying "clipboard". Any
+	// hotkey stops the recording; the action is the one it was started with.
+	ActionHotkeys []ActionHotkey `json:"actionHotkeys"`
+
+	// QuietMicRecordings warns that the microphone may be muted after this many
+	// consecutive recordings with an RMS below QuietMicThreshold (0 disables)
+	QuietMicRecordings int     `json:"quietMicRecordings"`
+	QuietMicThreshold  float32 `json:"quietMicThreshold"`
+
+	// ClaudeOutputMode is ClaudeOutputReplace, ClaudeOutputBelow or
+	// ClaudeOutputAbove. The last two keep the original next to the rephrased
+	// text, joined by ClaudeSeparator.
+	ClaudeOutputMode string `json:"claudeOutputMode"`
+	ClaudeSeparator  string `json:"claudeSeparator"`
+
+	// ClaudeClipboardType is what a dictation with both "claude" and
+	// "clipboard" types: ClaudeClipboardTypeNone, ClaudeClipboardTypeRephrased
+	// or ClaudeClipboardTypeOriginal. The other text goes to the clipboard.
+	ClaudeClipboardType string `json:"claudeClipboardType"`
+
+	// ClaudeAliases are words treated like the "claude" keyword, for common
+	// Whisper misrecognitions of it. Remove one if you need it as a word.
+	ClaudeAliases []string `json:"claudeAliases"`
+
+	// AutoRephraseConfidence sends a dictation to Claude as if "claude" was
+	// said when Whisper's average token probability is below it, since those
+	// transcriptions are the most likely to be garbled. 0 disables it; only
+	// the local backend reports a confidence.
+	AutoRephraseConfidence float32 `json:"autoRephraseConfidence"`
+
+	// KeywordCommands bind extra keywords to shell commands, e.g. "search"
+	// to a CLI that answers the question. Their output is typed or copied
+	// like a dictation; no other keyword applies to one.
+	KeywordCommands []KeywordCommand `json:"keywordCommands"`
+
+	// SpokenPunctuation replaces spoken commands such as "comma" or "new line"
+	// with the symbols they name. SpokenPunctuationMap replaces the built-in
+	// commands when set. Off by default since those words can't be dictated
+	// literally while it's on.
+	SpokenPunctuation    bool              `json:"spokenPunctuation"`
+	SpokenPunctuationMap map[string]string `json:"spokenPunctuationMap"`
+
+	// ClipboardAccumulate makes the "clipboard" keyword append to the current
+	// clipboard on a new line instead of replacing it. The result is cut to the
+	// last ClipboardAccumulateMaxChars characters (0 = no limit).
+	ClipboardAccumulate         bool `json:"clipboardAccumulate"`
+	ClipboardAccumulateMaxChars int  `json:"clipboardAccumulateMaxChars"`
+
+	// InjectPrefix and InjectSuffix are added around text typed into the window,
+	// e.g. to start every chat message with a name. They also apply to the
+	// "clipboard" keyword when InjectAffixClipboard is set.
+	InjectPrefix         string `json:"injectPrefix"`
+	InjectSuffix         string `json:"injectSuffix"`
+	InjectAffixClipboard bool   `json:"injectAffixClipboard"`
+
+	// SingleSegment and NoContext set the whisper.cpp options of the same name,
+	// which make short command-style dictation faster and less prone to
+	// hallucination
+	SingleSegment bool `json:"singleSegment"`
+	NoContext     bool `json:"noContext"`
+
+	// SegmentSeparator is put between the segments Whisper splits a
+	// transcription into, roughly its sentences. "\n" puts each on its own
+	// line, which helps when dictating lists; "" means a space.
+	SegmentSeparator string `json:"segmentSeparator"`
+
+	// SuppressPhrases and SuppressPatterns are removed from every
+	// transcription; if nothing else is left it is discarded. The defaults are
+	// what Whisper typically hallucinates during silence, learned from YouTube
+	// captions. Phrases only match a whole sentence, ignoring case and
+	// trailing punctuation; patterns are regular expressions.
+	SuppressPhrases  []string `json:"suppressPhrases"`
+	SuppressPatterns []string `json:"suppressPatterns"`
+
+	// UndoPhrases delete the previous dictation, like "Undo Last Dictation",
+	// when one is all that was said. They match ignoring case and punctuation,
+	// never as part of a longer sentence.
+	UndoPhrases []string `json:"undoPhrases"`
+
+	// A transcription that is a single word, ignoring punctuation, shorter
+	// than DiscardShorterThan characters or in DiscardWords is thrown away
+	// like an empty one. Accidental noisy recordings come out as a lone "."
+	// or "you". 0 and an empty list keep everything.
+	DiscardShorterThan int      `json:"discardShorterThan"`
+	DiscardWords       []string `json:"discardWords"`
+}
+
+// Default returns the built-in settings used when no config file exists
+func Default() Config {
+	return Config{
+		InjectionDelayMs:            100,
+		ClipboardRestoreDelayMs:     100,
+		RestoreClipboard:            true,
+		TargetAppReturnFocus:        true,
+		AutoStopSilenceMs:           0,
+		AutoStopThreshold:           0.01,
+		InputGain:                   1,
+		SessionPauseMs:              1200,
+		TrimSilence:                 false,
+		TrimThreshold:               0.01,
+		BlinkRecordingIcon:          true,
+		BlinkIntervalMs:             750,
+		RecordingIconOn:             "🔴",
+		RecordingIconOff:            "⭕",
+		ClaudeMaxChars:              0,
+		ClaudeMaxRatio:              3,
+		InjectionMode:               InjectionModePaste,
+		SlowTypeChunkSize:           10,
+		SlowTypeDelayMs:             50,
+		PasteShortcut:               "cmd+v",
+		MaxOutputChars:              4000,
+		OutputTarget:                OutputTargetWindow,
+		OutputURLTemplate:           "drafts://create?text={text}",
+		HotkeyDebounceMs:            200,
+		ProcessingCooldownMs:        500,
+		LogFormat:                   LogFormatText,
+		LogLevel:                    LogLevelInfo,
+		WAVFormat:                   "int16",
+		RecordingHotkey:             "cmd+shift+p",
+		TemperatureFallback:         0.2,
+		TailCaptureMs:               200,
+		WakeWindowMs:                2000,
+		WakeIntervalMs:              1000,
+		TranscribeBackend:           TranscribeBackendLocal,
+		RemoteURL:                   "http://127.0.0.1:8080/inference",
+		RemoteTimeoutSec:            30,
+		QuietMicRecordings:          3,
+		QuietMicThreshold:           0.001,
+		ClaudeOutputMode:            ClaudeOutputReplace,
+		ClaudeSeparator:             "\n---\n",
+		ClaudeClipboardType:         ClaudeClipboardTypeNone,
+		ClaudeAliases:               []string{"clot"},
+		ClipboardAccumulateMaxChars: 10000,
+		FillerWords:                 []string{"um", "uh", "er", "you know", "like"},
+		KeepRepeats:                 []string{"very", "really", "so", "no", "yes", "yeah", "ha", "bye", "knock", "that", "had"},
+		SpellCheckDictionary:        "en_US",
+		TimestampFormat:             "2006-01-02 15:04",
+		SegmentSeparator:            " ",
+		SuppressPhrases: []string{
+			"Thanks for watching",
+			"Thank you for watching",
+			"Thank you so much for watching",
+			"Please subscribe to my channel",
+			"Don't forget to like and subscribe",
+			"Subtitles by the Amara.org community",
+		},
+		SuppressPatterns: []string{
+			`\[[A-Z_ ]+\]`,                    // [BLANK_AUDIO], [MUSIC]
+			`(?i)\([a-z ]*(music|applause)\)`, // (upbeat music), (applause)
+		},
+		UndoPhrases: []string{"scratch that", "undo that"},
+	}
+}
+
+// DefaultPath returns the config file path from environment or default
+func DefaultPath() string {
+	if path := os.Getenv("GOWHISPER_CONFIG"); path != "" {
+		return path
+	}
+	return filepath.Join(paths.ConfigDir(), "config.json")
+}
+
+// Load reads the config file at path on top of the defaults.
+// A missing file is not an error and yields the defaults.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	} else if err != nil {
+		return cfg, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Default(), fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Changed returns the JSON names of the settings that differ between c and
+// other, in declaration order
+func (c Config) Changed(other Config) []string {
+	var names []string
+	cv, ov := reflect.ValueOf(c), reflect.ValueOf(other)
+	for i := 0; i < cv.NumField(); i++ {
+		if !reflect.DeepEqual(cv.Field(i).Interface(), ov.Field(i).Interface()) {
+			name, _, _ := strings.Cut(cv.Type().Field(i).Tag.Get("json"), ",")
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// InjectionDelay returns InjectionDelayMs as a duration
+func (c Config) InjectionDelay() time.Duration {
+	return time.Duration(c.InjectionDelayMs) * time.Millisecond
+}
+
+// SlowTypeDelay returns SlowTypeDelayMs as a duration
+func (c Config) SlowTypeDelay() time.Duration {
+	return time.Duration(c.SlowTypeDelayMs) * time.Millisecond
+}
+
+// SessionPause returns SessionPauseMs as a duration
+func (c Config) SessionPause() time.Duration {
+	return time.Duration(c.SessionPauseMs) * time.Millisecond
+}
+
+// AutoStopSilence returns AutoStopSilenceMs as a duration
+func (c Config) AutoStopSilence() time.Duration {
+	return time.Duration(c.AutoStopSilenceMs) * time.Millisecond
+}
+
+// BlinkInterval returns BlinkIntervalMs as a duration, never below 100ms
+func (c Config) BlinkInterval() time.Duration {
+	if c.BlinkIntervalMs < 100 {
+		return 100 * time.Millisecond
+	}
+	return time.Duration(c.BlinkIntervalMs) * time.Millisecond
+}
+
+// HotkeyDebounce returns HotkeyDebounceMs as a duration
+func (c Config) HotkeyDebounce() time.Duration {
+	return time.Duration(c.HotkeyDebounceMs) * time.Millisecond
+}
+
+// ProcessingCooldown returns ProcessingCooldownMs as a duration
+func (c Config) ProcessingCooldown() time.Duration {
+	return time.Duration(c.ProcessingCooldownMs) * time.Millisecond
+}
+
+// TailCapture returns TailCaptureMs as a duration
+func (c Config) TailCapture() time.Duration {
+	return time.Duration(c.TailCaptureMs) * time.Millisecond
+}
+
+// PreRoll returns PreRollMs as a duration
+func (c Config) PreRoll() time.Duration {
+	return time.Duration(c.PreRollMs) * time.Millisecond
+}
+
+// WakeWindow returns WakeWindowMs as a duration
+func (c Config) WakeWindow() time.Duration {
+	return time.Duration(c.WakeWindowMs) * time.Millisecond
+}
+
+// WakeInterval returns WakeIntervalMs as a duration
+func (c Config) WakeInterval() time.Duration {
+	return time.Duration(c.WakeIntervalMs) * time.Millisecond
+}
+
+// RemoteTimeout returns RemoteTimeoutSec as a duration
+func (c Config) RemoteTimeout() time.Duration {
+	return time.Duration(c.RemoteTimeoutSec) * time.Second
+}
+
+// ModelIdleTimeout returns ModelIdleTimeoutMin as a duration
+func (c Config) ModelIdleTimeout() time.Duration {
+	return time.Duration(c.ModelIdleTimeoutMin) * time.Minute
+}
+
+// ClipboardRestoreDelay returns ClipboardRestoreDelayMs as a duration
+func (c Config) ClipboardRestoreDelay() time.Duration {
+	return time.Duration(c.ClipboardRestoreDelayMs) * time.Millisecond
+}
+
+// shortcutModifiers maps the modifier names accepted in a shortcut to the
+// ones ParseShortcut returns
+var shortcutModifiers = map[string]string{
+	"cmd":     "cmd",
+	"command": "cmd",
+	"shift":   "shift",
+	"opt":     "option",
+	"option":  "option",
+	"alt":     "option",
+	"ctrl":    "control",
+	"control": "control",
+}
+
+// ParseShortcut splits a shortcut such as PasteShortcut, written as modifiers
+// and a single key joined by "+", e.g. "cmd+shift+v". It returns the
+// lowercased key and each modifier once, as "cmd", "shift", "option" or
+// "control".
+func ParseShortcut(shortcut string) (modifiers []string, key string, err error) {
+	parts := strings.Split(strings.ToLower(strings.ReplaceAll(shortcut, " ", "")), "+")
+	key = parts[len(parts)-1]
+	if utf8.RuneCountInString(key) != 1 {
+		return nil, "", fmt.Errorf("invalid shortcut %q: must end in a single key", shortcut)
+	}
+
+	for _, name := range parts[:len(parts)-1] {
+		mod, ok := shortcutModifiers[name]
+		if !ok {
+			return nil, "", fmt.Errorf("invalid shortcut %q: unknown modifier %q", shortcut, name)
+		}
+		if !slices.Contains(modifiers, mod) {
+			modifiers = append(modifiers, mod)
+		}
+	}
+	return modifiers, key, nil
+}
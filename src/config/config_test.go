@@ -0,0 +1,87 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a config file into a temp dir and returns its path
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return path
+}
+
+// TestLoad tests reading config files on top of the defaults
+func TestLoad(t *testing.T) {
+	t.Run("missing file yields defaults", func(t *testing.T) {
+		cfg, err := Load(filepath.Join(t.TempDir(), "does-not-exist.json"))
+		if err != nil {
+			t.Fatalf("Load() error = %v, want nil", err)
+		}
+		if !reflect.DeepEqual(cfg, Default()) {
+			t.Errorf("Load() = %+v, want defaults %+v", cfg, Default())
+		}
+	})
+
+	t.Run("partial file keeps other defaults", func(t *testing.T) {
+		cfg, err := Load(writeConfig(t, `{"injectionDelayMs": 250}`))
+		if err != nil {
+			t.Fatalf("Load() error = %v, want nil", err)
+		}
+		if cfg.InjectionDelayMs != 250 {
+			t.Errorf("InjectionDelayMs = %d, want 250", cfg.InjectionDelayMs)
+		}
+		if cfg.ClipboardRestoreDelayMs != Default().ClipboardRestoreDelayMs {
+			t.Errorf("ClipboardRestoreDelayMs = %d, want default %d", cfg.ClipboardRestoreDelayMs, Default().ClipboardRestoreDelayMs)
+		}
+		if cfg.InjectionDelay() != 250*time.Millisecond {
+			t.Errorf("InjectionDelay() = %v, want 250ms", cfg.InjectionDelay())
+		}
+	})
+
+	t.Run("invalid JSON returns error and defaults", func(t *testing.T) {
+		cfg, err := Load(writeConfig(t, `{"injectionDelayMs": `))
+		if err == nil {
+			t.Error("Load() error = nil, want parse error")
+		}
+		if !reflect.DeepEqual(cfg, Default()) {
+			t.Errorf("Load() = %+v, want defaults on error", cfg)
+		}
+	})
+}
+
+// TestChanged tests listing the settings that differ between two configs
+func TestChanged(t *testing.T) {
+	base := Default()
+	changed := Default()
+	changed.InjectionDelayMs = 500
+	changed.ClaudeAliases = []string{"clot", "cloud"}
+	changed.Verbose = !base.Verbose
+
+	if got := base.Changed(Default()); len(got) != 0 {
+		t.Errorf("Changed() = %v, want none for equal configs", got)
+	}
+	want := []string{"injectionDelayMs", "verbose", "claudeAliases"}
+	got := base.Changed(changed)
+	slices.Sort(got)
+	slices.Sort(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Changed() = %v, want %v", got, want)
+	}
+}
+
+// TestDefaultPath tests the GOWHISPER_CONFIG override
+func TestDefaultPath(t *testing.T) {
+	t.Setenv("GOWHISPER_CONFIG", "/tmp/custom.json")
+	if got := DefaultPath(); got != "/tmp/custom.json" {
+		t.Errorf("DefaultPath() = %q, want /tmp/custom.json", got)
+	}
+}
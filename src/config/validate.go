@@ -0,0 +1,337 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// FieldError describes one invalid config value
+type FieldError struct {
+	Field  string // JSON name of the field
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+}
+
+// rule checks one field. reset copies the field's default from d into c.
+type rule struct {
+	field string
+	check func(c Config) string // Returns the reason the value is invalid, or ""
+	reset func(c *Config, d Config)
+}
+
+// intRange checks that v is within [min, max]
+func intRange(v, min, max int) string {
+	if v < min || v > max {
+		return fmt.Sprintf("%d is out of range %d-%d", v, min, max)
+	}
+	return ""
+}
+
+// floatRange checks that v is within [min, max]
+func floatRange(v, min, max float64) string {
+	if v < min || v > max {
+		return fmt.Sprintf("%g is out of range %g-%g", v, min, max)
+	}
+	return ""
+}
+
+// oneOf checks that v is one of the allowed values
+func oneOf(v string, allowed ...string) string {
+	for _, a := range allowed {
+		if v == a {
+			return ""
+		}
+	}
+	return fmt.Sprintf("%q is not one of %s", v, strings.Join(allowed, ", "))
+}
+
+// httpURL checks that v is an absolute http or https URL
+func httpURL(v string) string {
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Sprintf("%q is not an http(s) URL", v)
+	}
+	return ""
+}
+
+// notEmpty checks that v isn't blank
+func notEmpty(v string) string {
+	if strings.TrimSpace(v) == "" {
+		return "must not be empty"
+	}
+	return ""
+}
+
+// singleWord checks that v is one word, with no spaces, and not one of the
+// built-in keywords it would be shadowed by
+func singleWord(v string) string {
+	if reason := notEmpty(v); reason != "" {
+		return reason
+	}
+	if len(strings.Fields(v)) != 1 || strings.TrimSpace(v) != v {
+		return fmt.Sprintf("%q is not a single word", v)
+	}
+	for _, builtin := range []string{"claude", "clipboard", "translate", "note", "proofread", "timestamp"} {
+		if strings.EqualFold(v, builtin) {
+			return fmt.Sprintf("%q is a built-in keyword", v)
+		}
+	}
+	return ""
+}
+
+// hotkeyID normalizes a hotkey such as "Shift + Cmd + P" for comparison:
+// the canonical names of its modifiers, sorted, and then its key
+func hotkeyID(s string) string {
+	parts := strings.Split(strings.ToLower(strings.ReplaceAll(s, " ", "")), "+")
+	key, mods := parts[len(parts)-1], parts[:len(parts)-1]
+	for i, name := range mods {
+		if canonical, ok := shortcutModifiers[name]; ok {
+			mods[i] = canonical
+		}
+	}
+	slices.Sort(mods)
+	return strings.Join(append(slices.Compact(mods), key), "+")
+}
+
+// validRegexps checks that every pattern compiles
+func validRegexps(patterns []string) string {
+	for _, p := range patterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Sprintf("%q is not a valid regular expression", p)
+		}
+	}
+	return ""
+}
+
+var rules = []rule{
+	{"injectionDelayMs",
+		func(c Config) string { return intRange(c.InjectionDelayMs, 0, 5000) },
+		func(c *Config, d Config) { c.InjectionDelayMs = d.InjectionDelayMs }},
+	{"clipboardRestoreDelayMs",
+		func(c Config) string { return intRange(c.ClipboardRestoreDelayMs, 0, 10000) },
+		func(c *Config, d Config) { c.ClipboardRestoreDelayMs = d.ClipboardRestoreDelayMs }},
+	{"autoStopSilenceMs",
+		func(c Config) string { return intRange(c.AutoStopSilenceMs, 0, 60000) },
+		func(c *Config, d Config) { c.AutoStopSilenceMs = d.AutoStopSilenceMs }},
+	{"sessionPauseMs",
+		func(c Config) string { return intRange(c.SessionPauseMs, 300, 10000) },
+		func(c *Config, d Config) { c.SessionPauseMs = d.SessionPauseMs }},
+	{"autoStopThreshold",
+		func(c Config) string { return floatRange(float64(c.AutoStopThreshold), 0, 1) },
+		func(c *Config, d Config) { c.AutoStopThreshold = d.AutoStopThreshold }},
+	{"inputGain",
+		func(c Config) string { return floatRange(float64(c.InputGain), 0.1, 20) },
+		func(c *Config, d Config) { c.InputGain = d.InputGain }},
+	{"trimThreshold",
+		func(c Config) string { return floatRange(float64(c.TrimThreshold), 0, 1) },
+		func(c *Config, d Config) { c.TrimThreshold = d.TrimThreshold }},
+	{"blinkIntervalMs",
+		func(c Config) string { return intRange(c.BlinkIntervalMs, 0, 10000) },
+		func(c *Config, d Config) { c.BlinkIntervalMs = d.BlinkIntervalMs }},
+	{"recordingIconOn",
+		func(c Config) string { return notEmpty(c.RecordingIconOn) },
+		func(c *Config, d Config) { c.RecordingIconOn = d.RecordingIconOn }},
+	{"recordingIconOff",
+		func(c Config) string { return notEmpty(c.RecordingIconOff) },
+		func(c *Config, d Config) { c.RecordingIconOff = d.RecordingIconOff }},
+	{"claudeMaxChars",
+		func(c Config) string { return intRange(c.ClaudeMaxChars, 0, 1000000) },
+		func(c *Config, d Config) { c.ClaudeMaxChars = d.ClaudeMaxChars }},
+	{"claudeMaxRatio",
+		func(c Config) string { return floatRange(c.ClaudeMaxRatio, 0, 100) },
+		func(c *Config, d Config) { c.ClaudeMaxRatio = d.ClaudeMaxRatio }},
+	{"injectionMode",
+		func(c Config) string {
+			return oneOf(c.InjectionMode, InjectionModePaste, InjectionModeKeystroke, InjectionModeSlow)
+		},
+		func(c *Config, d Config) { c.InjectionMode = d.InjectionMode }},
+	{"slowTypeChunkSize",
+		func(c Config) string { return intRange(c.SlowTypeChunkSize, 1, 1000) },
+		func(c *Config, d Config) { c.SlowTypeChunkSize = d.SlowTypeChunkSize }},
+	{"slowTypeDelayMs",
+		func(c Config) string { return intRange(c.SlowTypeDelayMs, 0, 5000) },
+		func(c *Config, d Config) { c.SlowTypeDelayMs = d.SlowTypeDelayMs }},
+	{"hotkeyDebounceMs",
+		func(c Config) string { return intRange(c.HotkeyDebounceMs, 0, 5000) },
+		func(c *Config, d Config) { c.HotkeyDebounceMs = d.HotkeyDebounceMs }},
+	{"processingCooldownMs",
+		func(c Config) string { return intRange(c.ProcessingCooldownMs, 0, 10000) },
+		func(c *Config, d Config) { c.ProcessingCooldownMs = d.ProcessingCooldownMs }},
+	{"timestampFormat",
+		func(c Config) string { return notEmpty(c.TimestampFormat) },
+		func(c *Config, d Config) { c.TimestampFormat = d.TimestampFormat }},
+	{"logFormat",
+		func(c Config) string { return oneOf(c.LogFormat, LogFormatText, LogFormatJSON) },
+		func(c *Config, d Config) { c.LogFormat = d.LogFormat }},
+	{"logLevel",
+		func(c Config) string {
+			return oneOf(c.LogLevel, LogLevelError, LogLevelWarn, LogLevelInfo, LogLevelDebug)
+		},
+		func(c *Config, d Config) { c.LogLevel = d.LogLevel }},
+	{"modelIdleTimeoutMin",
+		func(c Config) string { return intRange(c.ModelIdleTimeoutMin, 0, 24*60) },
+		func(c *Config, d Config) { c.ModelIdleTimeoutMin = d.ModelIdleTimeoutMin }},
+	{"metricsPort",
+		func(c Config) string { return intRange(c.MetricsPort, 0, 65535) },
+		func(c *Config, d Config) { c.MetricsPort = d.MetricsPort }},
+	{"preRollMs",
+		func(c Config) string { return intRange(c.PreRollMs, 0, 10000) },
+		func(c *Config, d Config) { c.PreRollMs = d.PreRollMs }},
+	{"wakeWindowMs",
+		func(c Config) string { return intRange(c.WakeWindowMs, 1000, 10000) },
+		func(c *Config, d Config) { c.WakeWindowMs = d.WakeWindowMs }},
+	{"wakeIntervalMs",
+		func(c Config) string { return intRange(c.WakeIntervalMs, 250, 10000) },
+		func(c *Config, d Config) { c.WakeIntervalMs = d.WakeIntervalMs }},
+	{"tailCaptureMs",
+		func(c Config) string { return intRange(c.TailCaptureMs, 0, 2000) },
+		func(c *Config, d Config) { c.TailCaptureMs = d.TailCaptureMs }},
+	{"transcribeBackend",
+		func(c Config) string {
+			return oneOf(c.TranscribeBackend, TranscribeBackendLocal, TranscribeBackendRemote)
+		},
+		func(c *Config, d Config) { c.TranscribeBackend = d.TranscribeBackend }},
+	{"remoteURL",
+		func(c Config) string { return httpURL(c.RemoteURL) },
+		func(c *Config, d Config) { c.RemoteURL = d.RemoteURL }},
+	{"remoteTimeoutSec",
+		func(c Config) string { return intRange(c.RemoteTimeoutSec, 1, 600) },
+		func(c *Config, d Config) { c.RemoteTimeoutSec = d.RemoteTimeoutSec }},
+	{"temperature",
+		func(c Config) string { return floatRange(float64(c.Temperature), 0, 1) },
+		func(c *Config, d Config) { c.Temperature = d.Temperature }},
+	{"temperatureFallback",
+		func(c Config) string { return floatRange(float64(c.TemperatureFallback), 0, 1) },
+		func(c *Config, d Config) { c.TemperatureFallback = d.TemperatureFallback }},
+	{"quietMicRecordings",
+		func(c Config) string { return intRange(c.QuietMicRecordings, 0, 100) },
+		func(c *Config, d Config) { c.QuietMicRecordings = d.QuietMicRecordings }},
+	{"quietMicThreshold",
+		func(c Config) string { return floatRange(float64(c.QuietMicThreshold), 0, 1) },
+		func(c *Config, d Config) { c.QuietMicThreshold = d.QuietMicThreshold }},
+	{"autoRephraseConfidence",
+		func(c Config) string { return floatRange(float64(c.AutoRephraseConfidence), 0, 1) },
+		func(c *Config, d Config) { c.AutoRephraseConfidence = d.AutoRephraseConfidence }},
+	{"claudeOutputMode",
+		func(c Config) string {
+			return oneOf(c.ClaudeOutputMode, ClaudeOutputReplace, ClaudeOutputBelow, ClaudeOutputAbove)
+		},
+		func(c *Config, d Config) { c.ClaudeOutputMode = d.ClaudeOutputMode }},
+	{"claudeClipboardType",
+		func(c Config) string {
+			return oneOf(c.ClaudeClipboardType, ClaudeClipboardTypeNone, ClaudeClipboardTypeRephrased, ClaudeClipboardTypeOriginal)
+		},
+		func(c *Config, d Config) { c.ClaudeClipboardType = d.ClaudeClipboardType }},
+	{"clipboardAccumulateMaxChars",
+		func(c Config) string { return intRange(c.ClipboardAccumulateMaxChars, 0, 10000000) },
+		func(c *Config, d Config) { c.ClipboardAccumulateMaxChars = d.ClipboardAccumulateMaxChars }},
+	{"outputTarget",
+		func(c Config) string { return oneOf(c.OutputTarget, OutputTargetWindow, OutputTargetURL) },
+		func(c *Config, d Config) { c.OutputTarget = d.OutputTarget }},
+	{"outputURLTemplate",
+		func(c Config) string {
+			if !strings.Contains(c.OutputURLTemplate, "{text}") {
+				return `must contain "{text}"`
+			}
+			return ""
+		},
+		func(c *Config, d Config) { c.OutputURLTemplate = d.OutputURLTemplate }},
+	{"maxOutputChars",
+		func(c Config) string { return intRange(c.MaxOutputChars, 0, 10000000) },
+		func(c *Config, d Config) { c.MaxOutputChars = d.MaxOutputChars }},
+	{"actionHotkeys",
+		func(c Config) string {
+			for _, h := range c.ActionHotkeys {
+				if reason := notEmpty(h.Hotkey); reason != "" {
+					return "hotkey " + reason
+				}
+				if reason := oneOf(h.Action, ActionPlain, ActionClipboard, ActionRephrase, ActionSession); reason != "" {
+					return reason
+				}
+			}
+			return ""
+		},
+		func(c *Config, d Config) { c.ActionHotkeys = d.ActionHotkeys }},
+	{"recordingHotkey",
+		func(c Config) string {
+			if reason := notEmpty(c.RecordingHotkey); reason != "" {
+				return reason
+			}
+			for _, h := range c.ActionHotkeys {
+				if hotkeyID(h.Hotkey) == hotkeyID(c.RecordingHotkey) {
+					return fmt.Sprintf("%q is also the hotkey of the %s action", c.RecordingHotkey, h.Action)
+				}
+			}
+			return ""
+		},
+		func(c *Config, d Config) { c.RecordingHotkey = d.RecordingHotkey }},
+	{"keepRecordings",
+		func(c Config) string { return intRange(c.KeepRecordings, 0, 1000) },
+		func(c *Config, d Config) { c.KeepRecordings = d.KeepRecordings }},
+	{"discardShorterThan",
+		func(c Config) string { return intRange(c.DiscardShorterThan, 0, 20) },
+		func(c *Config, d Config) { c.DiscardShorterThan = d.DiscardShorterThan }},
+	{"wavFormat",
+		func(c Config) string { return oneOf(c.WAVFormat, "int16", "int24", "float32") },
+		func(c *Config, d Config) { c.WAVFormat = d.WAVFormat }},
+	{"keywordCommands",
+		func(c Config) string {
+			for _, k := range c.KeywordCommands {
+				if reason := singleWord(k.Keyword); reason != "" {
+					return "keyword " + reason
+				}
+				if reason := notEmpty(k.Command); reason != "" {
+					return "command " + reason
+				}
+				if reason := intRange(k.TimeoutSec, 0, 600); reason != "" {
+					return "timeoutSec " + reason
+				}
+			}
+			return ""
+		},
+		func(c *Config, d Config) { c.KeywordCommands = d.KeywordCommands }},
+	{"pasteShortcut",
+		func(c Config) string {
+			if _, _, err := ParseShortcut(c.PasteShortcut); err != nil {
+				return err.Error()
+			}
+			return ""
+		},
+		func(c *Config, d Config) { c.PasteShortcut = d.PasteShortcut }},
+	{"suppressPatterns",
+		func(c Config) string { return validRegexps(c.SuppressPatterns) },
+		func(c *Config, d Config) { c.SuppressPatterns = d.SuppressPatterns }},
+}
+
+// Validate checks enum values and numeric ranges. It returns all problems
+// joined with errors.Join, each a *FieldError, or nil if the config is valid.
+func (c Config) Validate() error {
+	var errs []error
+	for _, r := range rules {
+		if reason := r.check(c); reason != "" {
+			errs = append(errs, &FieldError{Field: r.field, Reason: reason})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Repair returns a copy of c with every invalid field reset to its default,
+// together with the problems found (see Validate)
+func (c Config) Repair() (Config, error) {
+	d := Default()
+	var errs []error
+	for _, r := range rules {
+		if reason := r.check(c); reason != "" {
+			errs = append(errs, &FieldError{Field: r.field, Reason: reason})
+			r.reset(&c, d)
+		}
+	}
+	return c, errors.Join(errs...)
+}
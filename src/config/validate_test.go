@@ -0,0 +1,131 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestValidateDefaults tests that the defaults pass validation
+func TestValidateDefaults(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Errorf("Default().Validate() = %v, want nil", err)
+	}
+}
+
+// TestValidateRules tests each validation rule with a bad and a good value
+func TestValidateRules(t *testing.T) {
+	tests := []struct {
+		field string
+		bad   func(c *Config)
+		good  func(c *Config)
+	}{
+		{"injectionDelayMs", func(c *Config) { c.InjectionDelayMs = -1 }, func(c *Config) { c.InjectionDelayMs = 0 }},
+		{"clipboardRestoreDelayMs", func(c *Config) { c.ClipboardRestoreDelayMs = 60000 }, func(c *Config) { c.ClipboardRestoreDelayMs = 500 }},
+		{"autoStopSilenceMs", func(c *Config) { c.AutoStopSilenceMs = -100 }, func(c *Config) { c.AutoStopSilenceMs = 1500 }},
+		{"autoStopThreshold", func(c *Config) { c.AutoStopThreshold = 1.5 }, func(c *Config) { c.AutoStopThreshold = 0.02 }},
+		{"inputGain", func(c *Config) { c.InputGain = 0 }, func(c *Config) { c.InputGain = 2.5 }},
+		{"trimThreshold", func(c *Config) { c.TrimThreshold = -0.1 }, func(c *Config) { c.TrimThreshold = 0 }},
+		{"blinkIntervalMs", func(c *Config) { c.BlinkIntervalMs = -5 }, func(c *Config) { c.BlinkIntervalMs = 300 }},
+		{"recordingIconOn", func(c *Config) { c.RecordingIconOn = " " }, func(c *Config) { c.RecordingIconOn = "●" }},
+		{"recordingIconOff", func(c *Config) { c.RecordingIconOff = "" }, func(c *Config) { c.RecordingIconOff = "○" }},
+		{"claudeMaxChars", func(c *Config) { c.ClaudeMaxChars = -1 }, func(c *Config) { c.ClaudeMaxChars = 2000 }},
+		{"claudeMaxRatio", func(c *Config) { c.ClaudeMaxRatio = -2 }, func(c *Config) { c.ClaudeMaxRatio = 0 }},
+		{"injectionMode", func(c *Config) { c.InjectionMode = "Paste" }, func(c *Config) { c.InjectionMode = "keystroke" }},
+		{"slowTypeChunkSize", func(c *Config) { c.SlowTypeChunkSize = 0 }, func(c *Config) { c.SlowTypeChunkSize = 1 }},
+		{"slowTypeDelayMs", func(c *Config) { c.SlowTypeDelayMs = -10 }, func(c *Config) { c.SlowTypeDelayMs = 200 }},
+		{"hotkeyDebounceMs", func(c *Config) { c.HotkeyDebounceMs = 10000 }, func(c *Config) { c.HotkeyDebounceMs = 0 }},
+		{"processingCooldownMs", func(c *Config) { c.ProcessingCooldownMs = -1 }, func(c *Config) { c.ProcessingCooldownMs = 1000 }},
+		{"timestampFormat", func(c *Config) { c.TimestampFormat = "" }, func(c *Config) { c.TimestampFormat = "Mon 15:04" }},
+		{"logFormat", func(c *Config) { c.LogFormat = "yaml" }, func(c *Config) { c.LogFormat = "json" }},
+		{"logLevel", func(c *Config) { c.LogLevel = "verbose" }, func(c *Config) { c.LogLevel = "debug" }},
+		{"modelIdleTimeoutMin", func(c *Config) { c.ModelIdleTimeoutMin = -1 }, func(c *Config) { c.ModelIdleTimeoutMin = 30 }},
+		{"metricsPort", func(c *Config) { c.MetricsPort = 70000 }, func(c *Config) { c.MetricsPort = 9464 }},
+		{"tailCaptureMs", func(c *Config) { c.TailCaptureMs = -1 }, func(c *Config) { c.TailCaptureMs = 500 }},
+		{"preRollMs", func(c *Config) { c.PreRollMs = 20000 }, func(c *Config) { c.PreRollMs = 500 }},
+		{"wakeWindowMs", func(c *Config) { c.WakeWindowMs = 100 }, func(c *Config) { c.WakeWindowMs = 3000 }},
+		{"wakeIntervalMs", func(c *Config) { c.WakeIntervalMs = 0 }, func(c *Config) { c.WakeIntervalMs = 500 }},
+		{"temperature", func(c *Config) { c.Temperature = 2 }, func(c *Config) { c.Temperature = 0.4 }},
+		{"temperatureFallback", func(c *Config) { c.TemperatureFallback = -0.2 }, func(c *Config) { c.TemperatureFallback = 0 }},
+		{"quietMicRecordings", func(c *Config) { c.QuietMicRecordings = -1 }, func(c *Config) { c.QuietMicRecordings = 0 }},
+		{"quietMicThreshold", func(c *Config) { c.QuietMicThreshold = 2 }, func(c *Config) { c.QuietMicThreshold = 0.005 }},
+		{"autoRephraseConfidence", func(c *Config) { c.AutoRephraseConfidence = 1.5 }, func(c *Config) { c.AutoRephraseConfidence = 0.6 }},
+		{"claudeOutputMode", func(c *Config) { c.ClaudeOutputMode = "insert" }, func(c *Config) { c.ClaudeOutputMode = "below" }},
+		{"claudeClipboardType", func(c *Config) { c.ClaudeClipboardType = "both" }, func(c *Config) { c.ClaudeClipboardType = "original" }},
+		{"clipboardAccumulateMaxChars", func(c *Config) { c.ClipboardAccumulateMaxChars = -1 }, func(c *Config) { c.ClipboardAccumulateMaxChars = 0 }},
+		{"transcribeBackend", func(c *Config) { c.TranscribeBackend = "cloud" }, func(c *Config) { c.TranscribeBackend = "remote" }},
+		{"remoteURL", func(c *Config) { c.RemoteURL = "desktop.local:8080" }, func(c *Config) { c.RemoteURL = "https://desktop.local/inference" }},
+		{"remoteTimeoutSec", func(c *Config) { c.RemoteTimeoutSec = 0 }, func(c *Config) { c.RemoteTimeoutSec = 120 }},
+		{"outputTarget", func(c *Config) { c.OutputTarget = "drafts" }, func(c *Config) { c.OutputTarget = "url" }},
+		{"outputURLTemplate", func(c *Config) { c.OutputURLTemplate = "drafts://create" }, func(c *Config) { c.OutputURLTemplate = "bear://x-callback-url/create?text={text}" }},
+		{"maxOutputChars", func(c *Config) { c.MaxOutputChars = -1 }, func(c *Config) { c.MaxOutputChars = 0 }},
+		{"sessionPauseMs", func(c *Config) { c.SessionPauseMs = 100 }, func(c *Config) { c.SessionPauseMs = 2000 }},
+		{"actionHotkeys", func(c *Config) { c.ActionHotkeys = []ActionHotkey{{"cmd+shift+c", "copy"}} }, func(c *Config) { c.ActionHotkeys = []ActionHotkey{{"cmd+shift+c", ActionClipboard}} }},
+		{"keepRecordings", func(c *Config) { c.KeepRecordings = -1 }, func(c *Config) { c.KeepRecordings = 5 }},
+		{"discardShorterThan", func(c *Config) { c.DiscardShorterThan = -1 }, func(c *Config) { c.DiscardShorterThan = 3 }},
+		{"wavFormat", func(c *Config) { c.WAVFormat = "mp3" }, func(c *Config) { c.WAVFormat = "float32" }},
+		{"keywordCommands", func(c *Config) { c.KeywordCommands = []KeywordCommand{{"clipboard", "echo {text}", 0}} }, func(c *Config) { c.KeywordCommands = []KeywordCommand{{"search", "echo {text}", 5}} }},
+		{"recordingHotkey", func(c *Config) {
+			c.ActionHotkeys = []ActionHotkey{{Hotkey: "Shift + Command + P", Action: ActionClipboard}}
+		}, func(c *Config) { c.RecordingHotkey = "ctrl+option+space" }},
+		{"pasteShortcut", func(c *Config) { c.PasteShortcut = "cmd+paste" }, func(c *Config) { c.PasteShortcut = "cmd+shift+v" }},
+		{"suppressPatterns", func(c *Config) { c.SuppressPatterns = []string{"(unclosed"} }, func(c *Config) { c.SuppressPatterns = nil }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			bad := Default()
+			tt.bad(&bad)
+			err := bad.Validate()
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) || fieldErr.Field != tt.field {
+				t.Errorf("Validate() = %v, want FieldError for %s", err, tt.field)
+			}
+
+			good := Default()
+			tt.good(&good)
+			if err := good.Validate(); err != nil {
+				t.Errorf("Validate() = %v, want nil", err)
+			}
+		})
+	}
+}
+
+// TestValidateAggregates tests that all problems are reported at once
+func TestValidateAggregates(t *testing.T) {
+	c := Default()
+	c.InjectionMode = "typing"
+	c.LogFormat = "xml"
+	c.Temperature = -1
+
+	err := c.Validate()
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("Validate() = %v, want joined errors", err)
+	}
+	if got := len(joined.Unwrap()); got != 3 {
+		t.Errorf("Validate() reported %d problems, want 3: %v", got, err)
+	}
+}
+
+// TestRepair tests that only invalid fields are reset to their defaults
+func TestRepair(t *testing.T) {
+	c := Default()
+	c.InjectionMode = "typing"
+	c.InjectionDelayMs = 250
+	c.PreRollMs = -1
+
+	repaired, err := c.Repair()
+	if err == nil {
+		t.Error("Repair() error = nil, want the problems found")
+	}
+
+	want := Default()
+	want.InjectionDelayMs = 250
+	if !reflect.DeepEqual(repaired, want) {
+		t.Errorf("Repair() = %+v, want %+v", repaired, want)
+	}
+	if err := repaired.Validate(); err != nil {
+		t.Errorf("repaired config still invalid: %v", err)
+	}
+}
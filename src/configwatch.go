@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/stephanwesten/go-whisper/src/audio"
+	"github.com/stephanwesten/go-whisper/src/config"
+)
+
+// configPollInterval is how often the config file is checked for changes
+const configPollInterval = 2 * time.Second
+
+// restartSettings are only read at startup. A reload keeps their running
+// values and logs that a restart is needed to apply them.
+var restartSettings = map[string]func(c *config.Config, running config.Config){
+	"logFormat":            func(c *config.Config, r config.Config) { c.LogFormat = r.LogFormat },
+	"verbose":              func(c *config.Config, r config.Config) { c.Verbose = r.Verbose },
+	"history":              func(c *config.Config, r config.Config) { c.History = r.History },
+	"historyEncrypt":       func(c *config.Config, r config.Config) { c.HistoryEncrypt = r.HistoryEncrypt },
+	"modelIdleTimeoutMin":  func(c *config.Config, r config.Config) { c.ModelIdleTimeoutMin = r.ModelIdleTimeoutMin },
+	"recordingHotkey":      func(c *config.Config, r config.Config) { c.RecordingHotkey = r.RecordingHotkey },
+	"rephraseToggleHotkey": func(c *config.Config, r config.Config) { c.RephraseToggleHotkey = r.RephraseToggleHotkey },
+	"metricsPort":          func(c *config.Config, r config.Config) { c.MetricsPort = r.MetricsPort },
+	"wakePhrase":           func(c *config.Config, r config.Config) { c.WakePhrase = r.WakePhrase },
+	"wakeWindowMs":         func(c *config.Config, r config.Config) { c.WakeWindowMs = r.WakeWindowMs },
+	"wakeIntervalMs":       func(c *config.Config, r config.Config) { c.WakeIntervalMs = r.WakeIntervalMs },
+}
+
+// fileStamp identifies a version of a file well enough to notice edits
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// statFile returns the stamp of the file at path, and false if it can't be read
+func statFile(path string) (fileStamp, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, false
+	}
+	return fileStamp{info.ModTime(), info.Size()}, true
+}
+
+// watchConfig signals changed each time the file at path is modified. It polls
+// the modification time and size, which also catches editors that save by
+// replacing the file. A missing file is ignored, so a config that is briefly
+// gone mid-save doesn't fall back to the defaults. Returns when ctx is
+// cancelled.
+func watchConfig(ctx context.Context, path string, interval time.Duration, changed chan<- struct{}) {
+	last, _ := statFile(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		stamp, ok := statFile(path)
+		if !ok || stamp == last {
+			continue
+		}
+		last = stamp
+		// A reload already pending will read the latest file anyway
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// reloadConfig reads the config file at path again and applies it while the
+// app keeps running. Call it while Idle, from the goroutine that runs the
+// dictations. A file that can't be parsed leaves the current settings alone.
+func reloadConfig(path string, triggerCh chan<- string) {
+	loaded, err := config.Load(path)
+	if err != nil {
+		log.Printf("Warning: %v (keeping the current settings)", err)
+		return
+	}
+	if repaired, err := loaded.Repair(); err != nil {
+		loaded = repaired
+		log.Printf("Warning: invalid config, using defaults for:\n%v", err)
+	}
+
+	running := *cfg()
+	var applied, restart []string
+	for _, name := range running.Changed(loaded) {
+		if keep, ok := restartSettings[name]; ok {
+			keep(&loaded, running)
+			restart = append(restart, name)
+		} else {
+			applied = append(applied, name)
+		}
+	}
+	if len(applied) == 0 && len(restart) == 0 {
+		return
+	}
+
+	setCfg(loaded)
+	applyConfig(running, triggerCh)
+	if len(applied) > 0 {
+		log.Printf("Config reloaded from %s, applied: %s", path, strings.Join(applied, ", "))
+	}
+	if len(restart) > 0 {
+		log.Printf("Warning: restart GoWhisper to apply: %s", strings.Join(restart, ", "))
+	}
+}
+
+// applyConfig updates the parts of the app set up from the config at startup
+// after cfg changed from previous
+func applyConfig(previous config.Config, triggerCh chan<- string) {
+	changed := previous.Changed(*cfg())
+	anyChanged := func(names ...string) bool {
+		return slices.ContainsFunc(names, func(name string) bool { return slices.Contains(changed, name) })
+	}
+
+	if anyChanged("logLevel") {
+		setLogLevel(cfg().LogLevel)
+	}
+
+	if recorder != nil {
+		if anyChanged("autoStopSilenceMs", "autoStopThreshold") {
+			recorder.SetAutoStop(cfg().AutoStopThreshold, cfg().AutoStopSilence())
+		}
+		if anyChanged("inputGain") {
+			recorder.SetInputGain(cfg().InputGain)
+		}
+		if anyChanged("preRollMs") {
+			if err := recorder.SetPreRoll(cfg().PreRoll()); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+		if anyChanged("keepMicOpen") {
+			if err := recorder.SetKeepStreamOpen(cfg().KeepMicOpen); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+	}
+	if anyChanged("quietMicRecordings", "quietMicThreshold") {
+		levelMonitor = audio.NewLevelMonitor(cfg().QuietMicRecordings, cfg().QuietMicThreshold)
+	}
+	if anyChanged("temperature", "temperatureFallback", "singleSegment", "noContext", "segmentSeparator", "suppressPhrases", "suppressPatterns") {
+		transcriberMu.Lock()
+		if transcriber != nil {
+			configureTranscriber(transcriber)
+		}
+		transcriberMu.Unlock()
+	}
+	if !reflect.DeepEqual(previous.ActionHotkeys, cfg().ActionHotkeys) {
+		replaceActionHotkeys(triggerCh)
+	}
+}
+
+// replaceActionHotkeys unregisters the action hotkeys and registers the ones
+// now in cfg.ActionHotkeys, leaving them unregistered while the hotkey is
+// disabled
+func replaceActionHotkeys(triggerCh chan<- string) {
+	hotkeyRegMu.Lock()
+	defer hotkeyRegMu.Unlock()
+
+	enabled := isHotkeyEnabled()
+	if enabled {
+		setActionHotkeysRegistered(false)
+	}
+	stopActionHotkeys()
+	actionHotkeys = nil
+	registerActionHotkeys(triggerCh)
+	if !enabled {
+		setActionHotkeysRegistered(false)
+	}
+}
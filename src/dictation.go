@@ -0,0 +1,1292 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/getlantern/systray"
+	"github.com/stephanwesten/go-whisper/src/audio"
+	"github.com/stephanwesten/go-whisper/src/config"
+	"github.com/stephanwesten/go-whisper/src/notes"
+	"github.com/stephanwesten/go-whisper/src/paths"
+	"github.com/stephanwesten/go-whisper/src/postprocess"
+	"github.com/stephanwesten/go-whisper/src/textcase"
+	"github.com/stephanwesten/go-whisper/src/whisper"
+)
+
+// The dictation flow reaches the microphone, Whisper, Claude and the menu bar
+// only through the interfaces and variables below, so tests can run it end to
+// end with fakes. Text injection already goes through injector.
+
+// speechRecorder records from the microphone, see audio.Recorder
+type speechRecorder interface {
+	Start() error
+	Stop() ([]float32, error)
+	SetAutoStop(threshold float32, silence time.Duration)
+}
+
+// speechTranscriber converts recorded audio to text, see whisper.Transcriber
+// and whisper.RemoteTranscriber
+type speechTranscriber interface {
+	TranscribeContext(ctx context.Context, samples []float32, translate bool, onSegment func(whisper.Segment)) (string, error)
+	LastTimings() whisper.Timings
+	LastConfidence() float32
+}
+
+// statusUI is the part of the menu bar the dictation flow updates
+type statusUI interface {
+	// SetIcon sets the menu bar title used as the app icon
+	SetIcon(title string)
+	// SetStatus, ShowStatus and HideStatus control the status line in the menu
+	SetStatus(title string)
+	ShowStatus()
+	HideStatus()
+	// SetHotkeyTitle sets the label of the start/stop menu item
+	SetHotkeyTitle(title string)
+	StartRecordingAnimation()
+	StopRecordingAnimation()
+	// ShowAccessWarning shows or hides the degraded mode, see accessDenied
+	ShowAccessWarning(show bool)
+}
+
+var (
+	// dictationRecorder is the recorder created in onReady
+	dictationRecorder speechRecorder
+	// loadTranscriber returns the Whisper transcriber, reloading it if it was
+	// released, or the whisper.cpp server with the remote backend
+	loadTranscriber = func() (speechTranscriber, error) {
+		if cfg().TranscribeBackend == config.TranscribeBackendRemote {
+			return newRemoteTranscriber(), nil
+		}
+		t, err := acquireTranscriber()
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+	// rephraseText sends text to Claude, stopping early when ctx is cancelled
+	rephraseText = rephraseWithClaude
+	// runCommand runs the command bound to a keyword, see config.KeywordCommands
+	runCommand = runKeywordCommand
+	// correctSpelling fixes obvious typos with a local spell checker
+	correctSpelling = postprocess.SpellCheck
+	// ui is the menu bar
+	ui statusUI = trayUI{}
+	// processInBackground hands a dictation to the dictation worker, so
+	// neither the trigger loop nor the menu waits for Whisper and Claude
+	processInBackground = func(work func()) {
+		dictationWorkerOnce.Do(func() { go runDictationWorker() })
+		dictationWork <- work
+	}
+)
+
+var (
+	// dictationWork feeds the dictation worker. The state machine allows one
+	// dictation in Processing at a time, so a send never waits long.
+	dictationWork       = make(chan func(), 1)
+	dictationWorkerOnce sync.Once
+	// dictationDone receives a value after the worker finished a dictation
+	dictationDone = make(chan struct{}, 1)
+	// pasteSettleDelay gives the target app time to take typed text before
+	// pasteWasAccepted looks at the focused element
+	pasteSettleDelay = 150 * time.Millisecond
+	// appSwitchDelay lets cfg.TargetApp come to the front before typing, and
+	// take the text before the focus returns
+	appSwitchDelay = 200 * time.Millisecond
+)
+
+// runDictationWorker processes dictations one at a time while the app runs
+func runDictationWorker() {
+	for work := range dictationWork {
+		work()
+		select {
+		case dictationDone <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// trayUI is the systray implementation of statusUI
+type trayUI struct{}
+
+func (trayUI) SetStatus(title string)      { mStatus.SetTitle(title) }
+func (trayUI) ShowStatus()                 { mStatus.Show() }
+func (trayUI) HideStatus()                 { mStatus.Hide() }
+func (trayUI) SetHotkeyTitle(title string) { mHotkey.SetTitle(title) }
+func (trayUI) StartRecordingAnimation()    { startRecordingAnimation() }
+func (trayUI) StopRecordingAnimation()     { stopRecordingAnimation() }
+
+// SetIcon keeps the degraded mode visible next to whatever icon is shown
+func (trayUI) SetIcon(title string) {
+	if isAccessDenied() {
+		title += accessDeniedIcon
+	}
+	systray.SetTitle(title)
+}
+
+// ShowAccessWarning is only called while idle, so the icon is the idle one
+func (u trayUI) ShowAccessWarning(show bool) {
+	if show {
+		mAccess.Show()
+	} else {
+		mAccess.Hide()
+	}
+	u.SetIcon("◉")
+}
+
+// startDictation starts recording and types the recording indicator.
+// The caller has already moved the state from Idle to Recording.
+func startDictation() {
+	// Start recording
+	log.Println("Starting recording...")
+	ui.StartRecordingAnimation()
+	ui.SetHotkeyTitle("⌘⇧P - Stop Recording")
+	if isSessionActive() {
+		ui.SetStatus("🎤 Continuous dictation... (⌘⇧P to end)")
+	} else {
+		ui.SetStatus("🎤 Recording...")
+	}
+	ui.ShowStatus()
+
+	if err := dictationRecorder.Start(); err != nil {
+		endSession()
+		log.Printf("Error starting recording: %v", err)
+		ui.StopRecordingAnimation()
+		ui.SetIcon("◉")
+		ui.SetHotkeyTitle("⌘⇧P - Start Recording")
+		noMicrophone := errors.Is(err, audio.ErrNoInputDevice)
+		if noMicrophone {
+			ui.SetStatus("Error: No microphone detected")
+		} else {
+			ui.SetStatus("Error: Failed to start")
+		}
+		ui.ShowStatus()
+		setState(StateIdle)
+		if noMicrophone {
+			showErrorDialog("GoWhisper - No Microphone",
+				"No microphone was detected.\n\n"+
+					"Connect a microphone or enable one in System Settings → Sound → Input, then try again.")
+		}
+		return
+	}
+
+	log.Printf("Recording started - press %s again to stop", hotkeyLabel(recordingHotkeyName()))
+
+	// Add delay before sending indicator text to ensure the hotkey
+	// is fully released before AppleScript types. Without this delay, the modifier keys
+	// may still be pressed when keystroke injection occurs, causing incorrect characters.
+	time.Sleep(cfg().InjectionDelay())
+	if err := typeIndicator(recordingIndicator); err != nil {
+		log.Printf("Error sending recording indicator: %v", err)
+	}
+}
+
+// Errors a dictation can fail with, wrapped in dictationResult.Err
+var (
+	errStopRecording = errors.New("failed to stop recording")
+	errLoadModel     = errors.New("failed to load model")
+	errTranscribe    = errors.New("transcription failed")
+	errRephrase      = errors.New("claude rephrasing failed")
+	errCommand       = errors.New("keyword command failed")
+	errSaveNote      = errors.New("failed to save note")
+	errCopy          = errors.New("failed to copy")
+	errType          = errors.New("failed to type")
+	errOpenURL       = errors.New("failed to open URL")
+	errCancelled     = errors.New("dictation cancelled")
+)
+
+// dictationAction is what a dictation did with its text
+type dictationAction string
+
+const (
+	actionNone      dictationAction = "none"      // Nothing was output
+	actionType      dictationAction = "type"      // Typed into the active window
+	actionClipboard dictationAction = "clipboard" // Copied to the clipboard
+	actionNote      dictationAction = "note"      // Appended to the notes file
+	actionURL       dictationAction = "url"       // Handed to an app through cfg.OutputURLTemplate
+	actionUndo      dictationAction = "undo"      // Deleted the previous dictation, see cfg.UndoPhrases
+)
+
+// dictationResult is the outcome of one recording going through runDictation
+type dictationResult struct {
+	RawText     string   // Whisper's transcription before any processing
+	Text        string   // The text that was output
+	Keywords    []string // Keywords detected in RawText: claude, clipboard, translate, note, proofread, timestamp or a command keyword
+	Rephrased   bool     // Text was sent to Claude, even if the call was cancelled
+	Action      dictationAction
+	Degraded    bool        // Audio was dropped while recording
+	NoTranslate bool        // The translate keyword was said, but the model can't translate
+	NotPasted   bool        // The focused app ignored the typed text, it's on the clipboard instead
+	NoAccess    bool        // Copied to the clipboard because typing isn't allowed, see accessDenied
+	NoTarget    bool        // Copied to the clipboard because cfg.TargetApp couldn't be activated
+	QuietMic    bool        // Recent recordings were all near-silent
+	Empty       emptyReason // Why nothing was transcribed, if so
+	SavedAudio  string      // WAV file the recording was kept in after Whisper failed, if any
+	Recording   string      // WAV file in the recordings directory, see cfg.KeepRecordings
+	Timings     whisper.Timings
+	Err         error // Wraps one of the err values above; Action is actionNone
+}
+
+// emptyReason says why a dictation produced no text
+type emptyReason int
+
+const (
+	emptyNone     emptyReason = iota
+	emptySilent               // The recording was near-silent
+	emptyNoSpeech             // There was sound, but Whisper recognized no speech in it
+)
+
+// emptyStatus returns the status line explaining an empty dictation, or ""
+func emptyStatus(reason emptyReason) string {
+	switch reason {
+	case emptySilent:
+		return "Nothing heard, check the microphone"
+	case emptyNoSpeech:
+		return "No speech recognized"
+	}
+	return ""
+}
+
+// dictationStage is a step of runDictation worth showing to the user
+type dictationStage int
+
+const (
+	stageLoadingModel dictationStage = iota
+	stageTranscribing
+	stageTranslating
+	stageRephrasing
+	stageRunningCommand
+	stageSavingNote
+	stageCopying
+	stageTyping
+	stageOpeningURL
+)
+
+// finishDictation stops recording and hands the dictation to the dictation
+// worker, which shows the result and returns to Idle when done. The caller
+// has already moved the state from Recording to Processing.
+func finishDictation() {
+	log.Println("Stopping recording...")
+	ui.StopRecordingAnimation()
+	ui.SetIcon("◉")
+	ui.SetStatus("Processing...")
+	ui.ShowStatus()
+	log.Println("⏳ Processing transcription...")
+
+	// Set up before the worker starts, so Cancel Dictation works right away
+	ctx, cancel := context.WithCancel(context.Background())
+	setDictationCancel(cancel)
+	action := getRecordingAction()
+	processInBackground(func() {
+		processDictation(ctx, cancel, action)
+	})
+}
+
+// dictationProgress is a step of runDictation on its way to the menu bar
+type dictationProgress struct {
+	stage    dictationStage
+	segments int
+}
+
+// processDictation runs the dictation on the worker and shows its result.
+// Progress goes to the menu bar through a channel, so slow menu bar updates
+// never hold up Whisper's segment callback.
+func processDictation(ctx context.Context, cancel context.CancelFunc, action string) {
+	progressCh := make(chan dictationProgress, 16)
+	drained := make(chan struct{})
+	go func() {
+		for p := range progressCh {
+			showDictationProgress(p.stage, p.segments)
+		}
+		close(drained)
+	}()
+
+	res := runDictation(ctx, dictationRecorder, action, func(stage dictationStage, segments int) {
+		progressCh <- dictationProgress{stage, segments}
+	})
+
+	// Let the last progress update land before the result replaces it
+	close(progressCh)
+	<-drained
+
+	// Done before a continuous session records again and sets up the next one
+	setDictationCancel(nil)
+	cancel()
+	metrics.record(res)
+	showDictationResult(res)
+}
+
+// setDictationCancel records the cancel func of the dictation being
+// processed, nil when it finished (thread-safe)
+func setDictationCancel(cancel context.CancelFunc) {
+	dictationCancelMu.Lock()
+	defer dictationCancelMu.Unlock()
+	dictationCancel = cancel
+}
+
+// cancelDictation abandons the dictation being processed without outputting
+// anything, returning false if there is none (thread-safe)
+func cancelDictation() bool {
+	dictationCancelMu.Lock()
+	defer dictationCancelMu.Unlock()
+	if dictationCancel == nil {
+		return false
+	}
+	log.Println("Cancelling dictation")
+	dictationCancel()
+	dictationCancel = nil
+	return true
+}
+
+// showDictationResult shows the outcome of a dictation and returns to Idle,
+// or straight to Recording in a continuous session
+func showDictationResult(res dictationResult) {
+	ui.SetIcon("◉")
+	ui.SetHotkeyTitle("⌘⇧P - Start Recording")
+	if res.QuietMic {
+		go showErrorDialog("GoWhisper - Microphone Too Quiet",
+			fmt.Sprintf("Your last %d recordings were almost completely silent.\n\n"+
+				"The microphone may be muted, the input volume too low, or the wrong input device selected. "+
+				"Check System Settings → Sound → Input.", cfg().QuietMicRecordings))
+	}
+
+	if errors.Is(res.Err, errCancelled) {
+		endSession()
+		setState(StateIdle)
+		showStatusBriefly("Dictation cancelled")
+		return
+	}
+	if res.Err != nil {
+		endSession()
+		status := dictationErrorStatus(res.Err)
+		if res.SavedAudio != "" {
+			status += ", audio saved"
+		}
+		ui.SetStatus(status)
+		ui.ShowStatus()
+		if errors.Is(res.Err, errType) && accessLost() {
+			showAccessDialog("GoWhisper needs Accessibility permissions to type text. " +
+				"Until it has them, dictations are copied to the clipboard instead.")
+		}
+		setState(StateIdle)
+		return
+	}
+
+	// A continuous session goes straight back to recording
+	if isSessionActive() && tryTransitionState(StateProcessing, StateRecording) {
+		startDictation()
+		return
+	}
+
+	showTimings := false
+	briefStatus := ""
+	if res.Action == actionNone {
+		// The quiet microphone dialog already explains a silent recording
+		if status := emptyStatus(res.Empty); status != "" && !res.QuietMic {
+			briefStatus = status
+		} else {
+			ui.HideStatus()
+		}
+	} else if res.Action == actionUndo {
+		briefStatus = "Undid last dictation"
+	} else if res.NoAccess {
+		// The menu bar already shows the degraded mode, this says where the text went
+		briefStatus = "Copied to clipboard, no permission to type"
+		injector.ShowNotification("GoWhisper", "No Accessibility permission to type, the dictation is on the clipboard")
+	} else if res.NoTarget {
+		// Keep the status visible, the user has to paste the text themselves
+		ui.SetStatus("Not pasted, " + cfg().TargetApp + " not available")
+		ui.ShowStatus()
+		injector.ShowNotification("GoWhisper", "Couldn't switch to "+cfg().TargetApp+", the dictation is on the clipboard")
+	} else if res.NotPasted {
+		// Keep the status visible, the user has to paste the text themselves
+		ui.SetStatus("Not pasted, text copied to clipboard")
+		ui.ShowStatus()
+		injector.ShowNotification("GoWhisper", "Couldn't paste into the focused app, the dictation is on the clipboard")
+	} else if res.NoTranslate {
+		// Keep the status visible, the text was typed untranslated
+		ui.SetStatus("Translation needs a multilingual model")
+		ui.ShowStatus()
+	} else if res.Degraded {
+		// Keep the warning visible so the user knows why the text may be off
+		ui.SetStatus("Warning: Audio dropped, recording may be degraded")
+		ui.ShowStatus()
+	} else if cfg().ShowTimings {
+		showTimings = true
+	} else {
+		ui.HideStatus()
+	}
+	setState(StateIdle)
+	if showTimings {
+		showTimingsBriefly(res.Timings)
+	} else if briefStatus != "" {
+		showStatusBriefly(briefStatus)
+	}
+}
+
+// showDictationProgress shows the step runDictation is at in the menu bar
+func showDictationProgress(stage dictationStage, segments int) {
+	switch stage {
+	case stageLoadingModel:
+		ui.SetStatus("Loading model...")
+	case stageTranscribing:
+		if segments == 0 {
+			ui.SetStatus("Transcribing...")
+		} else {
+			ui.SetStatus(fmt.Sprintf("Transcribing... (%d segments)", segments))
+		}
+	case stageTranslating:
+		ui.SetStatus("Translating...")
+	case stageRephrasing:
+		ui.SetIcon("C")
+		ui.SetStatus("Asking Claude... (⌘⇧P to cancel)")
+	case stageRunningCommand:
+		ui.SetIcon("◉")
+		ui.SetStatus("Running command...")
+	case stageSavingNote:
+		ui.SetIcon("◉")
+		ui.SetStatus("Saving note...")
+	case stageCopying:
+		ui.SetIcon("◉")
+		ui.SetStatus("Copying to clipboard...")
+	case stageTyping:
+		ui.SetIcon("◉")
+		ui.SetStatus("Typing...")
+	case stageOpeningURL:
+		ui.SetIcon("◉")
+		ui.SetStatus("Opening app...")
+	}
+}
+
+// dictationErrorStatus returns the menu bar status line for a failed dictation
+func dictationErrorStatus(err error) string {
+	for _, e := range []error{errStopRecording, errLoadModel, errTranscribe, errRephrase, errCommand, errSaveNote, errCopy, errType, errOpenURL} {
+		if errors.Is(err, e) {
+			msg := e.Error()
+			return "Error: " + strings.ToUpper(msg[:1]) + msg[1:]
+		}
+	}
+	return "Error: " + err.Error()
+}
+
+// runDictation stops the recorder, transcribes the recording, handles the
+// keywords and outputs the text. action is one of the config.Action values,
+// a preset that acts as if its keyword was said. It types the window
+// indicators but leaves the menu bar to the caller, reporting its steps
+// through progress. Cancelling ctx abandons the dictation before anything
+// is output, failing with errCancelled.
+func runDictation(ctx context.Context, recorder speechRecorder, action string, progress func(stage dictationStage, segments int)) dictationResult {
+	res := dictationResult{Action: actionNone}
+
+	// cancelled deletes the indicator still in the window, if any, and gives up
+	cancelled := func(indicator string) dictationResult {
+		log.Println("Dictation cancelled")
+		logStage("cancel", "discarded")
+		if err := deleteIndicator(indicator); err != nil {
+			log.Printf("Error deleting %q indicator: %v", indicator, err)
+		}
+		res.Err = errCancelled
+		return res
+	}
+	stopRequested := time.Now()
+
+	// Add delay before sending processing indicator to ensure the hotkey
+	// is fully released before AppleScript types. Without this delay, the modifier keys
+	// may still be pressed when keystroke injection occurs, causing incorrect characters.
+	time.Sleep(cfg().InjectionDelay())
+
+	// Delete the "Recording" text (9 characters) before showing "Processing"
+	if err := deleteIndicator(recordingIndicator); err != nil {
+		log.Printf("Error deleting recording indicator: %v", err)
+	}
+
+	if err := typeIndicator(processingIndicator); err != nil {
+		log.Printf("Error sending processing indicator: %v", err)
+	}
+
+	// The microphone kept recording while the indicator was typed; only wait
+	// for what is left of the tail
+	if wait := cfg().TailCapture() - time.Since(stopRequested); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	samples, err := recorder.Stop()
+	// An input overflow still yields usable samples, so warn instead of aborting
+	if errors.Is(err, audio.ErrInputOverflow) {
+		log.Printf("Warning: %v", err)
+		res.Degraded = true
+		err = nil
+	}
+	if err != nil {
+		log.Printf("Error stopping recording: %v", err)
+		res.Err = fmt.Errorf("%w: %v", errStopRecording, err)
+		return res
+	}
+
+	logDebug("Recorded %d samples (%.2f seconds)", len(samples), float64(len(samples))/float64(audio.SampleRate))
+
+	// Calculate audio volume/amplitude
+	var maxAmplitude float32
+	for _, sample := range samples {
+		// Calculate absolute value
+		abs := sample
+		if abs < 0 {
+			abs = -abs
+		}
+		// Check if this is the maximum amplitude
+		if abs > maxAmplitude {
+			maxAmplitude = abs
+		}
+	}
+	rms := audio.RMS(samples)
+	logDebug("Audio levels - Max amplitude: %.4f, RMS: %.4f", maxAmplitude, rms)
+	logStage("record", "samples=%d duration=%.2fs max=%.4f rms=%.4f degraded=%v",
+		len(samples), float64(len(samples))/float64(audio.SampleRate), maxAmplitude, rms, res.Degraded)
+
+	// Only recordings long enough to contain speech say anything about the mic
+	if len(samples) >= minSpeechSamples && levelMonitor.Add(rms) {
+		log.Printf("Warning: last %d recordings were all near-silent, microphone may be muted", cfg().QuietMicRecordings)
+		res.QuietMic = true
+	}
+
+	// Decide on the audio Whisper will actually see, so a long but mostly
+	// silent recording is treated as too short
+	recorded := len(samples)
+	samples = prepareSamples(samples)
+	if len(samples) < minSpeechSamples {
+		// A quick tap of the hotkey needs no explanation, a recording trimmed
+		// down to nothing does
+		if recorded >= minSpeechSamples {
+			res.Empty = emptySilent
+		}
+		log.Printf("Recording too short (%.2f seconds of audio), ignoring", float64(len(samples))/float64(audio.SampleRate))
+		return res
+	}
+
+	if ctx.Err() != nil {
+		return cancelled(processingIndicator)
+	}
+	res.Recording = keepRecording(samples)
+
+	// Reload the model if it was released while idle
+	if cfg().TranscribeBackend == config.TranscribeBackendLocal && !isTranscriberLoaded() {
+		progress(stageLoadingModel, 0)
+	}
+	transcriber, err := loadTranscriber()
+	if err != nil {
+		log.Printf("Error loading Whisper model: %v", err)
+		res.Err = fmt.Errorf("%w: %v", errLoadModel, err)
+		return res
+	}
+
+	// Transcribe
+	log.Println("Transcribing...")
+	progress(stageTranscribing, 0)
+
+	// Show progress for long recordings, live only in single-segment mode. The
+	// callback runs synchronously on this goroutine and systray marshals title
+	// updates to the main thread itself.
+	segmentCount := 0
+	var transcribeStart time.Time
+	transcribe := func() (string, error) {
+		segmentCount = 0
+		transcribeStart = time.Now()
+		return transcriber.TranscribeContext(ctx, samples, false, func(segment whisper.Segment) {
+			segmentCount++
+			progress(stageTranscribing, segmentCount)
+		})
+	}
+	text, err := transcribe()
+	if err != nil && ctx.Err() == nil && cfg().TranscribeRetry {
+		log.Printf("Warning: transcription failed, retrying once: %v", err)
+		logStage("whisper", "error=%v retrying", err)
+		progress(stageTranscribing, 0)
+		text, err = transcribe()
+	}
+	transcribeDuration := time.Since(transcribeStart)
+	if ctx.Err() != nil {
+		return cancelled(processingIndicator)
+	}
+	if err != nil {
+		logEventError("transcription", err, "Error transcribing",
+			"sample_count", len(samples), "duration_ms", transcribeDuration.Milliseconds())
+		logStage("whisper", "error=%v", err)
+		log.Println("✗ Transcription failed")
+		res.Err = fmt.Errorf("%w: %v", errTranscribe, err)
+		res.SavedAudio = keepFailedRecording(samples)
+		return res
+	}
+
+	res.RawText = text
+	res.Timings = transcriber.LastTimings()
+	timings := res.Timings
+	confidence := transcriber.LastConfidence()
+	logEvent("transcription", fmt.Sprintf("✓ Transcription: %s", text),
+		"sample_count", len(samples), "duration_ms", transcribeDuration.Milliseconds(),
+		"segments", segmentCount, "confidence", confidence, "text", text)
+	logEvent("timings", fmt.Sprintf("Transcribed %.1fs of audio in %.2fs (%.1fx real time)",
+		timings.Audio.Seconds(), timings.Processing.Seconds(), timings.RealTimeFactor()),
+		"audio_ms", timings.Audio.Milliseconds(), "processing_ms", timings.Processing.Milliseconds(),
+		"rtf", timings.RealTimeFactor())
+	logStage("whisper", "text=%q confidence=%.2f", text, confidence)
+
+	// The recording of a dictation started by the wake phrase begins with it
+	if action == actionWake {
+		text = afterWakePhrase(text, cfg().WakePhrase)
+	}
+
+	if text == "" {
+		res.Empty = emptyNoSpeech
+		if rms < cfg().QuietMicThreshold {
+			res.Empty = emptySilent
+		}
+		log.Printf("No speech detected (RMS: %.4f)", rms)
+		return res
+	}
+
+	// A lone filler word is what Whisper makes of an accidental noisy
+	// recording, not a dictation
+	if isJunkTranscription(text, cfg().DiscardShorterThan, cfg().DiscardWords) {
+		if err := deleteIndicator(processingIndicator); err != nil {
+			log.Printf("Error deleting processing indicator: %v", err)
+		}
+		log.Printf("Discarding transcription %q, only a filler word", text)
+		logStage("discard", "text=%q", text)
+		return res
+	}
+
+	// Saying only an undo phrase deletes what the previous dictation typed
+	if isUndoPhrase(text, cfg().UndoPhrases) {
+		if err := deleteIndicator(processingIndicator); err != nil {
+			log.Printf("Error deleting processing indicator: %v", err)
+		}
+		if !lastInjectionIntact() {
+			log.Println("Undo phrase heard, but the text before the cursor isn't the last dictation")
+			logStage("undo", "changed")
+			return res
+		}
+		count := takeLastInjectedLen()
+		if count == 0 {
+			log.Println("Undo phrase heard, but there is nothing to undo")
+			logStage("undo", "nothing")
+			return res
+		}
+		if err := sendBackspaces(count); err != nil {
+			log.Printf("Error undoing last dictation: %v", err)
+			logStage("undo", "error=%v", err)
+			res.Err = fmt.Errorf("%w: %v", errType, err)
+			return res
+		}
+		log.Printf("Undo phrase heard, undid last dictation (%d characters)", count)
+		logStage("undo", "count=%d", count)
+		res.Action = actionUndo
+		return res
+	}
+
+	// Detect keywords in transcription. The rest of a dictation starting with
+	// a command keyword is the command's argument, so no other keyword applies.
+	command, commandText, hasCommand := matchKeywordCommand(text, cfg().KeywordCommands)
+	hasClaude := !hasCommand && containsClaude(text)
+	hasClipboard := !hasCommand && containsClipboardKeyword(text)
+	hasTranslate := !hasCommand && containsTranslateKeyword(text)
+	// "note" starts many ordinary sentences, so it's only a keyword once a notes directory is configured
+	hasNote := !hasCommand && cfg().NotesDir != "" && containsNoteKeyword(text)
+	hasProofread := !hasCommand && containsProofreadKeyword(text)
+	hasTimestamp := !hasCommand && containsTimestampKeyword(text)
+
+	logDebug("Keyword detection - Claude: %v, Clipboard: %v, Translate: %v, Note: %v, Proofread: %v, Timestamp: %v", hasClaude, hasClipboard, hasTranslate, hasNote, hasProofread, hasTimestamp)
+	logStage("keywords", "claude=%v clipboard=%v translate=%v note=%v proofread=%v timestamp=%v", hasClaude, hasClipboard, hasTranslate, hasNote, hasProofread, hasTimestamp)
+	for _, k := range []struct {
+		name  string
+		found bool
+	}{{"claude", hasClaude}, {"clipboard", hasClipboard}, {"translate", hasTranslate}, {"note", hasNote}, {"proofread", hasProofread}, {"timestamp", hasTimestamp}} {
+		if k.found {
+			res.Keywords = append(res.Keywords, k.name)
+		}
+	}
+	if hasCommand {
+		log.Printf("Command keyword %q detected", command.Keyword)
+		res.Keywords = append(res.Keywords, command.Keyword)
+	}
+
+	if hasTranslate {
+		// Run the same audio again in translate mode. Keywords were detected on
+		// the first pass; the English text only needs them stripped.
+		log.Println("Translate keyword detected, transcribing again with translation to English")
+		progress(stageTranslating, 0)
+		translated, err := transcriber.TranscribeContext(ctx, samples, true, nil)
+		if ctx.Err() != nil {
+			return cancelled(processingIndicator)
+		}
+		if errors.Is(err, whisper.ErrNotMultilingual) {
+			log.Printf("Warning: %v, keeping original transcription", err)
+			res.NoTranslate = true
+		} else if err != nil {
+			log.Printf("Warning: translation failed, keeping original transcription: %v", err)
+		} else if translated != "" {
+			text = translated
+			logStage("translate", "text=%q", text)
+		}
+		text = removeTranslateKeyword(text)
+	}
+	if hasNote {
+		text = removeNoteKeyword(text)
+	}
+	if hasProofread {
+		text = removeProofreadKeyword(text)
+	}
+	if hasTimestamp {
+		text = removeTimestampKeyword(text)
+	}
+
+	// Determine output text and action based on keywords
+	var outputText string
+	var shouldCopyToClipboard bool
+	var shouldRephrase bool
+
+	if hasCommand {
+		// Command keyword: the rest is passed to the command, whose output is typed
+		outputText = commandText
+		log.Printf("Will run the %q command with: %s", command.Keyword, outputText)
+	} else if hasClaude && hasClipboard {
+		// Both keywords: Remove both, rephrase with Claude, copy to clipboard
+		outputText = removeCombinedKeywords(text)
+		shouldRephrase = true
+		shouldCopyToClipboard = true
+		log.Printf("Both keywords detected. Will rephrase and copy: %s", outputText)
+	} else if hasClaude {
+		// Only Claude: Remove keyword, rephrase, type to window
+		outputText = removeCombinedKeywords(text)
+		shouldRephrase = true
+		shouldCopyToClipboard = false
+		log.Printf("Claude keyword detected. Will rephrase and type: %s", outputText)
+	} else if hasClipboard {
+		// Only Clipboard: Remove keyword, copy to clipboard
+		outputText = removeClipboardPrefix(text)
+		shouldRephrase = false
+		shouldCopyToClipboard = true
+		log.Printf("Clipboard keyword detected. Will copy: %s", outputText)
+	} else {
+		// No keywords: Type original text
+		outputText = text
+		shouldRephrase = false
+		shouldCopyToClipboard = false
+	}
+
+	// A preset action from its own hotkey works like saying its keyword
+	switch action {
+	case config.ActionClipboard:
+		shouldCopyToClipboard = true
+	case config.ActionRephrase:
+		shouldRephrase = !hasCommand
+	}
+	if action != config.ActionPlain {
+		logDebug("Hotkey action %s: rephrase=%v clipboard=%v", action, shouldRephrase, shouldCopyToClipboard)
+	}
+
+	if cfg().CollapseRepeats {
+		outputText = postprocess.CollapseRepeats(outputText, cfg().KeepRepeats)
+		logStage("repeats", "text=%q", outputText)
+	}
+	if cfg().RemoveFillers {
+		outputText = postprocess.RemoveFillers(outputText, cfg().FillerWords)
+		logStage("fillers", "text=%q", outputText)
+	}
+
+	// A local alternative to Claude for obvious typos, run before spoken
+	// punctuation adds symbols the checker would trip over
+	if hasProofread || (cfg().SpellCheck && !shouldRephrase && !hasCommand) {
+		outputText = proofread(outputText)
+	}
+
+	if cfg().SpokenPunctuation && !hasCommand {
+		mapping := cfg().SpokenPunctuationMap
+		if mapping == nil {
+			mapping = postprocess.DefaultSpokenPunctuation
+		}
+		outputText = postprocess.ApplySpokenPunctuation(outputText, mapping)
+		logStage("punctuation", "text=%q", outputText)
+	}
+
+	// Sticky rephrase mode acts as if "claude" was said; an explicit
+	// "claude" was already stripped above
+	if !shouldRephrase && !hasCommand && isRephraseByDefault() {
+		shouldRephrase = true
+		log.Println("Rephrase by default is on, will rephrase with Claude")
+	}
+	if !shouldRephrase && !hasCommand && lowConfidence(confidence) {
+		shouldRephrase = true
+		log.Printf("Transcription confidence %.2f is below %.2f, will rephrase with Claude", confidence, cfg().AutoRephraseConfidence)
+	}
+
+	// A note goes to the notes file only, never to the window or clipboard
+	shouldSaveNote := hasNote
+	if shouldSaveNote {
+		shouldCopyToClipboard = false
+	}
+
+	// An optional casing modifier may follow the clipboard keyword, e.g.
+	// "clipboard snake my variable" copies "my_variable"
+	clipboardCase := textcase.None
+	if shouldCopyToClipboard {
+		clipboardCase, outputText = textcase.SplitModifier(outputText)
+	}
+
+	// Local clean-up for text that won't be rephrased by Claude anyway
+	if !shouldRephrase && !hasCommand && clipboardCase == textcase.None && cfg().AutoPunctuate {
+		outputText = postprocess.AutoPunctuate(outputText)
+	}
+
+	logStage("output", "text=%q rephrase=%v command=%v clipboard=%v case=%v note=%v", outputText, shouldRephrase, hasCommand, shouldCopyToClipboard, clipboardCase, shouldSaveNote)
+
+	// Spell checking may have taken a while
+	if ctx.Err() != nil {
+		return cancelled(processingIndicator)
+	}
+
+	// Delete the "Processing" text first
+	if err := deleteIndicator(processingIndicator); err != nil {
+		log.Printf("Error deleting processing indicator: %v", err)
+	}
+
+	// Rephrase with Claude if needed, keeping the original for "claude
+	// clipboard" to output both
+	var original string
+	if shouldRephrase {
+		const claudeIndicator = "Asking Claude"
+		res.Rephrased = true
+		progress(stageRephrasing, 0)
+
+		// Show "Asking Claude" text in the window, unless this is a note
+		// which must not touch the window
+		if !shouldSaveNote {
+			if err := typeIndicator(claudeIndicator); err != nil {
+				log.Printf("Error sending Claude indicator: %v", err)
+			}
+		}
+
+		// The hotkey or the menu can cancel a slow rephrase, which falls back
+		// to the original text. Cancelling the dictation stops it too.
+		claudeCtx, cancel := context.WithCancel(ctx)
+		setClaudeCancel(cancel)
+		claudeStart := time.Now()
+		rephrased, err := rephraseText(claudeCtx, outputText)
+		claudeDuration := time.Since(claudeStart)
+		setClaudeCancel(nil)
+		if claudeCtx.Err() != nil && ctx.Err() == nil {
+			log.Println("Claude rephrase cancelled, using the original text")
+			logStage("claude", "cancelled")
+			rephrased, err = outputText, nil
+		}
+		cancel()
+
+		// Delete the "Asking Claude" text
+		if !shouldSaveNote {
+			if err := deleteIndicator(claudeIndicator); err != nil {
+				log.Printf("Error deleting Claude indicator: %v", err)
+			}
+		}
+		if ctx.Err() != nil {
+			return cancelled("")
+		}
+
+		if err != nil {
+			logEventError("claude", err, "Error rephrasing with Claude", "duration_ms", claudeDuration.Milliseconds())
+			logStage("claude", "error=%v", err)
+			res.Err = fmt.Errorf("%w: %v", errRephrase, err)
+			return res
+		}
+		original = outputText
+		outputText = combineRephrased(outputText, rephrased)
+		logEvent("claude", fmt.Sprintf("Successfully rephrased: %s", outputText),
+			"duration_ms", claudeDuration.Milliseconds(), "text", outputText)
+		logStage("claude", "text=%q", outputText)
+	}
+
+	// "claude clipboard" may type one version and copy the other. The copy
+	// goes first, so a paste borrowing the clipboard restores it afterward.
+	alsoCopied := ""
+	if hasClaude && hasClipboard && shouldCopyToClipboard && res.Rephrased && cfg().ClaudeClipboardType != config.ClaudeClipboardTypeNone {
+		typed, copied := outputText, original
+		if cfg().ClaudeClipboardType == config.ClaudeClipboardTypeOriginal {
+			typed, copied = original, outputText
+		}
+		if err := setClipboardContent(copied); err != nil {
+			log.Printf("Error copying the %s text: %v", cfg().ClaudeClipboardType, err)
+			logStage("inject", "mode=clipboard error=%v", err)
+			res.Err = fmt.Errorf("%w: %v", errCopy, err)
+			return res
+		}
+		log.Printf("Copied one version to the clipboard, typing the %s text", cfg().ClaudeClipboardType)
+		logStage("claude", "typed=%s copied=%q", cfg().ClaudeClipboardType, copied)
+		outputText = typed
+		alsoCopied = copied
+		shouldCopyToClipboard = false
+	}
+
+	// Run the command bound to the keyword, showing an indicator like Claude
+	if hasCommand {
+		indicator := "Running " + command.Keyword
+		progress(stageRunningCommand, 0)
+		if err := typeIndicator(indicator); err != nil {
+			log.Printf("Error sending command indicator: %v", err)
+		}
+		commandStart := time.Now()
+		output, err := runCommand(ctx, command, outputText)
+		commandDuration := time.Since(commandStart)
+		if err := deleteIndicator(indicator); err != nil {
+			log.Printf("Error deleting command indicator: %v", err)
+		}
+		if ctx.Err() != nil {
+			return cancelled("")
+		}
+
+		if err != nil {
+			logEventError("command", err, "Error running keyword command", "keyword", command.Keyword, "duration_ms", commandDuration.Milliseconds())
+			logStage("command", "error=%v", err)
+			res.Err = fmt.Errorf("%w: %v", errCommand, err)
+			return res
+		}
+		outputText = output
+		logEvent("command", fmt.Sprintf("Command %q printed: %s", command.Keyword, outputText),
+			"keyword", command.Keyword, "duration_ms", commandDuration.Milliseconds(), "text", outputText)
+		logStage("command", "text=%q", outputText)
+	}
+
+	// Added last so Claude and the text clean-up leave the date alone
+	if hasTimestamp {
+		outputText = prefixTimestamp(outputText, time.Now())
+		logStage("timestamp", "text=%q", outputText)
+	}
+
+	// Plain dictations go to a URL instead of the window when configured. One
+	// too long to open is copied instead so it isn't lost.
+	openAsURL := !shouldSaveNote && !shouldCopyToClipboard && cfg().OutputTarget == config.OutputTargetURL
+	if openAsURL && len(outputURL(cfg().OutputURLTemplate, outputText)) > maxOpenURLLength {
+		log.Printf("Warning: Dictation too long to open as a URL, copying it instead")
+		openAsURL = false
+		shouldCopyToClipboard = true
+	}
+
+	// Without permission to type, the clipboard is the only way to hand over
+	// the text
+	if !shouldSaveNote && !shouldCopyToClipboard && !openAsURL && isAccessDenied() {
+		log.Println("No Accessibility permission, copying dictation instead of typing it")
+		shouldCopyToClipboard = true
+		res.NoAccess = true
+	}
+
+	// Let the user check, and fix, the text before it lands in a document
+	if !shouldSaveNote && !shouldCopyToClipboard && !openAsURL && cfg().PreviewOutput {
+		choice, edited := previewOutput(outputText)
+		if strings.TrimSpace(edited) == "" {
+			choice = longOutputDiscard
+		}
+		switch choice {
+		case longOutputType:
+			log.Println("Typing dictation as previewed")
+			outputText = edited
+		case longOutputCopy:
+			log.Println("Copying previewed dictation instead of typing it")
+			outputText = edited
+			shouldCopyToClipboard = true
+		default:
+			log.Println("Discarded dictation in preview")
+			logStage("inject", "mode=discard")
+			return res
+		}
+		logStage("preview", "choice=%q text=%q", choice, outputText)
+	}
+
+	// A recording left running can produce a wall of text, so check before
+	// typing it into whatever window has focus. The preview already showed it.
+	if !shouldSaveNote && !shouldCopyToClipboard && !openAsURL && !cfg().PreviewOutput && cfg().MaxOutputChars > 0 && utf8.RuneCountInString(outputText) > cfg().MaxOutputChars {
+		switch confirmLongOutput(outputText) {
+		case longOutputType:
+			log.Println("Typing long dictation as confirmed")
+		case longOutputCopy:
+			log.Println("Copying long dictation instead of typing it")
+			shouldCopyToClipboard = true
+		default:
+			log.Printf("Discarded dictation of %d characters", utf8.RuneCountInString(outputText))
+			logStage("inject", "mode=discard")
+			return res
+		}
+	}
+
+	// Switch to the pinned app only now, so a slow Claude call or a dialog
+	// doesn't keep it in front. If it can't be activated the text goes to the
+	// clipboard rather than into the wrong window.
+	returnFocus := false
+	if !shouldSaveNote && !shouldCopyToClipboard && !openAsURL && cfg().TargetApp != "" {
+		restore, err := injector.ActivateApp(cfg().TargetApp)
+		if err != nil {
+			log.Printf("Warning: Can't switch to %s, copying dictation instead: %v", cfg().TargetApp, err)
+			logStage("inject", "mode=target error=%v", err)
+			shouldCopyToClipboard = true
+			res.NoTarget = true
+		} else {
+			time.Sleep(appSwitchDelay)
+			if cfg().TargetAppReturnFocus {
+				returnFocus = true
+				defer returnToPreviousApp(restore)
+			}
+		}
+	}
+
+	if shouldSaveNote {
+		progress(stageSavingNote, 0)
+		path, err := notes.Append(cfg().NotesDir, outputText, time.Now())
+		if err != nil {
+			log.Printf("Error saving note: %v", err)
+			logStage("inject", "mode=note error=%v", err)
+			res.Err = fmt.Errorf("%w: %v", errSaveNote, err)
+			return res
+		}
+		log.Printf("Saved note to %s: %s", path, outputText)
+		logStage("inject", "mode=note ok")
+		res.Action = actionNote
+	} else if shouldCopyToClipboard {
+		outputText = textcase.Apply(outputText, clipboardCase)
+		if cfg().InjectAffixClipboard {
+			outputText = wrapInjectedText(outputText)
+		}
+
+		// Copy to clipboard
+		progress(stageCopying, 0)
+		copyToClipboard := setClipboardContent
+		if cfg().ClipboardAccumulate {
+			copyToClipboard = func(text string) error {
+				return appendClipboardContent(text, cfg().ClipboardAccumulateMaxChars)
+			}
+		}
+		if err := copyToClipboard(outputText); err != nil {
+			// Type the text instead of dropping it, the user can still copy it from the window
+			log.Printf("Warning: Clipboard unavailable, typing text instead: %v", err)
+			logStage("inject", "mode=clipboard error=%v", err)
+			progress(stageTyping, 0)
+			if err := sendTextToActiveWindow(outputText); err != nil {
+				log.Printf("Error sending text: %v", err)
+				logStage("inject", "mode=type error=%v", err)
+				res.Err = fmt.Errorf("%w: %v", errCopy, err)
+				return res
+			}
+			setLastInjectedText(outputText)
+			log.Println("Successfully typed text instead of copying")
+			logStage("inject", "mode=type ok")
+			res.Action = actionType
+		} else {
+			log.Printf("Successfully copied to clipboard: %s", outputText)
+			logStage("inject", "mode=clipboard ok")
+			res.Action = actionClipboard
+		}
+		setLastOutput(outputText)
+	} else if openAsURL {
+		progress(stageOpeningURL, 0)
+		if err := openURL(outputURL(cfg().OutputURLTemplate, outputText)); err != nil {
+			log.Printf("Error opening output URL: %v", err)
+			logStage("inject", "mode=url error=%v", err)
+			res.Err = fmt.Errorf("%w: %v", errOpenURL, err)
+			return res
+		}
+		setLastOutput(outputText)
+		log.Println("Successfully opened output URL")
+		logStage("inject", "mode=url ok")
+		res.Action = actionURL
+	} else {
+		// Send transcribed text to active window. The indicators are already
+		// gone, and undo uses the length of the wrapped text.
+		outputText = wrapInjectedText(outputText)
+		progress(stageTyping, 0)
+		if err := sendTextToActiveWindow(outputText); err != nil {
+			log.Printf("Error sending text: %v", err)
+			logStage("inject", "mode=type error=%v", err)
+			res.Err = fmt.Errorf("%w: %v", errType, err)
+			return res
+		}
+		if !pasteWasAccepted(outputText) {
+			// Leave the text on the clipboard, the pending restore would wipe it
+			if err := setClipboardContent(outputText); err != nil {
+				log.Printf("Warning: Failed to keep the text on the clipboard: %v", err)
+			}
+			setLastOutput(outputText)
+			log.Println("Warning: The focused app didn't take the text, kept it on the clipboard")
+			logStage("inject", "mode=type not-pasted")
+			res.Action = actionClipboard
+			res.NotPasted = true
+			res.Text = outputText
+			recordHistory(res)
+			return res
+		}
+		// Undo can't reach the text once the focus is back in another app
+		if !returnFocus {
+			setLastInjectedText(outputText)
+		}
+		// Without a restore the paste left the typed text on the clipboard
+		if alsoCopied != "" && !cfg().RestoreClipboard {
+			scheduleClipboardContent(alsoCopied, cfg().ClipboardRestoreDelay())
+		}
+		setLastOutput(outputText)
+		log.Println("Successfully sent transcribed text")
+		logStage("inject", "mode=type ok")
+		res.Action = actionType
+	}
+
+	res.Text = outputText
+	recordHistory(res)
+	return res
+}
+
+// returnToPreviousApp switches back to the app that was in front before
+// cfg.TargetApp, once it had time to take the text
+func returnToPreviousApp(restore func() error) {
+	time.Sleep(appSwitchDelay)
+	if err := restore(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// keepFailedRecording keeps what it can of a recording Whisper failed on, as
+// configured: the audio in a WAV file and a note about it on the clipboard.
+// Returns the path of the WAV file, or "" if it wasn't saved.
+func keepFailedRecording(samples []float32) string {
+	path := ""
+	if cfg().SaveFailedAudio {
+		name := filepath.Join(paths.DataDir(), "failed", time.Now().Format("2006-01-02_15-04-05")+".wav")
+		if err := audio.SaveWAV(name, samples, wavFormat()); err != nil {
+			log.Printf("Warning: Failed to save the recording: %v", err)
+		} else {
+			path = name
+			log.Printf("Saved the recording to %s", path)
+		}
+	}
+
+	if cfg().FailedClipboardNote {
+		note := fmt.Sprintf("GoWhisper couldn't transcribe the dictation of %s.", time.Now().Format("2006-01-02 15:04"))
+		if path != "" {
+			note += " The recording is saved in " + path + ", transcribe it with: GoWhisper --stdin < " + path
+		}
+		if err := setClipboardContent(note); err != nil {
+			log.Printf("Warning: Failed to copy the note to the clipboard: %v", err)
+		}
+	}
+	return path
+}
+
+// pasteWasAccepted checks, once the target app had pasteSettleDelay to take
+// text, that the focused element holds it. When the element can't be read the
+// paste is assumed to have worked.
+func pasteWasAccepted(text string) bool {
+	if !cfg().VerifyPaste {
+		return true
+	}
+	time.Sleep(pasteSettleDelay)
+	role, value, err := injector.FocusedElement()
+	if err != nil {
+		return true
+	}
+	return pasteLanded(role, value, text)
+}
+
+// lastInjectionIntact checks that the text before the cursor in the focused
+// element still ends with the text the last dictation typed, so undoing
+// deletes that rather than whatever the user or an autocomplete put there
+// since. Unlike pasteWasAccepted it doesn't depend on cfg.VerifyPaste, undo is
+// rare enough to always check. When the element can't be read undo goes ahead.
+func lastInjectionIntact() bool {
+	text := getLastInjectedText()
+	if text == "" {
+		return true
+	}
+	role, before, err := injector.TextBeforeCursor()
+	if err != nil {
+		return true
+	}
+	return injectionBeforeCursor(role, before, text)
+}
+
+// proofread fixes obvious typos in text with the local spell checker. Without
+// one installed the text is kept as it is.
+func proofread(text string) string {
+	corrected, err := correctSpelling(text, cfg().SpellCheckDictionary)
+	if err != nil {
+		log.Printf("Warning: spell check skipped: %v", err)
+		return text
+	}
+	if corrected != text {
+		log.Printf("Spell check corrected: %s", corrected)
+	}
+	logStage("spellcheck", "text=%q", corrected)
+	return corrected
+}
+
+// prefixTimestamp puts now, formatted with cfg.TimestampFormat, before text.
+// Saying only the keyword outputs just the timestamp.
+func prefixTimestamp(text string, now time.Time) string {
+	stamp := now.Format(cfg().TimestampFormat)
+	if text == "" {
+		return stamp
+	}
+	return stamp + " " + text
+}
+
+// Choices offered for a dictation over cfg.MaxOutputChars or with
+// cfg.PreviewOutput
+const (
+	longOutputDiscard = "Discard"
+	longOutputCopy    = "Copy to Clipboard"
+	longOutputType    = "Type It"
+)
+
+// confirmLongOutput asks what to do with a dictation over cfg.MaxOutputChars,
+// returning one of the longOutput choices
+func confirmLongOutput(text string) string {
+	const previewChars = 200
+	preview := []rune(text)
+	if len(preview) > previewChars {
+		preview = append(preview[:previewChars], '…')
+	}
+	message := fmt.Sprintf("This dictation is %d characters long, more than the %d set in maxOutputChars.\n\n%s",
+		utf8.RuneCountInString(text), cfg().MaxOutputChars, string(preview))
+	return askChoice("GoWhisper - Long Dictation", message, longOutputDiscard, longOutputCopy, longOutputType)
+}
+
+// previewOutput shows the text about to be typed for the user to edit,
+// returning one of the longOutput choices and the edited text
+func previewOutput(text string) (string, string) {
+	return editText("GoWhisper - Preview", "Check the dictation before it is typed:", text,
+		longOutputDiscard, longOutputCopy, longOutputType)
+}
+
+// prepareSamples applies optional denoising and silence trimming to a
+// recording before transcription
+func prepareSamples(samples []float32) []float32 {
+	// Denoise first so trimming measures the level of the cleaned-up audio
+	if cfg().Denoise {
+		start := time.Now()
+		samples = audio.Denoise(samples)
+		log.Printf("Denoised %d samples in %v", len(samples), time.Since(start).Round(time.Millisecond))
+		logStage("denoise", "samples=%d duration=%v", len(samples), time.Since(start))
+	}
+	if !cfg().TrimSilence {
+		return samples
+	}
+	trimmed := audio.TrimSilence(samples, cfg().TrimThreshold)
+	logDebug("Trimmed silence: %d -> %d samples", len(samples), len(trimmed))
+	logStage("trim", "samples=%d trimmed=%d", len(samples), len(trimmed))
+	return trimmed
+}
+
+// lowConfidence reports whether a transcription with the given confidence is
+// rephrased automatically, see config.AutoRephraseConfidence. An unknown (0)
+// confidence never is.
+func lowConfidence(confidence float32) bool {
+	return cfg().AutoRephraseConfidence > 0 && confidence > 0 && confidence < cfg().AutoRephraseConfidence
+}
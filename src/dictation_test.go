@@ -0,0 +1,1692 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stephanwesten/go-whisper/src/audio"
+	"github.com/stephanwesten/go-whisper/src/config"
+	"github.com/stephanwesten/go-whisper/src/history"
+	"github.com/stephanwesten/go-whisper/src/paths"
+	"github.com/stephanwesten/go-whisper/src/postprocess"
+	"github.com/stephanwesten/go-whisper/src/whisper"
+)
+
+// fakeInjector records what would have been typed into the active window
+type fakeInjector struct {
+	events  []string
+	sendErr error
+	choice  string // Returned by AskChoice and EditText
+	edited  string // Returned by EditText instead of the text, if set
+	// accessErr is returned by CheckAccess
+	accessErr error
+	// apps are the running apps ActivateApp can switch to
+	apps []string
+	// focused backs FocusedElement, the focused element is unknown if nil
+	focused func() (role, value string, err error)
+	// beforeCursor backs TextBeforeCursor, unknown if nil
+	beforeCursor func() (role, text string, err error)
+}
+
+func (f *fakeInjector) SendText(text string) error {
+	f.events = append(f.events, "type:"+text)
+	return f.sendErr
+}
+
+func (f *fakeInjector) SendBackspaces(count int) error {
+	f.events = append(f.events, fmt.Sprintf("backspace:%d", count))
+	return nil
+}
+
+func (f *fakeInjector) ShowErrorDialog(title, message string) {
+	f.events = append(f.events, "dialog:"+title)
+}
+
+func (f *fakeInjector) AskConfirmation(title, message, confirmButton string) bool { return false }
+func (f *fakeInjector) CheckAccess() error                                        { return f.accessErr }
+
+func (f *fakeInjector) FocusedElement() (string, string, error) {
+	if f.focused == nil {
+		return "", "", errors.New("focused element unknown")
+	}
+	return f.focused()
+}
+
+func (f *fakeInjector) TextBeforeCursor() (string, string, error) {
+	if f.beforeCursor == nil {
+		return "", "", errors.New("text before the cursor unknown")
+	}
+	return f.beforeCursor()
+}
+
+func (f *fakeInjector) ShowNotification(title, message string) {
+	f.events = append(f.events, "notify:"+title)
+}
+
+func (f *fakeInjector) ActivateApp(name string) (func() error, error) {
+	if !slices.Contains(f.apps, name) {
+		return nil, errAppNotRunning
+	}
+	f.events = append(f.events, "activate:"+name)
+	return func() error {
+		f.events = append(f.events, "restore")
+		return nil
+	}, nil
+}
+
+func (f *fakeInjector) EditText(title, message, text string, choices []string) (string, string) {
+	f.events = append(f.events, "dialog:"+title)
+	if f.edited != "" {
+		text = f.edited
+	}
+	return f.choice, text
+}
+
+func (f *fakeInjector) AskChoice(title, message string, choices []string) string {
+	f.events = append(f.events, "dialog:"+title)
+	return f.choice
+}
+
+// fakeRecorder returns fixed samples instead of recording
+type fakeRecorder struct {
+	samples   []float32
+	startErr  error
+	stoppedAt time.Time
+	autoStop  time.Duration
+	starts    int
+}
+
+func (f *fakeRecorder) Start() error {
+	f.starts++
+	return f.startErr
+}
+func (f *fakeRecorder) SetAutoStop(threshold float32, silence time.Duration) { f.autoStop = silence }
+func (f *fakeRecorder) Stop() ([]float32, error) {
+	f.stoppedAt = time.Now()
+	return f.samples, nil
+}
+
+// fakeTranscriber returns fixed text for any audio
+type fakeTranscriber struct {
+	text         string
+	translated   string
+	translateErr error                     // Returned when translating, if set
+	during       func(ctx context.Context) // Called while transcribing, if set
+	errs         []error                   // Returned by the first calls, one each
+	calls        int
+	confidence   float32
+}
+
+func (f *fakeTranscriber) TranscribeContext(ctx context.Context, samples []float32, translate bool, onSegment func(whisper.Segment)) (string, error) {
+	f.calls++
+	if f.calls <= len(f.errs) {
+		return "", f.errs[f.calls-1]
+	}
+	if f.during != nil {
+		f.during(ctx)
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+	}
+	if translate {
+		return f.translated, f.translateErr
+	}
+	return f.text, nil
+}
+
+func (f *fakeTranscriber) LastTimings() whisper.Timings { return whisper.Timings{} }
+func (f *fakeTranscriber) LastConfidence() float32      { return f.confidence }
+
+// fakeUI records the last status line instead of updating the menu bar
+type fakeUI struct {
+	status        string
+	accessWarning bool
+}
+
+func (f *fakeUI) SetIcon(string)           {}
+func (f *fakeUI) SetStatus(title string)   { f.status = title }
+func (f *fakeUI) ShowStatus()              {}
+func (f *fakeUI) HideStatus()              {}
+func (f *fakeUI) SetHotkeyTitle(string)    {}
+func (f *fakeUI) StartRecordingAnimation() {}
+func (f *fakeUI) StopRecordingAnimation()  {}
+func (f *fakeUI) ShowAccessWarning(show bool) {
+	f.accessWarning = show
+}
+
+// dictationFakes holds the fakes installed by setupDictation
+type dictationFakes struct {
+	injector    *fakeInjector
+	recorder    *fakeRecorder
+	transcriber *fakeTranscriber
+	ui          *fakeUI
+	clipboard   *string
+	rephrased   []string // Texts sent to Claude
+}
+
+// setupDictation replaces everything the dictation flow touches with fakes
+// for the duration of a test
+func setupDictation(t *testing.T, transcript string) *dictationFakes {
+	t.Helper()
+
+	origInjector, origRecorder, origLoad, origRephrase, origUI := injector, dictationRecorder, loadTranscriber, rephraseText, ui
+	origCfg, origMonitor, origRephraseAll, origProcess := *cfg(), levelMonitor, rephraseByDefault, processInBackground
+	origSettle, origCommand, origSwitch := pasteSettleDelay, runCommand, appSwitchDelay
+	t.Cleanup(func() {
+		endSession()
+		injector, dictationRecorder, loadTranscriber, rephraseText, ui = origInjector, origRecorder, origLoad, origRephrase, origUI
+		processInBackground, pasteSettleDelay, runCommand, appSwitchDelay = origProcess, origSettle, origCommand, origSwitch
+		levelMonitor, rephraseByDefault = origMonitor, origRephraseAll
+		setCfg(origCfg)
+		setState(StateIdle)
+		takeLastInjectedLen()
+		setLastOutput("")
+		accessDenied = false
+	})
+
+	// One second of audio loud enough not to count as a muted microphone
+	samples := make([]float32, audio.SampleRate)
+	for i := range samples {
+		samples[i] = 0.1
+	}
+
+	f := &dictationFakes{
+		injector:    &fakeInjector{},
+		recorder:    &fakeRecorder{samples: samples},
+		transcriber: &fakeTranscriber{text: transcript},
+		ui:          &fakeUI{},
+		clipboard:   fakeClipboard(t, "user content"),
+	}
+	injector, dictationRecorder, ui = f.injector, f.recorder, f.ui
+	loadTranscriber = func() (speechTranscriber, error) { return f.transcriber, nil }
+	rephraseText = func(_ context.Context, text string) (string, error) {
+		f.rephrased = append(f.rephrased, text)
+		return "Rephrased.", nil
+	}
+	// Processing finishes before the stop press returns, see TestDictationWorker
+	processInBackground = func(work func()) { work() }
+
+	cfg().InjectionDelayMs = 0
+	pasteSettleDelay = 0
+	appSwitchDelay = 0
+	cfg().TailCaptureMs = 0
+	cfg().HotkeyDebounceMs = 0
+	cfg().ProcessingCooldownMs = 0
+	cfg().TrimSilence = false
+	cfg().AutoPunctuate = false
+	cfg().ShowTimings = false
+	levelMonitor = audio.NewLevelMonitor(0, 0)
+	rephraseByDefault = false
+	accessDenied = false
+	setState(StateIdle)
+	return f
+}
+
+// TestDictationFlow tests a full start/stop cycle for each keyword branch
+func TestDictationFlow(t *testing.T) {
+	recordingEvents := []string{"type:Recording", "backspace:9", "type:Processing", "backspace:10"}
+
+	tests := []struct {
+		name          string
+		transcript    string
+		notes         bool
+		removeFillers bool
+		spokenPunct   bool
+		wantEvents    []string // After the indicators above
+		wantClipboard string
+		wantRephrased []string
+		wantNote      string
+	}{
+		{
+			name:          "plain dictation is typed",
+			transcript:    "hello world",
+			wantEvents:    []string{"type:hello world"},
+			wantClipboard: "user content",
+		},
+		{
+			name:          "clipboard keyword copies without typing",
+			transcript:    "clipboard copy this",
+			wantClipboard: "copy this",
+		},
+		{
+			name:          "claude keyword types the rephrased text",
+			transcript:    "claude fix this",
+			wantEvents:    []string{"type:Asking Claude", "backspace:13", "type:Rephrased."},
+			wantClipboard: "user content",
+			wantRephrased: []string{"fix this"},
+		},
+		{
+			name:          "claude and clipboard copies the rephrased text",
+			transcript:    "claude clipboard fix this",
+			wantEvents:    []string{"type:Asking Claude", "backspace:13"},
+			wantClipboard: "Rephrased.",
+			wantRephrased: []string{"fix this"},
+		},
+		{
+			name:          "note keyword writes the notes file only",
+			transcript:    "note buy milk",
+			notes:         true,
+			wantClipboard: "user content",
+			wantNote:      "buy milk",
+		},
+		{
+			name:          "fillers are removed before typing",
+			transcript:    "um, send it, you know, today",
+			removeFillers: true,
+			wantEvents:    []string{"type:send it, today"},
+			wantClipboard: "user content",
+		},
+		{
+			name:          "spoken punctuation is typed as symbols",
+			transcript:    "Dear Sam, new line, thanks",
+			spokenPunct:   true,
+			wantEvents:    []string{"type:Dear Sam\nthanks"},
+			wantClipboard: "user content",
+		},
+		{
+			name:          "no speech outputs nothing",
+			transcript:    "",
+			wantEvents:    nil,
+			wantClipboard: "user content",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := setupDictation(t, tt.transcript)
+			if tt.notes {
+				cfg().NotesDir = t.TempDir()
+			}
+			cfg().RemoveFillers = tt.removeFillers
+			cfg().SpokenPunctuation = tt.spokenPunct
+
+			handleHotkey()
+			if got := getState(); got != StateRecording {
+				t.Fatalf("state after first press = %s, want Recording", got)
+			}
+			handleHotkey()
+			if got := getState(); got != StateIdle {
+				t.Fatalf("state after second press = %s, want Idle", got)
+			}
+
+			wantEvents := recordingEvents
+			if tt.transcript == "" {
+				// Nothing was transcribed, so the processing indicator is left alone
+				wantEvents = recordingEvents[:3]
+			}
+			wantEvents = append(append([]string{}, wantEvents...), tt.wantEvents...)
+			if got := strings.Join(f.injector.events, ", "); got != strings.Join(wantEvents, ", ") {
+				t.Errorf("injected events = [%s], want [%s]", got, strings.Join(wantEvents, ", "))
+			}
+			if *f.clipboard != tt.wantClipboard {
+				t.Errorf("clipboard = %q, want %q", *f.clipboard, tt.wantClipboard)
+			}
+			if strings.Join(f.rephrased, "|") != strings.Join(tt.wantRephrased, "|") {
+				t.Errorf("sent to Claude = %q, want %q", f.rephrased, tt.wantRephrased)
+			}
+			if tt.wantNote != "" {
+				data, err := os.ReadFile(filepath.Join(cfg().NotesDir, time.Now().Format("2006-01-02")+".md"))
+				if err != nil {
+					t.Fatalf("failed to read note: %v", err)
+				}
+				if !strings.Contains(string(data), tt.wantNote) {
+					t.Errorf("note file = %q, want it to contain %q", data, tt.wantNote)
+				}
+			}
+		})
+	}
+}
+
+// TestDictationFlowErrors tests that failures return to Idle with an error status
+func TestDictationFlowErrors(t *testing.T) {
+	t.Run("recorder fails to start", func(t *testing.T) {
+		f := setupDictation(t, "hello world")
+		f.recorder.startErr = errors.New("no microphone")
+
+		handleHotkey()
+		if got := getState(); got != StateIdle {
+			t.Errorf("state = %s, want Idle", got)
+		}
+		if len(f.injector.events) != 0 {
+			t.Errorf("injected events = %v, want none", f.injector.events)
+		}
+		if f.ui.status != "Error: Failed to start" {
+			t.Errorf("status = %q, want %q", f.ui.status, "Error: Failed to start")
+		}
+	})
+
+	t.Run("no microphone", func(t *testing.T) {
+		f := setupDictation(t, "hello world")
+		f.recorder.startErr = fmt.Errorf("starting: %w", audio.ErrNoInputDevice)
+
+		handleHotkey()
+		if got := getState(); got != StateIdle {
+			t.Errorf("state = %s, want Idle", got)
+		}
+		if f.ui.status != "Error: No microphone detected" {
+			t.Errorf("status = %q, want %q", f.ui.status, "Error: No microphone detected")
+		}
+		if want := []string{"dialog:GoWhisper - No Microphone"}; !reflect.DeepEqual(f.injector.events, want) {
+			t.Errorf("injected events = %v, want %v", f.injector.events, want)
+		}
+	})
+
+	t.Run("claude fails", func(t *testing.T) {
+		f := setupDictation(t, "claude fix this")
+		rephraseText = func(context.Context, string) (string, error) { return "", errors.New("claude not found") }
+
+		handleHotkey()
+		handleHotkey()
+		if got := getState(); got != StateIdle {
+			t.Errorf("state = %s, want Idle", got)
+		}
+		if got := getLastOutput(); got != "" {
+			t.Errorf("getLastOutput() = %q, want nothing output", got)
+		}
+		if f.ui.status != "Error: Claude rephrasing failed" {
+			t.Errorf("status = %q, want %q", f.ui.status, "Error: Claude rephrasing failed")
+		}
+	})
+
+	t.Run("typing fails", func(t *testing.T) {
+		f := setupDictation(t, "hello world")
+		handleHotkey()
+		f.injector.sendErr = errors.New("not allowed")
+		handleHotkey()
+		if got := getState(); got != StateIdle {
+			t.Errorf("state = %s, want Idle", got)
+		}
+		if got := takeLastInjectedLen(); got != 0 {
+			t.Errorf("takeLastInjectedLen() = %d, want 0 after failed typing", got)
+		}
+		if f.ui.status != "Error: Failed to type" {
+			t.Errorf("status = %q, want %q", f.ui.status, "Error: Failed to type")
+		}
+	})
+}
+
+// TestClaudeClipboardType tests which version "claude clipboard" types and
+// which it copies
+func TestClaudeClipboardType(t *testing.T) {
+	tests := []struct {
+		mode     string
+		wantType string // Empty when nothing is typed
+		wantClip string
+	}{
+		{config.ClaudeClipboardTypeNone, "", "Rephrased."},
+		{config.ClaudeClipboardTypeRephrased, "Rephrased.", "fix this"},
+		{config.ClaudeClipboardTypeOriginal, "fix this", "Rephrased."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			f := setupDictation(t, "clipboard claude fix this")
+			cfg().ClaudeClipboardType = tt.mode
+
+			res := runDictation(context.Background(), f.recorder, config.ActionPlain, func(dictationStage, int) {})
+			if res.Err != nil {
+				t.Fatalf("runDictation() error = %v", res.Err)
+			}
+			var typed []string
+			for _, e := range f.injector.events {
+				if text, ok := strings.CutPrefix(e, "type:"); ok && text != processingIndicator && text != "Asking Claude" {
+					typed = append(typed, text)
+				}
+			}
+			if got := strings.Join(typed, ", "); got != tt.wantType {
+				t.Errorf("typed %q, want %q", got, tt.wantType)
+			}
+			if *f.clipboard != tt.wantClip {
+				t.Errorf("clipboard = %q, want %q", *f.clipboard, tt.wantClip)
+			}
+			// Repeat outputs the typed text, or the copied one when nothing was typed
+			wantLast := tt.wantType
+			if wantLast == "" {
+				wantLast = tt.wantClip
+			}
+			if got := getLastOutput(); got != wantLast {
+				t.Errorf("getLastOutput() = %q, want %q", got, wantLast)
+			}
+		})
+	}
+}
+
+// TestPreviewOutput tests that a previewed dictation is only typed when
+// accepted, with the user's edits
+func TestPreviewOutput(t *testing.T) {
+	tests := []struct {
+		name       string
+		choice     string
+		edited     string
+		wantEvents []string
+		wantClip   string
+	}{
+		{"type", longOutputType, "", []string{"dialog:GoWhisper - Preview", "type:hello world"}, "user content"},
+		{"type edited", longOutputType, "hello, world!", []string{"dialog:GoWhisper - Preview", "type:hello, world!"}, "user content"},
+		{"copy", longOutputCopy, "hello there", []string{"dialog:GoWhisper - Preview"}, "hello there"},
+		{"discard", longOutputDiscard, "", []string{"dialog:GoWhisper - Preview"}, "user content"},
+		{"dismissed", "", "", []string{"dialog:GoWhisper - Preview"}, "user content"},
+		{"emptied", longOutputType, "  ", []string{"dialog:GoWhisper - Preview"}, "user content"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := setupDictation(t, "hello world")
+			cfg().PreviewOutput = true
+			f.injector.choice, f.injector.edited = tt.choice, tt.edited
+
+			runDictation(context.Background(), f.recorder, config.ActionPlain, func(dictationStage, int) {})
+			// Leave out the indicators typed before the preview
+			events := f.injector.events[3:]
+			if got := strings.Join(events, ", "); got != strings.Join(tt.wantEvents, ", ") {
+				t.Errorf("events = %q, want %q", got, strings.Join(tt.wantEvents, ", "))
+			}
+			if *f.clipboard != tt.wantClip {
+				t.Errorf("clipboard = %q, want %q", *f.clipboard, tt.wantClip)
+			}
+		})
+	}
+}
+
+// TestTargetApp tests that a pinned app gets the text, without indicators in
+// the window that had focus
+func TestTargetApp(t *testing.T) {
+	tests := []struct {
+		name        string
+		running     []string
+		returnFocus bool
+		wantEvents  []string
+		wantClip    string
+		wantUndo    int
+	}{
+		{"returns focus", []string{"Notes"}, true, []string{"activate:Notes", "type:hello world", "restore"}, "user content", 0},
+		{"stays in the app", []string{"Notes"}, false, []string{"activate:Notes", "type:hello world"}, "user content", 11},
+		{"not running", nil, true, []string{"notify:GoWhisper"}, "hello world", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := setupDictation(t, "hello world")
+			cfg().TargetApp = "Notes"
+			cfg().TargetAppReturnFocus = tt.returnFocus
+			f.injector.apps = tt.running
+
+			handleHotkey()
+			handleHotkey()
+			if got := strings.Join(f.injector.events, ", "); got != strings.Join(tt.wantEvents, ", ") {
+				t.Errorf("events = %q, want %q", got, strings.Join(tt.wantEvents, ", "))
+			}
+			if *f.clipboard != tt.wantClip {
+				t.Errorf("clipboard = %q, want %q", *f.clipboard, tt.wantClip)
+			}
+			if got := takeLastInjectedLen(); got != tt.wantUndo {
+				t.Errorf("takeLastInjectedLen() = %d, want %d", got, tt.wantUndo)
+			}
+		})
+	}
+}
+
+// TestAccessDenied tests that typing without Accessibility permission
+// switches to copying dictations, telling the user only once
+func TestAccessDenied(t *testing.T) {
+	t.Run("first failure enters the degraded mode", func(t *testing.T) {
+		f := setupDictation(t, "hello world")
+		handleHotkey()
+		f.injector.sendErr = errors.New("not allowed")
+		f.injector.accessErr = errors.New("not allowed")
+		handleHotkey()
+		if !slices.Contains(f.injector.events, "dialog:Accessibility Permission Required") {
+			t.Errorf("events = %q, want the permission dialog", f.injector.events)
+		}
+		if !isAccessDenied() || !f.ui.accessWarning {
+			t.Fatalf("isAccessDenied() = %v, warning shown %v, want both true", isAccessDenied(), f.ui.accessWarning)
+		}
+
+		f.injector.events = nil
+		handleHotkey()
+		handleHotkey()
+		if got := strings.Join(f.injector.events, ", "); got != "notify:GoWhisper" {
+			t.Errorf("events = %q, want only a notification", got)
+		}
+		if *f.clipboard != "hello world" {
+			t.Errorf("clipboard = %q, want the dictation", *f.clipboard)
+		}
+		if got := getState(); got != StateIdle {
+			t.Errorf("state = %s, want Idle", got)
+		}
+	})
+
+	t.Run("other failures keep typing", func(t *testing.T) {
+		f := setupDictation(t, "hello world")
+		handleHotkey()
+		f.injector.sendErr = errors.New("xdotool crashed")
+		handleHotkey()
+		if isAccessDenied() || slices.Contains(f.injector.events, "dialog:Accessibility Permission Required") {
+			t.Errorf("entered the degraded mode with permission granted, events %q", f.injector.events)
+		}
+	})
+
+	t.Run("check again", func(t *testing.T) {
+		f := setupDictation(t, "hello world")
+		setAccessDenied(true)
+		f.injector.accessErr = errors.New("not allowed")
+		recheckAccess()
+		if !isAccessDenied() || !slices.Contains(f.injector.events, "dialog:Accessibility Permission Required") {
+			t.Errorf("isAccessDenied() = %v, events %q, want still denied with a dialog", isAccessDenied(), f.injector.events)
+		}
+
+		f.injector.accessErr = nil
+		recheckAccess()
+		if isAccessDenied() || f.ui.accessWarning {
+			t.Errorf("isAccessDenied() = %v, warning shown %v, want both false", isAccessDenied(), f.ui.accessWarning)
+		}
+	})
+}
+
+// TestCancelRephrase tests that cancelling a slow Claude call types the
+// original text and returns to Idle
+func TestCancelRephrase(t *testing.T) {
+	f := setupDictation(t, "claude fix this")
+	rephraseText = func(ctx context.Context, text string) (string, error) {
+		// Simulate the user cancelling while Claude is still working
+		if !cancelRephrase() {
+			t.Error("cancelRephrase() = false during rephrase, want true")
+		}
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+
+	handleHotkey()
+	handleHotkey()
+	if got := getState(); got != StateIdle {
+		t.Errorf("state = %s, want Idle", got)
+	}
+	want := []string{"type:Recording", "backspace:9", "type:Processing", "backspace:10",
+		"type:Asking Claude", "backspace:13", "type:fix this"}
+	if got := strings.Join(f.injector.events, ", "); got != strings.Join(want, ", ") {
+		t.Errorf("injected events = [%s], want [%s]", got, strings.Join(want, ", "))
+	}
+	if cancelRephrase() {
+		t.Error("cancelRephrase() = true after the rephrase finished, want false")
+	}
+}
+
+// TestRunDictationResult tests the result runDictation reports without the menu bar
+func TestRunDictationResult(t *testing.T) {
+	tests := []struct {
+		name       string
+		transcript string
+		notes      bool
+		claudeErr  error
+		want       dictationResult
+	}{
+		{
+			name:       "plain dictation",
+			transcript: "hello world",
+			want:       dictationResult{RawText: "hello world", Text: "hello world", Action: actionType},
+		},
+		{
+			name:       "clipboard keyword",
+			transcript: "clipboard copy this",
+			want:       dictationResult{RawText: "clipboard copy this", Text: "copy this", Keywords: []string{"clipboard"}, Action: actionClipboard},
+		},
+		{
+			name:       "claude and clipboard",
+			transcript: "claude clipboard fix this",
+			want: dictationResult{RawText: "claude clipboard fix this", Text: "Rephrased.",
+				Keywords: []string{"claude", "clipboard"}, Rephrased: true, Action: actionClipboard},
+		},
+		{
+			name:       "note keyword",
+			transcript: "note buy milk",
+			notes:      true,
+			want:       dictationResult{RawText: "note buy milk", Text: "buy milk", Keywords: []string{"note"}, Action: actionNote},
+		},
+		{
+			name:       "no speech",
+			transcript: "",
+			want:       dictationResult{Action: actionNone, Empty: emptyNoSpeech},
+		},
+		{
+			name:       "claude fails",
+			transcript: "claude fix this",
+			claudeErr:  errors.New("claude not found"),
+			want: dictationResult{RawText: "claude fix this", Keywords: []string{"claude"}, Rephrased: true,
+				Action: actionNone, Err: errRephrase},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := setupDictation(t, tt.transcript)
+			if tt.notes {
+				cfg().NotesDir = t.TempDir()
+			}
+			if tt.claudeErr != nil {
+				rephraseText = func(context.Context, string) (string, error) { return "", tt.claudeErr }
+			}
+
+			var stages []dictationStage
+			got := runDictation(context.Background(), f.recorder, config.ActionPlain, func(stage dictationStage, _ int) { stages = append(stages, stage) })
+
+			if !errors.Is(got.Err, tt.want.Err) || (got.Err == nil) != (tt.want.Err == nil) {
+				t.Errorf("Err = %v, want %v", got.Err, tt.want.Err)
+			}
+			got.Err, tt.want.Err = nil, nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("runDictation() = %+v, want %+v", got, tt.want)
+			}
+			if !slices.Contains(stages, stageTranscribing) {
+				t.Errorf("progress stages = %v, want transcribing reported", stages)
+			}
+			if f.ui.status != "" {
+				t.Errorf("status = %q, want the menu bar left alone", f.ui.status)
+			}
+		})
+	}
+}
+
+// TestDictationErrorStatus tests the status line shown for each failure
+func TestDictationErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("%w: exit status 1", errRephrase), "Error: Claude rephrasing failed"},
+		{fmt.Errorf("%w: not allowed", errType), "Error: Failed to type"},
+		{errors.New("something else"), "Error: something else"},
+	}
+
+	for _, tt := range tests {
+		if got := dictationErrorStatus(tt.err); got != tt.want {
+			t.Errorf("dictationErrorStatus(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+// TestHotkeyActions tests that a recording keeps the action of the hotkey
+// that started it, whichever hotkey stops it
+func TestHotkeyActions(t *testing.T) {
+	tests := []struct {
+		action        string
+		wantEvents    []string // After the processing indicator is deleted
+		wantClipboard string
+	}{
+		{config.ActionPlain, []string{"type:hello world"}, "user content"},
+		{config.ActionClipboard, nil, "hello world"},
+		{config.ActionRephrase, []string{"type:Asking Claude", "backspace:13", "type:Rephrased."}, "user content"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			f := setupDictation(t, "hello world")
+
+			handleHotkeyAction(tt.action)
+			handleHotkey()
+			if got := getState(); got != StateIdle {
+				t.Fatalf("state = %s, want Idle", got)
+			}
+
+			want := append([]string{"type:Recording", "backspace:9", "type:Processing", "backspace:10"}, tt.wantEvents...)
+			if got := strings.Join(f.injector.events, ", "); got != strings.Join(want, ", ") {
+				t.Errorf("injected events = [%s], want [%s]", got, strings.Join(want, ", "))
+			}
+			if *f.clipboard != tt.wantClipboard {
+				t.Errorf("clipboard = %q, want %q", *f.clipboard, tt.wantClipboard)
+			}
+		})
+	}
+}
+
+// TestLongOutputConfirmation tests the choices offered for a dictation over
+// maxOutputChars
+func TestLongOutputConfirmation(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxChars      int
+		choice        string
+		wantEvents    []string // After the processing indicator is deleted
+		wantClipboard string
+		wantAction    dictationAction
+	}{
+		{"under the limit", 100, "", []string{"type:hello world"}, "user content", actionType},
+		{"check disabled", 0, "", []string{"type:hello world"}, "user content", actionType},
+		{"type it", 5, longOutputType, []string{"dialog:GoWhisper - Long Dictation", "type:hello world"}, "user content", actionType},
+		{"copy instead", 5, longOutputCopy, []string{"dialog:GoWhisper - Long Dictation"}, "hello world", actionClipboard},
+		{"discard", 5, longOutputDiscard, []string{"dialog:GoWhisper - Long Dictation"}, "user content", actionNone},
+		{"dialog failed", 5, "", []string{"dialog:GoWhisper - Long Dictation"}, "user content", actionNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := setupDictation(t, "hello world")
+			cfg().MaxOutputChars = tt.maxChars
+			f.injector.choice = tt.choice
+
+			res := runDictation(context.Background(), f.recorder, config.ActionPlain, func(dictationStage, int) {})
+
+			want := append([]string{"backspace:9", "type:Processing", "backspace:10"}, tt.wantEvents...)
+			if got := strings.Join(f.injector.events, ", "); got != strings.Join(want, ", ") {
+				t.Errorf("injected events = [%s], want [%s]", got, strings.Join(want, ", "))
+			}
+			if *f.clipboard != tt.wantClipboard {
+				t.Errorf("clipboard = %q, want %q", *f.clipboard, tt.wantClipboard)
+			}
+			if res.Action != tt.wantAction {
+				t.Errorf("Action = %q, want %q", res.Action, tt.wantAction)
+			}
+		})
+	}
+}
+
+// TestEmptyDictationStatus tests the status explaining why nothing was typed
+func TestEmptyDictationStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		level      float32
+		transcript string
+		wantEmpty  emptyReason
+		wantStatus string
+	}{
+		{"silent recording", 0, "", emptySilent, "Nothing heard, check the microphone"},
+		{"sound without speech", 0.1, "", emptyNoSpeech, "No speech recognized"},
+		{"speech", 0.1, "hello world", emptyNone, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := setupDictation(t, tt.transcript)
+			for i := range f.recorder.samples {
+				f.recorder.samples[i] = tt.level
+			}
+
+			res := runDictation(context.Background(), f.recorder, config.ActionPlain, func(dictationStage, int) {})
+			if res.Empty != tt.wantEmpty {
+				t.Errorf("Empty = %v, want %v", res.Empty, tt.wantEmpty)
+			}
+			if got := emptyStatus(res.Empty); got != tt.wantStatus {
+				t.Errorf("emptyStatus() = %q, want %q", got, tt.wantStatus)
+			}
+		})
+	}
+
+	t.Run("shown after dictation", func(t *testing.T) {
+		f := setupDictation(t, "")
+		handleHotkey()
+		handleHotkey()
+		if f.ui.status != "No speech recognized" {
+			t.Errorf("status = %q, want %q", f.ui.status, "No speech recognized")
+		}
+	})
+}
+
+// TestProofread tests the local spell check step
+func TestProofread(t *testing.T) {
+	tests := []struct {
+		name       string
+		transcript string
+		spellCheck bool
+		checkErr   error
+		want       string // Text typed, "" if nothing was checked
+		wantCheck  string
+	}{
+		{"keyword", "proofread please recieve it", false, nil, "please receive it", "please recieve it"},
+		{"keyword with clipboard", "proofread clipboard recieve it", false, nil, "", "recieve it"},
+		{"every dictation", "please recieve it", true, nil, "please receive it", "please recieve it"},
+		{"not without keyword or config", "please recieve it", false, nil, "please recieve it", ""},
+		{"skipped for claude", "claude please recieve it", true, nil, "Rephrased.", ""},
+		{"checker missing", "proofread please recieve it", false, postprocess.ErrNoSpellChecker, "please recieve it", "please recieve it"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := setupDictation(t, tt.transcript)
+			cfg().SpellCheck = tt.spellCheck
+			origCorrect := correctSpelling
+			t.Cleanup(func() { correctSpelling = origCorrect })
+			var checked string
+			correctSpelling = func(text, dictionary string) (string, error) {
+				checked = text
+				if tt.checkErr != nil {
+					return text, tt.checkErr
+				}
+				return strings.ReplaceAll(text, "recieve", "receive"), nil
+			}
+
+			res := runDictation(context.Background(), f.recorder, config.ActionPlain, func(dictationStage, int) {})
+			if checked != tt.wantCheck {
+				t.Errorf("checked %q, want %q", checked, tt.wantCheck)
+			}
+			if tt.want != "" && res.Text != tt.want {
+				t.Errorf("Text = %q, want %q", res.Text, tt.want)
+			}
+		})
+	}
+}
+
+// TestTimestampKeyword tests that "timestamp" puts the date and time before
+// the text, also together with other keywords
+func TestTimestampKeyword(t *testing.T) {
+	tests := []struct {
+		name          string
+		transcript    string
+		want          string // Typed text, with {ts} for the timestamp
+		wantClipboard string
+		wantRephrased string
+	}{
+		{"prefixed", "timestamp call the dentist", "{ts} call the dentist", "user content", ""},
+		{"keyword alone", "Timestamp.", "{ts}", "user content", ""},
+		{"with clipboard", "clipboard timestamp call back", "", "{ts} call back", ""},
+		{"with claude", "claude timestamp fix this", "{ts} Rephrased.", "user content", "fix this"},
+		{"not a keyword further in", "set the timestamp column", "set the timestamp column", "user content", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := setupDictation(t, tt.transcript)
+			cfg().TimestampFormat = "2006-01-02"
+
+			before := time.Now().Format(cfg().TimestampFormat)
+			res := runDictation(context.Background(), f.recorder, config.ActionPlain, func(dictationStage, int) {})
+			if res.Err != nil {
+				t.Fatalf("runDictation() error = %v", res.Err)
+			}
+			// Any date from the run counts, in case it crossed midnight
+			after := time.Now().Format(cfg().TimestampFormat)
+			matches := func(got, want string) bool {
+				return got == strings.ReplaceAll(want, "{ts}", before) || got == strings.ReplaceAll(want, "{ts}", after)
+			}
+
+			typed := ""
+			if res.Action == actionType {
+				typed = res.Text
+			}
+			if !matches(typed, tt.want) {
+				t.Errorf("typed %q, want %q", typed, tt.want)
+			}
+			if !matches(*f.clipboard, tt.wantClipboard) {
+				t.Errorf("clipboard = %q, want %q", *f.clipboard, tt.wantClipboard)
+			}
+			if got := strings.Join(f.rephrased, "|"); got != tt.wantRephrased {
+				t.Errorf("sent to Claude = %q, want %q", got, tt.wantRephrased)
+			}
+		})
+	}
+}
+
+// TestPasteFallback tests that text the focused app ignored stays on the
+// clipboard and the user is told to paste it
+func TestPasteFallback(t *testing.T) {
+	tests := []struct {
+		name        string
+		role, value string
+		verify      bool
+		wantPasted  bool
+	}{
+		{"landed", "AXTextArea", "notes: hello world", true, true},
+		{"button focused", "AXButton", "", true, false},
+		{"text field without the text", "AXTextField", "search", true, false},
+		{"check disabled", "AXButton", "", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := setupDictation(t, "hello world")
+			cfg().VerifyPaste = tt.verify
+			f.injector.focused = func() (string, string, error) { return tt.role, tt.value, nil }
+
+			handleHotkey()
+			handleHotkey()
+
+			notified := slices.Contains(f.injector.events, "notify:GoWhisper")
+			if tt.wantPasted {
+				if notified || f.ui.status == "Not pasted, text copied to clipboard" {
+					t.Errorf("fallback used for a paste that landed, events %v", f.injector.events)
+				}
+				if got := takeLastInjectedLen(); got != len("hello world") {
+					t.Errorf("last injected length = %d, want %d", got, len("hello world"))
+				}
+				return
+			}
+			if !notified {
+				t.Errorf("events = %v, want a notification", f.injector.events)
+			}
+			if f.ui.status != "Not pasted, text copied to clipboard" {
+				t.Errorf("status = %q, want the not pasted warning", f.ui.status)
+			}
+			if *f.clipboard != "hello world" {
+				t.Errorf("clipboard = %q, want the dictation", *f.clipboard)
+			}
+			// Undo must not delete text that was never typed
+			if got := takeLastInjectedLen(); got != 0 {
+				t.Errorf("last injected length = %d, want 0", got)
+			}
+		})
+	}
+}
+
+// TestTranslateEnglishOnlyModel tests that the translate keyword with an
+// English-only model types the original text and says why
+func TestTranslateEnglishOnlyModel(t *testing.T) {
+	f := setupDictation(t, "translate hello world")
+	f.transcriber.translateErr = whisper.ErrNotMultilingual
+
+	handleHotkey()
+	handleHotkey()
+
+	if !slices.Contains(f.injector.events, "type:hello world") {
+		t.Errorf("events = %v, want the untranslated text typed", f.injector.events)
+	}
+	if f.ui.status != "Translation needs a multilingual model" {
+		t.Errorf("status = %q, want the multilingual model warning", f.ui.status)
+	}
+}
+
+// TestTranscribeErrorPolicy tests the retry, the saved recording and the
+// clipboard note when Whisper fails
+func TestTranscribeErrorPolicy(t *testing.T) {
+	failure := errors.New("server unreachable")
+	tests := []struct {
+		name      string
+		errs      []error
+		retry     bool
+		save      bool
+		note      bool
+		wantCalls int
+		wantErr   bool
+		wantSaved bool
+		wantNote  string // Start of the clipboard, "" if untouched
+	}{
+		{"retry succeeds", []error{failure}, true, false, false, 2, false, false, ""},
+		{"retry fails too", []error{failure, failure}, true, false, false, 2, true, false, ""},
+		{"no retry", []error{failure}, false, false, false, 1, true, false, ""},
+		{"audio saved", []error{failure}, false, true, false, 1, true, true, ""},
+		{"note", []error{failure}, false, false, true, 1, true, false, "GoWhisper couldn't transcribe"},
+		{"note with saved audio", []error{failure}, false, true, true, 1, true, true, "GoWhisper couldn't transcribe"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(paths.HomeEnv, t.TempDir())
+			f := setupDictation(t, "hello world")
+			f.transcriber.errs = tt.errs
+			cfg().TranscribeRetry, cfg().SaveFailedAudio, cfg().FailedClipboardNote = tt.retry, tt.save, tt.note
+
+			res := runDictation(context.Background(), f.recorder, config.ActionPlain, func(dictationStage, int) {})
+			if f.transcriber.calls != tt.wantCalls {
+				t.Errorf("transcribed %d times, want %d", f.transcriber.calls, tt.wantCalls)
+			}
+			if (res.Err != nil) != tt.wantErr {
+				t.Fatalf("runDictation() error = %v, want error %v", res.Err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				if res.Text != "hello world" {
+					t.Errorf("Text = %q, want %q", res.Text, "hello world")
+				}
+				return
+			}
+
+			if (res.SavedAudio != "") != tt.wantSaved {
+				t.Errorf("SavedAudio = %q, want saved %v", res.SavedAudio, tt.wantSaved)
+			}
+			if tt.wantSaved {
+				data, err := os.ReadFile(res.SavedAudio)
+				if err != nil {
+					t.Fatalf("reading saved audio: %v", err)
+				}
+				wav, _ := audio.EncodeWAV(f.recorder.samples)
+				if want := len(wav); len(data) != want {
+					t.Errorf("saved %d bytes, want a %d byte WAV of the recording", len(data), want)
+				}
+			}
+
+			clipboard := *f.clipboard
+			if tt.wantNote == "" {
+				if clipboard != "user content" {
+					t.Errorf("clipboard = %q, want it untouched", clipboard)
+				}
+			} else if !strings.HasPrefix(clipboard, tt.wantNote) {
+				t.Errorf("clipboard = %q, want a note", clipboard)
+			} else if tt.wantSaved && !strings.Contains(clipboard, res.SavedAudio) {
+				t.Errorf("clipboard = %q, want it to mention %q", clipboard, res.SavedAudio)
+			}
+		})
+	}
+}
+
+// TestWakeDictation tests that a dictation started by the wake phrase types
+// what was said after it, and that the phrase can't stop a recording
+func TestWakeDictation(t *testing.T) {
+	f := setupDictation(t, "Okay. Hey whisper, clipboard call the dentist")
+	cfg().WakePhrase = "hey whisper"
+
+	handleHotkeyAction(actionWake)
+	if got := getState(); got != StateRecording {
+		t.Fatalf("state after wake phrase = %s, want Recording", got)
+	}
+	handleHotkeyAction(actionWake)
+	if got := getState(); got != StateRecording {
+		t.Fatalf("state after second wake phrase = %s, want still Recording", got)
+	}
+	handleHotkey()
+
+	if *f.clipboard != "call the dentist" {
+		t.Errorf("clipboard = %q, want %q", *f.clipboard, "call the dentist")
+	}
+}
+
+// TestAutoRephraseConfidence tests that only transcriptions Whisper was
+// unsure about are sent to Claude, and only when enabled
+func TestAutoRephraseConfidence(t *testing.T) {
+	tests := []struct {
+		name          string
+		threshold     float32
+		confidence    float32
+		wantRephrased bool
+	}{
+		{"disabled", 0, 0.3, false},
+		{"confident", 0.6, 0.9, false},
+		{"unsure", 0.6, 0.3, true},
+		{"unknown confidence", 0.6, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := setupDictation(t, "the whether is nice")
+			cfg().AutoRephraseConfidence = tt.threshold
+			f.transcriber.confidence = tt.confidence
+
+			res := runDictation(context.Background(), f.recorder, config.ActionPlain, func(dictationStage, int) {})
+			if res.Rephrased != tt.wantRephrased {
+				t.Errorf("Rephrased = %v, want %v", res.Rephrased, tt.wantRephrased)
+			}
+			if sent := len(f.rephrased) > 0; sent != tt.wantRephrased {
+				t.Errorf("sent to Claude = %v, want %v", sent, tt.wantRephrased)
+			}
+		})
+	}
+}
+
+// TestKeywordCommand tests that a command keyword outputs what its command
+// printed, with no other keyword applied to the spoken argument
+func TestKeywordCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		transcript  string
+		action      string
+		commandErr  error
+		wantArg     string
+		wantEvents  []string
+		wantClip    string
+		wantErr     error
+		wantKeyword []string
+	}{
+		{"typed", "Search, clipboard the weather in Utrecht", config.ActionPlain, nil, "clipboard the weather in Utrecht",
+			[]string{"backspace:9", "type:Processing", "backspace:10", "type:Running search", "backspace:14", "type:Sunny."}, "user content", nil, []string{"search"}},
+		{"copied by hotkey", "search the weather", config.ActionClipboard, nil, "the weather",
+			[]string{"backspace:9", "type:Processing", "backspace:10", "type:Running search", "backspace:14"}, "Sunny.", nil, []string{"search"}},
+		{"command fails", "search the weather", config.ActionPlain, errors.New("exit status 1"), "the weather",
+			[]string{"backspace:9", "type:Processing", "backspace:10", "type:Running search", "backspace:14"}, "user content", errCommand, []string{"search"}},
+		{"not first word", "I search the weather", config.ActionPlain, nil, "",
+			[]string{"backspace:9", "type:Processing", "backspace:10", "type:I search the weather"}, "user content", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := setupDictation(t, tt.transcript)
+			cfg().KeywordCommands = []config.KeywordCommand{{Keyword: "search", Command: "answer {text}"}}
+			var arg string
+			runCommand = func(_ context.Context, command config.KeywordCommand, text string) (string, error) {
+				arg = text
+				return "Sunny.", tt.commandErr
+			}
+
+			res := runDictation(context.Background(), f.recorder, tt.action, func(dictationStage, int) {})
+			if arg != tt.wantArg {
+				t.Errorf("command ran with %q, want %q", arg, tt.wantArg)
+			}
+			if got := strings.Join(f.injector.events, ", "); got != strings.Join(tt.wantEvents, ", ") {
+				t.Errorf("injected events = [%s], want [%s]", got, strings.Join(tt.wantEvents, ", "))
+			}
+			if *f.clipboard != tt.wantClip {
+				t.Errorf("clipboard = %q, want %q", *f.clipboard, tt.wantClip)
+			}
+			if !errors.Is(res.Err, tt.wantErr) {
+				t.Errorf("Err = %v, want %v", res.Err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(res.Keywords, tt.wantKeyword) {
+				t.Errorf("Keywords = %v, want %v", res.Keywords, tt.wantKeyword)
+			}
+		})
+	}
+}
+
+// TestUndoPhrase tests that saying only an undo phrase deletes the previous
+// dictation instead of typing the phrase
+func TestUndoPhrase(t *testing.T) {
+	tests := []struct {
+		name       string
+		first      string // Dictated before the undo phrase, "" for none
+		before     string // Text before the cursor in the focused field, unreadable if ""
+		transcript string
+		wantAction dictationAction
+		wantEvents []string
+	}{
+		{"undo", "hello world", "", "Scratch that.", actionUndo,
+			[]string{"backspace:9", "type:Processing", "backspace:10", "backspace:11"}},
+		{"nothing to undo", "", "", "scratch that", actionNone,
+			[]string{"backspace:9", "type:Processing", "backspace:10"}},
+		{"longer sentence", "hello world", "", "Scratch that idea from the list.", actionType,
+			[]string{"backspace:9", "type:Processing", "backspace:10", "type:Scratch that idea from the list."}},
+		{"dictation right before the cursor", "hello world", "Notes: Hello, world!", "Scratch that.", actionUndo,
+			[]string{"backspace:9", "type:Processing", "backspace:10", "backspace:11"}},
+		{"text typed after it", "hello world", "hello world and more", "Scratch that.", actionNone,
+			[]string{"backspace:9", "type:Processing", "backspace:10"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := setupDictation(t, tt.first)
+			if tt.first != "" {
+				runDictation(context.Background(), f.recorder, config.ActionPlain, func(dictationStage, int) {})
+			}
+			f.injector.events = nil
+			f.transcriber.text = tt.transcript
+			if tt.before != "" {
+				f.injector.beforeCursor = func() (string, string, error) { return "AXTextArea", tt.before, nil }
+			}
+
+			res := runDictation(context.Background(), f.recorder, config.ActionPlain, func(dictationStage, int) {})
+			if res.Action != tt.wantAction {
+				t.Errorf("Action = %q, want %q", res.Action, tt.wantAction)
+			}
+			if got := strings.Join(f.injector.events, ", "); got != strings.Join(tt.wantEvents, ", ") {
+				t.Errorf("injected events = [%s], want [%s]", got, strings.Join(tt.wantEvents, ", "))
+			}
+		})
+	}
+}
+
+// TestPreserveSelection tests that only the dictation is typed into the
+// window, so it replaces a selection there
+func TestPreserveSelection(t *testing.T) {
+	tests := []struct {
+		name       string
+		transcript string
+		cancel     bool
+		wantEvents []string
+	}{
+		{"plain dictation", "hello world", false, []string{"type:hello world"}},
+		{"claude", "claude fix this", false, []string{"type:Rephrased."}},
+		{"cancelled", "hello world", true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := setupDictation(t, tt.transcript)
+			cfg().PreserveSelection = true
+			if tt.cancel {
+				f.transcriber.during = func(context.Context) { cancelDictation() }
+			}
+
+			handleHotkey()
+			handleHotkey()
+			if got := strings.Join(f.injector.events, ", "); got != strings.Join(tt.wantEvents, ", ") {
+				t.Errorf("injected events = [%s], want [%s]", got, strings.Join(tt.wantEvents, ", "))
+			}
+		})
+	}
+}
+
+// TestCancelDictation tests that cancelling a dictation outputs nothing and
+// cleans up the window indicators, wherever it is cancelled
+func TestCancelDictation(t *testing.T) {
+	recording := []string{"type:Recording", "backspace:9", "type:Processing"}
+	tests := []struct {
+		name       string
+		transcript string
+		setup      func(f *dictationFakes)
+		wantEvents []string
+	}{
+		{
+			name:       "while transcribing",
+			transcript: "hello world",
+			setup: func(f *dictationFakes) {
+				f.transcriber.during = func(context.Context) { cancelDictation() }
+			},
+			wantEvents: []string{"backspace:10"},
+		},
+		{
+			name:       "while asking Claude",
+			transcript: "claude fix this",
+			setup: func(f *dictationFakes) {
+				rephraseText = func(ctx context.Context, text string) (string, error) {
+					cancelDictation()
+					<-ctx.Done()
+					return "", ctx.Err()
+				}
+			},
+			wantEvents: []string{"backspace:10", "type:Asking Claude", "backspace:13"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := setupDictation(t, tt.transcript)
+			tt.setup(f)
+
+			handleHotkey()
+			handleHotkey()
+			if got := getState(); got != StateIdle {
+				t.Errorf("state = %s, want Idle", got)
+			}
+			want := append(append([]string{}, recording...), tt.wantEvents...)
+			if got := strings.Join(f.injector.events, ", "); got != strings.Join(want, ", ") {
+				t.Errorf("injected events = [%s], want [%s]", got, strings.Join(want, ", "))
+			}
+			if got := getLastOutput(); got != "" {
+				t.Errorf("getLastOutput() = %q, want nothing output", got)
+			}
+			if f.ui.status != "Dictation cancelled" {
+				t.Errorf("status = %q, want %q", f.ui.status, "Dictation cancelled")
+			}
+			if cancelDictation() {
+				t.Error("cancelDictation() = true after the dictation finished, want false")
+			}
+		})
+	}
+}
+
+// TestDictationWorker tests that the stop press returns while the dictation
+// is still processing on the worker
+func TestDictationWorker(t *testing.T) {
+	worker := processInBackground
+	f := setupDictation(t, "hello world")
+	processInBackground = worker
+	select {
+	case <-dictationDone: // Left over from an earlier test
+	default:
+	}
+	release := make(chan struct{})
+	f.transcriber.during = func(context.Context) { <-release }
+
+	handleHotkey()
+	handleHotkey()
+	if got := getState(); got != StateProcessing {
+		t.Fatalf("state after stop press = %s, want Processing", got)
+	}
+
+	close(release)
+	select {
+	case <-dictationDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("dictation worker did not finish")
+	}
+	if got := getState(); got != StateIdle {
+		t.Errorf("state = %s, want Idle", got)
+	}
+	if got := getLastOutput(); got != "hello world" {
+		t.Errorf("getLastOutput() = %q, want %q", got, "hello world")
+	}
+}
+
+// TestContinuousSession tests that a session keeps recording after each pause
+// until a hotkey is pressed
+func TestContinuousSession(t *testing.T) {
+	f := setupDictation(t, "hello world")
+	cfg().AutoStopSilenceMs = 0
+	cfg().SessionPauseMs = 1500
+
+	handleHotkeyAction(config.ActionSession)
+	if !isSessionActive() || getState() != StateRecording {
+		t.Fatalf("session = %v, state = %s, want a session recording", isSessionActive(), getState())
+	}
+	if f.recorder.autoStop != 1500*time.Millisecond {
+		t.Errorf("auto-stop = %v, want the session pause", f.recorder.autoStop)
+	}
+
+	// Two pauses each type the text and go straight back to recording
+	finishAfterPause()
+	finishAfterPause()
+	if got := getState(); got != StateRecording {
+		t.Errorf("state after pause = %s, want Recording", got)
+	}
+	if f.recorder.starts != 3 {
+		t.Errorf("recording started %d times, want 3", f.recorder.starts)
+	}
+	var typed int
+	for _, e := range f.injector.events {
+		if e == "type:hello world" {
+			typed++
+		}
+	}
+	if typed != 2 {
+		t.Errorf("typed the text %d times, want once per pause: %v", typed, f.injector.events)
+	}
+
+	// The hotkey types what was said so far and ends the session
+	handleHotkey()
+	if isSessionActive() || getState() != StateIdle {
+		t.Errorf("session = %v, state = %s, want ended and Idle", isSessionActive(), getState())
+	}
+	if f.recorder.autoStop != 0 {
+		t.Errorf("auto-stop = %v, want the configured auto-stop restored", f.recorder.autoStop)
+	}
+}
+
+// TestSessionEndsWhileProcessing tests that a press during a session's
+// processing stops it from resuming
+func TestSessionEndsWhileProcessing(t *testing.T) {
+	setupDictation(t, "hello world")
+	handleHotkeyAction(config.ActionSession)
+	setState(StateProcessing)
+
+	handleHotkey()
+	if isSessionActive() {
+		t.Error("session still active after a press while processing")
+	}
+	if got := getState(); got != StateProcessing {
+		t.Errorf("state = %s, want the dictation in progress left alone", got)
+	}
+}
+
+// TestTailCapture tests that recording continues briefly after the stop hotkey
+func TestTailCapture(t *testing.T) {
+	tests := []struct {
+		name        string
+		tailMs      int
+		injectionMs int
+		wantMin     time.Duration
+	}{
+		{"tail only", 60, 0, 60 * time.Millisecond},
+		{"covered by the injection delay", 30, 60, 60 * time.Millisecond},
+		{"disabled", 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := setupDictation(t, "hello world")
+			cfg().TailCaptureMs = tt.tailMs
+			cfg().InjectionDelayMs = tt.injectionMs
+
+			start := time.Now()
+			runDictation(context.Background(), f.recorder, config.ActionPlain, func(dictationStage, int) {})
+			if got := f.recorder.stoppedAt.Sub(start); got < tt.wantMin || got > tt.wantMin+200*time.Millisecond {
+				t.Errorf("stopped after %v, want about %v", got, tt.wantMin)
+			}
+		})
+	}
+}
+
+// TestURLOutput tests sending plain dictations to the output URL
+func TestURLOutput(t *testing.T) {
+	tests := []struct {
+		name          string
+		transcript    string
+		openErr       error
+		wantURL       string
+		wantClipboard string
+		wantAction    dictationAction
+		wantErr       error
+	}{
+		{"opened", "hello world", nil, "drafts://create?text=hello%20world", "user content", actionURL, nil},
+		{"clipboard keyword still copies", "clipboard hello", nil, "", "hello", actionClipboard, nil},
+		{"too long is copied", strings.Repeat("a", maxOpenURLLength), nil, "", strings.Repeat("a", maxOpenURLLength), actionClipboard, nil},
+		{"open fails", "hello world", errors.New("no app for scheme"), "drafts://create?text=hello%20world", "user content", actionNone, errOpenURL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := setupDictation(t, tt.transcript)
+			cfg().OutputTarget = config.OutputTargetURL
+			cfg().MaxOutputChars = 0
+			origOpen := openURL
+			t.Cleanup(func() { openURL = origOpen })
+			var opened string
+			openURL = func(u string) error {
+				opened = u
+				return tt.openErr
+			}
+
+			res := runDictation(context.Background(), f.recorder, config.ActionPlain, func(dictationStage, int) {})
+
+			if opened != tt.wantURL {
+				t.Errorf("opened URL = %q, want %q", opened, tt.wantURL)
+			}
+			if *f.clipboard != tt.wantClipboard {
+				t.Errorf("clipboard = %q, want %q", *f.clipboard, tt.wantClipboard)
+			}
+			if res.Action != tt.wantAction {
+				t.Errorf("Action = %q, want %q", res.Action, tt.wantAction)
+			}
+			if !errors.Is(res.Err, tt.wantErr) {
+				t.Errorf("Err = %v, want %v", res.Err, tt.wantErr)
+			}
+			for _, e := range f.injector.events {
+				if strings.HasPrefix(e, "type:") && e != "type:Processing" {
+					t.Errorf("injected %q, want nothing typed", e)
+				}
+			}
+		})
+	}
+}
+
+// TestDictationHistory tests that successful dictations are added to the history
+func TestDictationHistory(t *testing.T) {
+	f := setupDictation(t, "clipboard copy this")
+	origHistory := dictationHistory
+	t.Cleanup(func() { dictationHistory = origHistory })
+	dictationHistory = history.Open(filepath.Join(t.TempDir(), "history.jsonl"))
+
+	runDictation(context.Background(), f.recorder, config.ActionPlain, func(dictationStage, int) {})
+	f.transcriber.text = ""
+	runDictation(context.Background(), f.recorder, config.ActionPlain, func(dictationStage, int) {})
+
+	entries, err := dictationHistory.Entries()
+	if err != nil {
+		t.Fatalf("Entries() error = %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("history has %d entries, want 1 for the dictation with speech", len(entries))
+	}
+	got := entries[0]
+	if got.Action != "clipboard" || got.Raw != "clipboard copy this" || got.Text != "copy this" {
+		t.Errorf("history entry = %+v, want the clipboard dictation", got)
+	}
+}
+
+// TestKeepRecordings tests that only the newest recordings are kept, each
+// linked from its history entry
+func TestKeepRecordings(t *testing.T) {
+	f := setupDictation(t, "hello world")
+	origHistory := dictationHistory
+	t.Cleanup(func() { dictationHistory = origHistory })
+	dictationHistory = history.Open(filepath.Join(t.TempDir(), "history.jsonl"))
+	t.Setenv(paths.HomeEnv, t.TempDir())
+	cfg().KeepRecordings = 2
+
+	for i := 0; i < 3; i++ {
+		// Files are named by the millisecond they were saved in
+		time.Sleep(2 * time.Millisecond)
+		runDictation(context.Background(), f.recorder, config.ActionPlain, func(dictationStage, int) {})
+	}
+
+	entries, err := dictationHistory.Entries()
+	if err != nil {
+		t.Fatalf("Entries() error = %v", err)
+	}
+	files, err := filepath.Glob(filepath.Join(recordingsDir(), "*.wav"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("recordings = %v, %v, want 2 files", files, err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("history has %d entries, want 3", len(entries))
+	}
+	if entries[0].Audio == "" || slices.Contains(files, entries[0].Audio) {
+		t.Errorf("oldest entry audio = %q, want a deleted file", entries[0].Audio)
+	}
+	for _, e := range entries[1:] {
+		if !slices.Contains(files, e.Audio) {
+			t.Errorf("entry audio = %q, want one of %v", e.Audio, files)
+		}
+	}
+}
+
+// TestRecentMenuTitle tests the one-line previews in the Recent Dictations menu
+func TestRecentMenuTitle(t *testing.T) {
+	at := time.Date(2026, 3, 14, 9, 5, 0, 0, time.Local)
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"short", "hello world", "09:05  hello world"},
+		{"lines joined", "first line\n\nsecond  line", "09:05  first line second line"},
+		{"truncated", "the quick brown fox jumps over the lazy dog again", "09:05  the quick brown fox jumps over the lazy…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := recentMenuTitle(history.Entry{Time: at, Text: tt.text}); got != tt.want {
+				t.Errorf("recentMenuTitle() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCopyRecentDictation tests that a recent dictation is copied in full
+func TestCopyRecentDictation(t *testing.T) {
+	content := fakeClipboard(t, "user content")
+	origTexts := recentTexts
+	t.Cleanup(func() { recentTexts = origTexts })
+	recentTexts = []string{"newest\nwith two lines", "older"}
+
+	copyRecentDictation(0)
+	if *content != "newest\nwith two lines" {
+		t.Errorf("clipboard = %q, want the full newest dictation", *content)
+	}
+	copyRecentDictation(2) // No dictation in that item
+	if *content != "newest\nwith two lines" {
+		t.Errorf("clipboard = %q after clicking an empty item, want it unchanged", *content)
+	}
+}
+
+// TestTranscribeClipboardFile tests that a path that can't be transcribed is
+// reported in a notification and leaves the clipboard alone
+func TestTranscribeClipboardFile(t *testing.T) {
+	dir := t.TempDir()
+	notes := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(notes, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty.wav")
+	if err := os.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		clipboard string
+	}{
+		{"empty clipboard", ""},
+		{"missing file", filepath.Join(dir, "missing.wav")},
+		{"not audio", notes},
+		{"no audio data", empty},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := setupDictation(t, "hello world")
+			*f.clipboard = tt.clipboard
+
+			transcribeClipboardFile()
+			if got := strings.Join(f.injector.events, ", "); got != "notify:GoWhisper" {
+				t.Errorf("events = %q, want a notification", got)
+			}
+			if *f.clipboard != tt.clipboard {
+				t.Errorf("clipboard = %q, want it unchanged", *f.clipboard)
+			}
+			if f.transcriber.calls != 0 {
+				t.Errorf("transcribed %d times, want 0", f.transcriber.calls)
+			}
+		})
+	}
+}
+
+// TestTranscribeClipboardFileState tests that a file is transcribed in
+// Processing, so the settings and the model can't change underneath it, and
+// that it waits for a dictation to finish
+func TestTranscribeClipboardFileState(t *testing.T) {
+	f := setupDictation(t, "hello world")
+	path := filepath.Join(t.TempDir(), "memo.wav")
+	wav, err := audio.EncodeWAV(f.recorder.samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, wav, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var during AppState
+	f.transcriber.during = func(context.Context) { during = getState() }
+	*f.clipboard = path
+	transcribeClipboardFile()
+	if during != StateProcessing {
+		t.Errorf("state while transcribing = %v, want Processing", during)
+	}
+	if got := getState(); got != StateIdle {
+		t.Errorf("state after transcribing = %v, want Idle", got)
+	}
+	if *f.clipboard != "hello world" {
+		t.Errorf("clipboard = %q, want the transcription", *f.clipboard)
+	}
+
+	*f.clipboard = path
+	setState(StateRecording)
+	transcribeClipboardFile()
+	if f.transcriber.calls != 1 {
+		t.Errorf("transcribed %d times, want no transcription while recording", f.transcriber.calls)
+	}
+	if getState() != StateRecording {
+		t.Errorf("state = %v, want the recording left alone", getState())
+	}
+}
+
+// TestDiscardJunk tests that a lone filler word is thrown away like an empty
+// transcription, leaving nothing in the window
+func TestDiscardJunk(t *testing.T) {
+	tests := []struct {
+		name       string
+		transcript string
+		wantAction dictationAction
+		wantEvents []string
+	}{
+		{"lone period", ".", actionNone, []string{"backspace:9", "type:Processing", "backspace:10"}},
+		{"filler word", "You.", actionNone, []string{"backspace:9", "type:Processing", "backspace:10"}},
+		{"sentence", "you too", actionType, []string{"backspace:9", "type:Processing", "backspace:10", "type:you too"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := setupDictation(t, tt.transcript)
+			cfg().DiscardShorterThan = 2
+			cfg().DiscardWords = []string{"you"}
+
+			res := runDictation(context.Background(), f.recorder, config.ActionPlain, func(dictationStage, int) {})
+			if res.Action != tt.wantAction || res.Empty != emptyNone || res.Err != nil {
+				t.Errorf("result = %q, empty %v, error %v, want %q", res.Action, res.Empty, res.Err, tt.wantAction)
+			}
+			if got := strings.Join(f.injector.events, ", "); got != strings.Join(tt.wantEvents, ", ") {
+				t.Errorf("injected events = [%s], want [%s]", got, strings.Join(tt.wantEvents, ", "))
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gordonklaus/portaudio"
+	"github.com/stephanwesten/go-whisper/src/audio"
+	"github.com/stephanwesten/go-whisper/src/whisper"
+)
+
+const (
+	// doctorRecordDuration is how long the microphone test records
+	doctorRecordDuration = 2 * time.Second
+	// doctorMinRMS is the level below which the test clip counts as silent.
+	// macOS delivers pure silence when microphone access is denied.
+	doctorMinRMS = 0.001
+	// doctorSamplePhrase is part of doctorSamplePath
+	doctorSamplePhrase = "ask not what your country can do for you"
+	// doctorSamplePath is the JFK sample from whisper.cpp in doctorSamples
+	doctorSamplePath = "samples/jfk.wav"
+)
+
+// doctorSamples holds the known recording the transcription check runs, so
+// it works wherever GoWhisper is installed. See samples/README.md.
+//
+//go:embed samples
+var doctorSamples embed.FS
+
+// doctorCheck is one step of the --doctor self-test. run returns a short
+// detail for the summary, or an error if the check failed.
+type doctorCheck struct {
+	name string
+	run  func() (string, error)
+}
+
+// runDoctor checks the microphone, model and permissions one by one and prints
+// a pass/fail line for each. Returns the process exit code.
+func runDoctor() int {
+	var t *whisper.Transcriber
+	defer func() {
+		if t != nil {
+			t.Close()
+		}
+	}()
+
+	checks := []doctorCheck{
+		{"Audio devices", checkAudioDevices},
+		{"Microphone", checkMicrophone},
+		{"Whisper model", func() (string, error) {
+			var err error
+			t, err = whisper.NewTranscriber(getModelPath())
+			if err != nil {
+				return "", err
+			}
+			return getModelPath(), nil
+		}},
+		{"Transcription", func() (string, error) {
+			if t == nil {
+				return "", fmt.Errorf("skipped, model not loaded")
+			}
+			return checkTranscription(t)
+		}},
+		{"Accessibility", func() (string, error) {
+			if err := injector.CheckAccess(); err != nil {
+				return "", fmt.Errorf("%v (grant access in System Settings → Privacy & Security → Accessibility)", err)
+			}
+			return "keystroke injection allowed", nil
+		}},
+	}
+
+	failed := 0
+	for _, check := range checks {
+		fmt.Printf("Checking %s...\n", strings.ToLower(check.name))
+		detail, err := check.run()
+		if err != nil {
+			failed++
+			fmt.Printf("  ✗ FAIL %s: %v\n", check.name, err)
+		} else {
+			fmt.Printf("  ✓ PASS %s: %s\n", check.name, detail)
+		}
+	}
+
+	fmt.Println()
+	if failed > 0 {
+		fmt.Printf("%d of %d checks failed\n", failed, len(checks))
+		return 1
+	}
+	fmt.Printf("All %d checks passed\n", len(checks))
+	return 0
+}
+
+// checkAudioDevices initializes PortAudio and lists the input devices
+func checkAudioDevices() (string, error) {
+	if err := portaudio.Initialize(); err != nil {
+		return "", fmt.Errorf("failed to initialize PortAudio: %w", err)
+	}
+	defer portaudio.Terminate()
+
+	devices, err := portaudio.Devices()
+	if err != nil {
+		return "", fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	var inputs []string
+	for _, device := range devices {
+		if device.MaxInputChannels > 0 {
+			inputs = append(inputs, device.Name)
+			fmt.Printf("    input: %s (%d channels, %.0fHz)\n", device.Name, device.MaxInputChannels, device.DefaultSampleRate)
+		}
+	}
+	if len(inputs) == 0 {
+		return "", fmt.Errorf("no input devices found")
+	}
+
+	detail := fmt.Sprintf("%d input device(s)", len(inputs))
+	if def, err := portaudio.DefaultInputDevice(); err == nil {
+		detail += ", default: " + def.Name
+	}
+	return detail, nil
+}
+
+// checkMicrophone records a short clip from the default input and reports its level
+func checkMicrophone() (string, error) {
+	recorder, err := audio.NewRecorder()
+	if err != nil {
+		return "", err
+	}
+	defer recorder.Close()
+
+	fmt.Printf("    recording for %v, say something...\n", doctorRecordDuration)
+	if err := recorder.Start(); err != nil {
+		return "", err
+	}
+	time.Sleep(doctorRecordDuration)
+	samples, err := recorder.Stop()
+	if err != nil && len(samples) == 0 {
+		return "", err
+	}
+
+	rms := audio.RMS(samples)
+	if rms < doctorMinRMS {
+		return "", fmt.Errorf("recorded only silence (RMS %.5f), check microphone permission and input volume", rms)
+	}
+	return fmt.Sprintf("%d samples, RMS %.4f", len(samples), rms), nil
+}
+
+// checkTranscription runs the built-in JFK sample through Transcribe and
+// checks the expected phrase comes out
+func checkTranscription(t *whisper.Transcriber) (string, error) {
+	data, err := doctorSamples.ReadFile(doctorSamplePath)
+	if err != nil {
+		return "", fmt.Errorf("sample not built in, add src/%s and rebuild: %w", doctorSamplePath, err)
+	}
+
+	samples, err := audio.DecodeWAV(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode %s: %w", doctorSamplePath, err)
+	}
+
+	start := time.Now()
+	text, err := t.Transcribe(samples, false)
+	if err != nil {
+		return "", err
+	}
+	elapsed := time.Since(start)
+
+	normalized := strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !('a' <= r && r <= 'z')
+	}), " ")
+	if !strings.Contains(normalized, doctorSamplePhrase) {
+		return "", fmt.Errorf("unexpected output %q", text)
+	}
+	return fmt.Sprintf("sample transcribed correctly in %v", elapsed.Round(time.Millisecond)), nil
+}
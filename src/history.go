@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getlantern/systray"
+	"github.com/stephanwesten/go-whisper/src/history"
+	"github.com/stephanwesten/go-whisper/src/paths"
+)
+
+const (
+	// keychainService names GoWhisper's items in the keychain
+	keychainService = "GoWhisper"
+	// historyKeychainAccount holds the passphrase of the encrypted history
+	historyKeychainAccount = "history"
+)
+
+// errKeychainItemNotFound is returned by keychainLookup when the keychain
+// works but has no item for the account, as opposed to a locked keychain or
+// denied access
+var errKeychainItemNotFound = errors.New("not in the keychain")
+
+// dictationHistory is the history of dictations, nil unless enabled in config
+var dictationHistory *history.Store
+
+// recentMenuSize is how many dictations the Recent Dictations menu lists
+const recentMenuSize = 5
+
+// recentPreviewLength is how many characters of a dictation its menu item shows
+const recentPreviewLength = 40
+
+var (
+	// The Recent Dictations submenu and its items, nil until onReady. The
+	// items are reused for newer dictations since systray can't remove them.
+	mRecent      *systray.MenuItem
+	mRecentItems []*systray.MenuItem
+
+	// Full text of each recent dictation, by menu item
+	recentMu    sync.Mutex
+	recentTexts []string
+)
+
+// historyPaths returns the plain and the encrypted history file
+func historyPaths() (plain, encrypted string) {
+	return filepath.Join(paths.DataDir(), "history.jsonl"), filepath.Join(paths.DataDir(), "history.enc")
+}
+
+// initHistory opens the history if enabled in config. Without a working
+// keychain an encrypted history is disabled rather than written in plain text.
+func initHistory() {
+	if !cfg().History {
+		return
+	}
+	plainPath, encryptedPath := historyPaths()
+	if !cfg().HistoryEncrypt {
+		dictationHistory = history.Open(plainPath)
+		log.Printf("Saving dictation history to %s", plainPath)
+		return
+	}
+
+	passphrase, err := historyPassphrase(true)
+	if err != nil {
+		log.Printf("Warning: history disabled, %v", err)
+		return
+	}
+	store, err := history.OpenEncrypted(encryptedPath, passphrase)
+	if err != nil {
+		log.Printf("Warning: history disabled, %v", err)
+		return
+	}
+	dictationHistory = store
+	log.Printf("Saving encrypted dictation history to %s", encryptedPath)
+}
+
+// historyPassphrase returns the passphrase of the encrypted history from the
+// keychain. With create set, a random one is generated and saved on first use,
+// when the keychain has none and there is no encrypted history yet. Replacing
+// the passphrase of an existing history would make it unreadable.
+func historyPassphrase(create bool) (string, error) {
+	passphrase, err := keychainLookup(historyKeychainAccount)
+	if !create || !errors.Is(err, errKeychainItemNotFound) {
+		return passphrase, err
+	}
+	_, encryptedPath := historyPaths()
+	if _, statErr := os.Stat(encryptedPath); !errors.Is(statErr, fs.ErrNotExist) {
+		return "", fmt.Errorf("%w, but %s exists; restore the keychain item or move the file away", err, encryptedPath)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	passphrase = base64.StdEncoding.EncodeToString(key)
+	if err := keychainStore(historyKeychainAccount, passphrase); err != nil {
+		return "", err
+	}
+	log.Println("Created a new history passphrase in the keychain")
+	return passphrase, nil
+}
+
+// recordHistory adds a successful dictation to the history, if enabled
+func recordHistory(res dictationResult) {
+	if dictationHistory == nil || res.Action == actionNone {
+		return
+	}
+	entry := history.Entry{Time: time.Now(), Action: string(res.Action), Raw: res.RawText, Text: res.Text, Audio: res.Recording}
+	if err := dictationHistory.Append(entry); err != nil {
+		log.Printf("Warning: failed to save history: %v", err)
+		return
+	}
+	refreshRecentMenu()
+}
+
+// addRecentMenu adds the Recent Dictations submenu, which copies an earlier
+// dictation to the clipboard. It lists the history, so it is left out when
+// the history is disabled.
+func addRecentMenu(ctx context.Context) {
+	if dictationHistory == nil {
+		return
+	}
+	mRecent = systray.AddMenuItem("Recent Dictations", "Copy an earlier dictation to the clipboard")
+	for i := range recentMenuSize {
+		item := mRecent.AddSubMenuItem("", "Copy to the clipboard")
+		item.Hide()
+		mRecentItems = append(mRecentItems, item)
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-item.ClickedCh:
+					copyRecentDictation(i)
+				}
+			}
+		}()
+	}
+	refreshRecentMenu()
+}
+
+// refreshRecentMenu shows the newest dictations from the history in the
+// Recent Dictations menu
+func refreshRecentMenu() {
+	if mRecent == nil {
+		return
+	}
+	entries, err := dictationHistory.Recent(recentMenuSize)
+	if err != nil {
+		log.Printf("Warning: failed to read recent dictations: %v", err)
+		return
+	}
+
+	recentMu.Lock()
+	defer recentMu.Unlock()
+	recentTexts = recentTexts[:0]
+	for i, item := range mRecentItems {
+		if i >= len(entries) {
+			item.Hide()
+			continue
+		}
+		recentTexts = append(recentTexts, entries[i].Text)
+		item.SetTitle(recentMenuTitle(entries[i]))
+		item.Show()
+	}
+	if len(entries) == 0 {
+		mRecent.Disable()
+	} else {
+		mRecent.Enable()
+	}
+}
+
+// copyRecentDictation puts the full text of the i-th recent dictation on the
+// clipboard
+func copyRecentDictation(i int) {
+	recentMu.Lock()
+	if i >= len(recentTexts) {
+		recentMu.Unlock()
+		return
+	}
+	text := recentTexts[i]
+	recentMu.Unlock()
+
+	if err := setClipboardContent(text); err != nil {
+		log.Printf("Error copying recent dictation: %v", err)
+		mStatus.SetTitle("Error: Could not copy to the clipboard")
+		mStatus.Show()
+		return
+	}
+	log.Println("Copied a recent dictation to the clipboard")
+}
+
+// recentMenuTitle is the menu item of e: its time and the start of its text
+// on one line
+func recentMenuTitle(e history.Entry) string {
+	text := strings.Join(strings.Fields(e.Text), " ")
+	if runes := []rune(text); len(runes) > recentPreviewLength {
+		text = strings.TrimSpace(string(runes[:recentPreviewLength])) + "…"
+	}
+	return e.Time.Local().Format("15:04") + "  " + text
+}
+
+// runHistory prints the plain and the encrypted history to stdout, oldest
+// first, decrypting with the passphrase from the keychain. Returns the
+// process exit code.
+func runHistory() int {
+	plainPath, encryptedPath := historyPaths()
+	var entries []history.Entry
+
+	plain, err := history.Open(plainPath).Entries()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	entries = append(entries, plain...)
+
+	if _, err := os.Stat(encryptedPath); err == nil {
+		passphrase, err := historyPassphrase(false)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: can't decrypt %s: %v\n", encryptedPath, err)
+			return 1
+		}
+		store, err := history.OpenEncrypted(encryptedPath, passphrase)
+		if err == nil {
+			var encrypted []history.Entry
+			encrypted, err = store.Entries()
+			entries = append(entries, encrypted...)
+		}
+		if err != nil {
+			if errors.Is(err, history.ErrWrongKey) {
+				err = fmt.Errorf("%w; was the keychain item replaced?", err)
+			}
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	if len(entries) == 0 {
+		fmt.Fprintln(os.Stderr, "No history yet. Set \"history\": true in config.json to keep one.")
+		return 0
+	}
+	slices.SortStableFunc(entries, func(a, b history.Entry) int { return a.Time.Compare(b.Time) })
+	for _, e := range entries {
+		fmt.Println(formatHistoryEntry(e))
+	}
+	return 0
+}
+
+// formatHistoryEntry formats e as one block for --history output
+func formatHistoryEntry(e history.Entry) string {
+	text := strings.ReplaceAll(e.Text, "\n", "\n    ")
+	line := fmt.Sprintf("%s [%s] %s", e.Time.Local().Format("2006-01-02 15:04"), e.Action, text)
+	if e.Audio != "" {
+		line += "\n    Audio: " + e.Audio
+	}
+	return line
+}
@@ -0,0 +1,253 @@
+// Package history keeps a log of past dictations, optionally encrypted at rest.
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is one dictation in the history
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"` // What was done with the text, e.g. "type"
+	Raw    string    `json:"raw"`    // Whisper's transcription
+	Text   string    `json:"text"`   // The text that was output
+	// Recording kept for the dictation, which is deleted again once newer
+	// ones replace it
+	Audio string `json:"audio,omitempty"`
+}
+
+const (
+	// header starts the first line of an encrypted history, followed by the
+	// base64 salt the key was derived with
+	header   = "gowhisper-history-v1"
+	saltSize = 16
+)
+
+// kdfIterations is the PBKDF2-SHA256 work factor recommended by OWASP. A
+// variable so tests can make opening cheaper.
+var kdfIterations = 600000
+
+// ErrWrongKey is returned when an encrypted entry can't be decrypted with the
+// passphrase the history was opened with
+var ErrWrongKey = errors.New("history can't be decrypted with this passphrase")
+
+// Store appends entries to a history file and reads them back. A plain store
+// writes one JSON object per line; an encrypted one writes each entry sealed
+// with AES-256-GCM and base64 encoded, so appending never rewrites the file.
+type Store struct {
+	mu   sync.Mutex
+	path string
+	aead cipher.AEAD // nil for a plain text history
+}
+
+// Open returns the plain text history at path
+func Open(path string) *Store {
+	return &Store{path: path}
+}
+
+// OpenEncrypted returns the encrypted history at path, with a key derived
+// from passphrase. A new file is started with a random salt; an existing one
+// keeps its salt, so the same passphrase always opens it. ErrWrongKey is
+// returned if the key doesn't decrypt the first entry, so entries are never
+// appended under a second key.
+func OpenEncrypted(path, passphrase string) (*Store, error) {
+	salt, err := readSalt(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		salt, err = createWithSalt(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, kdfIterations, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive history key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{path: path, aead: aead}
+	if err := s.checkKey(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// checkKey decrypts the first entry of the encrypted history, if it has any
+func (s *Store) checkKey() error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	if _, err := reader.ReadBytes('\n'); err != nil {
+		return fmt.Errorf("failed to read history header: %w", err)
+	}
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			_, err := s.open(line)
+			return err
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read history: %w", err)
+		}
+	}
+}
+
+// readSalt returns the salt from the header of the encrypted history at path
+func readSalt(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history header: %w", err)
+	}
+	name, encoded, _ := strings.Cut(strings.TrimSpace(line), " ")
+	if name != header {
+		return nil, fmt.Errorf("%s is not an encrypted GoWhisper history", path)
+	}
+	salt, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid history header: %w", err)
+	}
+	return salt, nil
+}
+
+// createWithSalt starts a new encrypted history at path with a random salt
+func createWithSalt(path string) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+	line := header + " " + base64.StdEncoding.EncodeToString(salt) + "\n"
+	if err := os.WriteFile(path, []byte(line), 0600); err != nil {
+		return nil, fmt.Errorf("failed to create history: %w", err)
+	}
+	return salt, nil
+}
+
+// Append adds e to the end of the history, creating the file if needed
+func (s *Store) Append(e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if s.aead != nil {
+		nonce := make([]byte, s.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		sealed := s.aead.Seal(nonce, nonce, line, nil)
+		line = []byte(base64.StdEncoding.EncodeToString(sealed))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// Entries returns every entry in the history, oldest first. A missing file
+// is an empty history.
+func (s *Store) Entries() ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	var entries []Entry
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) == 0 || (s.aead != nil && i == 0) {
+			continue // Blank line or the encryption header
+		}
+		if s.aead != nil {
+			if line, err = s.open(line); err != nil {
+				return nil, err
+			}
+		}
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, fmt.Errorf("invalid history entry on line %d: %w", i+1, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Recent returns the last n entries in the history, newest first
+func (s *Store) Recent(n int) ([]Entry, error) {
+	entries, err := s.Entries()
+	if err != nil {
+		return nil, err
+	}
+	entries = entries[max(0, len(entries)-n):]
+	slices.Reverse(entries)
+	return entries, nil
+}
+
+// open decrypts one encrypted line
+func (s *Store) open(line []byte) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(string(line))
+	if err != nil || len(sealed) < s.aead.NonceSize() {
+		return nil, fmt.Errorf("invalid encrypted history entry")
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	plain, err := s.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	return plain, nil
+}
@@ -0,0 +1,146 @@
+package history
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func init() {
+	kdfIterations = 1000
+}
+
+var testEntries = []Entry{
+	{Time: time.Date(2026, 3, 14, 9, 5, 0, 0, time.UTC), Action: "type", Raw: "hello world", Text: "hello world"},
+	{Time: time.Date(2026, 3, 14, 9, 6, 0, 0, time.UTC), Action: "clipboard", Raw: "clipboard secret\nplan", Text: "secret\nplan"},
+}
+
+// TestPlainHistory tests appending to and reading a plain text history
+func TestPlainHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "history.jsonl")
+	s := Open(path)
+
+	if got, err := s.Entries(); err != nil || got != nil {
+		t.Fatalf("Entries() of missing file = %v, %v, want nil, nil", got, err)
+	}
+	for _, e := range testEntries {
+		if err := s.Append(e); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	got, err := s.Entries()
+	if err != nil {
+		t.Fatalf("Entries() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, testEntries) {
+		t.Errorf("Entries() = %+v, want %+v", got, testEntries)
+	}
+}
+
+// TestEncryptedHistory tests that an encrypted history round-trips, keeps no
+// plain text on disk and can be reopened with the same passphrase only
+func TestEncryptedHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.enc")
+	s, err := OpenEncrypted(path, "correct horse")
+	if err != nil {
+		t.Fatalf("OpenEncrypted() error = %v", err)
+	}
+	for _, e := range testEntries {
+		if err := s.Append(e); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") || strings.Contains(string(data), "hello") {
+		t.Errorf("encrypted history contains plain text:\n%s", data)
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0600 {
+		t.Errorf("history permissions = %v, want 0600", info.Mode().Perm())
+	}
+
+	// Reopening reuses the salt, so the same passphrase reads the old entries
+	reopened, err := OpenEncrypted(path, "correct horse")
+	if err != nil {
+		t.Fatalf("OpenEncrypted() reopen error = %v", err)
+	}
+	got, err := reopened.Entries()
+	if err != nil {
+		t.Fatalf("Entries() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, testEntries) {
+		t.Errorf("Entries() = %+v, want %+v", got, testEntries)
+	}
+
+	// A wrong passphrase fails right away, before anything is appended
+	if _, err := OpenEncrypted(path, "battery staple"); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("OpenEncrypted() with wrong passphrase error = %v, want ErrWrongKey", err)
+	}
+	if after, err := os.ReadFile(path); err != nil || !bytes.Equal(after, data) {
+		t.Errorf("history changed by opening it with the wrong passphrase")
+	}
+}
+
+// TestOpenEncryptedEmpty tests that a history without entries yet opens with
+// any passphrase, as there is nothing to check it against
+func TestOpenEncryptedEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.enc")
+	if _, err := OpenEncrypted(path, "correct horse"); err != nil {
+		t.Fatalf("OpenEncrypted() error = %v", err)
+	}
+	if _, err := OpenEncrypted(path, "battery staple"); err != nil {
+		t.Errorf("OpenEncrypted() of an empty history error = %v, want nil", err)
+	}
+}
+
+// TestOpenEncryptedPlainFile tests that a plain history isn't mistaken for an
+// encrypted one
+func TestOpenEncryptedPlainFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	if err := Open(path).Append(testEntries[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenEncrypted(path, "pass"); err == nil {
+		t.Error("OpenEncrypted() of a plain history error = nil, want error")
+	}
+}
+
+// TestRecent tests getting the newest entries first
+func TestRecent(t *testing.T) {
+	s := Open(filepath.Join(t.TempDir(), "history.jsonl"))
+	if got, err := s.Recent(5); err != nil || len(got) != 0 {
+		t.Fatalf("Recent() of missing file = %v, %v, want none", got, err)
+	}
+	for _, e := range testEntries {
+		if err := s.Append(e); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		n    int
+		want []Entry
+	}{
+		{0, []Entry{}},
+		{1, []Entry{testEntries[1]}},
+		{5, []Entry{testEntries[1], testEntries[0]}},
+	}
+	for _, tt := range tests {
+		got, err := s.Recent(tt.n)
+		if err != nil {
+			t.Fatalf("Recent(%d) error = %v", tt.n, err)
+		}
+		if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("Recent(%d) = %+v, want %+v", tt.n, got, tt.want)
+		}
+	}
+}
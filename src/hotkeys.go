@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/stephanwesten/go-whisper/src/config"
+	"golang.design/x/hotkey"
+)
+
+// hotkeyClosedRetry is how long collectHotkey waits before reading the
+// keydown channel of an unregistered hotkey again
+const hotkeyClosedRetry = 100 * time.Millisecond
+
+// hotkeyKeys maps the key names accepted in configured hotkeys
+var hotkeyKeys = map[string]hotkey.Key{
+	"a": hotkey.KeyA, "b": hotkey.KeyB, "c": hotkey.KeyC, "d": hotkey.KeyD,
+	"e": hotkey.KeyE, "f": hotkey.KeyF, "g": hotkey.KeyG, "h": hotkey.KeyH,
+	"i": hotkey.KeyI, "j": hotkey.KeyJ, "k": hotkey.KeyK, "l": hotkey.KeyL,
+	"m": hotkey.KeyM, "n": hotkey.KeyN, "o": hotkey.KeyO, "p": hotkey.KeyP,
+	"q": hotkey.KeyQ, "r": hotkey.KeyR, "s": hotkey.KeyS, "t": hotkey.KeyT,
+	"u": hotkey.KeyU, "v": hotkey.KeyV, "w": hotkey.KeyW, "x": hotkey.KeyX,
+	"y": hotkey.KeyY, "z": hotkey.KeyZ,
+	"0": hotkey.Key0, "1": hotkey.Key1, "2": hotkey.Key2, "3": hotkey.Key3,
+	"4": hotkey.Key4, "5": hotkey.Key5, "6": hotkey.Key6, "7": hotkey.Key7,
+	"8": hotkey.Key8, "9": hotkey.Key9,
+	"space": hotkey.KeySpace, "tab": hotkey.KeyTab, "escape": hotkey.KeyEscape, "esc": hotkey.KeyEscape,
+	"return": hotkey.KeyReturn, "enter": hotkey.KeyReturn,
+	// The key labelled delete on a Mac keyboard, which deletes backwards
+	"delete": hotkey.KeyDelete, "backspace": hotkey.KeyDelete,
+	"left": hotkey.KeyLeft, "right": hotkey.KeyRight, "up": hotkey.KeyUp, "down": hotkey.KeyDown,
+	"f1": hotkey.KeyF1, "f2": hotkey.KeyF2, "f3": hotkey.KeyF3, "f4": hotkey.KeyF4,
+	"f5": hotkey.KeyF5, "f6": hotkey.KeyF6, "f7": hotkey.KeyF7, "f8": hotkey.KeyF8,
+	"f9": hotkey.KeyF9, "f10": hotkey.KeyF10, "f11": hotkey.KeyF11, "f12": hotkey.KeyF12,
+	"f13": hotkey.KeyF13, "f14": hotkey.KeyF14, "f15": hotkey.KeyF15, "f16": hotkey.KeyF16,
+	"f17": hotkey.KeyF17, "f18": hotkey.KeyF18, "f19": hotkey.KeyF19, "f20": hotkey.KeyF20,
+}
+
+// parseHotkey parses modifiers and a key joined by "+", e.g. "cmd+shift+c".
+// At least one modifier is required so a plain key press isn't swallowed
+// system-wide.
+func parseHotkey(s string) ([]hotkey.Modifier, hotkey.Key, error) {
+	parts := strings.Split(strings.ToLower(strings.ReplaceAll(s, " ", "")), "+")
+	key, ok := hotkeyKeys[parts[len(parts)-1]]
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid hotkey %q: unknown key %q", s, parts[len(parts)-1])
+	}
+	if len(parts) == 1 {
+		return nil, 0, fmt.Errorf("invalid hotkey %q: needs a modifier", s)
+	}
+
+	var mods []hotkey.Modifier
+	for _, name := range parts[:len(parts)-1] {
+		mod, ok := hotkeyModifierNames[name]
+		if !ok {
+			return nil, 0, fmt.Errorf("invalid hotkey %q: unknown modifier %q", s, name)
+		}
+		if !slices.Contains(mods, mod) {
+			mods = append(mods, mod)
+		}
+	}
+	return mods, key, nil
+}
+
+// registerActionHotkeys registers the hotkeys in cfg.ActionHotkeys, forwarding
+// their presses to triggerCh. A hotkey that can't be registered is skipped
+// with a warning; the main hotkey still works. The collectors run until
+// stopActionHotkeys or Quit.
+func registerActionHotkeys(triggerCh chan<- string) {
+	ctx, cancel := context.WithCancel(appCtx)
+	stopActionCollectors = cancel
+	for _, ah := range cfg().ActionHotkeys {
+		mods, key, err := parseHotkey(ah.Hotkey)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		h := hotkey.New(mods, key)
+		if err := h.Register(); err != nil {
+			log.Printf("Warning: failed to register %s hotkey %s: %v", ah.Action, ah.Hotkey, err)
+			continue
+		}
+		log.Printf("Hotkey registered: %s (%s)", ah.Hotkey, ah.Action)
+		actionHotkeys = append(actionHotkeys, h)
+		triggerSenders.Add(1)
+		go collectHotkey(ctx, h, ah.Action, triggerCh)
+	}
+}
+
+// stopActionHotkeys stops the collectors started by registerActionHotkeys
+func stopActionHotkeys() {
+	if stopActionCollectors != nil {
+		stopActionCollectors()
+		stopActionCollectors = nil
+	}
+}
+
+// keydownSource is the part of *hotkey.Hotkey read by collectHotkey
+type keydownSource interface {
+	Keydown() <-chan hotkey.Event
+}
+
+// collectHotkey forwards presses of h to triggerCh as action until ctx is
+// cancelled. Presses arriving while a trigger is still queued are dropped.
+// The caller adds it to triggerSenders.
+func collectHotkey(ctx context.Context, h keydownSource, action string, triggerCh chan<- string) {
+	defer triggerSenders.Done()
+	for {
+		// Unregister closes the keydown channel and the next Register
+		// makes a new one, so it is fetched again on every pass
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-h.Keydown():
+			if !ok {
+				// Unregistered; wait a moment for it to be registered again
+				// instead of spinning on the closed channel
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(hotkeyClosedRetry):
+				}
+				continue
+			}
+		}
+		// A press during a Claude rephrase cancels it, and one during a
+		// session ends it before the recording resumes. Handled here so the
+		// debounce in the trigger loop can't drop it.
+		if getState() == StateProcessing && (cancelRephrase() || endSession()) {
+			continue
+		}
+		// Try to send, but don't block if channel is full
+		select {
+		case triggerCh <- action:
+		default:
+		}
+	}
+}
+
+// setActionHotkeysRegistered registers or unregisters the action hotkeys
+// along with the main one. Failures are only logged.
+func setActionHotkeysRegistered(register bool) {
+	for _, h := range actionHotkeys {
+		var err error
+		if register {
+			err = h.Register()
+		} else {
+			err = h.Unregister()
+		}
+		if err != nil {
+			log.Printf("Warning: failed to update action hotkey %v: %v", h, err)
+		}
+	}
+}
+
+// setRecordingAction records the action of the hotkey that started the
+// current recording (thread-safe)
+func setRecordingAction(action string) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	recordingAction = action
+}
+
+// getRecordingAction returns the action the current recording was started
+// with (thread-safe)
+func getRecordingAction() string {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	if recordingAction == "" {
+		return config.ActionPlain
+	}
+	return recordingAction
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/stephanwesten/go-whisper/src/config"
+	"golang.design/x/hotkey"
+)
+
+// Injector types text into the focused window and shows dialogs.
+// Each platform provides its own implementation, selected by build tags.
+type Injector interface {
+	// SendText types text into the currently focused window
+	SendText(text string) error
+	// SendBackspaces deletes count characters before the cursor
+	SendBackspaces(count int) error
+	// ShowErrorDialog displays a blocking error dialog
+	ShowErrorDialog(title, message string)
+	// AskConfirmation shows a blocking dialog with Cancel and confirmButton,
+	// returning true if the user confirmed
+	AskConfirmation(title, message, confirmButton string) bool
+	// AskChoice shows a blocking dialog with one button per choice, the last
+	// being the default, returning the chosen one or "" if it was dismissed
+	AskChoice(title, message string, choices []string) string
+	// EditText is AskChoice with text the user can edit before choosing,
+	// returning the choice and the edited text
+	EditText(title, message, text string, choices []string) (choice, edited string)
+	// CheckAccess sends a harmless key press to verify keystrokes may be injected
+	CheckAccess() error
+	// FocusedElement returns the accessibility role of the focused UI element
+	// ("" when nothing has focus) and its text, if it exposes any
+	FocusedElement() (role, value string, err error)
+	// TextBeforeCursor returns the accessibility role of the focused UI
+	// element ("" when nothing has focus) and its text up to the cursor, or
+	// the start of the selection
+	TextBeforeCursor() (role, text string, err error)
+	// ShowNotification shows a notification that doesn't wait for the user
+	ShowNotification(title, message string)
+	// ActivateApp brings the running app name to the front, failing with
+	// errAppNotRunning if it isn't running. restore switches back to the app
+	// that was in front before.
+	ActivateApp(name string) (restore func() error, err error)
+}
+
+// errAppNotRunning is returned by ActivateApp for an app that isn't running,
+// which is not started since it may take a while to be ready for text
+var errAppNotRunning = errors.New("app not running")
+
+// errUnsupportedPlatform is returned on platforms without a backend, see
+// inject_other.go
+var errUnsupportedPlatform = errors.New("not supported on " + runtime.GOOS)
+
+// injector is the platform backend, see inject_darwin.go, inject_linux.go
+// and inject_other.go
+var injector Injector = newPlatformInjector()
+
+// sendBackspaces sends the specified number of backspace key presses to delete text
+func sendBackspaces(count int) error {
+	if count <= 0 {
+		return nil
+	}
+	return injector.SendBackspaces(count)
+}
+
+// sendTextToActiveWindow sends text to the currently active window
+func sendTextToActiveWindow(text string) error {
+	return injector.SendText(text)
+}
+
+// typeIndicator types a progress indicator such as "Recording" into the
+// active window. With cfg.PreserveSelection the window is left alone until
+// the text is output, so a selection in it is replaced by the dictation.
+// Without permission to type there is nothing to show it in, and with
+// cfg.TargetApp the active window isn't where the text will go.
+func typeIndicator(indicator string) error {
+	if cfg().PreserveSelection || isAccessDenied() || cfg().TargetApp != "" {
+		return nil
+	}
+	return sendTextToActiveWindow(indicator)
+}
+
+// deleteIndicator deletes an indicator typed by typeIndicator
+func deleteIndicator(indicator string) error {
+	if cfg().PreserveSelection || isAccessDenied() || cfg().TargetApp != "" {
+		return nil
+	}
+	return sendBackspaces(len(indicator))
+}
+
+// typeInChunks types text through typeChunk at most size characters at a
+// time, pausing between chunks, for apps that drop characters typed too fast
+func typeInChunks(text string, size int, pause time.Duration, typeChunk func(string) error) error {
+	for i, chunk := range textChunks(text, size) {
+		if i > 0 {
+			time.Sleep(pause)
+		}
+		if err := typeChunk(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// textChunks splits text into pieces of at most size characters. A "\r\n"
+// line break counts as one character and is never split.
+func textChunks(text string, size int) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	runes := []rune(text)
+	var chunks []string
+	for len(runes) > 0 {
+		n := min(max(size, 1), len(runes))
+		chunks = append(chunks, string(runes[:n]))
+		runes = runes[n:]
+	}
+	return chunks
+}
+
+// nonTextRoles are accessibility roles of focused elements that ignore a paste
+var nonTextRoles = []string{
+	"AXButton", "AXCheckBox", "AXRadioButton", "AXPopUpButton", "AXMenuButton",
+	"AXSlider", "AXImage", "AXList", "AXTable", "AXOutline", "AXBrowser",
+	"AXScrollArea", "AXWindow", "AXApplication",
+}
+
+// textRoles are accessibility roles of fields whose value is their text
+var textRoles = []string{"AXTextField", "AXTextArea", "AXComboBox"}
+
+// pasteTailLength is how many letters and digits from the end of the typed
+// text must show up in a readable text field
+const pasteTailLength = 12
+
+// pasteLanded judges from the focused element whether text typed into it can
+// have arrived. Only a clear miss counts: nothing focused, an element that
+// doesn't take text, or a text field whose content lacks the end of text.
+// Anything else, such as web content that doesn't expose its text, passes.
+func pasteLanded(role, value, text string) bool {
+	if role == "" || slices.Contains(nonTextRoles, role) {
+		return false
+	}
+	if !slices.Contains(textRoles, role) || value == "" {
+		return true
+	}
+	// Compare letters and digits only, ignoring case, so line breaks,
+	// auto-formatted punctuation and auto-capitalization in the field don't
+	// matter
+	tail := []rune(strings.ToLower(alphanumeric(text)))
+	tail = tail[max(0, len(tail)-pasteTailLength):]
+	return strings.Contains(strings.ToLower(alphanumeric(value)), string(tail))
+}
+
+// injectionBeforeCursor judges from the text before the cursor in the focused
+// element whether text is still right in front of it, ignoring case the app
+// may have changed. Only a readable text field can tell; anything else passes.
+func injectionBeforeCursor(role, before, text string) bool {
+	if !slices.Contains(textRoles, role) {
+		return true
+	}
+	return strings.HasSuffix(strings.ToLower(alphanumeric(before)), strings.ToLower(alphanumeric(text)))
+}
+
+// alphanumeric returns the letters and digits of s
+func alphanumeric(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// showErrorDialog displays an error dialog to the user
+func showErrorDialog(title, message string) {
+	injector.ShowErrorDialog(title, message)
+}
+
+// askConfirmation asks the user to confirm an action
+func askConfirmation(title, message, confirmButton string) bool {
+	return injector.AskConfirmation(title, message, confirmButton)
+}
+
+// askChoice asks the user to pick one of choices
+func askChoice(title, message string, choices ...string) string {
+	return injector.AskChoice(title, message, choices)
+}
+
+// editText shows text for the user to edit and pick one of choices for, the
+// last being the default
+func editText(title, message, text string, choices ...string) (string, string) {
+	return injector.EditText(title, message, text, choices)
+}
+
+// openFolder creates dir if needed and opens it in the file manager
+func openFolder(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	if openFolderCommand == "" {
+		return fmt.Errorf("failed to open %s: %w", dir, errUnsupportedPlatform)
+	}
+	if err := exec.Command(openFolderCommand, dir).Run(); err != nil {
+		return fmt.Errorf("failed to open %s: %w", dir, err)
+	}
+	return nil
+}
+
+// maxOpenURLLength is the longest URL handed to the open command. Launch
+// Services and URL handlers reject long URLs well before the argument limit.
+const maxOpenURLLength = 32 * 1024
+
+// outputURL returns template with "{text}" replaced by text, percent-encoded
+// so spaces become %20 rather than the "+" that not every app decodes
+func outputURL(template, text string) string {
+	escaped := strings.ReplaceAll(url.QueryEscape(text), "+", "%20")
+	return strings.ReplaceAll(template, "{text}", escaped)
+}
+
+// openURL opens u with the app registered for its scheme. A variable so tests
+// don't launch apps.
+var openURL = func(u string) error {
+	if openFolderCommand == "" {
+		return errUnsupportedPlatform
+	}
+	if out, err := exec.Command(openFolderCommand, u).CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// recordingHotkey returns the global hotkey used to start/stop recording,
+// see recordingHotkeyName
+func recordingHotkey() *hotkey.Hotkey {
+	mods, key, err := parseHotkey(recordingHotkeyName())
+	if err != nil {
+		return hotkey.New(hotkeyModifiers, hotkey.KeyP)
+	}
+	return hotkey.New(mods, key)
+}
+
+// recordingHotkeyName returns cfg.RecordingHotkey, or the default if it can't
+// be parsed
+func recordingHotkeyName() string {
+	if _, _, err := parseHotkey(cfg().RecordingHotkey); err != nil {
+		return config.Default().RecordingHotkey
+	}
+	return cfg().RecordingHotkey
+}
+
+// hotkeyLabel formats a hotkey for messages, e.g. "ctrl+option+space" as
+// "Ctrl+Option+Space"
+func hotkeyLabel(s string) string {
+	parts := strings.Split(strings.ReplaceAll(s, " ", ""), "+")
+	for i, part := range parts {
+		if r, size := utf8.DecodeRuneInString(part); size > 0 {
+			parts[i] = string(unicode.ToUpper(r)) + strings.ToLower(part[size:])
+		}
+	}
+	return strings.Join(parts, "+")
+}
+
+// rephraseHotkey returns the optional global hotkey that toggles rephrase by default
+func rephraseHotkey() *hotkey.Hotkey {
+	return hotkey.New(hotkeyModifiers, hotkey.KeyR)
+}
@@ -0,0 +1,416 @@
+//go:build darwin
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/stephanwesten/go-whisper/src/config"
+	"golang.design/x/hotkey"
+)
+
+// hotkeyModifiers is Cmd+Shift on macOS
+var hotkeyModifiers = []hotkey.Modifier{hotkey.ModCmd, hotkey.ModShift}
+
+// hotkeyModifierNames maps the modifier names accepted in configured hotkeys
+var hotkeyModifierNames = map[string]hotkey.Modifier{
+	"cmd":     hotkey.ModCmd,
+	"command": hotkey.ModCmd,
+	"shift":   hotkey.ModShift,
+	"ctrl":    hotkey.ModCtrl,
+	"control": hotkey.ModCtrl,
+	"opt":     hotkey.ModOption,
+	"option":  hotkey.ModOption,
+	"alt":     hotkey.ModOption,
+}
+
+// openFolderCommand reveals a folder in Finder
+const openFolderCommand = "open"
+
+// appleScriptInjector injects keystrokes and shows dialogs via osascript
+type appleScriptInjector struct{}
+
+func newPlatformInjector() Injector {
+	return appleScriptInjector{}
+}
+
+// SendBackspaces sends backspace key presses through System Events
+func (appleScriptInjector) SendBackspaces(count int) error {
+	// AppleScript to send backspace keys (key code 51 is delete/backspace)
+	script := `
+		tell application "System Events"
+			repeat ` + fmt.Sprintf("%d", count) + ` times
+				key code 51
+			end repeat
+		end tell
+	`
+
+	cmd := exec.Command("osascript", "-e", script)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		logDebug("AppleScript output: %s", string(output))
+		return err
+	}
+
+	log.Printf("Successfully sent %d backspaces", count)
+	return nil
+}
+
+// SendText types text into the active window using the configured injection mode
+func (a appleScriptInjector) SendText(text string) error {
+	switch cfg().InjectionMode {
+	case config.InjectionModeKeystroke:
+		return a.typeText(text)
+	case config.InjectionModeSlow:
+		if err := typeInChunks(text, cfg().SlowTypeChunkSize, cfg().SlowTypeDelay(), sendKeystrokes); err != nil {
+			return err
+		}
+		log.Printf("Successfully typed text slowly: %s", text)
+		return nil
+	}
+	return a.pasteText(text)
+}
+
+// typeText types text key by key, never touching the clipboard
+func (appleScriptInjector) typeText(text string) error {
+	if err := sendKeystrokes(text); err != nil {
+		return err
+	}
+
+	log.Printf("Successfully typed text: %s", text)
+	return nil
+}
+
+// sendKeystrokes types text through System Events
+func sendKeystrokes(text string) error {
+	cmd := exec.Command("osascript", "-e", keystrokeScript(text))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		logDebug("AppleScript output: %s", string(output))
+		return err
+	}
+	return nil
+}
+
+// pasteText pastes text into the active window using the clipboard and the
+// configured paste shortcut, Cmd+V by default
+func (a appleScriptInjector) pasteText(text string) error {
+	// For complex text (multiline, special chars), use clipboard + paste instead of keystroke
+	// This avoids AppleScript escaping issues and permission dialogs
+
+	// Put text in clipboard, saving the user's content for later unless the
+	// user would rather keep the pasted text there
+	restore := cfg().RestoreClipboard
+	var gen int
+	var err error
+	if restore {
+		gen, err = borrowClipboard(text)
+	} else {
+		err = setClipboardContent(text)
+	}
+	if err != nil {
+		// Without a usable clipboard, typing is slower but doesn't lose the text
+		log.Printf("Warning: Clipboard unavailable, typing text instead: %v", err)
+		return a.typeText(text)
+	}
+
+	// Use AppleScript to paste. Some apps need their "paste and match style"
+	// shortcut to drop the formatting of the surrounding text.
+	script, err := pasteShortcutScript(cfg().PasteShortcut)
+	if err != nil {
+		log.Printf("Warning: %v, using Cmd+V", err)
+		script, _ = pasteShortcutScript(config.Default().PasteShortcut)
+	}
+
+	cmd := exec.Command("osascript", "-e", script)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		logDebug("AppleScript output: %s", string(output))
+		// Try to restore clipboard even if paste failed
+		if restore {
+			restoreClipboardIfCurrent(gen)
+		}
+		return err
+	}
+
+	// Restore original clipboard content after a short delay
+	if restore {
+		scheduleClipboardRestore(gen, cfg().ClipboardRestoreDelay())
+	}
+
+	log.Printf("Successfully sent text: %s", text)
+	return nil
+}
+
+// ShowErrorDialog displays an AppleScript dialog
+func (appleScriptInjector) ShowErrorDialog(title, message string) {
+	// Escape inputs to prevent AppleScript injection
+	safeTitle := escapeAppleScriptString(title)
+	safeMessage := escapeAppleScriptString(message)
+
+	// AppleScript to show a dialog
+	script := `
+		display dialog "` + safeMessage + `" with title "` + safeTitle + `" buttons {"OK"} default button "OK" with icon caution
+	`
+
+	cmd := exec.Command("osascript", "-e", script)
+	if err := cmd.Run(); err != nil {
+		log.Printf("Failed to show error dialog: %v", err)
+	}
+}
+
+// AskConfirmation displays an AppleScript dialog with Cancel and confirmButton
+func (appleScriptInjector) AskConfirmation(title, message, confirmButton string) bool {
+	safeTitle := escapeAppleScriptString(title)
+	safeMessage := escapeAppleScriptString(message)
+	safeButton := escapeAppleScriptString(confirmButton)
+
+	script := `
+		display dialog "` + safeMessage + `" with title "` + safeTitle + `" buttons {"Cancel", "` + safeButton + `"} default button "` + safeButton + `" with icon caution
+	`
+
+	// Pressing Cancel makes osascript exit with an error
+	return exec.Command("osascript", "-e", script).Run() == nil
+}
+
+// AskChoice displays an AppleScript dialog with a button per choice
+func (appleScriptInjector) AskChoice(title, message string, choices []string) string {
+	safeTitle := escapeAppleScriptString(title)
+	safeMessage := escapeAppleScriptString(message)
+	buttons := make([]string, len(choices))
+	for i, c := range choices {
+		buttons[i] = `"` + escapeAppleScriptString(c) + `"`
+	}
+
+	script := `
+		display dialog "` + safeMessage + `" with title "` + safeTitle + `" buttons {` + strings.Join(buttons, ", ") + `} default button ` + buttons[len(buttons)-1] + ` with icon caution
+	`
+
+	// Prints "button returned:<choice>"
+	output, err := exec.Command("osascript", "-e", script).Output()
+	if err != nil {
+		log.Printf("Failed to show choice dialog: %v", err)
+		return ""
+	}
+	_, choice, _ := strings.Cut(strings.TrimSpace(string(output)), "button returned:")
+	return choice
+}
+
+// editTextScript shows a dialog with an editable text field. The arguments
+// are the title, the message, the text and the buttons, the last being the
+// default; passing them as arguments needs no escaping. Prints the button
+// clicked and the edited text on the following lines.
+const editTextScript = `
+	on run argv
+		set choices to items 4 thru -1 of argv
+		set answer to display dialog (item 2 of argv) with title (item 1 of argv) default answer (item 3 of argv) buttons choices default button (item -1 of argv)
+		return (button returned of answer) & linefeed & (text returned of answer)
+	end run
+`
+
+// EditText displays an AppleScript dialog with a text field and a button
+// per choice
+func (appleScriptInjector) EditText(title, message, text string, choices []string) (string, string) {
+	args := append([]string{"-e", editTextScript, title, message, text}, choices...)
+	output, err := exec.Command("osascript", args...).Output()
+	if err != nil {
+		log.Printf("Failed to show edit dialog: %v", err)
+		return "", ""
+	}
+	choice, edited, _ := strings.Cut(strings.TrimSuffix(string(output), "\n"), "\n")
+	return choice, edited
+}
+
+// focusedElementScript prints the role of the frontmost app's focused UI
+// element and its value on the following lines, or nothing when no element
+// has focus
+const focusedElementScript = `
+	tell application "System Events"
+		set frontApp to first application process whose frontmost is true
+		set focused to value of attribute "AXFocusedUIElement" of frontApp
+		if focused is missing value then return ""
+		set elementValue to ""
+		try
+			set elementValue to value of focused as text
+		end try
+		return (role of focused) & linefeed & elementValue
+	end tell
+`
+
+// FocusedElement reads the focused UI element through System Events
+func (appleScriptInjector) FocusedElement() (string, string, error) {
+	output, err := exec.Command("osascript", "-e", focusedElementScript).Output()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read the focused element: %w", err)
+	}
+	role, value, _ := strings.Cut(strings.TrimSuffix(string(output), "\n"), "\n")
+	return role, value, nil
+}
+
+// textBeforeCursorScript prints the role of the frontmost app's focused UI
+// element, where its selection starts and its value on the following lines,
+// or nothing when no element has focus. System Events can't read the
+// parameterized AXStringForRange, so the value is cut at the selection in Go.
+// AXSelectedTextRange comes back as {location + 1, location + length}.
+const textBeforeCursorScript = `
+	tell application "System Events"
+		set frontApp to first application process whose frontmost is true
+		set focused to value of attribute "AXFocusedUIElement" of frontApp
+		if focused is missing value then return ""
+		set selectedRange to value of attribute "AXSelectedTextRange" of focused
+		set elementValue to value of focused as text
+		return (role of focused) & linefeed & ((item 1 of selectedRange) - 1) & linefeed & elementValue
+	end tell
+`
+
+// TextBeforeCursor reads the focused UI element and where its selection
+// starts through System Events
+func (appleScriptInjector) TextBeforeCursor() (string, string, error) {
+	output, err := exec.Command("osascript", "-e", textBeforeCursorScript).Output()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read the text before the cursor: %w", err)
+	}
+	role, rest, _ := strings.Cut(strings.TrimSuffix(string(output), "\n"), "\n")
+	if role == "" {
+		return "", "", nil
+	}
+	location, value, _ := strings.Cut(rest, "\n")
+	offset, err := strconv.Atoi(location)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid selection start %q", location)
+	}
+	return role, utf16Prefix(value, offset), nil
+}
+
+// utf16Prefix returns the start of s up to offset, counted in UTF-16 code
+// units like accessibility ranges
+func utf16Prefix(s string, offset int) string {
+	units := 0
+	for i, r := range s {
+		if units >= offset {
+			return s[:i]
+		}
+		units += max(1, utf16.RuneLen(r))
+	}
+	return s
+}
+
+// activateAppScript brings the process named by the first argument to the
+// front and prints the name of the process that was in front before, or
+// nothing if the app isn't running
+const activateAppScript = `
+	on run argv
+		tell application "System Events"
+			if not (exists application process (item 1 of argv)) then return ""
+			set previousApp to name of first application process whose frontmost is true
+			set frontmost of application process (item 1 of argv) to true
+			return previousApp
+		end tell
+	end run
+`
+
+// focusAppScript brings the process named by the first argument to the front
+const focusAppScript = `
+	on run argv
+		tell application "System Events" to set frontmost of application process (item 1 of argv) to true
+	end run
+`
+
+// ActivateApp switches apps through System Events, by process name
+func (appleScriptInjector) ActivateApp(name string) (func() error, error) {
+	output, err := exec.Command("osascript", "-e", activateAppScript, name).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to activate %s: %w", name, err)
+	}
+	previous := strings.TrimSpace(string(output))
+	if previous == "" {
+		return nil, fmt.Errorf("%s: %w", name, errAppNotRunning)
+	}
+	return func() error {
+		if err := exec.Command("osascript", "-e", focusAppScript, previous).Run(); err != nil {
+			return fmt.Errorf("failed to switch back to %s: %w", previous, err)
+		}
+		return nil
+	}, nil
+}
+
+// ShowNotification displays a notification through Notification Center
+func (appleScriptInjector) ShowNotification(title, message string) {
+	script := `display notification "` + escapeAppleScriptString(message) + `" with title "` + escapeAppleScriptString(title) + `"`
+	if err := exec.Command("osascript", "-e", script).Run(); err != nil {
+		log.Printf("Failed to show notification: %v", err)
+	}
+}
+
+// CheckAccess taps Shift through System Events, which fails without
+// Accessibility permission but has no effect on the focused app
+func (appleScriptInjector) CheckAccess() error {
+	// key code 56 is the left Shift key
+	cmd := exec.Command("osascript", "-e", `tell application "System Events" to key code 56`)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("System Events refused the key press: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// keystrokeScript builds an AppleScript that types text key by key through
+// System Events. Line breaks are sent as Return presses since keystroke
+// doesn't reliably type them.
+func keystrokeScript(text string) string {
+	var b strings.Builder
+	b.WriteString("tell application \"System Events\"\n")
+	for i, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if i > 0 {
+			b.WriteString("\tkey code 36\n") // Return
+		}
+		if line != "" {
+			b.WriteString("\tkeystroke \"" + escapeAppleScriptString(line) + "\"\n")
+		}
+	}
+	b.WriteString("end tell")
+	return b.String()
+}
+
+// shortcutModifiers maps the modifiers of a parsed shortcut to their
+// AppleScript "using" clause
+var shortcutModifiers = map[string]string{
+	"cmd":     "command down",
+	"shift":   "shift down",
+	"option":  "option down",
+	"control": "control down",
+}
+
+// pasteShortcutScript builds an AppleScript that presses shortcut, written as
+// modifiers and a single key joined by "+", e.g. "cmd+shift+v"
+func pasteShortcutScript(shortcut string) (string, error) {
+	modifiers, key, err := config.ParseShortcut(shortcut)
+	if err != nil {
+		return "", err
+	}
+
+	keystroke := "keystroke \"" + escapeAppleScriptString(key) + "\""
+	if len(modifiers) > 0 {
+		using := make([]string, len(modifiers))
+		for i, mod := range modifiers {
+			using[i] = shortcutModifiers[mod]
+		}
+		keystroke += " using {" + strings.Join(using, ", ") + "}"
+	}
+	return "tell application \"System Events\" to " + keystroke, nil
+}
+
+// escapeAppleScriptString escapes special characters for safe use in AppleScript strings
+// This prevents AppleScript injection attacks
+func escapeAppleScriptString(s string) string {
+	// Escape backslashes first (must be done before escaping quotes)
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	// Then escape double quotes
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return s
+}
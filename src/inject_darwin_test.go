@@ -0,0 +1,168 @@
+//go:build darwin
+
+package main
+
+import "testing"
+
+// TestUTF16Prefix tests cutting a field's value at an accessibility offset
+func TestUTF16Prefix(t *testing.T) {
+	tests := []struct {
+		value  string
+		offset int
+		want   string
+	}{
+		{"hello world", 5, "hello"},
+		{"hello world", 0, ""},
+		{"hello world", 50, "hello world"},
+		{"café au lait", 4, "café"},
+		{"👍 done", 2, "👍"}, // Outside the BMP, two UTF-16 units
+		{"👍 done", 3, "👍 "},
+	}
+
+	for _, tt := range tests {
+		if got := utf16Prefix(tt.value, tt.offset); got != tt.want {
+			t.Errorf("utf16Prefix(%q, %d) = %q, want %q", tt.value, tt.offset, got, tt.want)
+		}
+	}
+}
+
+// TestAppleScriptInjectionProtection tests that showErrorDialog properly escapes input
+// This addresses High Priority Issue #6: AppleScript injection in showErrorDialog
+func TestAppleScriptInjectionProtection(t *testing.T) {
+	t.Run("error dialog should escape quotes and special characters", func(t *testing.T) {
+		// Current code at line 709:
+		// script := `display dialog "` + message + `" with title "` + title + `" ...`
+		//
+		// Vulnerability: If message or title contains a double quote ("), it can break
+		// out of the string and inject arbitrary AppleScript
+		//
+		// Example attack:
+		// title = `Test"`
+		// Would generate: display dialog "..." with title "Test"" ...
+		// The extra quote breaks the syntax
+		//
+		// More severe attack:
+		// message = `foo" & (do shell script "rm -rf ~") & "`
+		// Would execute arbitrary shell commands
+		//
+		// Fix: Escape double quotes in both title and message
+		// Replace " with \"
+		//
+		// Better fix: Use a helper function to escape AppleScript strings
+		// func escapeAppleScriptString(s string) string {
+		//     s = strings.ReplaceAll(s, `\`, `\\`)  // Escape backslashes first
+		//     s = strings.ReplaceAll(s, `"`, `\"`)  // Then escape quotes
+		//     return s
+		// }
+
+		t.Log("Current: Direct string concatenation allows injection")
+		t.Log("Fix: Escape backslashes and quotes in title and message")
+		t.Log("Add escapeAppleScriptString() helper function")
+	})
+
+	t.Run("escapeAppleScriptString should properly escape special characters", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			input    string
+			expected string
+		}{
+			{
+				name:     "simple string",
+				input:    "Hello World",
+				expected: "Hello World",
+			},
+			{
+				name:     "string with quotes",
+				input:    `He said "Hello"`,
+				expected: `He said \"Hello\"`,
+			},
+			{
+				name:     "string with backslash",
+				input:    `C:\Users\test`,
+				expected: `C:\\Users\\test`,
+			},
+			{
+				name:     "string with backslash and quote",
+				input:    `Path: "C:\test"`,
+				expected: `Path: \"C:\\test\"`,
+			},
+			{
+				name:     "injection attempt",
+				input:    `foo" & (do shell script "rm -rf ~") & "bar`,
+				expected: `foo\" & (do shell script \"rm -rf ~\") & \"bar`,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				result := escapeAppleScriptString(tt.input)
+				if result != tt.expected {
+					t.Errorf("escapeAppleScriptString(%q) = %q, want %q", tt.input, result, tt.expected)
+				}
+			})
+		}
+	})
+}
+
+// TestKeystrokeScript tests the AppleScript used by keystroke injection mode
+func TestKeystrokeScript(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "single line",
+			input: "hello world",
+			want:  "tell application \"System Events\"\n\tkeystroke \"hello world\"\nend tell",
+		},
+		{
+			name:  "quotes are escaped",
+			input: `say "hi" \o/`,
+			want:  "tell application \"System Events\"\n\tkeystroke \"say \\\"hi\\\" \\\\o/\"\nend tell",
+		},
+		{
+			name:  "newlines become Return presses",
+			input: "line one\n\nline two",
+			want:  "tell application \"System Events\"\n\tkeystroke \"line one\"\n\tkey code 36\n\tkey code 36\n\tkeystroke \"line two\"\nend tell",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := keystrokeScript(tt.input); got != tt.want {
+				t.Errorf("keystrokeScript(%q) =\n%s\nwant\n%s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPasteShortcutScript tests building the AppleScript for the paste shortcut
+func TestPasteShortcutScript(t *testing.T) {
+	tests := []struct {
+		shortcut string
+		want     string
+		wantErr  bool
+	}{
+		{"cmd+v", `tell application "System Events" to keystroke "v" using {command down}`, false},
+		{"Cmd + Shift + V", `tell application "System Events" to keystroke "v" using {command down, shift down}`, false},
+		{"cmd+alt+shift+v", `tell application "System Events" to keystroke "v" using {command down, option down, shift down}`, false},
+		{"command+cmd+v", `tell application "System Events" to keystroke "v" using {command down}`, false},
+		{"v", `tell application "System Events" to keystroke "v"`, false},
+		{"cmd+paste", "", true},
+		{"hyper+v", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.shortcut, func(t *testing.T) {
+			got, err := pasteShortcutScript(tt.shortcut)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pasteShortcutScript(%q) error = %v, wantErr %v", tt.shortcut, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("pasteShortcutScript(%q) = %q, want %q", tt.shortcut, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/stephanwesten/go-whisper/src/config"
+	"golang.design/x/hotkey"
+)
+
+// hotkeyModifiers is Ctrl+Shift on Linux, which has no Cmd key
+var hotkeyModifiers = []hotkey.Modifier{hotkey.ModCtrl, hotkey.ModShift}
+
+// hotkeyModifierNames maps the modifier names accepted in configured hotkeys.
+// Cmd means Ctrl like in hotkeyModifiers, so one config works on both systems.
+var hotkeyModifierNames = map[string]hotkey.Modifier{
+	"cmd":     hotkey.ModCtrl,
+	"command": hotkey.ModCtrl,
+	"shift":   hotkey.ModShift,
+	"ctrl":    hotkey.ModCtrl,
+	"control": hotkey.ModCtrl,
+	"opt":     hotkey.Mod1,
+	"option":  hotkey.Mod1,
+	"alt":     hotkey.Mod1,
+	"super":   hotkey.Mod4,
+}
+
+// openFolderCommand opens a folder in the desktop's file manager
+const openFolderCommand = "xdg-open"
+
+// linuxInjector types text with wtype on Wayland or xdotool on X11,
+// and shows dialogs with zenity
+type linuxInjector struct{}
+
+func newPlatformInjector() Injector {
+	return linuxInjector{}
+}
+
+// useWayland reports whether to use wtype instead of xdotool
+func useWayland() bool {
+	if os.Getenv("WAYLAND_DISPLAY") == "" {
+		return false
+	}
+	_, err := exec.LookPath("wtype")
+	return err == nil
+}
+
+// run executes a command and logs its output on failure
+func run(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Printf("%s output: %s", name, string(output))
+		return fmt.Errorf("%s failed: %w", name, err)
+	}
+	return nil
+}
+
+// SendBackspaces sends backspace key presses to the focused window
+func (linuxInjector) SendBackspaces(count int) error {
+	var err error
+	if useWayland() {
+		args := make([]string, 0, count*2)
+		for i := 0; i < count; i++ {
+			args = append(args, "-k", "BackSpace")
+		}
+		err = run("wtype", args...)
+	} else {
+		err = run("xdotool", "key", "--clearmodifiers", "--repeat", strconv.Itoa(count), "BackSpace")
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Successfully sent %d backspaces", count)
+	return nil
+}
+
+// SendText types text into the focused window. Unlike macOS this types the
+// characters directly, so the clipboard is never touched. The slow injection
+// mode types it in chunks.
+func (linuxInjector) SendText(text string) error {
+	var err error
+	if cfg().InjectionMode == config.InjectionModeSlow {
+		err = typeInChunks(text, cfg().SlowTypeChunkSize, cfg().SlowTypeDelay(), typeDirect)
+	} else {
+		err = typeDirect(text)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Successfully sent text: %s", text)
+	return nil
+}
+
+// typeDirect types text with wtype or xdotool
+func typeDirect(text string) error {
+	if useWayland() {
+		return run("wtype", "--", text)
+	}
+	return run("xdotool", "type", "--clearmodifiers", "--", text)
+}
+
+// ShowErrorDialog displays a zenity error dialog
+func (linuxInjector) ShowErrorDialog(title, message string) {
+	// Arguments are passed directly (no shell), --no-markup stops Pango interpreting the text
+	if err := run("zenity", "--error", "--no-markup", "--title", title, "--text", message); err != nil {
+		log.Printf("Failed to show error dialog: %v", err)
+	}
+}
+
+// AskConfirmation displays a zenity question dialog
+func (linuxInjector) AskConfirmation(title, message, confirmButton string) bool {
+	// zenity exits non-zero when the user cancels
+	return run("zenity", "--question", "--no-markup", "--title", title, "--text", message, "--ok-label", confirmButton) == nil
+}
+
+// AskChoice displays a zenity question dialog with a button per choice
+func (linuxInjector) AskChoice(title, message string, choices []string) string {
+	args := []string{"--question", "--no-markup", "--title", title, "--text", message,
+		"--ok-label", choices[len(choices)-1]}
+	if len(choices) > 1 {
+		args = append(args, "--cancel-label", choices[0])
+	}
+	for _, c := range choices[1 : len(choices)-1] {
+		args = append(args, "--extra-button", c)
+	}
+
+	// zenity exits 0 for OK and 1 otherwise, printing the label of an extra button
+	output, err := exec.Command("zenity", args...).Output()
+	if err == nil {
+		return choices[len(choices)-1]
+	}
+	if label := strings.TrimSpace(string(output)); label != "" {
+		return label
+	}
+	var exitErr *exec.ExitError
+	if len(choices) > 1 && errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return choices[0]
+	}
+	log.Printf("Failed to show choice dialog: %v", err)
+	return ""
+}
+
+// EditText displays a zenity entry dialog with a button per choice. The
+// entry holds a single line, so line breaks in text become spaces.
+func (linuxInjector) EditText(title, message, text string, choices []string) (string, string) {
+	args := []string{"--entry", "--title", title, "--text", message,
+		"--entry-text", strings.Join(strings.Fields(text), " "), "--ok-label", choices[len(choices)-1]}
+	if len(choices) > 1 {
+		args = append(args, "--cancel-label", choices[0])
+	}
+	for _, c := range choices[1 : len(choices)-1] {
+		args = append(args, "--extra-button", c)
+	}
+
+	// zenity exits 0 for OK, printing the entry, and 1 otherwise, printing
+	// the label of an extra button
+	output, err := exec.Command("zenity", args...).Output()
+	if err == nil {
+		return choices[len(choices)-1], strings.TrimSuffix(string(output), "\n")
+	}
+	if label := strings.TrimSpace(string(output)); label != "" {
+		return label, text
+	}
+	var exitErr *exec.ExitError
+	if len(choices) > 1 && errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return choices[0], text
+	}
+	log.Printf("Failed to show edit dialog: %v", err)
+	return "", ""
+}
+
+// errNoFocusInfo is returned by FocusedElement and TextBeforeCursor, Linux
+// desktops have no
+// accessibility API the injector can query
+var errNoFocusInfo = errors.New("focused element unknown on Linux")
+
+// FocusedElement is not supported on Linux
+func (linuxInjector) FocusedElement() (string, string, error) {
+	return "", "", errNoFocusInfo
+}
+
+// TextBeforeCursor is not supported on Linux
+func (linuxInjector) TextBeforeCursor() (string, string, error) {
+	return "", "", errNoFocusInfo
+}
+
+// ShowNotification displays a desktop notification with notify-send
+func (linuxInjector) ShowNotification(title, message string) {
+	if err := run("notify-send", "--app-name=GoWhisper", title, message); err != nil {
+		log.Printf("Failed to show notification: %v", err)
+	}
+}
+
+// ActivateApp raises the first window whose class is name with xdotool.
+// Wayland has no way for one app to raise another's window.
+func (linuxInjector) ActivateApp(name string) (func() error, error) {
+	if useWayland() {
+		return nil, errors.New("switching apps is not supported on Wayland")
+	}
+	previous, err := exec.Command("xdotool", "getactivewindow").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the active window: %w", err)
+	}
+	windows, _ := exec.Command("xdotool", "search", "--onlyvisible", "--class", name).Output()
+	fields := strings.Fields(string(windows))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%s: %w", name, errAppNotRunning)
+	}
+	if err := run("xdotool", "windowactivate", "--sync", fields[0]); err != nil {
+		return nil, err
+	}
+	previousWindow := strings.TrimSpace(string(previous))
+	return func() error {
+		return run("xdotool", "windowactivate", previousWindow)
+	}, nil
+}
+
+// CheckAccess taps Shift, which has no effect on the focused window but fails
+// when the injection tool is missing or can't reach the display
+func (linuxInjector) CheckAccess() error {
+	if useWayland() {
+		return run("wtype", "-M", "shift", "-m", "shift")
+	}
+	return run("xdotool", "key", "shift")
+}
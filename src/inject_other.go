@@ -0,0 +1,69 @@
+//go:build !darwin && !linux
+
+package main
+
+import "golang.design/x/hotkey"
+
+// hotkeyModifiers is Ctrl+Shift, the modifiers every platform has
+var hotkeyModifiers = []hotkey.Modifier{hotkey.ModCtrl, hotkey.ModShift}
+
+// hotkeyModifierNames maps the modifier names accepted in configured hotkeys.
+// Cmd means Ctrl like in hotkeyModifiers, so one config works everywhere.
+var hotkeyModifierNames = map[string]hotkey.Modifier{
+	"cmd":     hotkey.ModCtrl,
+	"command": hotkey.ModCtrl,
+	"shift":   hotkey.ModShift,
+	"ctrl":    hotkey.ModCtrl,
+	"control": hotkey.ModCtrl,
+}
+
+// openFolderCommand is empty, openFolder and openURL fail with
+// errUnsupportedPlatform
+const openFolderCommand = ""
+
+// unsupportedInjector fails every action, so the app runs but can't type
+type unsupportedInjector struct{}
+
+func newPlatformInjector() Injector {
+	return unsupportedInjector{}
+}
+
+func (unsupportedInjector) SendText(text string) error {
+	return errUnsupportedPlatform
+}
+
+func (unsupportedInjector) SendBackspaces(count int) error {
+	return errUnsupportedPlatform
+}
+
+func (unsupportedInjector) ShowErrorDialog(title, message string) {}
+
+func (unsupportedInjector) AskConfirmation(title, message, confirmButton string) bool {
+	return false
+}
+
+func (unsupportedInjector) AskChoice(title, message string, choices []string) string {
+	return ""
+}
+
+func (unsupportedInjector) EditText(title, message, text string, choices []string) (string, string) {
+	return "", ""
+}
+
+func (unsupportedInjector) CheckAccess() error {
+	return errUnsupportedPlatform
+}
+
+func (unsupportedInjector) FocusedElement() (string, string, error) {
+	return "", "", errUnsupportedPlatform
+}
+
+func (unsupportedInjector) TextBeforeCursor() (string, string, error) {
+	return "", "", errUnsupportedPlatform
+}
+
+func (unsupportedInjector) ShowNotification(title, message string) {}
+
+func (unsupportedInjector) ActivateApp(name string) (func() error, error) {
+	return nil, errUnsupportedPlatform
+}
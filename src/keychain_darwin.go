@@ -0,0 +1,39 @@
+//go:build darwin
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// errSecItemNotFound is the exit status of security when there is no
+// matching item
+const errSecItemNotFound = 44
+
+// keychainLookup returns the password stored for account in the login
+// keychain, or errKeychainItemNotFound if there is none
+func keychainLookup(account string) (string, error) {
+	output, err := exec.Command("security", "find-generic-password", "-s", keychainService, "-a", account, "-w").Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == errSecItemNotFound {
+		return "", fmt.Errorf("%s password %w", account, errKeychainItemNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s password from the keychain: %w", account, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// keychainStore saves password for account in the login keychain
+func keychainStore(account, password string) error {
+	// -U updates an existing item instead of failing. security only takes the
+	// password as an argument, briefly visible to other local processes.
+	output, err := exec.Command("security", "add-generic-password", "-U", "-s", keychainService, "-a", account, "-w", password).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to save %s password to the keychain: %s", account, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
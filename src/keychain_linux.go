@@ -0,0 +1,38 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// keychainLookup returns the password stored for account in the desktop
+// keyring through secret-tool, or errKeychainItemNotFound if there is none
+func keychainLookup(account string) (string, error) {
+	output, err := exec.Command("secret-tool", "lookup", "service", keychainService, "account", account).Output()
+	// secret-tool fails silently when there is no match, and explains any
+	// other failure, such as no keyring daemon, on stderr
+	var exitErr *exec.ExitError
+	if (err == nil && len(output) == 0) || (errors.As(err, &exitErr) && len(bytes.TrimSpace(exitErr.Stderr)) == 0) {
+		return "", fmt.Errorf("%s password %w", account, errKeychainItemNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s password from the keyring: %w", account, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// keychainStore saves password for account in the desktop keyring
+func keychainStore(account, password string) error {
+	// secret-tool reads the secret from stdin, keeping it off the command line
+	cmd := exec.Command("secret-tool", "store", "--label", "GoWhisper "+account, "service", keychainService, "account", account)
+	cmd.Stdin = strings.NewReader(password)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to save %s password to the keyring: %s", account, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !darwin && !linux
+
+package main
+
+import "fmt"
+
+// keychainLookup fails, there is no keychain backend for this platform
+func keychainLookup(account string) (string, error) {
+	return "", fmt.Errorf("no %s password: keychain %w", account, errUnsupportedPlatform)
+}
+
+// keychainStore fails, there is no keychain backend for this platform
+func keychainStore(account, password string) error {
+	return fmt.Errorf("failed to save %s password: keychain %w", account, errUnsupportedPlatform)
+}
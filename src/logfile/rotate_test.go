@@ -0,0 +1,64 @@
+package logfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRotatingWriter tests size-based rotation and backup pruning
+func TestRotatingWriter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs", "pipeline.log")
+
+	w, err := NewRotatingWriter(path, 10, 2)
+	if err != nil {
+		t.Fatalf("NewRotatingWriter() error = %v", err)
+	}
+	defer w.Close()
+
+	// Each write is 6 bytes, so every second write triggers a rotation
+	for _, line := range []string{"aaaaa\n", "bbbbb\n", "ccccc\n", "ddddd\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("Write(%q) error = %v", line, err)
+		}
+	}
+
+	want := map[string]string{
+		path:        "ddddd\n",
+		path + ".1": "ccccc\n",
+		path + ".2": "bbbbb\n",
+	}
+	for file, content := range want {
+		got, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("ReadFile(%s) error = %v", file, err)
+		}
+		if string(got) != content {
+			t.Errorf("%s = %q, want %q", filepath.Base(file), got, content)
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected oldest backup to be pruned, got err = %v", err)
+	}
+}
+
+// TestRotatingWriterAppends tests that reopening continues the existing file
+func TestRotatingWriterAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pipeline.log")
+
+	for _, line := range []string{"first\n", "second\n"} {
+		w, err := NewRotatingWriter(path, 1024, 1)
+		if err != nil {
+			t.Fatalf("NewRotatingWriter() error = %v", err)
+		}
+		w.Write([]byte(line))
+		w.Close()
+	}
+
+	got, _ := os.ReadFile(path)
+	if string(got) != "first\nsecond\n" {
+		t.Errorf("log content = %q, want both lines", got)
+	}
+}
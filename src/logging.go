@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/stephanwesten/go-whisper/src/config"
+)
+
+// structuredLogs switches key events to JSON via slog, see initLogging
+var structuredLogs bool
+
+// logLevel drops messages below it, set from cfg.LogLevel
+var logLevel = new(slog.LevelVar)
+
+// logLevels maps the config.LogLevel values to slog levels
+var logLevels = map[string]slog.Level{
+	config.LogLevelError: slog.LevelError,
+	config.LogLevelWarn:  slog.LevelWarn,
+	config.LogLevelInfo:  slog.LevelInfo,
+	config.LogLevelDebug: slog.LevelDebug,
+}
+
+// setLogLevel changes the threshold of the application log. It can be called
+// at any time, for a config reload.
+func setLogLevel(level string) {
+	if l, ok := logLevels[level]; ok {
+		logLevel.Set(l)
+	}
+}
+
+// initLogging sends all logging through slog so it can be filtered by
+// level: JSON records with format config.LogFormatJSON, otherwise lines that
+// look like the standard logger's. Plain log.Printf calls keep working and
+// get their level from the message, see messageLevel.
+func initLogging(format string) {
+	var h slog.Handler = &textHandler{w: os.Stderr}
+	if format == config.LogFormatJSON {
+		h = slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
+		structuredLogs = true
+	}
+	slog.SetDefault(slog.New(levelHandler{h}))
+}
+
+// messageLevel is the level of a message logged through the log package,
+// which knows no levels. Messages follow a few conventions: failures start
+// with "Error", "Failed", "FATAL" or "✗", and warnings with "Warning:".
+func messageLevel(msg string) slog.Level {
+	for _, prefix := range []string{"Error", "Failed", "FATAL", "✗"} {
+		if strings.HasPrefix(msg, prefix) {
+			return slog.LevelError
+		}
+	}
+	if strings.HasPrefix(msg, "Warning") {
+		return slog.LevelWarn
+	}
+	return slog.LevelInfo
+}
+
+// levelHandler filters records by logLevel. Messages from the log package
+// all arrive at info level and are given their real level first.
+type levelHandler struct {
+	slog.Handler
+}
+
+// Enabled can't tell what a message from the log package is before seeing
+// it, so lets every info record through to Handle
+func (h levelHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level == slog.LevelInfo || level >= logLevel.Level()
+}
+
+func (h levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level == slog.LevelInfo {
+		r.Level = messageLevel(r.Message)
+	}
+	if r.Level < logLevel.Level() {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return levelHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h levelHandler) WithGroup(name string) slog.Handler {
+	return levelHandler{h.Handler.WithGroup(name)}
+}
+
+// textHandler writes only the time and message, like the standard logger
+// did before initLogging. Attributes are left to the JSON format.
+type textHandler struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (h *textHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	line := r.Time.Format("2006/01/02 15:04:05") + " " + r.Message + "\n"
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, line)
+	return err
+}
+
+func (h *textHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *textHandler) WithGroup(string) slog.Handler      { return h }
+
+// logDebug logs details only needed to debug GoWhisper itself, shown with
+// "logLevel": "debug"
+func logDebug(format string, args ...any) {
+	slog.Debug(fmt.Sprintf(format, args...))
+}
+
+// logEvent logs a key event. In structured mode the event name and attributes
+// become JSON fields; otherwise only the human-readable message is printed.
+// attrs are alternating key/value pairs as in slog.
+func logEvent(event string, message string, attrs ...any) {
+	if structuredLogs {
+		slog.Info(message, append([]any{"event", event}, attrs...)...)
+		return
+	}
+	log.Print(message)
+}
+
+// logEventDebug is logEvent for events only needed to debug GoWhisper itself
+func logEventDebug(event string, message string, attrs ...any) {
+	if structuredLogs {
+		slog.Debug(message, append([]any{"event", event}, attrs...)...)
+		return
+	}
+	slog.Debug(message)
+}
+
+// logEventError is logEvent for failures, logged at error level in structured mode
+func logEventError(event string, err error, message string, attrs ...any) {
+	if structuredLogs {
+		slog.Error(message, append([]any{"event", event, "error", err.Error()}, attrs...)...)
+		return
+	}
+	log.Printf("%s: %v", message, err)
+}
@@ -1,17 +1,26 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
-	"github.com/atotto/clipboard"
 	"github.com/getlantern/systray"
 	"github.com/stephanwesten/go-whisper/src/audio"
+	"github.com/stephanwesten/go-whisper/src/config"
+	"github.com/stephanwesten/go-whisper/src/logfile"
+	"github.com/stephanwesten/go-whisper/src/paths"
 	"github.com/stephanwesten/go-whisper/src/whisper"
 	"golang.design/x/hotkey"
 	"golang.design/x/hotkey/mainthread"
@@ -20,6 +29,9 @@ import (
 const (
 	recordingIndicator  = "Recording"
 	processingIndicator = "Processing"
+
+	// minSpeechSamples is the shortest audio (0.5 seconds) worth sending to Whisper
+	minSpeechSamples = audio.SampleRate / 2
 )
 
 // AppState represents the current state of the application
@@ -48,24 +60,119 @@ func (s AppState) String() string {
 }
 
 var (
+	pipelineLog   *log.Logger // Verbose per-stage log, nil unless enabled in config
 	recorder      *audio.Recorder
-	transcriber   *whisper.Transcriber
+	levelMonitor  *audio.LevelMonitor
 	mStatus       *systray.MenuItem
 	mHotkey       *systray.MenuItem
 	mToggleHotkey *systray.MenuItem
+	mUndo         *systray.MenuItem
+	mRepeat       *systray.MenuItem
+	mRephraseAll  *systray.MenuItem
 	stopAnimation chan bool
 	hk            *hotkey.Hotkey
+	// Extra hotkeys from cfg.ActionHotkeys, registered alongside hk
+	actionHotkeys []*hotkey.Hotkey
+	// Stops the goroutines collecting presses of actionHotkeys
+	stopActionCollectors context.CancelFunc
 
 	// State machine with mutex protection
-	stateMu      sync.Mutex
-	currentState AppState = StateIdle
+	stateMu        sync.Mutex
+	currentState   AppState  = StateIdle
+	lastTransition time.Time // When currentState last changed, for debouncing
+	// When processing last returned to Idle, for the post-processing cooldown
+	processingFinishedAt time.Time
+	// Action of the hotkey that started the current recording, see config.ActionHotkeys
+	recordingAction string
+	// Set while a continuous dictation session runs, see session.go
+	sessionActive bool
 
 	// Hotkey enable/disable state
 	enabledMu sync.Mutex
 	isEnabled bool = true
+
+	// Held while registering or unregistering hk, so re-registering after
+	// wake can't interleave with the user toggling the hotkey
+	hotkeyRegMu sync.Mutex
+
+	// Length in runes of the last dictation typed into the active window,
+	// used by the undo action. Zero means there is nothing to undo. The text
+	// itself lets undo check that it is still there.
+	lastInjectedMu   sync.Mutex
+	lastInjectedLen  int
+	lastInjectedText string
+
+	// Final output of the last successful dictation, kept until the next one
+	// succeeds so it can be typed again with "Repeat Last Dictation"
+	lastOutputMu   sync.Mutex
+	lastOutputText string
+
+	// Sticky toggle that sends every dictation to Claude, as if "claude" was said
+	rephraseMu        sync.Mutex
+	rephraseByDefault bool
+
+	// Cancels the Claude call in flight, nil when there is none
+	claudeCancelMu sync.Mutex
+	claudeCancel   context.CancelFunc
+
+	// Cancels the dictation being processed, nil when there is none
+	dictationCancelMu sync.Mutex
+	dictationCancel   context.CancelFunc
+
+	// Loaded Whisper model, nil while released after being idle. Holding
+	// transcriberMu during a reload makes concurrent dictations wait for it.
+	transcriberMu       sync.Mutex
+	transcriber         *whisper.Transcriber
+	transcriberLoadedAt time.Time
+
+	// Settings in use, see cfg
+	currentConfig atomic.Pointer[config.Config]
+
+	// Cancelled on Quit, which stops the background goroutines started by onReady
+	appCtx, stopApp = context.WithCancel(context.Background())
+	// Goroutines that send to the trigger channel, which is only closed
+	// once they all returned
+	triggerSenders sync.WaitGroup
 )
 
+func init() {
+	setCfg(config.Default())
+}
+
+// cfg returns the settings in use. A config reload stores a new Config rather
+// than changing this one, so any goroutine can read it while a reload runs.
+// Only tests modify it in place.
+func cfg() *config.Config {
+	return currentConfig.Load()
+}
+
+// setCfg makes c the settings in use
+func setCfg(c config.Config) {
+	currentConfig.Store(&c)
+}
+
 func main() {
+	stdin := flag.Bool("stdin", false, "transcribe audio from stdin (WAV, or any format ffmpeg reads) and print the text, without starting the menu bar app")
+	doctor := flag.Bool("doctor", false, "check microphone, model and permissions, then exit")
+	showVersion := flag.Bool("version", false, "print version information and exit")
+	showHistory := flag.Bool("history", false, "print the dictation history, decrypting it if needed, then exit")
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Println(versionString())
+		return
+	}
+
+	if *doctor {
+		os.Exit(runDoctor())
+	}
+	if *stdin {
+		os.Exit(runStdin())
+	}
+	if *showHistory {
+		os.Exit(runHistory())
+	}
+
 	mainthread.Init(fn)
 }
 
@@ -78,34 +185,110 @@ func getModelPath() string {
 	if path := os.Getenv("GOWHISPER_MODEL"); path != "" {
 		return path
 	}
-	return "~/.go-whisper/models/ggml-small.en.bin"
+	return filepath.Join(paths.DataDir(), "models", "ggml-small.en.bin")
+}
+
+// modelsDir returns the folder holding the configured Whisper model
+func modelsDir() string {
+	modelPath, err := whisper.ExpandPath(getModelPath())
+	if err != nil {
+		return filepath.Join(paths.DataDir(), "models")
+	}
+	return filepath.Dir(modelPath)
 }
 
 func onReady() {
 	// Set the menu bar icon and title
 	systray.SetTitle("◉")
-	systray.SetTooltip("GoWhisper - Press Cmd+Shift+P to record")
+	log.Println(versionString())
+
+	// Load user settings, falling back to defaults if the file is broken
+	configPath := config.DefaultPath()
+	loaded, err := config.Load(configPath)
+	setCfg(loaded)
+	if err != nil {
+		log.Printf("Warning: %v (using defaults)", err)
+	} else {
+		log.Printf("Config loaded from: %s", configPath)
+	}
+
+	if repaired, err := cfg().Repair(); err != nil {
+		// Keep the valid settings and fall back to defaults for the rest
+		setCfg(repaired)
+		log.Printf("Warning: invalid config, using defaults for:\n%v", err)
+		go showErrorDialog("GoWhisper - Config Problems",
+			fmt.Sprintf("Some settings in %s are invalid and were replaced by their defaults:\n\n%v", configPath, err))
+	}
+
+	systray.SetTooltip(fmt.Sprintf("GoWhisper %s - Press %s to record", version, hotkeyLabel(recordingHotkeyName())))
+
+	setLogLevel(cfg().LogLevel)
+	initLogging(cfg().LogFormat)
+	if cfg().Verbose {
+		initPipelineLog()
+	}
+	initHistory()
+	if cfg().MetricsPort > 0 {
+		startMetricsServer(cfg().MetricsPort)
+	}
 
 	// Initialize audio recorder
-	var err error
 	recorder, err = audio.NewRecorder()
 	if err != nil {
 		log.Fatalf("Failed to initialize recorder: %v", err)
 	}
+	if cfg().AutoStopSilenceMs > 0 {
+		recorder.SetAutoStop(cfg().AutoStopThreshold, cfg().AutoStopSilence())
+		log.Printf("Auto-stop enabled after %dms of silence (threshold %.3f)", cfg().AutoStopSilenceMs, cfg().AutoStopThreshold)
+	}
+	if cfg().InputGain != 1 {
+		recorder.SetInputGain(cfg().InputGain)
+		log.Printf("Input gain set to %.2g", cfg().InputGain)
+	}
+	dictationRecorder = recorder
+	levelMonitor = audio.NewLevelMonitor(cfg().QuietMicRecordings, cfg().QuietMicThreshold)
+	if cfg().PreRollMs > 0 {
+		if err := recorder.SetPreRoll(cfg().PreRoll()); err != nil {
+			log.Printf("Warning: %v", err)
+		} else {
+			log.Printf("Pre-roll enabled, keeping the last %dms of audio before recording", cfg().PreRollMs)
+		}
+	}
+	if cfg().KeepMicOpen {
+		if err := recorder.SetKeepStreamOpen(true); err != nil {
+			log.Printf("Warning: %v", err)
+		} else {
+			log.Println("Keeping the microphone open between recordings")
+		}
+	}
 
-	// Initialize Whisper transcriber
-	modelPath := getModelPath()
-	log.Printf("Loading Whisper model from: %s", modelPath)
-	transcriber, err = whisper.NewTranscriber(modelPath)
-	if err != nil {
-		log.Fatalf("Failed to initialize transcriber: %v", err)
+	// Initialize Whisper transcriber. A broken model file shouldn't leave a dead
+	// tray icon, so the app still starts and offers a re-download below.
+	var modelErr error
+	if cfg().TranscribeBackend == config.TranscribeBackendRemote {
+		log.Printf("Transcribing with the whisper.cpp server at %s", cfg().RemoteURL)
+	} else {
+		_, modelErr = acquireTranscriber()
+	}
+	if timeout := cfg().ModelIdleTimeout(); timeout > 0 && cfg().WakePhrase != "" {
+		log.Println("Warning: modelIdleTimeoutMin ignored, listening for the wake phrase keeps the model in use")
+	} else if timeout > 0 {
+		log.Printf("Whisper model will be released after %v idle", timeout)
+		go releaseTranscriberWhenIdle(appCtx, timeout)
 	}
-	log.Println("Whisper model loaded successfully")
 
 	// Add menu items
 	mHotkey = systray.AddMenuItem("⌘⇧P - Start Recording", "Click to start recording")
 	systray.AddSeparator()
 	mToggleHotkey = systray.AddMenuItem("Disable Hotkey", "Temporarily disable the global hotkey")
+	mUndo = systray.AddMenuItem("Undo Last Dictation", "Delete the text typed by the last dictation")
+	mRepeat = systray.AddMenuItem("Repeat Last Dictation", "Type the last dictation again into the active window")
+	addRecentMenu(appCtx)
+	mTranscribeFile := systray.AddMenuItem("Transcribe Audio File on Clipboard", "Transcribe the audio file whose path is on the clipboard and copy the text")
+	mRephraseAll = systray.AddMenuItemCheckbox("Rephrase All Dictations", "Send every dictation to Claude, without saying \"claude\"", false)
+	mCancelClaude := systray.AddMenuItem("Cancel Claude Rephrase", "Stop waiting for Claude and type the original text")
+	mCancelDictation := systray.AddMenuItem("Cancel Dictation", "Stop processing the recording without typing anything")
+	mSession := systray.AddMenuItem("Continuous Dictation", "Type after every pause until the hotkey is pressed again")
 	systray.AddSeparator()
 
 	// Voice Commands help menu with submenus
@@ -113,69 +296,230 @@ func onReady() {
 	mVoiceCommands.AddSubMenuItem("Say 'claude [text]' - Rephrase with AI", "")
 	mVoiceCommands.AddSubMenuItem("Say 'clipboard [text]' - Copy to clipboard", "")
 	mVoiceCommands.AddSubMenuItem("Say 'claude clipboard' - Both actions", "")
-	mVoiceCommands.AddSubMenuItem("Note: 'clot' also works for 'claude'", "")
+	mVoiceCommands.AddSubMenuItem("Say 'translate [text]' - Translate to English", "")
+	mVoiceCommands.AddSubMenuItem("Say 'note [text]' - Save to daily notes file", "")
+	mVoiceCommands.AddSubMenuItem("Say 'proofread [text]' - Fix typos without AI", "")
+	mVoiceCommands.AddSubMenuItem("Say 'timestamp [text]' - Start with the date and time", "")
+	mVoiceCommands.AddSubMenuItem("Say 'clipboard snake/kebab/camel/upper [text]' - Copy as identifier", "")
+	mVoiceCommands.AddSubMenuItem("Say only 'scratch that' - Undo last dictation", "")
+	if len(cfg().ClaudeAliases) > 0 {
+		mVoiceCommands.AddSubMenuItem(fmt.Sprintf("Note: '%s' also works for 'claude'", strings.Join(cfg().ClaudeAliases, "'/'")), "")
+	}
 
 	systray.AddSeparator()
 	mStatus = systray.AddMenuItem("", "Current operation status")
 	mStatus.Hide() // Hidden by default, shown during operations
+	addAccessMenu()
+	systray.AddSeparator()
+	mOpenConfig := systray.AddMenuItem("Open Config Folder", "Show the folder containing config.json")
+	mOpenModels := systray.AddMenuItem("Open Models Folder", "Show the folder containing the Whisper model")
 	systray.AddSeparator()
+	mVersion := systray.AddMenuItem(versionString(), "Include this in bug reports")
+	mVersion.Disable()
 	mQuit := systray.AddMenuItem("Quit", "Quit the application")
 
-	// Register global hotkey: Cmd+Shift+P
-	hk = hotkey.New([]hotkey.Modifier{hotkey.ModCmd, hotkey.ModShift}, hotkey.KeyP)
+	// Register the global recording hotkey, Cmd+Shift+P unless configured
+	if _, _, err := parseHotkey(cfg().RecordingHotkey); err != nil {
+		log.Printf("Warning: %v, using %s", err, config.Default().RecordingHotkey)
+	}
+	hk = recordingHotkey()
+	hotkeyName := hotkeyLabel(recordingHotkeyName())
 	if err := hk.Register(); err != nil {
 		log.Printf("FATAL: Failed to register hotkey: %v", err)
 		// Show error dialog and exit - the app cannot function without the hotkey
 		showErrorDialog("GoWhisper - Fatal Error",
-			"Failed to register global hotkey Cmd+Shift+P.\n\n"+
+			"Failed to register global hotkey "+hotkeyName+".\n\n"+
 				"This may happen if another application is using the same shortcut.\n"+
-				"Please close conflicting applications and try again.")
+				"Please close conflicting applications or set another \"recordingHotkey\" in config.json.")
 		os.Exit(1)
 		return // Never reached, but makes control flow clear
 	}
-	log.Println("Hotkey registered: Cmd+Shift+P")
+	log.Printf("Hotkey registered: %s", hotkeyName)
 
-	// Handle hotkey with channel to process one at a time
-	triggerCh := make(chan struct{}, 1)
+	if cfg().RephraseToggleHotkey {
+		rk := rephraseHotkey()
+		if err := rk.Register(); err != nil {
+			log.Printf("Warning: failed to register rephrase toggle hotkey: %v", err)
+		} else {
+			log.Println("Rephrase toggle hotkey registered")
+			go func() {
+				for {
+					select {
+					case <-appCtx.Done():
+						return
+					case <-rk.Keydown():
+						toggleRephraseByDefault()
+					}
+				}
+			}()
+		}
+	}
+
+	if errors.Is(modelErr, errModelDeclined) {
+		// Nothing is wrong with the file; the next dictation asks again
+		mStatus.SetTitle("Whisper model not loaded")
+		mStatus.Show()
+	} else if modelErr != nil {
+		go offerModelDownload(modelErr)
+	}
+
+	// Find out before the first dictation whether typing is allowed
+	go checkAccessAtStartup()
+
+	// macOS sometimes stops delivering the hotkey after sleep
+	go watchForWake(appCtx, reregisterHotkey)
+
+	// Handle hotkeys with channel to process one at a time. Each trigger
+	// carries the action of the hotkey that was pressed.
+	triggerCh := make(chan string, 1)
 
 	// Collect hotkey events (may fire multiple times)
 	// NOTE: This goroutine is only started after successful registration
-	go func() {
-		for {
-			<-hk.Keydown()
-			// Try to send, but don't block if channel is full
-			select {
-			case triggerCh <- struct{}{}:
-			default:
-			}
-		}
-	}()
+	triggerSenders.Add(1)
+	go collectHotkey(appCtx, hk, config.ActionPlain, triggerCh)
+	registerActionHotkeys(triggerCh)
+	if cfg().WakePhrase != "" {
+		startWakeListener(recorder, triggerCh)
+	}
 
-	// Process triggers one at a time
+	// Pick up edits to the config file without a restart
+	configChanged := make(chan struct{}, 1)
+	go watchConfig(appCtx, configPath, configPollInterval, configChanged)
+
+	triggersDone := make(chan struct{})
 	go func() {
-		for range triggerCh {
-			handleHotkey()
-		}
+		defer close(triggersDone)
+		processTriggers(appCtx, triggerCh, recorder.SilenceDetected(), configChanged, configPath)
 	}()
 
 	// Handle menu actions
 	go func() {
 		for {
 			select {
+			case <-appCtx.Done():
+				return
 			case <-mHotkey.ClickedCh:
 				log.Println("Start/Stop Recording menu item clicked")
 				handleHotkey()
 			case <-mToggleHotkey.ClickedCh:
 				toggleHotkey()
+			case <-mAccess.ClickedCh:
+				log.Println("Check Accessibility Permission clicked")
+				recheckAccess()
+			case <-mUndo.ClickedCh:
+				log.Println("Undo Last Dictation clicked")
+				undoLastInjection()
+			case <-mRepeat.ClickedCh:
+				log.Println("Repeat Last Dictation clicked")
+				repeatLastOutput()
+			case <-mTranscribeFile.ClickedCh:
+				log.Println("Transcribe Audio File on Clipboard clicked")
+				// Queued behind a dictation that is still processing, which
+				// shares the transcriber, without blocking the menu
+				go processInBackground(transcribeClipboardFile)
+			case <-mRephraseAll.ClickedCh:
+				toggleRephraseByDefault()
+			case <-mSession.ClickedCh:
+				log.Println("Continuous Dictation clicked")
+				handleHotkeyAction(config.ActionSession)
+			case <-mCancelClaude.ClickedCh:
+				if !cancelRephrase() {
+					log.Println("No Claude rephrase to cancel")
+				}
+			case <-mCancelDictation.ClickedCh:
+				if !cancelDictation() {
+					log.Println("No dictation to cancel")
+				}
+			case <-mOpenConfig.ClickedCh:
+				log.Println("Open Config Folder clicked")
+				if err := openFolder(filepath.Dir(config.DefaultPath())); err != nil {
+					log.Printf("Error opening config folder: %v", err)
+				}
+			case <-mOpenModels.ClickedCh:
+				log.Println("Open Models Folder clicked")
+				if err := openFolder(modelsDir()); err != nil {
+					log.Printf("Error opening models folder: %v", err)
+				}
 			case <-mQuit.ClickedCh:
 				log.Println("Quit clicked")
+				shutdown(triggerCh, triggersDone)
 				hk.Unregister()
+				setActionHotkeysRegistered(false)
 				systray.Quit()
+				return
 			}
 		}
 	}()
 }
 
+// processTriggers handles triggers one at a time, including auto-stop after
+// silence, until ctx is cancelled or triggerCh is closed. Auto-stop only acts
+// on a recording that is still running, so a late signal can never start a
+// new recording.
+func processTriggers(ctx context.Context, triggerCh chan string, silence, configChanged <-chan struct{}, configPath string) {
+	reloadPending := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case action, ok := <-triggerCh:
+			if !ok {
+				return
+			}
+			handleHotkeyAction(action)
+		case <-silence:
+			if getState() == StateRecording {
+				log.Println("Silence detected, stopping recording automatically")
+				finishAfterPause()
+			}
+		case <-configChanged:
+			reloadPending = true
+		case <-dictationDone:
+		}
+		// Reloading only while Idle never changes the settings halfway
+		// through a dictation, which runs on the dictation worker
+		if reloadPending && getState() == StateIdle {
+			reloadPending = false
+			reloadConfig(configPath, triggerCh)
+		}
+	}
+}
+
+// shutdown cancels the background goroutines and the dictation in flight,
+// then closes triggerCh once nothing can send to it anymore. loopDone is
+// closed when processTriggers returned, after which no new hotkey collectors
+// can be started by a config reload.
+func shutdown(triggerCh chan string, loopDone <-chan struct{}) {
+	stopApp()
+	cancelDictation()
+	<-loopDone
+	triggerSenders.Wait()
+	close(triggerCh)
+}
+
+// isRephraseByDefault returns whether every dictation is rephrased (thread-safe)
+func isRephraseByDefault() bool {
+	rephraseMu.Lock()
+	defer rephraseMu.Unlock()
+	return rephraseByDefault
+}
+
+// toggleRephraseByDefault flips the sticky rephrase mode and updates the menu
+func toggleRephraseByDefault() {
+	rephraseMu.Lock()
+	rephraseByDefault = !rephraseByDefault
+	enabled := rephraseByDefault
+	rephraseMu.Unlock()
+
+	if enabled {
+		mRephraseAll.Check()
+		log.Println("Rephrase by default enabled")
+	} else {
+		mRephraseAll.Uncheck()
+		log.Println("Rephrase by default disabled")
+	}
+}
+
 // isHotkeyEnabled returns whether the hotkey is enabled (thread-safe)
 func isHotkeyEnabled() bool {
 	enabledMu.Lock()
@@ -190,6 +534,212 @@ func setHotkeyEnabled(enabled bool) {
 	isEnabled = enabled
 }
 
+// initPipelineLog opens the rotating verbose log in the logs directory
+func initPipelineLog() {
+	path := filepath.Join(paths.DataDir(), "logs", "pipeline.log")
+	w, err := logfile.NewRotatingWriter(path, 5*1024*1024, 3)
+	if err != nil {
+		log.Printf("Warning: Failed to open verbose log: %v", err)
+		return
+	}
+	pipelineLog = log.New(w, "", log.LstdFlags|log.Lmicroseconds)
+	log.Printf("Verbose pipeline logging enabled: %s", path)
+}
+
+// logStage records the input/output of one pipeline stage in the verbose log.
+// It is a no-op unless verbose logging is enabled.
+func logStage(stage string, format string, args ...interface{}) {
+	if pipelineLog == nil {
+		return
+	}
+	pipelineLog.Printf("[%s] %s", stage, fmt.Sprintf(format, args...))
+}
+
+// setLastInjectedText remembers the length of text typed into the active window (thread-safe)
+func setLastInjectedText(text string) {
+	lastInjectedMu.Lock()
+	defer lastInjectedMu.Unlock()
+	lastInjectedLen = utf8.RuneCountInString(text)
+	lastInjectedText = text
+}
+
+// getLastInjectedText returns the last typed text without clearing it (thread-safe)
+func getLastInjectedText() string {
+	lastInjectedMu.Lock()
+	defer lastInjectedMu.Unlock()
+	return lastInjectedText
+}
+
+// takeLastInjectedLen returns the length of the last typed text and clears it,
+// so a second undo cannot delete unrelated text (thread-safe)
+func takeLastInjectedLen() int {
+	lastInjectedMu.Lock()
+	defer lastInjectedMu.Unlock()
+	n := lastInjectedLen
+	lastInjectedLen = 0
+	lastInjectedText = ""
+	return n
+}
+
+// setLastOutput caches the final text of a successful dictation (thread-safe)
+func setLastOutput(text string) {
+	lastOutputMu.Lock()
+	defer lastOutputMu.Unlock()
+	lastOutputText = text
+}
+
+// getLastOutput returns the cached text of the last successful dictation (thread-safe)
+func getLastOutput() string {
+	lastOutputMu.Lock()
+	defer lastOutputMu.Unlock()
+	return lastOutputText
+}
+
+// acquireTranscriber returns the Whisper transcriber, loading the model first
+// if it isn't loaded (at startup, or after an idle release)
+func acquireTranscriber() (*whisper.Transcriber, error) {
+	transcriberMu.Lock()
+	defer transcriberMu.Unlock()
+
+	if transcriber == nil {
+		modelPath := getModelPath()
+		if err := confirmModelMemory(modelPath); err != nil {
+			return nil, err
+		}
+		log.Printf("Loading Whisper model from: %s", modelPath)
+		t, err := whisper.NewTranscriber(modelPath)
+		if err != nil {
+			return nil, err
+		}
+		configureTranscriber(t)
+		transcriber = t
+		transcriberLoadedAt = time.Now()
+		log.Println("Whisper model loaded successfully")
+		if !t.IsMultilingual() {
+			log.Println("The model is English-only, the translate keyword needs a multilingual model")
+		}
+	}
+	return transcriber, nil
+}
+
+// newRemoteTranscriber returns a transcriber for the whisper.cpp server at
+// cfg.RemoteURL. It holds no model, so each dictation gets a new one with the
+// current settings.
+func newRemoteTranscriber() *whisper.RemoteTranscriber {
+	t := whisper.NewRemoteTranscriber(cfg().RemoteURL, cfg().RemoteTimeout())
+	t.SetSegmentSeparator(cfg().SegmentSeparator)
+	if err := t.SetSuppressed(cfg().SuppressPhrases, cfg().SuppressPatterns); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return t
+}
+
+// configureTranscriber applies the decoding settings from cfg to t
+func configureTranscriber(t *whisper.Transcriber) {
+	if err := t.SetTemperature(cfg().Temperature, cfg().TemperatureFallback); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	t.SetSingleSegment(cfg().SingleSegment)
+	t.SetNoContext(cfg().NoContext)
+	t.SetSegmentSeparator(cfg().SegmentSeparator)
+	if err := t.SetSuppressed(cfg().SuppressPhrases, cfg().SuppressPatterns); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// offerModelDownload tells the user the model couldn't be loaded and offers to
+// download it again. Blocks on the dialog, so run it on its own goroutine.
+func offerModelDownload(loadErr error) {
+	log.Printf("Error loading Whisper model: %v", loadErr)
+	mStatus.SetTitle("Error: Whisper model could not be loaded")
+	mStatus.Show()
+
+	modelPath := getModelPath()
+	problem := "could not be loaded"
+	if errors.Is(loadErr, os.ErrNotExist) {
+		problem = "is missing"
+	} else if errors.Is(loadErr, whisper.ErrModelCorrupt) {
+		problem = "is corrupt or incomplete, probably from an interrupted download"
+	}
+	message := fmt.Sprintf("The Whisper model at %s %s.\n\nDownload it again from %s?\nThis can take a few minutes.",
+		modelPath, problem, whisper.ModelURL(modelPath))
+	if !askConfirmation("GoWhisper - Model Problem", message, "Download") {
+		log.Println("Model download declined")
+		return
+	}
+
+	log.Printf("Downloading Whisper model from %s", whisper.ModelURL(modelPath))
+	mStatus.SetTitle("Downloading model...")
+	if err := whisper.DownloadModel(modelPath); err != nil {
+		log.Printf("Error downloading Whisper model: %v", err)
+		mStatus.SetTitle("Error: Model download failed")
+		showErrorDialog("GoWhisper - Download Failed", fmt.Sprintf("Downloading the model failed:\n\n%v", err))
+		return
+	}
+
+	if _, err := acquireTranscriber(); err != nil {
+		log.Printf("Error loading downloaded Whisper model: %v", err)
+		mStatus.SetTitle("Error: Whisper model could not be loaded")
+		return
+	}
+	mStatus.Hide()
+}
+
+// isTranscriberLoaded reports whether the model is currently in memory
+func isTranscriberLoaded() bool {
+	transcriberMu.Lock()
+	defer transcriberMu.Unlock()
+	return transcriber != nil
+}
+
+// releaseTranscriberWhenIdle periodically frees the model once the app has
+// been idle for the timeout, until ctx is cancelled
+func releaseTranscriberWhenIdle(ctx context.Context, timeout time.Duration) {
+	interval := timeout / 4
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			releaseIdleTranscriber(timeout)
+		}
+	}
+}
+
+// releaseIdleTranscriber closes the model if the app is Idle and neither a
+// state change nor a model load happened within timeout. Returns whether it
+// released the model.
+func releaseIdleTranscriber(timeout time.Duration) bool {
+	transcriberMu.Lock()
+	defer transcriberMu.Unlock()
+
+	if transcriber == nil {
+		return false
+	}
+
+	stateMu.Lock()
+	idleSince := lastTransition
+	idle := currentState == StateIdle
+	stateMu.Unlock()
+
+	if transcriberLoadedAt.After(idleSince) {
+		idleSince = transcriberLoadedAt
+	}
+	if !idle || time.Since(idleSince) < timeout {
+		return false
+	}
+
+	transcriber.Close()
+	transcriber = nil
+	log.Printf("Released Whisper model after %v idle", timeout)
+	return true
+}
+
 // getState returns the current application state (thread-safe)
 func getState() AppState {
 	stateMu.Lock()
@@ -203,7 +753,12 @@ func setState(newState AppState) {
 	defer stateMu.Unlock()
 	oldState := currentState
 	currentState = newState
-	log.Printf("State transition: %s -> %s", oldState, newState)
+	lastTransition = time.Now()
+	if oldState == StateProcessing && newState == StateIdle {
+		processingFinishedAt = lastTransition
+	}
+	logEventDebug("state_transition", fmt.Sprintf("State transition: %s -> %s", oldState, newState),
+		"from", oldState.String(), "to", newState.String())
 }
 
 // tryTransitionState attempts to transition from expectedState to newState
@@ -212,22 +767,49 @@ func tryTransitionState(expectedState, newState AppState) bool {
 	stateMu.Lock()
 	defer stateMu.Unlock()
 	if currentState != expectedState {
-		log.Printf("State transition rejected: expected %s, but current is %s", expectedState, currentState)
+		logDebug("State transition rejected: expected %s, but current is %s", expectedState, currentState)
 		return false
 	}
 	oldState := currentState
 	currentState = newState
-	log.Printf("State transition: %s -> %s", oldState, newState)
+	lastTransition = time.Now()
+	if oldState == StateProcessing && newState == StateIdle {
+		processingFinishedAt = lastTransition
+	}
+	logEventDebug("state_transition", fmt.Sprintf("State transition: %s -> %s", oldState, newState),
+		"from", oldState.String(), "to", newState.String())
 	return true
 }
 
+// withinDebounceWindow reports whether the last state transition happened less
+// than window ago. A trigger arriving that soon is most likely a duplicate
+// key event from the press that caused the transition.
+func withinDebounceWindow(window time.Duration) bool {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	return window > 0 && time.Since(lastTransition) < window
+}
+
+// withinProcessingCooldown reports whether processing returned to Idle less
+// than cooldown ago. A trigger that soon is most likely the stop press itself,
+// repeated because the keys were released late.
+func withinProcessingCooldown(cooldown time.Duration) bool {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	return cooldown > 0 && currentState == StateIdle && time.Since(processingFinishedAt) < cooldown
+}
+
 // toggleHotkey enables or disables the global hotkey
 func toggleHotkey() {
+	hotkeyRegMu.Lock()
+	defer hotkeyRegMu.Unlock()
+
 	enabled := isHotkeyEnabled()
 
 	if enabled {
 		// Disabling hotkey
 		log.Println("Disabling hotkey...")
+		endSession()
 
 		// If currently recording, stop and discard
 		state := getState()
@@ -248,7 +830,7 @@ func toggleHotkey() {
 			}
 
 			// Delete the "Recording" indicator text
-			if err := sendBackspaces(len(recordingIndicator)); err != nil {
+			if err := deleteIndicator(recordingIndicator); err != nil {
 				log.Printf("Error deleting recording indicator: %v", err)
 			}
 
@@ -269,6 +851,7 @@ func toggleHotkey() {
 		} else {
 			log.Println("Hotkey unregistered successfully")
 		}
+		setActionHotkeysRegistered(false)
 
 	} else {
 		// Enabling hotkey
@@ -282,352 +865,208 @@ func toggleHotkey() {
 		}
 
 		log.Println("Hotkey registered successfully")
+		setActionHotkeysRegistered(true)
 		setHotkeyEnabled(true)
 		mHotkey.Enable() // Re-enable the hotkey menu item
-		systray.SetTitle("◉") // Remove disabled overlay
+		ui.SetIcon("◉")  // Remove disabled overlay
 		mStatus.Hide()
 		mToggleHotkey.SetTitle("Disable Hotkey")
 	}
 }
 
+// handleHotkey starts or stops a plain dictation, see handleHotkeyAction
 func handleHotkey() {
+	handleHotkeyAction(config.ActionPlain)
+}
+
+// handleHotkeyAction starts a recording that will be handled as action, or
+// stops the current one. Only one recording runs at a time: a press of any
+// hotkey stops it, and it keeps the action it was started with.
+func handleHotkeyAction(action string) {
 	// CRITICAL: Check if hotkey is enabled first
 	if !isHotkeyEnabled() {
-		log.Println("Hotkey is disabled, ignoring")
+		logDebug("Hotkey is disabled, ignoring")
+		return
+	}
+
+	// Ignore duplicate triggers right after a state change, e.g. a press that
+	// arrives just as processing finishes would otherwise restart recording
+	if withinDebounceWindow(cfg().HotkeyDebounce()) {
+		logDebug("Ignoring hotkey within %dms debounce window", cfg().HotkeyDebounceMs)
+		return
+	}
+
+	// Processing can take long enough for the debounce window to pass while
+	// a repeated stop press is still queued
+	if withinProcessingCooldown(cfg().ProcessingCooldown()) {
+		logDebug("Ignoring hotkey within %dms cooldown after processing", cfg().ProcessingCooldownMs)
 		return
 	}
 
 	state := getState()
 
-	// Ignore hotkey presses while processing
+	// The wake phrase only ever starts a recording
+	if action == actionWake && state != StateIdle {
+		return
+	}
+
+	// Ignore hotkey presses while processing, except to end a session once
+	// the dictation in progress is done
 	if state == StateProcessing {
+		if endSession() {
+			return
+		}
 		log.Println("Already processing, ignoring hotkey")
 		return
 	}
 
 	if state == StateRecording {
+		// Any press ends a continuous session, typing what was said so far
+		endSession()
+
 		// Transition to processing state
 		if !tryTransitionState(StateRecording, StateProcessing) {
 			log.Println("Failed to transition to Processing state")
 			return
 		}
 
-		// Stop recording and transcribe
-		log.Println("Stopping recording...")
-		stopRecordingAnimation()
-		systray.SetTitle("◉")
-		mStatus.SetTitle("Processing...")
-		mStatus.Show()
-		log.Println("⏳ Processing transcription...")
-
-		// Add delay before sending processing indicator to ensure the hotkey (Cmd+Shift+P)
-		// is fully released before AppleScript types. Without this delay, the modifier keys
-		// may still be pressed when keystroke injection occurs, causing incorrect characters.
-		time.Sleep(100 * time.Millisecond)
-
-		// Delete the "Recording" text (9 characters) before showing "Processing"
-		if err := sendBackspaces(len(recordingIndicator)); err != nil {
-			log.Printf("Error deleting recording indicator: %v", err)
-		}
-
-		if err := sendTextToActiveWindow(processingIndicator); err != nil {
-			log.Printf("Error sending processing indicator: %v", err)
-		}
-
-		samples, err := recorder.Stop()
-		if err != nil {
-			log.Printf("Error stopping recording: %v", err)
-			mHotkey.SetTitle("⌘⇧P - Start Recording")
-			mStatus.SetTitle("Error: Failed to stop recording")
-			setState(StateIdle)
+		finishDictation()
+	} else if state == StateIdle {
+		// Transition to recording state
+		if !tryTransitionState(StateIdle, StateRecording) {
+			log.Println("Failed to transition to Recording state")
 			return
 		}
-
-		log.Printf("Recorded %d samples (%.2f seconds)", len(samples), float64(len(samples))/float64(audio.SampleRate))
-
-		// Calculate audio volume/amplitude
-		var maxAmplitude float32
-		var sumSquared float64
-		for _, sample := range samples {
-			// Calculate absolute value
-			abs := sample
-			if abs < 0 {
-				abs = -abs
-			}
-			// Check if this is the maximum amplitude
-			if abs > maxAmplitude {
-				maxAmplitude = abs
-			}
-			sumSquared += float64(sample * sample)
-		}
-		rms := float32(0)
-		if len(samples) > 0 {
-			rms = float32(sumSquared / float64(len(samples)))
-		}
-		log.Printf("Audio levels - Max amplitude: %.4f, RMS: %.4f", maxAmplitude, rms)
-
-		if len(samples) < audio.SampleRate/2 { // Less than 0.5 seconds
-			log.Println("Recording too short, ignoring")
-			mHotkey.SetTitle("⌘⇧P - Start Recording")
-			mStatus.Hide()
-			setState(StateIdle)
-			return
+		setRecordingAction(action)
+		if action == config.ActionSession {
+			startSession()
 		}
 
-		// Transcribe
-		log.Println("Transcribing...")
-		mStatus.SetTitle("Transcribing...")
+		startDictation()
+	} else {
+		log.Printf("Unexpected state in handleHotkey: %s", state)
+	}
+}
 
-		text, err := transcriber.Transcribe(samples)
-		if err != nil {
-			log.Printf("Error transcribing: %v", err)
-			mHotkey.SetTitle("⌘⇧P - Start Recording")
-			mStatus.SetTitle("Error: Transcription failed")
-			log.Println("✗ Transcription failed")
-			setState(StateIdle)
-			return
-		}
+// undoLastInjection deletes the text typed by the last dictation by sending backspaces.
+// Only allowed while idle, since indicators are being typed during recording/processing.
+func undoLastInjection() {
+	if state := getState(); state != StateIdle {
+		log.Printf("Cannot undo while %s, ignoring", state)
+		return
+	}
 
-		log.Printf("✓ Transcription: %s", text)
+	if !lastInjectionIntact() {
+		log.Println("Not undoing, the text before the cursor isn't the last dictation")
+		mStatus.SetTitle("Undo skipped: text changed since the dictation")
+		mStatus.Show()
+		return
+	}
+	count := takeLastInjectedLen()
+	if count == 0 {
+		log.Println("Nothing to undo")
+		return
+	}
 
-		if text == "" {
-			log.Println("No speech detected")
-			mHotkey.SetTitle("⌘⇧P - Start Recording")
-			mStatus.Hide()
-			setState(StateIdle)
-			return
-		}
+	if err := sendBackspaces(count); err != nil {
+		log.Printf("Error undoing last dictation: %v", err)
+		mStatus.SetTitle("Error: Undo failed")
+		mStatus.Show()
+		return
+	}
+	log.Printf("Undid last dictation (%d characters)", count)
+}
 
-		// Detect keywords in transcription
-		hasClaude := containsClaude(text)
-		hasClipboard := containsClipboardKeyword(text)
-
-		log.Printf("Keyword detection - Claude: %v, Clipboard: %v", hasClaude, hasClipboard)
-
-		// Determine output text and action based on keywords
-		var outputText string
-		var shouldCopyToClipboard bool
-		var shouldRephrase bool
-
-		if hasClaude && hasClipboard {
-			// Both keywords: Remove both, rephrase with Claude, copy to clipboard
-			outputText = removeCombinedKeywords(text)
-			shouldRephrase = true
-			shouldCopyToClipboard = true
-			log.Printf("Both keywords detected. Will rephrase and copy: %s", outputText)
-		} else if hasClaude {
-			// Only Claude: Remove keyword, rephrase, type to window
-			outputText = removeCombinedKeywords(text)
-			shouldRephrase = true
-			shouldCopyToClipboard = false
-			log.Printf("Claude keyword detected. Will rephrase and type: %s", outputText)
-		} else if hasClipboard {
-			// Only Clipboard: Remove keyword, copy to clipboard
-			outputText = removeClipboardPrefix(text)
-			shouldRephrase = false
-			shouldCopyToClipboard = true
-			log.Printf("Clipboard keyword detected. Will copy: %s", outputText)
-		} else {
-			// No keywords: Type original text
-			outputText = text
-			shouldRephrase = false
-			shouldCopyToClipboard = false
-		}
+// isJunkTranscription reports whether text is at most one word, ignoring
+// punctuation, that is shorter than minChars or one of words, ignoring case
+func isJunkTranscription(text string, minChars int, words []string) bool {
+	fields := strings.Fields(text)
+	if len(fields) > 1 {
+		return false
+	}
+	word := ""
+	if len(fields) == 1 {
+		word = stripPunctuation(fields[0])
+	}
+	if utf8.RuneCountInString(word) < minChars {
+		return true
+	}
+	return word != "" && slices.ContainsFunc(words, func(w string) bool {
+		return strings.EqualFold(stripPunctuation(strings.TrimSpace(w)), word)
+	})
+}
 
-		// Delete the "Processing" text first
-		if err := sendBackspaces(len(processingIndicator)); err != nil {
-			log.Printf("Error deleting processing indicator: %v", err)
+// isUndoPhrase reports whether text is nothing but one of phrases, ignoring
+// case, punctuation and spacing
+func isUndoPhrase(text string, phrases []string) bool {
+	normalize := func(s string) string {
+		words := strings.Fields(s)
+		for i, w := range words {
+			words[i] = strings.ToLower(stripPunctuation(w))
 		}
-
-		// Rephrase with Claude if needed
-		if shouldRephrase {
-			const claudeIndicator = "Asking Claude"
-			systray.SetTitle("C") // Change menu bar icon to "C"
-			mStatus.SetTitle("Asking Claude...")
-
-			// Show "Asking Claude" text in the window
-			if err := sendTextToActiveWindow(claudeIndicator); err != nil {
-				log.Printf("Error sending Claude indicator: %v", err)
-			}
-
-			rephrased, err := rephraseWithClaude(outputText)
-
-			// Delete the "Asking Claude" text
-			if err := sendBackspaces(len(claudeIndicator)); err != nil {
-				log.Printf("Error deleting Claude indicator: %v", err)
-			}
-
-			systray.SetTitle("◉") // Restore default icon
-
-			if err != nil {
-				log.Printf("Error rephrasing with Claude: %v", err)
-				mHotkey.SetTitle("⌘⇧P - Start Recording")
-				mStatus.SetTitle("Error: Claude rephrasing failed")
-				mStatus.Show()
-				setState(StateIdle)
-				return
-			}
-			outputText = rephrased
-			log.Printf("Successfully rephrased: %s", outputText)
+		return strings.Join(strings.Fields(strings.Join(words, " ")), " ")
+	}
+	said := normalize(text)
+	if said == "" {
+		return false
+	}
+	for _, phrase := range phrases {
+		if normalize(phrase) == said {
+			return true
 		}
+	}
+	return false
+}
 
-		if shouldCopyToClipboard {
-			// Copy to clipboard
-			mStatus.SetTitle("Copying to clipboard...")
-			if err := clipboard.WriteAll(outputText); err != nil {
-				log.Printf("Error copying to clipboard: %v", err)
-				mHotkey.SetTitle("⌘⇧P - Start Recording")
-				mStatus.SetTitle("Error: Failed to copy")
-				mStatus.Show()
-				setState(StateIdle)
-				return
-			}
-			log.Printf("Successfully copied to clipboard: %s", outputText)
-		} else {
-			// Send transcribed text to active window
-			mStatus.SetTitle("Typing...")
-			if err := sendTextToActiveWindow(outputText); err != nil {
-				log.Printf("Error sending text: %v", err)
-				mHotkey.SetTitle("⌘⇧P - Start Recording")
-				mStatus.SetTitle("Error: Failed to type")
-
-				// Show user-friendly error dialog
-				errorMsg := "GoWhisper needs Accessibility permissions to type text.\n\nPlease go to:\nSystem Settings → Privacy & Security → Accessibility\n\nAnd add your Terminal app to the allowed list."
-				showErrorDialog("Accessibility Permission Required", errorMsg)
-				setState(StateIdle)
-				return
-			}
-			log.Println("Successfully sent transcribed text")
-		}
+// repeatLastOutput types the last dictation's final text into the active window again,
+// without re-running Whisper or Claude. Only allowed while idle.
+func repeatLastOutput() {
+	if state := getState(); state != StateIdle {
+		log.Printf("Cannot repeat while %s, ignoring", state)
+		return
+	}
 
-		mHotkey.SetTitle("⌘⇧P - Start Recording")
-		mStatus.Hide()
-		setState(StateIdle)
+	text := getLastOutput()
+	if text == "" {
+		log.Println("Nothing to repeat")
+		return
+	}
 
-	} else if state == StateIdle {
-		// Transition to recording state
-		if !tryTransitionState(StateIdle, StateRecording) {
-			log.Println("Failed to transition to Recording state")
+	if isAccessDenied() {
+		if err := setClipboardContent(text); err != nil {
+			log.Printf("Error copying last dictation: %v", err)
 			return
 		}
-
-		// Start recording
-		log.Println("Starting recording...")
-		startRecordingAnimation()
-		mHotkey.SetTitle("⌘⇧P - Stop Recording")
-		mStatus.SetTitle("🎤 Recording...")
+		showStatusBriefly("Copied to clipboard, no permission to type")
+		log.Println("Copied last dictation, no permission to type it")
+		return
+	}
+	if err := sendTextToActiveWindow(text); err != nil {
+		log.Printf("Error repeating last dictation: %v", err)
+		mStatus.SetTitle("Error: Failed to type")
 		mStatus.Show()
-
-		if err := recorder.Start(); err != nil {
-			log.Printf("Error starting recording: %v", err)
-			stopRecordingAnimation()
-			systray.SetTitle("◉")
-			mHotkey.SetTitle("⌘⇧P - Start Recording")
-			mStatus.SetTitle("Error: Failed to start")
-			mStatus.Show()
-			setState(StateIdle)
-			return
-		}
-
-		log.Println("Recording started - press Cmd+Shift+P again to stop")
-
-		// Add delay before sending indicator text to ensure the hotkey (Cmd+Shift+P)
-		// is fully released before AppleScript types. Without this delay, the modifier keys
-		// may still be pressed when keystroke injection occurs, causing incorrect characters.
-		time.Sleep(100 * time.Millisecond)
-		if err := sendTextToActiveWindow(recordingIndicator); err != nil {
-			log.Printf("Error sending recording indicator: %v", err)
-		}
-	} else {
-		log.Printf("Unexpected state in handleHotkey: %s", state)
+		return
 	}
+	// The repeated text is now what undo should remove
+	setLastInjectedText(text)
+	log.Println("Repeated last dictation")
 }
 
 func onExit() {
 	// Cleanup when app exits
 	log.Println("Cleaning up...")
+	stopApp()
 	if recorder != nil {
 		recorder.Close()
 	}
+	transcriberMu.Lock()
 	if transcriber != nil {
 		transcriber.Close()
 	}
+	transcriberMu.Unlock()
 	log.Println("GoWhisper menu bar app exiting")
 }
 
-// sendBackspaces sends the specified number of backspace key presses to delete text
-func sendBackspaces(count int) error {
-	if count <= 0 {
-		return nil
-	}
-
-	// AppleScript to send backspace keys (key code 51 is delete/backspace)
-	script := `
-		tell application "System Events"
-			repeat ` + fmt.Sprintf("%d", count) + ` times
-				key code 51
-			end repeat
-		end tell
-	`
-
-	cmd := exec.Command("osascript", "-e", script)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		log.Printf("AppleScript output: %s", string(output))
-		return err
-	}
-
-	log.Printf("Successfully sent %d backspaces", count)
-	return nil
-}
-
-// sendTextToActiveWindow sends text to the currently active window using AppleScript
-func sendTextToActiveWindow(text string) error {
-	// For complex text (multiline, special chars), use clipboard + paste instead of keystroke
-	// This avoids AppleScript escaping issues and permission dialogs
-
-	// Save current clipboard content
-	originalClipboard, err := clipboard.ReadAll()
-	if err != nil {
-		log.Printf("Warning: Could not read clipboard: %v", err)
-		originalClipboard = ""
-	}
-
-	// Put text in clipboard
-	if err := clipboard.WriteAll(text); err != nil {
-		return fmt.Errorf("failed to write to clipboard: %v", err)
-	}
-
-	// Use AppleScript to paste (Cmd+V)
-	script := `
-		tell application "System Events"
-			keystroke "v" using command down
-		end tell
-	`
-
-	cmd := exec.Command("osascript", "-e", script)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		log.Printf("AppleScript output: %s", string(output))
-		// Try to restore clipboard even if paste failed
-		if restoreErr := clipboard.WriteAll(originalClipboard); restoreErr != nil {
-			log.Printf("Warning: Failed to restore clipboard after paste error: %v", restoreErr)
-		}
-		return err
-	}
-
-	// Restore original clipboard content after a short delay
-	go func() {
-		time.Sleep(100 * time.Millisecond)
-		if err := clipboard.WriteAll(originalClipboard); err != nil {
-			log.Printf("Warning: Failed to restore clipboard in goroutine: %v", err)
-		}
-	}()
-
-	log.Printf("Successfully sent text: %s", text)
-	return nil
-}
-
 // startsWithClipboard checks if text starts with "clipboard" (case-insensitive)
 func startsWithClipboard(text string) bool {
 	lower := strings.ToLower(strings.TrimSpace(text))
@@ -677,10 +1116,22 @@ func containsKeywordInFirstNWords(text string, keywords []string, maxWords int)
 	return false
 }
 
-// containsClaude checks if text starts with "claude" or "clot" keyword (case-insensitive)
-// "clot" is a common Whisper misrecognition of "claude" when audio is unclear
+// claudeKeywords returns "claude" and its configured aliases in lower case.
+// The default alias "clot" is a common Whisper misrecognition of "claude"
+// when audio is unclear.
+func claudeKeywords() []string {
+	keywords := []string{"claude"}
+	for _, alias := range cfg().ClaudeAliases {
+		if alias = strings.ToLower(strings.TrimSpace(alias)); alias != "" {
+			keywords = append(keywords, alias)
+		}
+	}
+	return keywords
+}
+
+// containsClaude checks if text starts with "claude" or one of its aliases (case-insensitive)
 func containsClaude(text string) bool {
-	return containsKeywordInFirstNWords(text, []string{"claude", "clot"}, 2)
+	return containsKeywordInFirstNWords(text, claudeKeywords(), 2)
 }
 
 // containsClipboardKeyword checks if text starts with "clipboard" keyword (case-insensitive)
@@ -688,15 +1139,69 @@ func containsClipboardKeyword(text string) bool {
 	return containsKeywordInFirstNWords(text, []string{"clipboard"}, 2)
 }
 
-// removeCombinedKeywords removes both "claude"/"clot" and "clipboard" from text (any order)
+// containsTranslateKeyword checks if text starts with "translate" keyword (case-insensitive)
+func containsTranslateKeyword(text string) bool {
+	return containsKeywordInFirstNWords(text, []string{"translate"}, 2)
+}
+
+// removeTranslateKeyword removes "translate" from the first 2 words, leaving any
+// other keyword in place so the clipboard prefix can still be detected
+func removeTranslateKeyword(text string) string {
+	return removeKeywordInFirstNWords(text, "translate", 2)
+}
+
+// containsProofreadKeyword checks if text starts with "proofread" keyword (case-insensitive)
+func containsProofreadKeyword(text string) bool {
+	return containsKeywordInFirstNWords(text, []string{"proofread"}, 2)
+}
+
+// removeProofreadKeyword removes "proofread" from the first 2 words
+func removeProofreadKeyword(text string) string {
+	return removeKeywordInFirstNWords(text, "proofread", 2)
+}
+
+// containsTimestampKeyword checks if text starts with "timestamp" keyword (case-insensitive)
+func containsTimestampKeyword(text string) bool {
+	return containsKeywordInFirstNWords(text, []string{"timestamp"}, 2)
+}
+
+// removeTimestampKeyword removes "timestamp" from the first 2 words
+func removeTimestampKeyword(text string) string {
+	return removeKeywordInFirstNWords(text, "timestamp", 2)
+}
+
+// containsNoteKeyword checks if text starts with "note" keyword (case-insensitive)
+func containsNoteKeyword(text string) bool {
+	return containsKeywordInFirstNWords(text, []string{"note"}, 2)
+}
+
+// removeNoteKeyword removes "note" from the first 2 words. Unlike the other
+// keywords it isn't removed further in, since "note" is a common word.
+func removeNoteKeyword(text string) string {
+	return removeKeywordInFirstNWords(text, "note", 2)
+}
+
+// removeKeywordInFirstNWords removes the first occurrence of keyword within the
+// first maxWords words, ignoring case and punctuation
+func removeKeywordInFirstNWords(text string, keyword string, maxWords int) string {
+	words := strings.Fields(strings.TrimSpace(text))
+	for i := 0; i < len(words) && i < maxWords; i++ {
+		if strings.ToLower(stripPunctuation(words[i])) == keyword {
+			words = append(words[:i], words[i+1:]...)
+			break
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// removeCombinedKeywords removes "claude" and its aliases and "clipboard" from text (any order)
 func removeCombinedKeywords(text string) string {
 	words := strings.Fields(strings.TrimSpace(text))
+	keywords := append(claudeKeywords(), "clipboard")
 	var filtered []string
 
 	for _, word := range words {
-		cleaned := strings.ToLower(stripPunctuation(word))
-		// Remove "claude", "clot" (misrecognition), and "clipboard"
-		if cleaned != "claude" && cleaned != "clot" && cleaned != "clipboard" {
+		if !slices.Contains(keywords, strings.ToLower(stripPunctuation(word))) {
 			filtered = append(filtered, word)
 		}
 	}
@@ -704,13 +1209,36 @@ func removeCombinedKeywords(text string) string {
 	return strings.TrimSpace(strings.Join(filtered, " "))
 }
 
-// rephraseWithClaude sends text to Claude for rephrasing
-func rephraseWithClaude(text string) (string, error) {
+// setClaudeCancel records the cancel func of the Claude call in flight, nil
+// when it finished (thread-safe)
+func setClaudeCancel(cancel context.CancelFunc) {
+	claudeCancelMu.Lock()
+	defer claudeCancelMu.Unlock()
+	claudeCancel = cancel
+}
+
+// cancelRephrase cancels the Claude call in flight, returning false if there
+// is none (thread-safe)
+func cancelRephrase() bool {
+	claudeCancelMu.Lock()
+	defer claudeCancelMu.Unlock()
+	if claudeCancel == nil {
+		return false
+	}
+	log.Println("Cancelling Claude rephrase")
+	claudeCancel()
+	claudeCancel = nil
+	return true
+}
+
+// rephraseWithClaude sends text to Claude for rephrasing. Cancelling ctx
+// kills the Claude CLI.
+func rephraseWithClaude(ctx context.Context, text string) (string, error) {
 	systemPrompt := "You are a text refinement assistant. Output ONLY the refined text with NO explanation, NO commentary, NO meta-discussion about your instructions, and NO additional formatting. Do NOT acknowledge this prompt. Do NOT say what you're going to do. Just output the improved text and nothing else."
 
 	// Use claude CLI with --print flag and system prompt
 	// Use --strict-mcp-config with empty mcpServers to skip MCP plugins for faster startup
-	cmd := exec.Command("claude", "--print", "--strict-mcp-config", "--mcp-config", `{"mcpServers":{}}`, "--system-prompt", systemPrompt, "-p", text)
+	cmd := exec.CommandContext(ctx, "claude", "--print", "--strict-mcp-config", "--mcp-config", `{"mcpServers":{}}`, "--system-prompt", systemPrompt, "-p", text)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		log.Printf("Claude CLI error: %v, output: %s", err, string(output))
@@ -723,64 +1251,146 @@ func rephraseWithClaude(text string) (string, error) {
 	}
 
 	log.Printf("Claude rephrasing:\nOriginal: %s\nRephrased: %s", text, rephrased)
-	return rephrased, nil
+	return limitRephrasedLength(text, rephrased), nil
 }
 
-// escapeAppleScriptString escapes special characters for safe use in AppleScript strings
-// This prevents AppleScript injection attacks
-func escapeAppleScriptString(s string) string {
-	// Escape backslashes first (must be done before escaping quotes)
-	s = strings.ReplaceAll(s, `\`, `\\`)
-	// Then escape double quotes
-	s = strings.ReplaceAll(s, `"`, `\"`)
-	return s
+// maxRephrasedLength returns the longest allowed Claude output in runes for the
+// given input, or 0 if there is no limit. The stricter of both limits wins.
+func maxRephrasedLength(original string, maxChars int, maxRatio float64) int {
+	limit := maxChars
+	if maxRatio > 0 {
+		byRatio := int(float64(utf8.RuneCountInString(original)) * maxRatio)
+		if limit <= 0 || byRatio < limit {
+			limit = byRatio
+		}
+	}
+	return limit
 }
 
-// showErrorDialog displays an error dialog to the user
-func showErrorDialog(title, message string) {
-	// Escape inputs to prevent AppleScript injection
-	safeTitle := escapeAppleScriptString(title)
-	safeMessage := escapeAppleScriptString(message)
+// limitRephrasedLength guards against Claude "helpfully" expanding the text.
+// If the output exceeds the configured limit it is truncated or replaced by
+// the original text, depending on cfg.ClaudeTruncateLongOutput.
+func limitRephrasedLength(original, rephrased string) string {
+	limit := maxRephrasedLength(original, cfg().ClaudeMaxChars, cfg().ClaudeMaxRatio)
+	length := utf8.RuneCountInString(rephrased)
+	if limit <= 0 || length <= limit {
+		return rephrased
+	}
 
-	// AppleScript to show a dialog
-	script := `
-		display dialog "` + safeMessage + `" with title "` + safeTitle + `" buttons {"OK"} default button "OK" with icon caution
-	`
+	if cfg().ClaudeTruncateLongOutput {
+		log.Printf("Warning: Claude output too long (%d > %d characters), truncating", length, limit)
+		return strings.TrimSpace(string([]rune(rephrased)[:limit]))
+	}
+	log.Printf("Warning: Claude output too long (%d > %d characters), using original text", length, limit)
+	return original
+}
+
+// briefStatusDuration is how long a status shown with showStatusBriefly stays
+// in the status line
+const briefStatusDuration = 5 * time.Second
+
+// showTimingsBriefly shows the transcription speed in the status line.
+// Call it after returning to Idle.
+func showTimingsBriefly(timings whisper.Timings) {
+	showStatusBriefly(fmt.Sprintf("⏱ %.1fs audio in %.2fs (%.1fx)",
+		timings.Audio.Seconds(), timings.Processing.Seconds(), timings.RealTimeFactor()))
+}
+
+// showStatusBriefly shows status in the status line, hiding it again after a
+// few seconds unless a new dictation has started since. Call it after
+// returning to Idle.
+func showStatusBriefly(status string) {
+	ui.SetStatus(status)
+	ui.ShowStatus()
+
+	shownAt := time.Now()
+	time.AfterFunc(briefStatusDuration, func() {
+		stateMu.Lock()
+		unchanged := currentState == StateIdle && !lastTransition.After(shownAt)
+		stateMu.Unlock()
+		if unchanged {
+			ui.HideStatus()
+		}
+	})
+}
 
-	cmd := exec.Command("osascript", "-e", script)
-	if err := cmd.Run(); err != nil {
-		log.Printf("Failed to show error dialog: %v", err)
+// combineRephrased builds the text to output after rephrasing according to
+// cfg.ClaudeOutputMode: the rephrased text alone, or together with the
+// original joined by cfg.ClaudeSeparator
+func combineRephrased(original, rephrased string) string {
+	// Nothing to compare if Claude's output was rejected in favor of the original
+	if rephrased == original {
+		return rephrased
+	}
+	switch cfg().ClaudeOutputMode {
+	case config.ClaudeOutputBelow:
+		return original + cfg().ClaudeSeparator + rephrased
+	case config.ClaudeOutputAbove:
+		return rephrased + cfg().ClaudeSeparator + original
+	default:
+		return rephrased
 	}
 }
 
-// startRecordingAnimation starts a blinking animation in the menu bar
+// wrapInjectedText adds cfg.InjectPrefix and cfg.InjectSuffix around text
+func wrapInjectedText(text string) string {
+	return cfg().InjectPrefix + text + cfg().InjectSuffix
+}
+
+// startRecordingAnimation starts a blinking animation in the menu bar and
+// shows the elapsed recording time in the status line, updated every second
 func startRecordingAnimation() {
 	// Stop any existing animation before starting a new one to prevent goroutine leaks
 	stopRecordingAnimation()
 
+	// Static indicator for people who find the blinking distracting
+	blink := cfg().BlinkRecordingIcon
+	if !blink {
+		systray.SetTitle(cfg().RecordingIconOn)
+	}
+
+	iconOn, iconOff := cfg().RecordingIconOn, cfg().RecordingIconOff
 	stopAnimation = make(chan bool, 1)
+	stop := stopAnimation
 	go func() {
-		ticker := time.NewTicker(750 * time.Millisecond) // Blink every 750ms
-		defer ticker.Stop()
+		var blinkTick <-chan time.Time
+		if blink {
+			ticker := time.NewTicker(cfg().BlinkInterval())
+			defer ticker.Stop()
+			blinkTick = ticker.C
+		}
+		elapsed := time.NewTicker(time.Second)
+		defer elapsed.Stop()
 
 		blinkState := false
 		for {
 			select {
-			case <-stopAnimation:
+			case <-stop:
 				return
-			case <-ticker.C:
+			case <-blinkTick:
 				if blinkState {
-					systray.SetTitle("🔴") // Filled red circle
+					systray.SetTitle(iconOn) // Filled red circle by default
 				} else {
-					systray.SetTitle("⭕") // Hollow red circle
+					systray.SetTitle(iconOff) // Hollow red circle by default
 				}
 				blinkState = !blinkState
+			case <-elapsed.C:
+				// A tick racing with stop must not overwrite "Processing..."
+				if getState() == StateRecording {
+					mStatus.SetTitle("🎤 Recording... " + formatElapsed(recorder.Duration()))
+				}
 			}
 		}
 	}()
 }
 
-// stopRecordingAnimation stops the blinking animation
+// formatElapsed formats a recording duration as m:ss
+func formatElapsed(d time.Duration) string {
+	seconds := int(d / time.Second)
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// stopRecordingAnimation stops the blinking animation and elapsed time updates
 func stopRecordingAnimation() {
 	if stopAnimation != nil {
 		select {
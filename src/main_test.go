@@ -1,8 +1,25 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"sync"
 	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stephanwesten/go-whisper/src/audio"
+	"github.com/stephanwesten/go-whisper/src/config"
+	"github.com/stephanwesten/go-whisper/src/whisper"
+	"golang.design/x/hotkey"
 )
 
 // TestStateManagement tests the thread-safe state management functions
@@ -814,6 +831,104 @@ func TestKeywordCombinations(t *testing.T) {
 	}
 }
 
+// TestTranslateKeyword tests detecting and removing the translate keyword, alone
+// and combined with the claude and clipboard keywords
+func TestTranslateKeyword(t *testing.T) {
+	tests := []struct {
+		name              string
+		input             string
+		expectTranslate   bool
+		expectClaude      bool
+		expectClipboard   bool
+		expectedProcessed string
+	}{
+		{
+			name:              "only translate",
+			input:             "translate bonjour tout le monde",
+			expectTranslate:   true,
+			expectedProcessed: "bonjour tout le monde",
+		},
+		{
+			name:              "translate with punctuation",
+			input:             "Translate, guten Morgen",
+			expectTranslate:   true,
+			expectedProcessed: "guten Morgen",
+		},
+		{
+			name:              "translate then clipboard",
+			input:             "translate clipboard hola amigos",
+			expectTranslate:   true,
+			expectClipboard:   true,
+			expectedProcessed: "hola amigos",
+		},
+		{
+			name:              "clipboard then translate",
+			input:             "clipboard translate hola amigos",
+			expectTranslate:   true,
+			expectClipboard:   true,
+			expectedProcessed: "hola amigos",
+		},
+		{
+			name:              "translate then claude",
+			input:             "translate claude ciao a tutti",
+			expectTranslate:   true,
+			expectClaude:      true,
+			expectedProcessed: "ciao a tutti",
+		},
+		{
+			name:              "claude then translate",
+			input:             "Claude translate ciao a tutti",
+			expectTranslate:   true,
+			expectClaude:      true,
+			expectedProcessed: "ciao a tutti",
+		},
+		{
+			name:              "translate later in sentence is not a keyword",
+			input:             "please help me translate this",
+			expectedProcessed: "please help me translate this",
+		},
+		{
+			name:              "translate later in a claude prompt is kept",
+			input:             "claude how do I translate this sentence",
+			expectClaude:      true,
+			expectedProcessed: "how do I translate this sentence",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasTranslate := containsTranslateKeyword(tt.input)
+			hasClaude := containsClaude(tt.input)
+			hasClipboard := containsClipboardKeyword(tt.input)
+
+			if hasTranslate != tt.expectTranslate {
+				t.Errorf("containsTranslateKeyword(%q) = %v, want %v", tt.input, hasTranslate, tt.expectTranslate)
+			}
+			if hasClaude != tt.expectClaude {
+				t.Errorf("containsClaude(%q) = %v, want %v", tt.input, hasClaude, tt.expectClaude)
+			}
+			if hasClipboard != tt.expectClipboard {
+				t.Errorf("containsClipboardKeyword(%q) = %v, want %v", tt.input, hasClipboard, tt.expectClipboard)
+			}
+
+			// Mirror the keyword removal in handleHotkey
+			processed := tt.input
+			if hasTranslate {
+				processed = removeTranslateKeyword(processed)
+			}
+			if hasClaude {
+				processed = removeCombinedKeywords(processed)
+			} else if hasClipboard {
+				processed = removeClipboardPrefix(processed)
+			}
+
+			if processed != tt.expectedProcessed {
+				t.Errorf("After processing %q, got %q, want %q", tt.input, processed, tt.expectedProcessed)
+			}
+		})
+	}
+}
+
 // TestToggleHotkeyRaceCondition tests that state transitions happen before cleanup operations
 // This exposes Critical Issue #1: Race condition in toggleHotkey
 func TestToggleHotkeyRaceCondition(t *testing.T) {
@@ -1002,84 +1117,6 @@ func TestRecordingAnimationGoroutineLeak(t *testing.T) {
 	})
 }
 
-// TestAppleScriptInjectionProtection tests that showErrorDialog properly escapes input
-// This addresses High Priority Issue #6: AppleScript injection in showErrorDialog
-func TestAppleScriptInjectionProtection(t *testing.T) {
-	t.Run("error dialog should escape quotes and special characters", func(t *testing.T) {
-		// Current code at line 709:
-		// script := `display dialog "` + message + `" with title "` + title + `" ...`
-		//
-		// Vulnerability: If message or title contains a double quote ("), it can break
-		// out of the string and inject arbitrary AppleScript
-		//
-		// Example attack:
-		// title = `Test"`
-		// Would generate: display dialog "..." with title "Test"" ...
-		// The extra quote breaks the syntax
-		//
-		// More severe attack:
-		// message = `foo" & (do shell script "rm -rf ~") & "`
-		// Would execute arbitrary shell commands
-		//
-		// Fix: Escape double quotes in both title and message
-		// Replace " with \"
-		//
-		// Better fix: Use a helper function to escape AppleScript strings
-		// func escapeAppleScriptString(s string) string {
-		//     s = strings.ReplaceAll(s, `\`, `\\`)  // Escape backslashes first
-		//     s = strings.ReplaceAll(s, `"`, `\"`)  // Then escape quotes
-		//     return s
-		// }
-
-		t.Log("Current: Direct string concatenation allows injection")
-		t.Log("Fix: Escape backslashes and quotes in title and message")
-		t.Log("Add escapeAppleScriptString() helper function")
-	})
-
-	t.Run("escapeAppleScriptString should properly escape special characters", func(t *testing.T) {
-		tests := []struct {
-			name     string
-			input    string
-			expected string
-		}{
-			{
-				name:     "simple string",
-				input:    "Hello World",
-				expected: "Hello World",
-			},
-			{
-				name:     "string with quotes",
-				input:    `He said "Hello"`,
-				expected: `He said \"Hello\"`,
-			},
-			{
-				name:     "string with backslash",
-				input:    `C:\Users\test`,
-				expected: `C:\\Users\\test`,
-			},
-			{
-				name:     "string with backslash and quote",
-				input:    `Path: "C:\test"`,
-				expected: `Path: \"C:\\test\"`,
-			},
-			{
-				name:     "injection attempt",
-				input:    `foo" & (do shell script "rm -rf ~") & "bar`,
-				expected: `foo\" & (do shell script \"rm -rf ~\") & \"bar`,
-			},
-		}
-
-		for _, tt := range tests {
-			t.Run(tt.name, func(t *testing.T) {
-				result := escapeAppleScriptString(tt.input)
-				if result != tt.expected {
-					t.Errorf("escapeAppleScriptString(%q) = %q, want %q", tt.input, result, tt.expected)
-				}
-			})
-		}
-	})
-}
-
 // TestAmplitudeCalculationLogic tests the amplitude calculation logic
 // This addresses High Priority Issue #7: Amplitude calculation bug
 func TestAmplitudeCalculationLogic(t *testing.T) {
@@ -1215,3 +1252,1501 @@ func TestKeywordRemovalWithAlternatives(t *testing.T) {
 		})
 	}
 }
+
+// TestClaudeAliasesConfig tests that the aliases for "claude" come from the config
+func TestClaudeAliasesConfig(t *testing.T) {
+	originalCfg := *cfg()
+	defer func() { setCfg(originalCfg) }()
+
+	tests := []struct {
+		name        string
+		aliases     []string
+		input       string
+		wantClaude  bool
+		wantRemoved string
+	}{
+		{"no aliases leaves clot alone", nil, "clot was found in the left leg", false, "clot was found in the left leg"},
+		{"claude still works without aliases", nil, "claude fix this", true, "fix this"},
+		{"custom alias", []string{"Cloud"}, "cloud, fix this", true, "fix this"},
+		{"removed alias no longer detected", []string{"cloud"}, "clot fix this", false, "clot fix this"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg().ClaudeAliases = tt.aliases
+			if got := containsClaude(tt.input); got != tt.wantClaude {
+				t.Errorf("containsClaude(%q) = %v, want %v", tt.input, got, tt.wantClaude)
+			}
+			if got := removeCombinedKeywords(tt.input); got != tt.wantRemoved {
+				t.Errorf("removeCombinedKeywords(%q) = %q, want %q", tt.input, got, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+// TestLastInjectedTextTracking tests the bookkeeping behind the undo action
+func TestLastInjectedTextTracking(t *testing.T) {
+	originalLen := lastInjectedLen
+	defer func() { lastInjectedLen = originalLen }()
+
+	t.Run("length is counted in runes", func(t *testing.T) {
+		setLastInjectedText("héllo wörld 👋")
+		if got := takeLastInjectedLen(); got != 13 {
+			t.Errorf("takeLastInjectedLen() = %d, want 13", got)
+		}
+	})
+
+	t.Run("take clears the stored length", func(t *testing.T) {
+		setLastInjectedText("hello")
+		if got := takeLastInjectedLen(); got != 5 {
+			t.Errorf("first takeLastInjectedLen() = %d, want 5", got)
+		}
+		if got := takeLastInjectedLen(); got != 0 {
+			t.Errorf("second takeLastInjectedLen() = %d, want 0 (double undo must not delete unrelated text)", got)
+		}
+	})
+
+	t.Run("undo ignored while not idle", func(t *testing.T) {
+		originalState := currentState
+		defer func() { currentState = originalState }()
+
+		setLastInjectedText("hello")
+		setState(StateRecording)
+		undoLastInjection()
+		if got := takeLastInjectedLen(); got != 5 {
+			t.Errorf("after undo while recording, takeLastInjectedLen() = %d, want 5 (unchanged)", got)
+		}
+	})
+}
+
+// fakeClipboard replaces the system clipboard for the duration of a test
+func fakeClipboard(t *testing.T, initial string) *string {
+	t.Helper()
+	content := initial
+	origRead, origWrite := readClipboard, writeClipboard
+	origGen, origSaved := clipboardGen, savedClipboard
+	readClipboard = func() (string, error) { return content, nil }
+	writeClipboard = func(text string) error {
+		content = text
+		return nil
+	}
+	savedClipboard = nil
+	t.Cleanup(func() {
+		readClipboard, writeClipboard = origRead, origWrite
+		clipboardGen, savedClipboard = origGen, origSaved
+	})
+	return &content
+}
+
+// TestClipboardOwnership tests that the final clipboard content matches user intent
+// for all four keyword branches, including restores that fire late
+func TestClipboardOwnership(t *testing.T) {
+	t.Run("plain dictation restores user clipboard", func(t *testing.T) {
+		content := fakeClipboard(t, "user content")
+
+		processingGen, _ := borrowClipboard(processingIndicator)
+		outputGen, _ := borrowClipboard("hello world")
+		if *content != "hello world" {
+			t.Fatalf("clipboard during paste = %q, want %q", *content, "hello world")
+		}
+
+		// The indicator's restore fires late, after the output was pasted
+		restoreClipboardIfCurrent(processingGen)
+		if *content != "hello world" {
+			t.Errorf("stale restore overwrote pasted text, clipboard = %q", *content)
+		}
+
+		restoreClipboardIfCurrent(outputGen)
+		if *content != "user content" {
+			t.Errorf("final clipboard = %q, want %q", *content, "user content")
+		}
+	})
+
+	t.Run("clipboard keyword keeps dictation on clipboard", func(t *testing.T) {
+		content := fakeClipboard(t, "user content")
+
+		processingGen, _ := borrowClipboard(processingIndicator)
+		if err := setClipboardContent("copied text"); err != nil {
+			t.Fatalf("setClipboardContent() error = %v", err)
+		}
+
+		restoreClipboardIfCurrent(processingGen)
+		if *content != "copied text" {
+			t.Errorf("final clipboard = %q, want %q", *content, "copied text")
+		}
+	})
+
+	t.Run("claude dictation restores user clipboard", func(t *testing.T) {
+		content := fakeClipboard(t, "user content")
+
+		processingGen, _ := borrowClipboard(processingIndicator)
+		claudeGen, _ := borrowClipboard("Asking Claude")
+		restoreClipboardIfCurrent(processingGen)
+		restoreClipboardIfCurrent(claudeGen)
+		outputGen, _ := borrowClipboard("Rephrased text.")
+		restoreClipboardIfCurrent(outputGen)
+
+		if *content != "user content" {
+			t.Errorf("final clipboard = %q, want %q", *content, "user content")
+		}
+	})
+
+	t.Run("claude and clipboard keeps rephrased text", func(t *testing.T) {
+		content := fakeClipboard(t, "user content")
+
+		processingGen, _ := borrowClipboard(processingIndicator)
+		claudeGen, _ := borrowClipboard("Asking Claude")
+		setClipboardContent("Rephrased text.")
+		restoreClipboardIfCurrent(processingGen)
+		restoreClipboardIfCurrent(claudeGen)
+
+		if *content != "Rephrased text." {
+			t.Errorf("final clipboard = %q, want %q", *content, "Rephrased text.")
+		}
+	})
+}
+
+// TestAppendClipboardContent tests accumulate mode for the clipboard keyword
+func TestAppendClipboardContent(t *testing.T) {
+	t.Run("appends on a new line", func(t *testing.T) {
+		content := fakeClipboard(t, "first")
+		if err := appendClipboardContent("second", 0); err != nil {
+			t.Fatalf("appendClipboardContent() error = %v", err)
+		}
+		if *content != "first\nsecond" {
+			t.Errorf("clipboard = %q, want %q", *content, "first\nsecond")
+		}
+	})
+
+	t.Run("empty clipboard gets text only", func(t *testing.T) {
+		content := fakeClipboard(t, "")
+		appendClipboardContent("only", 0)
+		if *content != "only" {
+			t.Errorf("clipboard = %q, want %q", *content, "only")
+		}
+	})
+
+	t.Run("appends to user content while an indicator is pasted", func(t *testing.T) {
+		content := fakeClipboard(t, "first")
+		processingGen, _ := borrowClipboard(processingIndicator)
+		appendClipboardContent("second", 0)
+		restoreClipboardIfCurrent(processingGen)
+		if *content != "first\nsecond" {
+			t.Errorf("clipboard = %q, want %q", *content, "first\nsecond")
+		}
+	})
+
+	t.Run("drops oldest lines over the limit", func(t *testing.T) {
+		content := fakeClipboard(t, "one\ntwo")
+		appendClipboardContent("three", 10)
+		if *content != "two\nthree" {
+			t.Errorf("clipboard = %q, want %q", *content, "two\nthree")
+		}
+	})
+}
+
+// TestKeepLastChars tests trimming accumulated clipboard text from the start
+func TestKeepLastChars(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		maxChars int
+		want     string
+	}{
+		{"no limit", "one\ntwo", 0, "one\ntwo"},
+		{"within limit", "one\ntwo", 7, "one\ntwo"},
+		{"cut at line start", "one\ntwo", 3, "two"},
+		{"cut mid-line skips to next line", "one\ntwo\nsix", 6, "six"},
+		{"single long line is cut", "abcdefgh", 3, "fgh"},
+		{"counts characters not bytes", "ééé\nüü", 2, "üü"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := keepLastChars(tt.text, tt.maxChars); got != tt.want {
+				t.Errorf("keepLastChars(%q, %d) = %q, want %q", tt.text, tt.maxChars, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestClipboardWriteFailure tests that a failed write reports an error and
+// leaves no pending restore behind
+func TestClipboardWriteFailure(t *testing.T) {
+	content := fakeClipboard(t, "user content")
+	writeClipboard = func(string) error { return errors.New("no clipboard") }
+
+	if _, err := borrowClipboard("hello world"); err == nil {
+		t.Error("borrowClipboard() error = nil, want error")
+	}
+	if savedClipboard != nil {
+		t.Errorf("savedClipboard = %q after failed write, want nil", *savedClipboard)
+	}
+	if err := setClipboardContent("copied text"); err == nil {
+		t.Error("setClipboardContent() error = nil, want error")
+	}
+	if *content != "user content" {
+		t.Errorf("clipboard = %q, want %q", *content, "user content")
+	}
+}
+
+// TestPrepareSamplesTooShort tests that the length check sees the trimmed audio
+func TestPrepareSamplesTooShort(t *testing.T) {
+	originalCfg := *cfg()
+	defer func() { setCfg(originalCfg) }()
+
+	// 2 seconds of recording with only 0.1 seconds of speech
+	samples := make([]float32, 2*audio.SampleRate)
+	for i := audio.SampleRate; i < audio.SampleRate+audio.SampleRate/10; i++ {
+		samples[i] = 0.5
+	}
+
+	t.Run("without trimming the raw length counts", func(t *testing.T) {
+		cfg().TrimSilence = false
+		if got := prepareSamples(samples); len(got) < minSpeechSamples {
+			t.Errorf("prepareSamples() length = %d, want untrimmed %d", len(got), len(samples))
+		}
+	})
+
+	t.Run("with trimming a mostly silent recording is too short", func(t *testing.T) {
+		cfg().TrimSilence = true
+		cfg().TrimThreshold = 0.01
+		if got := prepareSamples(samples); len(got) >= minSpeechSamples {
+			t.Errorf("prepareSamples() length = %d, want < %d after trimming", len(got), minSpeechSamples)
+		}
+	})
+}
+
+// TestLastOutputCache tests the cache behind "Repeat Last Dictation"
+func TestLastOutputCache(t *testing.T) {
+	originalText := lastOutputText
+	defer func() { lastOutputText = originalText }()
+
+	setLastOutput("first dictation")
+	setLastOutput("second dictation")
+	if got := getLastOutput(); got != "second dictation" {
+		t.Errorf("getLastOutput() = %q, want %q", got, "second dictation")
+	}
+	// Reading does not clear, so the text can be repeated several times
+	if got := getLastOutput(); got != "second dictation" {
+		t.Errorf("second getLastOutput() = %q, want %q", got, "second dictation")
+	}
+}
+
+// TestLimitRephrasedLength tests the guard against runaway Claude output
+func TestLimitRephrasedLength(t *testing.T) {
+	originalCfg := *cfg()
+	defer func() { setCfg(originalCfg) }()
+
+	t.Run("max length uses the stricter limit", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			original string
+			maxChars int
+			maxRatio float64
+			want     int
+		}{
+			{"no limits", "hello", 0, 0, 0},
+			{"chars only", "hello", 20, 0, 20},
+			{"ratio only", "hello", 0, 3, 15},
+			{"ratio stricter", "hello", 20, 3, 15},
+			{"chars stricter", "hello", 10, 3, 10},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if got := maxRephrasedLength(tt.original, tt.maxChars, tt.maxRatio); got != tt.want {
+					t.Errorf("maxRephrasedLength() = %d, want %d", got, tt.want)
+				}
+			})
+		}
+	})
+
+	t.Run("within limit is unchanged", func(t *testing.T) {
+		cfg().ClaudeMaxChars, cfg().ClaudeMaxRatio = 0, 3
+		if got := limitRephrasedLength("hi there", "Hi there!"); got != "Hi there!" {
+			t.Errorf("limitRephrasedLength() = %q, want %q", got, "Hi there!")
+		}
+	})
+
+	t.Run("too long falls back to original", func(t *testing.T) {
+		cfg().ClaudeMaxChars, cfg().ClaudeMaxRatio, cfg().ClaudeTruncateLongOutput = 0, 2, false
+		if got := limitRephrasedLength("fix this", "Here is a much longer answer than you asked for."); got != "fix this" {
+			t.Errorf("limitRephrasedLength() = %q, want original text", got)
+		}
+	})
+
+	t.Run("too long is truncated when configured", func(t *testing.T) {
+		cfg().ClaudeMaxChars, cfg().ClaudeMaxRatio, cfg().ClaudeTruncateLongOutput = 10, 0, true
+		if got := limitRephrasedLength("fix this", "Fixed text is here."); got != "Fixed text" {
+			t.Errorf("limitRephrasedLength() = %q, want %q", got, "Fixed text")
+		}
+	})
+}
+
+// TestDictationMetrics tests the counters served by the metrics endpoint
+func TestDictationMetrics(t *testing.T) {
+	origMetrics := metrics
+	t.Cleanup(func() { metrics = origMetrics })
+	metrics = &dictationMetrics{}
+
+	timings := whisper.Timings{Audio: 4 * time.Second, Processing: time.Second}
+	for _, res := range []dictationResult{
+		{Action: actionType, Timings: timings},
+		{Action: actionType, Timings: timings},
+		{Action: actionClipboard, Timings: timings},
+		{Action: actionNone, Err: fmt.Errorf("%w: %v", errTranscribe, "model crashed")},
+		{Action: actionNone, Err: errCancelled},
+		{Action: actionNone, Err: errors.New("unexpected")},
+	} {
+		metrics.record(res)
+	}
+
+	rec := httptest.NewRecorder()
+	serveMetrics(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"gowhisper_recordings_total 6\n",
+		"gowhisper_dictations_total{action=\"clipboard\"} 1\n",
+		"gowhisper_dictations_total{action=\"type\"} 2\n",
+		"gowhisper_errors_total{stage=\"transcribe\"} 1\n",
+		"gowhisper_errors_total{stage=\"other\"} 1\n",
+		"gowhisper_cancelled_total 1\n",
+		"gowhisper_audio_seconds_total 12\n",
+		"gowhisper_transcription_seconds_total 3\n",
+		"gowhisper_realtime_factor 4\n",
+		"# TYPE gowhisper_realtime_factor gauge\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q, got:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "gowhisper_errors_total{stage=\"type\"}") {
+		t.Errorf("metrics report an error that never happened:\n%s", body)
+	}
+}
+
+// TestAfterWakePhrase tests finding the wake phrase and removing it with
+// what was said before it
+func TestAfterWakePhrase(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		phrase    string
+		wantFound bool
+		want      string
+	}{
+		{"phrase then text", "Hey Whisper, buy milk.", "hey whisper", true, "buy milk."},
+		{"speech before the phrase", "so anyway. Hey, whisper! Call Sam", "hey whisper", true, "Call Sam"},
+		{"phrase alone", "Hey whisper.", "hey whisper", true, ""},
+		{"keyword after the phrase", "hey whisper clipboard copy this", "hey whisper", true, "clipboard copy this"},
+		{"words apart", "hey there whisper", "hey whisper", false, "hey there whisper"},
+		{"part of a word", "they whispered", "hey whisper", false, "they whispered"},
+		{"not heard", "buy milk", "hey whisper", false, "buy milk"},
+		{"empty phrase", "buy milk", "", false, "buy milk"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if found := findWakePhrase(tt.text, tt.phrase) >= 0; found != tt.wantFound {
+				t.Errorf("findWakePhrase(%q, %q) found = %v, want %v", tt.text, tt.phrase, found, tt.wantFound)
+			}
+			if got := afterWakePhrase(tt.text, tt.phrase); got != tt.want {
+				t.Errorf("afterWakePhrase(%q, %q) = %q, want %q", tt.text, tt.phrase, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestWakeListenerHeard tests that only audio loud enough is transcribed and
+// checked for the wake phrase
+func TestWakeListenerHeard(t *testing.T) {
+	origTranscribe := wakeTranscribe
+	t.Cleanup(func() { wakeTranscribe = origTranscribe })
+
+	tests := []struct {
+		name           string
+		level          float32
+		samples        int
+		transcript     string
+		err            error
+		want           bool
+		wantTranscribe int // Samples transcribed, 0 if not at all
+	}{
+		{"phrase heard", 0.1, audio.SampleRate, "Hey whisper.", nil, true, audio.SampleRate},
+		{"other speech", 0.1, audio.SampleRate, "what's for dinner", nil, false, audio.SampleRate},
+		{"only the window", 0.1, 3 * audio.SampleRate, "hey whisper", nil, true, 2 * audio.SampleRate},
+		{"silence skipped", 0.001, audio.SampleRate, "hey whisper", nil, false, 0},
+		{"too short", 0.1, audio.SampleRate / 4, "hey whisper", nil, false, 0},
+		{"transcription fails", 0.1, audio.SampleRate, "", errors.New("model gone"), false, audio.SampleRate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transcribed := 0
+			wakeTranscribe = func(samples []float32) (string, error) {
+				transcribed = len(samples)
+				return tt.transcript, tt.err
+			}
+			samples := make([]float32, tt.samples)
+			for i := range samples {
+				samples[i] = tt.level
+			}
+
+			w := wakeListener{phrase: "hey whisper", window: 2 * audio.SampleRate, threshold: 0.01}
+			if got := w.heard(samples); got != tt.want {
+				t.Errorf("heard() = %v, want %v", got, tt.want)
+			}
+			if transcribed != tt.wantTranscribe {
+				t.Errorf("transcribed %d samples, want %d", transcribed, tt.wantTranscribe)
+			}
+		})
+	}
+}
+
+// TestPasteLanded tests judging from the focused element whether typed text arrived
+func TestPasteLanded(t *testing.T) {
+	tests := []struct {
+		name  string
+		role  string
+		value string
+		text  string
+		want  bool
+	}{
+		{"nothing focused", "", "", "hello world", false},
+		{"button focused", "AXButton", "", "hello world", false},
+		{"list focused", "AXList", "", "hello world", false},
+		{"text field with text", "AXTextField", "Dear Bob, hello world.", "hello world.", true},
+		{"text area missing text", "AXTextArea", "Dear Bob,", "hello world", false},
+		{"punctuation reformatted", "AXTextArea", "“Quoted” – fine", "\"Quoted\" - fine", true},
+		{"auto-capitalized", "AXTextField", "Hello world", "hello world", true},
+		{"only the end of long text", "AXTextArea", "the end of a long dictation", "the start gets scrolled away, the end of a long dictation", true},
+		{"empty text field value", "AXTextField", "", "hello world", true},
+		{"web content", "AXWebArea", "", "hello world", true},
+		{"unknown role", "AXGroup", "something else", "hello world", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pasteLanded(tt.role, tt.value, tt.text); got != tt.want {
+				t.Errorf("pasteLanded(%q, %q, %q) = %v, want %v", tt.role, tt.value, tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTypeInChunks tests splitting text for the slow injection mode
+func TestTypeInChunks(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		size  int
+		want  []string
+	}{
+		{"even split", "abcdef", 3, []string{"abc", "def"}},
+		{"short last chunk", "abcdefg", 3, []string{"abc", "def", "g"}},
+		{"whole text fits", "hi", 10, []string{"hi"}},
+		{"multi-byte characters", "héllo wörld", 4, []string{"héll", "o wö", "rld"}},
+		{"CRLF kept together", "ab\r\ncd", 3, []string{"ab\n", "cd"}},
+		{"size below one", "abc", 0, []string{"a", "b", "c"}},
+		{"empty", "", 5, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := typeInChunks(tt.input, tt.size, 0, func(chunk string) error {
+				got = append(got, chunk)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("typeInChunks() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("typeInChunks(%q, %d) typed %q, want %q", tt.input, tt.size, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("stops at the first error", func(t *testing.T) {
+		calls := 0
+		err := typeInChunks("abcdef", 2, 0, func(string) error {
+			calls++
+			return errors.New("app not responding")
+		})
+		if err == nil || calls != 1 {
+			t.Errorf("typeInChunks() error = %v after %d chunks, want an error after 1", err, calls)
+		}
+	})
+}
+
+// TestOutputURL tests filling the output URL template
+func TestOutputURL(t *testing.T) {
+	tests := []struct {
+		template string
+		text     string
+		want     string
+	}{
+		{"drafts://create?text={text}", "hello world", "drafts://create?text=hello%20world"},
+		{"drafts://create?text={text}", "a+b & c=d?", "drafts://create?text=a%2Bb%20%26%20c%3Dd%3F"},
+		{"things:///add?title={text}&notes={text}", "café", "things:///add?title=caf%C3%A9&notes=caf%C3%A9"},
+		{"bear://x-callback-url/create?text={text}", "line one\nline two", "bear://x-callback-url/create?text=line%20one%0Aline%20two"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := outputURL(tt.template, tt.text); got != tt.want {
+				t.Errorf("outputURL(%q, %q) = %q, want %q", tt.template, tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseHotkey tests parsing configured hotkeys
+func TestParseHotkey(t *testing.T) {
+	cmd, shift, opt := hotkeyModifierNames["cmd"], hotkeyModifierNames["shift"], hotkeyModifierNames["option"]
+	ctrl := hotkeyModifierNames["ctrl"]
+	tests := []struct {
+		input    string
+		wantMods []hotkey.Modifier
+		wantKey  hotkey.Key
+		wantErr  bool
+	}{
+		{input: "cmd+shift+c", wantMods: []hotkey.Modifier{cmd, shift}, wantKey: hotkey.KeyC},
+		{input: "Command + Shift + 5", wantMods: []hotkey.Modifier{cmd, shift}, wantKey: hotkey.Key5},
+		{input: "alt+option+x", wantMods: []hotkey.Modifier{opt}, wantKey: hotkey.KeyX},
+		{input: "ctrl+option+space", wantMods: []hotkey.Modifier{ctrl, opt}, wantKey: hotkey.KeySpace},
+		{input: "Control+Alt+Space", wantMods: []hotkey.Modifier{ctrl, opt}, wantKey: hotkey.KeySpace},
+		{input: "opt+f5", wantMods: []hotkey.Modifier{opt}, wantKey: hotkey.KeyF5},
+		{input: "ctrl+shift+F12", wantMods: []hotkey.Modifier{ctrl, shift}, wantKey: hotkey.KeyF12},
+		{input: "cmd+f20", wantMods: []hotkey.Modifier{cmd}, wantKey: hotkey.KeyF20},
+		{input: "cmd+shift+enter", wantMods: []hotkey.Modifier{cmd, shift}, wantKey: hotkey.KeyReturn},
+		{input: "cmd+return", wantMods: []hotkey.Modifier{cmd}, wantKey: hotkey.KeyReturn},
+		{input: "ctrl+esc", wantMods: []hotkey.Modifier{ctrl}, wantKey: hotkey.KeyEscape},
+		{input: "ctrl+escape", wantMods: []hotkey.Modifier{ctrl}, wantKey: hotkey.KeyEscape},
+		{input: "ctrl+tab", wantMods: []hotkey.Modifier{ctrl}, wantKey: hotkey.KeyTab},
+		{input: "cmd+backspace", wantMods: []hotkey.Modifier{cmd}, wantKey: hotkey.KeyDelete},
+		{input: "cmd+delete", wantMods: []hotkey.Modifier{cmd}, wantKey: hotkey.KeyDelete},
+		{input: "ctrl+opt+left", wantMods: []hotkey.Modifier{ctrl, opt}, wantKey: hotkey.KeyLeft},
+		{input: "ctrl+opt+right", wantMods: []hotkey.Modifier{ctrl, opt}, wantKey: hotkey.KeyRight},
+		{input: "ctrl+opt+up", wantMods: []hotkey.Modifier{ctrl, opt}, wantKey: hotkey.KeyUp},
+		{input: "ctrl+opt+down", wantMods: []hotkey.Modifier{ctrl, opt}, wantKey: hotkey.KeyDown},
+		{input: "c", wantErr: true},
+		{input: "space", wantErr: true},
+		{input: "cmd+shift+", wantErr: true},
+		{input: "cmd+shift+f21", wantErr: true},
+		{input: "cmd+shift+insert", wantErr: true},
+		{input: "hyper+c", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			mods, key, err := parseHotkey(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseHotkey(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(mods, tt.wantMods) || key != tt.wantKey {
+				t.Errorf("parseHotkey(%q) = %v, %v, want %v, %v", tt.input, mods, key, tt.wantMods, tt.wantKey)
+			}
+		})
+	}
+}
+
+// TestRecordingHotkeyName tests the configured recording hotkey and the
+// fallback to the default for one that can't be parsed
+func TestRecordingHotkeyName(t *testing.T) {
+	originalCfg := *cfg()
+	defer func() { setCfg(originalCfg) }()
+
+	tests := []struct {
+		configured string
+		want       string
+		wantLabel  string
+	}{
+		{"ctrl+option+space", "ctrl+option+space", "Ctrl+Option+Space"},
+		{"Cmd + Shift + F5", "Cmd + Shift + F5", "Cmd+Shift+F5"},
+		{"cmd+shift+p", "cmd+shift+p", "Cmd+Shift+P"},
+		{"space", "cmd+shift+p", "Cmd+Shift+P"},
+		{"", "cmd+shift+p", "Cmd+Shift+P"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.configured, func(t *testing.T) {
+			cfg().RecordingHotkey = tt.configured
+			got := recordingHotkeyName()
+			if got != tt.want {
+				t.Errorf("recordingHotkeyName() = %q, want %q", got, tt.want)
+			}
+			if label := hotkeyLabel(got); label != tt.wantLabel {
+				t.Errorf("hotkeyLabel(%q) = %q, want %q", got, label, tt.wantLabel)
+			}
+		})
+	}
+}
+
+// TestHotkeyDebounce tests that triggers right after a state transition are ignored
+func TestHotkeyDebounce(t *testing.T) {
+	originalState := currentState
+	originalEnabled := isEnabled
+	originalTransition := lastTransition
+	originalCfg := *cfg()
+	defer func() {
+		currentState = originalState
+		isEnabled = originalEnabled
+		lastTransition = originalTransition
+		setCfg(originalCfg)
+	}()
+
+	t.Run("trigger right after processing finished is ignored", func(t *testing.T) {
+		cfg().HotkeyDebounceMs = 200
+		setHotkeyEnabled(true)
+		setState(StateProcessing)
+		setState(StateIdle) // Processing just completed
+
+		// Would start a new recording (and need a real recorder) if not debounced
+		handleHotkey()
+
+		if got := getState(); got != StateIdle {
+			t.Errorf("state after debounced trigger = %v, want StateIdle", got)
+		}
+	})
+
+	t.Run("window expires", func(t *testing.T) {
+		setState(StateIdle)
+		lastTransition = time.Now().Add(-300 * time.Millisecond)
+		if withinDebounceWindow(200 * time.Millisecond) {
+			t.Error("withinDebounceWindow() = true 300ms after transition, want false")
+		}
+	})
+
+	t.Run("zero window disables debounce", func(t *testing.T) {
+		setState(StateIdle)
+		if withinDebounceWindow(0) {
+			t.Error("withinDebounceWindow(0) = true, want false")
+		}
+	})
+}
+
+// TestProcessingCooldown tests that triggers right after processing finished
+// are ignored, even once the debounce window has passed
+func TestProcessingCooldown(t *testing.T) {
+	originalState := currentState
+	originalEnabled := isEnabled
+	originalTransition, originalFinished := lastTransition, processingFinishedAt
+	originalCfg := *cfg()
+	defer func() {
+		currentState = originalState
+		isEnabled = originalEnabled
+		lastTransition, processingFinishedAt = originalTransition, originalFinished
+		setCfg(originalCfg)
+	}()
+
+	t.Run("trigger within cooldown is ignored", func(t *testing.T) {
+		cfg().HotkeyDebounceMs = 0
+		cfg().ProcessingCooldownMs = 500
+		setHotkeyEnabled(true)
+		setState(StateProcessing)
+		setState(StateIdle)
+
+		// Would start a new recording (and need a real recorder) if not ignored
+		handleHotkey()
+
+		if got := getState(); got != StateIdle {
+			t.Errorf("state after trigger in cooldown = %v, want StateIdle", got)
+		}
+	})
+
+	t.Run("only processing starts the cooldown", func(t *testing.T) {
+		setState(StateRecording)
+		processingFinishedAt = time.Time{}
+		setState(StateIdle) // Recording cancelled, not processed
+		if withinProcessingCooldown(500 * time.Millisecond) {
+			t.Error("withinProcessingCooldown() = true after Recording -> Idle, want false")
+		}
+	})
+
+	t.Run("cooldown expires", func(t *testing.T) {
+		setState(StateProcessing)
+		setState(StateIdle)
+		processingFinishedAt = time.Now().Add(-600 * time.Millisecond)
+		if withinProcessingCooldown(500 * time.Millisecond) {
+			t.Error("withinProcessingCooldown() = true 600ms after processing, want false")
+		}
+	})
+
+	t.Run("zero cooldown disables it", func(t *testing.T) {
+		setState(StateProcessing)
+		setState(StateIdle)
+		if withinProcessingCooldown(0) {
+			t.Error("withinProcessingCooldown(0) = true, want false")
+		}
+	})
+}
+
+// TestDecodeAudioStreamRejectsInvalidInput tests that stdin mode reports a
+// clear error instead of transcribing garbage
+func TestDecodeAudioStreamRejectsInvalidInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "empty stream", input: ""},
+		{name: "plain text", input: "this is not audio"},
+		{name: "truncated header", input: "RIFF\x24\x00\x00\x00WAVE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples, err := decodeAudioStream(strings.NewReader(tt.input))
+			if err == nil {
+				t.Errorf("decodeAudioStream(%q) = %d samples, want error", tt.input, len(samples))
+			}
+		})
+	}
+}
+
+// TestReleaseIdleTranscriber tests that the model is only released after the
+// idle timeout while no dictation is in progress
+func TestReleaseIdleTranscriber(t *testing.T) {
+	originalTranscriber := transcriber
+	originalLoadedAt := transcriberLoadedAt
+	originalState := currentState
+	originalTransition := lastTransition
+	defer func() {
+		transcriber = originalTranscriber
+		transcriberLoadedAt = originalLoadedAt
+		currentState = originalState
+		lastTransition = originalTransition
+	}()
+
+	const timeout = 10 * time.Minute
+	longAgo := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name         string
+		state        AppState
+		transition   time.Time
+		loadedAt     time.Time
+		wantReleased bool
+	}{
+		{name: "idle past timeout", state: StateIdle, transition: longAgo, loadedAt: longAgo, wantReleased: true},
+		{name: "never used since startup", state: StateIdle, transition: time.Time{}, loadedAt: longAgo, wantReleased: true},
+		{name: "recent dictation", state: StateIdle, transition: time.Now(), loadedAt: longAgo, wantReleased: false},
+		{name: "recently reloaded", state: StateIdle, transition: longAgo, loadedAt: time.Now(), wantReleased: false},
+		{name: "recording", state: StateRecording, transition: longAgo, loadedAt: longAgo, wantReleased: false},
+		{name: "processing", state: StateProcessing, transition: longAgo, loadedAt: longAgo, wantReleased: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transcriber = &whisper.Transcriber{}
+			transcriberLoadedAt = tt.loadedAt
+			currentState = tt.state
+			lastTransition = tt.transition
+
+			if got := releaseIdleTranscriber(timeout); got != tt.wantReleased {
+				t.Errorf("releaseIdleTranscriber() = %v, want %v", got, tt.wantReleased)
+			}
+			if isTranscriberLoaded() == tt.wantReleased {
+				t.Errorf("isTranscriberLoaded() = %v after release=%v", isTranscriberLoaded(), tt.wantReleased)
+			}
+		})
+	}
+
+	t.Run("already released", func(t *testing.T) {
+		transcriber = nil
+		currentState = StateIdle
+		lastTransition = longAgo
+		if releaseIdleTranscriber(timeout) {
+			t.Error("releaseIdleTranscriber() = true with no model loaded")
+		}
+	})
+}
+
+// TestNoteKeyword tests detecting and removing the note keyword
+func TestNoteKeyword(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		shouldDetect bool
+		expected     string
+	}{
+		{"note first", "note call the dentist", true, "call the dentist"},
+		{"note with punctuation", "Note, call the dentist.", true, "call the dentist."},
+		{"claude note", "claude note summarize the meeting", true, "claude summarize the meeting"},
+		{"note later in sentence", "please take note of this", false, "please take note of this"},
+		{"only first note removed", "note note to self", true, "note to self"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := containsNoteKeyword(tt.input); got != tt.shouldDetect {
+				t.Errorf("containsNoteKeyword(%q) = %v, want %v", tt.input, got, tt.shouldDetect)
+			}
+			if !tt.shouldDetect {
+				return
+			}
+			if got := removeNoteKeyword(tt.input); got != tt.expected {
+				t.Errorf("removeNoteKeyword(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestCombineRephrased tests the replace, below and above Claude output modes
+func TestCombineRephrased(t *testing.T) {
+	originalCfg := *cfg()
+	defer func() { setCfg(originalCfg) }()
+
+	tests := []struct {
+		name       string
+		mode       string
+		original   string
+		rephrased  string
+		wantOutput string
+	}{
+		{"replace", config.ClaudeOutputReplace, "hey fix this", "Hey, fix this.", "Hey, fix this."},
+		{"below", config.ClaudeOutputBelow, "hey fix this", "Hey, fix this.", "hey fix this | Hey, fix this."},
+		{"above", config.ClaudeOutputAbove, "hey fix this", "Hey, fix this.", "Hey, fix this. | hey fix this"},
+		{"unchanged text is not repeated", config.ClaudeOutputBelow, "Fine as is.", "Fine as is.", "Fine as is."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setCfg(config.Default())
+			cfg().ClaudeOutputMode = tt.mode
+			cfg().ClaudeSeparator = " | "
+			if got := combineRephrased(tt.original, tt.rephrased); got != tt.wantOutput {
+				t.Errorf("combineRephrased(%q, %q) = %q, want %q", tt.original, tt.rephrased, got, tt.wantOutput)
+			}
+		})
+	}
+}
+
+// TestModelsDir tests that the models folder follows GOWHISPER_MODEL
+func TestModelsDir(t *testing.T) {
+	t.Setenv("GOWHISPER_MODEL", "/opt/whisper/models/ggml-base.en.bin")
+	if got := modelsDir(); got != "/opt/whisper/models" {
+		t.Errorf("modelsDir() = %q, want /opt/whisper/models", got)
+	}
+}
+
+// TestWrapInjectedText tests the configurable prefix and suffix
+func TestWrapInjectedText(t *testing.T) {
+	originalCfg := *cfg()
+	defer func() { setCfg(originalCfg) }()
+
+	tests := []struct {
+		name   string
+		prefix string
+		suffix string
+		want   string
+	}{
+		{"none", "", "", "hello world"},
+		{"prefix", "🙂 ", "", "🙂 hello world"},
+		{"suffix", "", " -- Stephan", "hello world -- Stephan"},
+		{"both", "[", "]", "[hello world]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg().InjectPrefix, cfg().InjectSuffix = tt.prefix, tt.suffix
+			got := wrapInjectedText("hello world")
+			if got != tt.want {
+				t.Errorf("wrapInjectedText() = %q, want %q", got, tt.want)
+			}
+
+			// Undo must remove the prefix and suffix too, counting characters not bytes
+			setLastInjectedText(got)
+			if n, want := takeLastInjectedLen(), utf8.RuneCountInString(tt.want); n != want {
+				t.Errorf("takeLastInjectedLen() = %d, want %d", n, want)
+			}
+		})
+	}
+}
+
+// TestSleptBetween tests detecting a wake from sleep by a jump in the wall clock
+func TestSleptBetween(t *testing.T) {
+	last := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		gap  time.Duration
+		want bool
+	}{
+		{"regular tick", wakeCheckInterval, false},
+		{"late tick on a busy system", wakeCheckInterval + 5*time.Second, false},
+		{"just over the threshold", wakeCheckInterval + sleepGapThreshold + time.Second, true},
+		{"overnight sleep", 8 * time.Hour, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sleptBetween(last, last.Add(tt.gap)); got != tt.want {
+				t.Errorf("sleptBetween() with gap %v = %v, want %v", tt.gap, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestReloadConfig tests applying an edited config file while running
+func TestReloadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		check   func(t *testing.T)
+	}{
+		{"runtime setting applied", `{"injectionDelayMs": 300, "claudeAliases": ["cloud"]}`, func(t *testing.T) {
+			if cfg().InjectionDelayMs != 300 || !reflect.DeepEqual(cfg().ClaudeAliases, []string{"cloud"}) {
+				t.Errorf("cfg = %d %v, want the reloaded settings", cfg().InjectionDelayMs, cfg().ClaudeAliases)
+			}
+		}},
+		{"startup setting kept", `{"injectionDelayMs": 300, "verbose": true, "history": true}`, func(t *testing.T) {
+			if cfg().InjectionDelayMs != 300 {
+				t.Errorf("InjectionDelayMs = %d, want 300", cfg().InjectionDelayMs)
+			}
+			if cfg().Verbose || cfg().History {
+				t.Errorf("Verbose, History = %v, %v, want the running values until a restart", cfg().Verbose, cfg().History)
+			}
+		}},
+		{"invalid value repaired", `{"injectionDelayMs": -5, "hotkeyDebounceMs": 7}`, func(t *testing.T) {
+			if cfg().InjectionDelayMs != config.Default().InjectionDelayMs || cfg().HotkeyDebounceMs != 7 {
+				t.Errorf("cfg = %d %d, want the default delay and the valid setting", cfg().InjectionDelayMs, cfg().HotkeyDebounceMs)
+			}
+		}},
+		{"unparsable file ignored", `{"injectionDelayMs": `, func(t *testing.T) {
+			if !reflect.DeepEqual(*cfg(), config.Default()) {
+				t.Errorf("cfg changed, want the running settings kept")
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origCfg := *cfg()
+			t.Cleanup(func() { setCfg(origCfg) })
+			setCfg(config.Default())
+
+			path := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+			reloadConfig(path, nil)
+			tt.check(t)
+		})
+	}
+}
+
+// TestWatchConfig tests noticing edits to the config file
+func TestWatchConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{}`), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	changed := make(chan struct{}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watchConfig(ctx, path, 10*time.Millisecond, changed)
+
+	select {
+	case <-changed:
+		t.Fatal("changed signalled before the file was edited")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(path, []byte(`{"injectionDelayMs": 300}`), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("changed not signalled after editing the file")
+	}
+}
+
+// TestShutdown tests that Quit stops the goroutines reading hotkeys and
+// processing triggers, and closes the trigger channel after them
+func TestShutdown(t *testing.T) {
+	originalCtx, originalStop := appCtx, stopApp
+	defer func() { appCtx, stopApp = originalCtx, originalStop }()
+	appCtx, stopApp = context.WithCancel(context.Background())
+
+	triggerCh := make(chan string, 1)
+	keydown := make(chan hotkey.Event)
+	triggerSenders.Add(1)
+	go collectHotkey(appCtx, &fakeHotkey{keydown: keydown}, config.ActionPlain, triggerCh)
+
+	keydown <- hotkey.Event{}
+	select {
+	case action := <-triggerCh:
+		if action != config.ActionPlain {
+			t.Errorf("trigger = %q, want %q", action, config.ActionPlain)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("hotkey press not forwarded to the trigger channel")
+	}
+
+	loopDone := make(chan struct{})
+	go func() {
+		defer close(loopDone)
+		processTriggers(appCtx, triggerCh, nil, nil, "")
+	}()
+
+	stopped := make(chan struct{})
+	go func() {
+		shutdown(triggerCh, loopDone)
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("goroutines still running a second after shutdown")
+	}
+
+	if _, ok := <-triggerCh; ok {
+		t.Error("trigger channel still open after shutdown")
+	}
+	select {
+	case keydown <- hotkey.Event{}:
+		t.Error("hotkey still read after shutdown")
+	default:
+	}
+}
+
+// fakeHotkey stands in for a *hotkey.Hotkey whose keydown channel is
+// replaced, as Unregister and Register do
+type fakeHotkey struct {
+	mu      sync.Mutex
+	keydown chan hotkey.Event
+}
+
+func (f *fakeHotkey) Keydown() <-chan hotkey.Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.keydown
+}
+
+// reregister closes the keydown channel and makes a new one
+func (f *fakeHotkey) reregister() chan hotkey.Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	close(f.keydown)
+	f.keydown = make(chan hotkey.Event)
+	return f.keydown
+}
+
+// TestCollectHotkeyAfterReregister tests that presses are still forwarded
+// after the hotkey was unregistered and registered again, and that the
+// closed channel doesn't produce presses of its own
+func TestCollectHotkeyAfterReregister(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h := &fakeHotkey{keydown: make(chan hotkey.Event)}
+	triggerCh := make(chan string, 1)
+	triggerSenders.Add(1)
+	go collectHotkey(ctx, h, config.ActionPlain, triggerCh)
+
+	keydown := h.reregister()
+	select {
+	case <-triggerCh:
+		t.Fatal("closing the keydown channel was forwarded as a press")
+	case <-time.After(3 * hotkeyClosedRetry):
+	}
+
+	select {
+	case keydown <- hotkey.Event{}:
+	case <-time.After(time.Second):
+		t.Fatal("new keydown channel not read after re-registering")
+	}
+	select {
+	case <-triggerCh:
+	case <-time.After(time.Second):
+		t.Fatal("press after re-registering not forwarded")
+	}
+}
+
+// TestMatchKeywordCommand tests which dictations run a keyword command
+func TestMatchKeywordCommand(t *testing.T) {
+	commands := []config.KeywordCommand{{Keyword: "search", Command: "a"}, {Keyword: "ask", Command: "b"}}
+	tests := []struct {
+		text        string
+		wantCommand string
+		wantText    string
+		wantOK      bool
+	}{
+		{"search the weather", "a", "the weather", true},
+		{"Search, the weather.", "a", "the weather.", true},
+		{"ASK what time it is", "b", "what time it is", true},
+		{"search", "a", "", true},
+		{"I search the weather", "", "", false},
+		{"searching the weather", "", "", false},
+		{"", "", "", false},
+	}
+
+	for _, tt := range tests {
+		command, text, ok := matchKeywordCommand(tt.text, commands)
+		if command.Command != tt.wantCommand || text != tt.wantText || ok != tt.wantOK {
+			t.Errorf("matchKeywordCommand(%q) = %q, %q, %v, want %q, %q, %v",
+				tt.text, command.Command, text, ok, tt.wantCommand, tt.wantText, tt.wantOK)
+		}
+	}
+}
+
+// TestRunKeywordCommand tests running a command with the spoken text
+func TestRunKeywordCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command config.KeywordCommand
+		text    string
+		want    string
+		wantErr string
+	}{
+		{"output", config.KeywordCommand{Keyword: "echo", Command: "echo {text}"}, "hello world", "hello world", ""},
+		{"quoted", config.KeywordCommand{Keyword: "echo", Command: "printf '%s' {text}"}, "it's $HOME; `date`", "it's $HOME; `date`", ""},
+		{"inside double quotes", config.KeywordCommand{Keyword: "echo", Command: `echo "said: {text}"`}, "$(echo injected) `date`", "said: $(echo injected) `date`", ""},
+		{"fails", config.KeywordCommand{Keyword: "fail", Command: "exit 3"}, "x", "", "command failed"},
+		{"no output", config.KeywordCommand{Keyword: "quiet", Command: "true"}, "x", "", "printed nothing"},
+		{"timeout", config.KeywordCommand{Keyword: "slow", Command: "sleep 5", TimeoutSec: 1}, "x", "", "timed out"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runKeywordCommand(context.Background(), tt.command, tt.text)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("runKeywordCommand() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("runKeywordCommand() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestPruneRecordings tests that the oldest recordings are deleted first and
+// other files are left alone
+func TestPruneRecordings(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"2024-05-02_10-00-00.000.wav", "2024-05-01_09-00-00.000.wav", "2024-05-02_09-30-00.500.wav", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	if err := pruneRecordings(dir, 2); err != nil {
+		t.Fatalf("pruneRecordings() error = %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	want := []string{"2024-05-02_09-30-00.500.wav", "2024-05-02_10-00-00.000.wav", "notes.txt"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("files left = %v, want %v", names, want)
+	}
+}
+
+// TestParseMeminfo tests reading the available memory on Linux
+func TestParseMeminfo(t *testing.T) {
+	meminfo := "MemTotal:       16318480 kB\nMemFree:         1234567 kB\nMemAvailable:    8159240 kB\nBuffers:          102400 kB\n"
+	if got, err := parseMeminfo(meminfo); err != nil || got != 8159240<<10 {
+		t.Errorf("parseMeminfo() = %d, %v, want %d", got, err, uint64(8159240)<<10)
+	}
+	if _, err := parseMeminfo("MemTotal: 16318480 kB\n"); err == nil {
+		t.Error("parseMeminfo() without MemAvailable succeeded, want error")
+	}
+}
+
+// TestParseVMStat tests reading the available memory on macOS
+func TestParseVMStat(t *testing.T) {
+	output := `Mach Virtual Memory Statistics: (page size of 16384 bytes)
+Pages free:                               10000.
+Pages active:                            300000.
+Pages inactive:                          200000.
+Pages speculative:                         5000.
+Pages throttled:                              0.
+Pages wired down:                        150000.
+Pages purgeable:                           1000.
+`
+	if got, err := parseVMStat(output); err != nil || got != 216000*16384 {
+		t.Errorf("parseVMStat() = %d, %v, want %d", got, err, 216000*16384)
+	}
+	if _, err := parseVMStat("Pages free: 10000.\n"); err == nil {
+		t.Error("parseVMStat() without page size succeeded, want error")
+	}
+}
+
+// TestConfirmModelMemory tests the warning before loading a model that
+// doesn't fit in the available memory
+func TestConfirmModelMemory(t *testing.T) {
+	modelPath := filepath.Join(t.TempDir(), "ggml-large.bin")
+	if err := os.WriteFile(modelPath, nil, 0o600); err != nil {
+		t.Fatalf("failed to create model: %v", err)
+	}
+	// Sparse, so the test doesn't write the file for real
+	if err := os.Truncate(modelPath, 1<<30); err != nil {
+		t.Fatalf("failed to size model: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		available uint64
+		memErr    error
+		choice    string
+		confirmed bool
+		wantErr   error
+		wantAsked bool
+	}{
+		{"fits", 4 << 30, nil, "", false, nil, false},
+		{"load anyway", 1 << 30, nil, "Load Anyway", false, nil, true},
+		{"declined", 1 << 30, nil, "Don't Load", false, errModelDeclined, true},
+		{"dismissed", 1 << 30, nil, "", false, errModelDeclined, true},
+		{"confirmed before", 1 << 30, nil, "", true, nil, false},
+		{"memory unknown", 0, errors.New("vm_stat failed"), "", false, nil, false},
+	}
+
+	origInjector, origAvailable, origConfirmed := injector, availableMemory, modelMemoryConfirmed
+	defer func() { injector, availableMemory, modelMemoryConfirmed = origInjector, origAvailable, origConfirmed }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeInjector{choice: tt.choice}
+			injector = fake
+			availableMemory = func() (uint64, error) { return tt.available, tt.memErr }
+			modelMemoryConfirmed = tt.confirmed
+
+			if err := confirmModelMemory(modelPath); !errors.Is(err, tt.wantErr) {
+				t.Errorf("confirmModelMemory() = %v, want %v", err, tt.wantErr)
+			}
+			if asked := len(fake.events) > 0; asked != tt.wantAsked {
+				t.Errorf("asked = %v (%v), want %v", asked, fake.events, tt.wantAsked)
+			}
+		})
+	}
+}
+
+// TestIsUndoPhrase tests which transcriptions count as an undo phrase
+func TestIsUndoPhrase(t *testing.T) {
+	phrases := []string{"scratch that", "Undo that!"}
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"scratch that", true},
+		{"Scratch that.", true},
+		{"  SCRATCH,   that!  ", true},
+		{"Undo that", true},
+		{"scratch", false},
+		{"Scratch that idea.", false},
+		{"Please scratch that", false},
+		{"", false},
+		{"...", false},
+	}
+
+	for _, tt := range tests {
+		if got := isUndoPhrase(tt.text, phrases); got != tt.want {
+			t.Errorf("isUndoPhrase(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+	if isUndoPhrase("scratch that", nil) {
+		t.Error("isUndoPhrase() with no phrases = true, want false")
+	}
+}
+
+// TestIsJunkTranscription tests recognizing a lone filler word
+func TestIsJunkTranscription(t *testing.T) {
+	words := []string{"you", "Thanks!"}
+	tests := []struct {
+		text     string
+		minChars int
+		want     bool
+	}{
+		{".", 2, true},
+		{"I", 2, true},
+		{"ok", 2, false},
+		{"You.", 0, true},
+		{"  thanks ", 0, true},
+		{"thank you", 0, false},
+		{"you know what", 0, false},
+		{"hello", 0, false},
+		{".", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		if got := isJunkTranscription(tt.text, tt.minChars, words); got != tt.want {
+			t.Errorf("isJunkTranscription(%q, %d) = %v, want %v", tt.text, tt.minChars, got, tt.want)
+		}
+	}
+	if isJunkTranscription("you", 0, nil) {
+		t.Error("isJunkTranscription() with no words and no minimum = true, want false")
+	}
+}
+
+// TestFormatElapsed tests the recording time shown in the status line
+func TestFormatElapsed(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0:00"},
+		{999 * time.Millisecond, "0:00"},
+		{12 * time.Second, "0:12"},
+		{61500 * time.Millisecond, "1:01"},
+		{10 * time.Minute, "10:00"},
+	}
+
+	for _, tt := range tests {
+		if got := formatElapsed(tt.d); got != tt.want {
+			t.Errorf("formatElapsed(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+// TestClipboardAudioPath tests which clipboard contents name an audio file
+func TestClipboardAudioPath(t *testing.T) {
+	dir := t.TempDir()
+	song := filepath.Join(dir, "my song.MP3")
+	notes := filepath.Join(dir, "notes.txt")
+	for _, path := range []string{song, notes} {
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string // Empty when an error is expected
+	}{
+		{"plain path", song, song},
+		{"surrounding whitespace", "  " + song + "\n", song},
+		{"double quoted", `"` + song + `"`, song},
+		{"single quoted", "'" + song + "'", song},
+		{"file URL", (&url.URL{Scheme: "file", Path: song}).String(), song},
+		{"empty", "   ", ""},
+		{"two lines", song + "\n" + song, ""},
+		{"relative path", "my song.MP3", ""},
+		{"missing file", filepath.Join(dir, "missing.wav"), ""},
+		{"folder", dir, ""},
+		{"not audio", notes, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := clipboardAudioPath(tt.content)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("clipboardAudioPath(%q) = %q, want an error", tt.content, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("clipboardAudioPath(%q) = %q, %v, want %q", tt.content, got, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestMessageLevel tests the level given to messages from the log package
+func TestMessageLevel(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want slog.Level
+	}{
+		{"Error sending text: exit status 1", slog.LevelError},
+		{"Failed to register hotkey: busy", slog.LevelError},
+		{"FATAL: Failed to register hotkey", slog.LevelError},
+		{"✗ Transcription failed", slog.LevelError},
+		{"Warning: Could not read clipboard", slog.LevelWarn},
+		{"✓ Transcription: hello", slog.LevelInfo},
+		{"Config loaded from: config.json", slog.LevelInfo},
+		{"No errors here", slog.LevelInfo},
+	}
+
+	for _, tt := range tests {
+		if got := messageLevel(tt.msg); got != tt.want {
+			t.Errorf("messageLevel(%q) = %v, want %v", tt.msg, got, tt.want)
+		}
+	}
+}
+
+// TestLevelHandler tests which messages each log level keeps
+func TestLevelHandler(t *testing.T) {
+	origLevel := logLevel.Level()
+	t.Cleanup(func() { logLevel.Set(origLevel) })
+
+	tests := []struct {
+		level string
+		want  []string
+	}{
+		{config.LogLevelDebug, []string{"details", "State transition: Idle -> Recording", "Config loaded", "Warning: slow", "Error typing"}},
+		{config.LogLevelInfo, []string{"Config loaded", "Warning: slow", "Error typing"}},
+		{config.LogLevelWarn, []string{"Warning: slow", "Error typing"}},
+		{config.LogLevelError, []string{"Error typing"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			setLogLevel(tt.level)
+			var out strings.Builder
+			logger := slog.New(levelHandler{&textHandler{w: &out}})
+
+			logger.Debug("details")
+			logger.Debug("State transition: Idle -> Recording")
+			// The log package hands every message over at info level
+			logger.Info("Config loaded")
+			logger.Info("Warning: slow")
+			logger.Info("Error typing")
+
+			var got []string
+			for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+				if line != "" {
+					// Drop the date and time
+					got = append(got, strings.SplitN(line, " ", 3)[2])
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("logged %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestScheduleClipboardContent tests that a delayed copy gives way to a newer
+// write
+func TestScheduleClipboardContent(t *testing.T) {
+	t.Run("copies after the delay", func(t *testing.T) {
+		content := fakeClipboard(t, "pasted")
+		scheduleClipboardContent("original", 10*time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		clipboardMu.Lock()
+		defer clipboardMu.Unlock()
+		if *content != "original" {
+			t.Errorf("clipboard = %q, want %q", *content, "original")
+		}
+	})
+
+	t.Run("newer write wins", func(t *testing.T) {
+		content := fakeClipboard(t, "pasted")
+		scheduleClipboardContent("original", 10*time.Millisecond)
+		if err := setClipboardContent("newer"); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(50 * time.Millisecond)
+		clipboardMu.Lock()
+		defer clipboardMu.Unlock()
+		if *content != "newer" {
+			t.Errorf("clipboard = %q, want %q", *content, "newer")
+		}
+	})
+}
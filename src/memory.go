@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/stephanwesten/go-whisper/src/whisper"
+)
+
+// errModelDeclined is returned when the user chose not to load a model that
+// needs more memory than is available
+var errModelDeclined = errors.New("loading the model was declined, it needs more memory than is available")
+
+// availableMemory returns how much RAM can be used without swapping, see
+// memory_darwin.go, memory_linux.go and memory_other.go
+var availableMemory = systemAvailableMemory
+
+// modelMemoryConfirmed is set once the user chose to load a model despite
+// the memory warning, so reloading it after an idle release doesn't ask again.
+// Guarded by transcriberMu.
+var modelMemoryConfirmed bool
+
+// confirmModelMemory warns before loading a model that likely doesn't fit in
+// the available memory, which makes the whole system thrash, and lets the
+// user load it anyway. Returns errModelDeclined if they don't. Problems
+// measuring the memory are only logged. Call it with transcriberMu held.
+func confirmModelMemory(modelPath string) error {
+	if modelMemoryConfirmed {
+		return nil
+	}
+	needed, err := whisper.MemoryNeeded(modelPath)
+	if err != nil {
+		// Loading reports a missing model better
+		return nil
+	}
+	available, err := availableMemory()
+	if err != nil {
+		log.Printf("Warning: can't check the memory the model needs: %v", err)
+		return nil
+	}
+	if needed <= available {
+		return nil
+	}
+
+	log.Printf("Warning: the model needs about %s of memory, only %s is available", formatBytes(needed), formatBytes(available))
+	const loadAnyway = "Load Anyway"
+	message := fmt.Sprintf("The Whisper model at %s needs about %s of memory, but only %s is available. "+
+		"Loading it may slow down or freeze your computer.\n\n"+
+		"Close other apps, or set GOWHISPER_MODEL to a smaller model such as ggml-small.en.bin.", modelPath, formatBytes(needed), formatBytes(available))
+	if askChoice("GoWhisper - Model Too Large", message, loadAnyway, "Don't Load") != loadAnyway {
+		log.Println("Loading the model declined")
+		return errModelDeclined
+	}
+	log.Println("Loading the model anyway")
+	modelMemoryConfirmed = true
+	return nil
+}
+
+// formatBytes formats n as GB with one decimal, or MB below 1GB
+func formatBytes(n uint64) string {
+	if n < 1<<30 {
+		return fmt.Sprintf("%dMB", n>>20)
+	}
+	return fmt.Sprintf("%.1fGB", float64(n)/(1<<30))
+}
+
+// parseMeminfo returns MemAvailable from the contents of /proc/meminfo
+func parseMeminfo(meminfo string) (uint64, error) {
+	scanner := bufio.NewScanner(strings.NewReader(meminfo))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "MemAvailable:" && fields[2] == "kB" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid MemAvailable %q", fields[1])
+			}
+			return kb << 10, nil
+		}
+	}
+	return 0, errors.New("no MemAvailable in /proc/meminfo")
+}
+
+// vmStatPageSize matches the page size in the first line of vm_stat output
+var vmStatPageSize = regexp.MustCompile(`page size of (\d+) bytes`)
+
+// parseVMStat returns the memory macOS can hand out without swapping from
+// vm_stat output: free pages, plus inactive, speculative and purgeable pages
+// it reclaims on demand
+func parseVMStat(output string) (uint64, error) {
+	m := vmStatPageSize.FindStringSubmatch(output)
+	if m == nil {
+		return 0, errors.New("no page size in vm_stat output")
+	}
+	pageSize, _ := strconv.ParseUint(m[1], 10, 64)
+
+	var pages uint64
+	found := 0
+	for _, line := range strings.Split(output, "\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch name {
+		case "Pages free", "Pages inactive", "Pages speculative", "Pages purgeable":
+			n, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(value), "."), 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid vm_stat line %q", line)
+			}
+			pages += n
+			found++
+		}
+	}
+	if found == 0 {
+		return 0, errors.New("no free pages in vm_stat output")
+	}
+	return pages * pageSize, nil
+}
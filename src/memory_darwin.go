@@ -0,0 +1,17 @@
+//go:build darwin
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// systemAvailableMemory reads the free and reclaimable memory from vm_stat
+func systemAvailableMemory() (uint64, error) {
+	output, err := exec.Command("vm_stat").Output()
+	if err != nil {
+		return 0, fmt.Errorf("vm_stat failed: %w", err)
+	}
+	return parseVMStat(string(output))
+}
@@ -0,0 +1,14 @@
+//go:build linux
+
+package main
+
+import "os"
+
+// systemAvailableMemory reads the kernel's estimate of available memory
+func systemAvailableMemory() (uint64, error) {
+	data, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	return parseMeminfo(string(data))
+}
@@ -0,0 +1,10 @@
+//go:build !darwin && !linux
+
+package main
+
+import "fmt"
+
+// systemAvailableMemory fails, so the memory check is skipped
+func systemAvailableMemory() (uint64, error) {
+	return 0, fmt.Errorf("available memory %w", errUnsupportedPlatform)
+}
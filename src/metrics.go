@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// errorLabels names the dictation errors in the metrics, in the order they
+// are reported
+var errorLabels = []struct {
+	err   error
+	label string
+}{
+	{errStopRecording, "stop_recording"},
+	{errLoadModel, "load_model"},
+	{errTranscribe, "transcribe"},
+	{errRephrase, "rephrase"},
+	{errCommand, "command"},
+	{errSaveNote, "save_note"},
+	{errCopy, "copy"},
+	{errType, "type"},
+	{errOpenURL, "open_url"},
+}
+
+// dictationMetrics counts what the dictations did since the app started
+type dictationMetrics struct {
+	mu         sync.Mutex
+	recordings int
+	cancelled  int
+	actions    map[dictationAction]int
+	errors     map[string]int
+	audio      time.Duration // Audio transcribed
+	processing time.Duration // Time Whisper spent on it
+}
+
+// metrics is fed by every processed dictation and read by the metrics endpoint
+var metrics = &dictationMetrics{}
+
+// record adds the outcome of one dictation (thread-safe)
+func (m *dictationMetrics) record(res dictationResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.actions == nil {
+		m.actions = make(map[dictationAction]int)
+		m.errors = make(map[string]int)
+	}
+
+	m.recordings++
+	m.audio += res.Timings.Audio
+	m.processing += res.Timings.Processing
+	switch {
+	case errors.Is(res.Err, errCancelled):
+		m.cancelled++
+	case res.Err != nil:
+		label := "other"
+		for _, e := range errorLabels {
+			if errors.Is(res.Err, e.err) {
+				label = e.label
+				break
+			}
+		}
+		m.errors[label]++
+	default:
+		m.actions[res.Action]++
+	}
+}
+
+// writeTo writes the metrics in the Prometheus text format (thread-safe)
+func (m *dictationMetrics) writeTo(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	metric("gowhisper_recordings_total", "counter", "Recordings processed, including failed and cancelled ones.")
+	fmt.Fprintf(w, "gowhisper_recordings_total %d\n", m.recordings)
+
+	metric("gowhisper_dictations_total", "counter", "Dictations that finished, by what was done with the text.")
+	actions := make([]dictationAction, 0, len(m.actions))
+	for action := range m.actions {
+		actions = append(actions, action)
+	}
+	slices.Sort(actions)
+	for _, action := range actions {
+		fmt.Fprintf(w, "gowhisper_dictations_total{action=%q} %d\n", action, m.actions[action])
+	}
+
+	metric("gowhisper_errors_total", "counter", "Dictations that failed, by the step that failed.")
+	for _, e := range errorLabels {
+		if n := m.errors[e.label]; n > 0 {
+			fmt.Fprintf(w, "gowhisper_errors_total{stage=%q} %d\n", e.label, n)
+		}
+	}
+	if n := m.errors["other"]; n > 0 {
+		fmt.Fprintf(w, "gowhisper_errors_total{stage=\"other\"} %d\n", n)
+	}
+
+	metric("gowhisper_cancelled_total", "counter", "Dictations cancelled before any output.")
+	fmt.Fprintf(w, "gowhisper_cancelled_total %d\n", m.cancelled)
+
+	metric("gowhisper_audio_seconds_total", "counter", "Seconds of audio transcribed.")
+	fmt.Fprintf(w, "gowhisper_audio_seconds_total %g\n", m.audio.Seconds())
+
+	metric("gowhisper_transcription_seconds_total", "counter", "Seconds spent transcribing.")
+	fmt.Fprintf(w, "gowhisper_transcription_seconds_total %g\n", m.processing.Seconds())
+
+	// Audio seconds per processing second over all dictations, 5 means five
+	// times faster than real time
+	rtf := 0.0
+	if m.processing > 0 {
+		rtf = m.audio.Seconds() / m.processing.Seconds()
+	}
+	metric("gowhisper_realtime_factor", "gauge", "Average seconds of audio transcribed per second of processing.")
+	fmt.Fprintf(w, "gowhisper_realtime_factor %g\n", rtf)
+}
+
+// serveMetrics answers a scrape of the metrics endpoint
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metrics.writeTo(w)
+}
+
+// startMetricsServer serves the metrics at /metrics on localhost:port in the
+// background, so only this machine can scrape them
+func startMetricsServer(port int) {
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Printf("Warning: metrics endpoint disabled, %v", err)
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", serveMetrics)
+	log.Printf("Serving metrics at http://%s/metrics", addr)
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			log.Printf("Warning: metrics endpoint stopped: %v", err)
+		}
+	}()
+}
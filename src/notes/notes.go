@@ -0,0 +1,52 @@
+// Package notes appends dictated notes to one markdown file per day.
+package notes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Append adds text as a timestamped bullet to dir/YYYY-MM-DD.md for the day of
+// now, creating the directory and file as needed. A "~/" prefix in dir is
+// expanded to the home directory. Returns the path of the file written.
+func Append(dir, text string, now time.Time) (string, error) {
+	if strings.HasPrefix(dir, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		dir = filepath.Join(home, dir[2:])
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create notes directory: %w", err)
+	}
+
+	date := now.Format("2006-01-02")
+	path := filepath.Join(dir, date+".md")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to open note file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat note file: %w", err)
+	}
+
+	var entry strings.Builder
+	if info.Size() == 0 {
+		fmt.Fprintf(&entry, "# %s\n\n", date)
+	}
+	// Keep multi-line dictations inside the bullet
+	body := strings.ReplaceAll(strings.TrimSpace(text), "\n", "\n  ")
+	fmt.Fprintf(&entry, "- %s %s\n", now.Format("15:04"), body)
+
+	if _, err := f.WriteString(entry.String()); err != nil {
+		return "", fmt.Errorf("failed to write note: %w", err)
+	}
+	return path, nil
+}
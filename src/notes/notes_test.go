@@ -0,0 +1,56 @@
+package notes
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestAppend tests that notes land in a dated file as timestamped bullets
+func TestAppend(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "Notes")
+	morning := time.Date(2026, 3, 14, 9, 5, 0, 0, time.Local)
+
+	path, err := Append(dir, "Buy oat milk. ", morning)
+	if err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	if want := filepath.Join(dir, "2026-03-14.md"); path != want {
+		t.Errorf("Append() path = %q, want %q", path, want)
+	}
+
+	if _, err := Append(dir, "First line\nsecond line", morning.Add(3*time.Hour)); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# 2026-03-14\n\n- 09:05 Buy oat milk.\n- 12:05 First line\n  second line\n"
+	if string(got) != want {
+		t.Errorf("note file = %q, want %q", got, want)
+	}
+}
+
+// TestAppendNewDay tests that a new day starts a new file
+func TestAppendNewDay(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2026, 3, 14, 23, 59, 0, 0, time.Local)
+
+	first, err := Append(dir, "late", day)
+	if err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	second, err := Append(dir, "early", day.Add(2*time.Minute))
+	if err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	if first == second {
+		t.Errorf("notes on different days share %q", first)
+	}
+	if filepath.Base(second) != "2026-03-15.md" {
+		t.Errorf("second note file = %q, want 2026-03-15.md", filepath.Base(second))
+	}
+}
@@ -0,0 +1,87 @@
+// Package paths resolves the directories GoWhisper keeps its files in.
+//
+// GOWHISPER_HOME, when set, holds everything. Otherwise macOS uses
+// ~/.go-whisper, and other systems follow the XDG base directory spec: the
+// config in $XDG_CONFIG_HOME/go-whisper (~/.config/go-whisper) and the rest
+// in $XDG_DATA_HOME/go-whisper (~/.local/share/go-whisper). An existing
+// ~/.go-whisper is used everywhere, so an upgrade doesn't lose the model,
+// config and history.
+package paths
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+const (
+	// HomeEnv names the environment variable that overrides every directory
+	HomeEnv = "GOWHISPER_HOME"
+
+	// legacyDir is the directory under the home directory used before XDG
+	// support, and still the default on macOS
+	legacyDir = ".go-whisper"
+
+	// appDir is GoWhisper's directory under the XDG base directories
+	appDir = "go-whisper"
+)
+
+// ConfigDir returns the directory holding config.json
+func ConfigDir() string {
+	return resolve(currentEnv()).config
+}
+
+// DataDir returns the directory holding everything else: the models,
+// whisper.cpp, history, logs and recordings
+func DataDir() string {
+	return resolve(currentEnv()).data
+}
+
+// env is what the directories are resolved from
+type env struct {
+	goos   string
+	home   string // The user's home directory, "" if unknown
+	getenv func(string) string
+	exists func(string) bool // Reports whether a directory exists
+}
+
+// currentEnv returns the env of this process
+func currentEnv() env {
+	home, _ := os.UserHomeDir()
+	return env{goos: runtime.GOOS, home: home, getenv: os.Getenv, exists: isDir}
+}
+
+// dirs are the resolved directories
+type dirs struct {
+	config, data string
+}
+
+// resolve applies the precedence described in the package comment
+func resolve(e env) dirs {
+	if dir := e.getenv(HomeEnv); dir != "" {
+		return dirs{config: dir, data: dir}
+	}
+	legacy := filepath.Join(e.home, legacyDir)
+	if e.goos == "darwin" || e.exists(legacy) {
+		return dirs{config: legacy, data: legacy}
+	}
+	return dirs{
+		config: filepath.Join(xdgDir(e, "XDG_CONFIG_HOME", ".config"), appDir),
+		data:   filepath.Join(xdgDir(e, "XDG_DATA_HOME", filepath.Join(".local", "share")), appDir),
+	}
+}
+
+// xdgDir returns the base directory named by the XDG variable name, or
+// fallback under the home directory. The spec says to ignore relative paths.
+func xdgDir(e env, name, fallback string) string {
+	if dir := e.getenv(name); filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(e.home, fallback)
+}
+
+// isDir reports whether path is an existing directory
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,59 @@
+package paths
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestResolve tests the precedence of the overrides and defaults
+func TestResolve(t *testing.T) {
+	const home = "/home/ann"
+	legacy := filepath.Join(home, ".go-whisper")
+
+	tests := []struct {
+		name       string
+		goos       string
+		vars       map[string]string
+		legacy     bool // ~/.go-whisper exists
+		wantConfig string
+		wantData   string
+	}{
+		{"macOS default", "darwin", nil, false, legacy, legacy},
+		{"macOS ignores XDG", "darwin", map[string]string{"XDG_CONFIG_HOME": "/xdg/config"}, false, legacy, legacy},
+		{"Linux default", "linux", nil, false, "/home/ann/.config/go-whisper", "/home/ann/.local/share/go-whisper"},
+		{"Linux XDG", "linux", map[string]string{"XDG_CONFIG_HOME": "/xdg/config", "XDG_DATA_HOME": "/xdg/data"}, false,
+			"/xdg/config/go-whisper", "/xdg/data/go-whisper"},
+		{"relative XDG ignored", "linux", map[string]string{"XDG_CONFIG_HOME": "config", "XDG_DATA_HOME": "./data"}, false,
+			"/home/ann/.config/go-whisper", "/home/ann/.local/share/go-whisper"},
+		{"existing legacy directory wins over XDG", "linux", map[string]string{"XDG_CONFIG_HOME": "/xdg/config"}, true, legacy, legacy},
+		{"GOWHISPER_HOME wins on macOS", "darwin", map[string]string{HomeEnv: "/opt/gw"}, true, "/opt/gw", "/opt/gw"},
+		{"GOWHISPER_HOME wins on Linux", "linux", map[string]string{HomeEnv: "/opt/gw", "XDG_DATA_HOME": "/xdg/data"}, true, "/opt/gw", "/opt/gw"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolve(env{
+				goos:   tt.goos,
+				home:   home,
+				getenv: func(name string) string { return tt.vars[name] },
+				exists: func(path string) bool { return tt.legacy && path == legacy },
+			})
+			if got.config != tt.wantConfig || got.data != tt.wantData {
+				t.Errorf("resolve() = config %q, data %q, want %q, %q", got.config, got.data, tt.wantConfig, tt.wantData)
+			}
+		})
+	}
+}
+
+// TestDirsFromEnvironment tests that GOWHISPER_HOME is read from the process
+// environment
+func TestDirsFromEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(HomeEnv, dir)
+	if got := ConfigDir(); got != dir {
+		t.Errorf("ConfigDir() = %q, want %q", got, dir)
+	}
+	if got := DataDir(); got != dir {
+		t.Errorf("DataDir() = %q, want %q", got, dir)
+	}
+}
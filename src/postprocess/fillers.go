@@ -0,0 +1,115 @@
+package postprocess
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RemoveFillers removes filler words and phrases such as "um" or "you know"
+// from text. Matching is per whole word, ignoring case and attached
+// punctuation, so it can't tell a filler "like" from the verb; leave such
+// words out of fillers if that matters. Sentence-ending punctuation on a
+// removed filler is kept, and a capitalized filler starting a sentence passes
+// its capital on. Text without fillers is returned unchanged; otherwise
+// whitespace is collapsed to single spaces.
+func RemoveFillers(text string, fillers []string) string {
+	var phrases [][]string
+	for _, filler := range fillers {
+		if words := strings.Fields(strings.ToLower(filler)); len(words) > 0 {
+			phrases = append(phrases, words)
+		}
+	}
+	words := strings.Fields(text)
+	if len(phrases) == 0 || len(words) == 0 {
+		return text
+	}
+
+	kept := make([]string, 0, len(words))
+	removedAny := false
+	capitalizeNext := false
+	for i := 0; i < len(words); {
+		n, _ := matchPhrase(words[i:], phrases)
+		if n == 0 {
+			word := words[i]
+			if capitalizeNext {
+				word = capitalize(word)
+				capitalizeNext = false
+			}
+			kept = append(kept, word)
+			i++
+			continue
+		}
+
+		removed := words[i : i+n]
+		removedAny = true
+		i += n
+
+		// "Um, what is it?" becomes "What is it?"
+		if startsSentence(kept) && startsUpper(removed[0]) {
+			capitalizeNext = true
+		}
+
+		// "it works, you know." becomes "it works."
+		end := trailingPunct(removed[n-1])
+		if strings.ContainsAny(end, ".!?") && len(kept) > 0 && !startsSentence(kept) {
+			last := strings.TrimRight(kept[len(kept)-1], ",;:")
+			kept[len(kept)-1] = last + strings.TrimLeft(end, ",;:")
+		}
+	}
+
+	if !removedAny {
+		return text
+	}
+	return strings.Join(kept, " ")
+}
+
+// matchPhrase returns how many of the leading words form one of the
+// lower-case phrases, preferring the longest match, and that phrase's index.
+// It returns 0, -1 if none matches.
+func matchPhrase(words []string, phrases [][]string) (int, int) {
+	best, bestIndex := 0, -1
+	for i, phrase := range phrases {
+		if len(phrase) > len(words) || len(phrase) <= best {
+			continue
+		}
+		matched := true
+		for j, want := range phrase {
+			if normalizeWord(words[j]) != want {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			best, bestIndex = len(phrase), i
+		}
+	}
+	return best, bestIndex
+}
+
+// normalizeWord lowercases word and strips surrounding punctuation
+func normalizeWord(word string) string {
+	return strings.ToLower(strings.TrimFunc(word, unicode.IsPunct))
+}
+
+// trailingPunct returns the punctuation at the end of word
+func trailingPunct(word string) string {
+	return word[len(strings.TrimRightFunc(word, unicode.IsPunct)):]
+}
+
+// startsSentence reports whether the next word after kept begins a sentence
+func startsSentence(kept []string) bool {
+	return len(kept) == 0 || endsSentence(kept[len(kept)-1])
+}
+
+// startsUpper reports whether word begins with an upper-case letter
+func startsUpper(word string) bool {
+	r, _ := utf8.DecodeRuneInString(word)
+	return unicode.IsUpper(r)
+}
+
+// capitalize upper-cases the first letter of word
+func capitalize(word string) string {
+	r, size := utf8.DecodeRuneInString(word)
+	return string(unicode.ToUpper(r)) + word[size:]
+}
@@ -0,0 +1,49 @@
+package postprocess
+
+import "testing"
+
+// TestRemoveFillers tests token-based filler removal and punctuation handling
+func TestRemoveFillers(t *testing.T) {
+	fillers := []string{"um", "uh", "er", "you know", "like"}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"no fillers unchanged", "send the  report", "send the  report"},
+		{"single filler", "send um the report", "send the report"},
+		{"filler with comma", "send the report, uh, today", "send the report, today"},
+		{"leading filler capitalizes next word", "Um, what is this?", "What is this?"},
+		{"lower-case leading filler", "uh what is this", "what is this"},
+		{"multi-word filler", "it's, you know, broken", "it's, broken"},
+		{"trailing filler keeps period", "it works, you know.", "it works."},
+		{"trailing filler keeps question mark", "is it done, uh?", "is it done?"},
+		{"filler after sentence end", "Done. Um, next one.", "Done. Next one."},
+		{"case-insensitive", "UM the thing", "The thing"},
+		{"repeated fillers", "um uh er okay", "okay"},
+		{"only fillers", "um, uh.", ""},
+		{"filler inside word kept", "the umbrella is under here", "the umbrella is under here"},
+		{"hyphenated word kept", "uh-huh sure", "uh-huh sure"},
+		{"like is removed even as a verb", "I like it", "I it"},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RemoveFillers(tt.input, fillers); got != tt.want {
+				t.Errorf("RemoveFillers(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRemoveFillersEmptyList tests that no configured fillers means no change
+func TestRemoveFillersEmptyList(t *testing.T) {
+	input := "um  hello"
+	for _, fillers := range [][]string{nil, {}, {"", "  "}} {
+		if got := RemoveFillers(input, fillers); got != input {
+			t.Errorf("RemoveFillers(%q, %q) = %q, want unchanged", input, fillers, got)
+		}
+	}
+}
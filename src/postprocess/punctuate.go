@@ -0,0 +1,64 @@
+package postprocess
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// codeChars are characters that rarely appear in prose; text containing them is
+// probably a command, path or identifier that must not get a trailing period
+const codeChars = "/\\_=<>{}[]|`$#~;@*"
+
+// looksLikeCode reports whether text appears to be code or a shell command
+func looksLikeCode(text string) bool {
+	if strings.ContainsAny(text, codeChars) {
+		return true
+	}
+	// Command-line flags such as -m or --force
+	for _, word := range strings.Fields(text) {
+		flag := strings.TrimLeft(word, "-")
+		if flag != word && flag != "" {
+			if r, _ := utf8.DecodeRuneInString(flag); unicode.IsLetter(r) {
+				return true
+			}
+		}
+	}
+	// A single token with an inner dot is likely a file name or domain (main.go, example.com)
+	if !strings.ContainsAny(text, " \t\n") {
+		trimmed := strings.TrimRight(text, ".")
+		return strings.Contains(trimmed, ".")
+	}
+	return false
+}
+
+// endsSentence reports whether text already ends with sentence-ending punctuation,
+// ignoring closing quotes and brackets ("Really?" or (done.))
+func endsSentence(text string) bool {
+	trimmed := strings.TrimRight(text, `"')]”’»`)
+	if trimmed == "" {
+		return false
+	}
+	r, _ := utf8.DecodeLastRuneInString(trimmed)
+	return strings.ContainsRune(".!?…:", r)
+}
+
+// AutoPunctuate capitalizes the first letter and appends a period when the text
+// doesn't already end a sentence. Code-like text is returned unchanged.
+func AutoPunctuate(text string) string {
+	text = strings.TrimSpace(text)
+	if text == "" || looksLikeCode(text) {
+		return text
+	}
+
+	first, size := utf8.DecodeRuneInString(text)
+	if unicode.IsLower(first) {
+		text = string(unicode.ToUpper(first)) + text[size:]
+	}
+
+	last, _ := utf8.DecodeLastRuneInString(text)
+	if !endsSentence(text) && (unicode.IsLetter(last) || unicode.IsDigit(last)) {
+		text += "."
+	}
+	return text
+}
@@ -0,0 +1,37 @@
+package postprocess
+
+import "testing"
+
+// TestAutoPunctuate tests capitalization and trailing period insertion
+func TestAutoPunctuate(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"short command", "send it now", "Send it now."},
+		{"already capitalized", "Hello world", "Hello world."},
+		{"question kept", "is it done?", "Is it done?"},
+		{"exclamation kept", "great!", "Great!"},
+		{"period kept", "all good.", "All good."},
+		{"quoted question", `she said "why?"`, `She said "why?"`},
+		{"trailing comma left alone", "first,", "First,"},
+		{"surrounding whitespace", "  ok  ", "Ok."},
+		{"empty", "", ""},
+		{"non-ascii first letter", "élan vital", "Élan vital."},
+		{"digit ending", "call me at 5", "Call me at 5."},
+		{"shell command", "git commit -m fix", "git commit -m fix"},
+		{"path", "cd ~/projects", "cd ~/projects"},
+		{"identifier", "snake_case_name", "snake_case_name"},
+		{"file name", "main.go", "main.go"},
+		{"assignment", "x = 5", "x = 5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AutoPunctuate(tt.input); got != tt.want {
+				t.Errorf("AutoPunctuate(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
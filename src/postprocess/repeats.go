@@ -0,0 +1,75 @@
+package postprocess
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CollapseRepeats merges a word Whisper wrote twice in a row, as in "the the"
+// or "I I want", into one. Words match ignoring case; the words in keep
+// ("very", "no", ...) may be repeated on purpose and are left alone, as are
+// words separated by punctuation ("No, no") or a line break. Punctuation
+// after the dropped word moves to the one kept, and all other whitespace is
+// kept as it was.
+func CollapseRepeats(text string, keep []string) string {
+	kept := make([]string, 0, len(keep))
+	for _, word := range keep {
+		kept = append(kept, strings.ToLower(strings.TrimSpace(word)))
+	}
+
+	var b strings.Builder
+	prev := "" // The last word written to b, to compare the next one with
+	for i := 0; i < len(text); {
+		// The whitespace before the next word, then the word itself
+		start := i
+		i = skipRunes(text, i, true)
+		space := text[start:i]
+		wordStart := i
+		i = skipRunes(text, i, false)
+		word := text[wordStart:i]
+
+		if isRepeat(prev, word, space, kept) {
+			// "the the." becomes "the."
+			if end := trailingPunct(word); end != "" {
+				b.WriteString(end)
+				prev += end
+			}
+			continue
+		}
+		b.WriteString(space)
+		b.WriteString(word)
+		prev = word
+	}
+	return b.String()
+}
+
+// skipRunes returns the index of the first rune in text from i on that is
+// not whitespace when space is set, or is whitespace otherwise. Runes are
+// decoded, since bytes of a multi-byte letter such as "à" can look like
+// whitespace on their own.
+func skipRunes(text string, i int, space bool) int {
+	for i < len(text) {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if unicode.IsSpace(r) != space {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+// isRepeat reports whether word, following prev after space, repeats prev
+// by accident
+func isRepeat(prev, word, space string, keep []string) bool {
+	if prev == "" || word == "" || strings.ContainsAny(space, "\n\r") {
+		return false
+	}
+	// Punctuation in between marks a deliberate repetition
+	if trailingPunct(prev) != "" || strings.TrimLeftFunc(word, unicode.IsPunct) != word {
+		return false
+	}
+	normalized := normalizeWord(prev)
+	return normalized != "" && normalized == normalizeWord(word) && !slices.Contains(keep, normalized)
+}
@@ -0,0 +1,43 @@
+package postprocess
+
+import "testing"
+
+// TestCollapseRepeats tests merging words Whisper repeated by accident
+func TestCollapseRepeats(t *testing.T) {
+	keep := []string{"very", "No", "that"}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"no repeats unchanged", "send the  report", "send the  report"},
+		{"double word", "send the the report", "send the report"},
+		{"at the start", "I I want it", "I want it"},
+		{"ignores case", "The the cat", "The cat"},
+		{"three times", "it it it works", "it works"},
+		{"punctuation moves to the kept word", "is it done done?", "is it done?"},
+		{"keeps other whitespace", "one  two two\tthree", "one  two\tthree"},
+		{"kept word", "it is very very good", "it is very very good"},
+		{"kept word ignores case", "no No way", "no No way"},
+		{"grammatical repeat in keep", "I know that that is true", "I know that that is true"},
+		{"comma in between", "No, no, no", "No, no, no"},
+		{"sentence in between", "It works. Works for me", "It works. Works for me"},
+		{"line break in between", "list\nlist", "list\nlist"},
+		{"quoted repeat", `say "say" again`, `say "say" again`},
+		{"only punctuation", "- -", "- -"},
+		{"different words", "the then", "the then"},
+		{"leading and trailing space", " the the ", " the "},
+		{"empty", "", ""},
+		{"non-ASCII word", "àà à à", "àà à"},
+		{"non-ASCII whitespace", "the\u00a0the cat", "the cat"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CollapseRepeats(tt.input, keep); got != tt.want {
+				t.Errorf("CollapseRepeats(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
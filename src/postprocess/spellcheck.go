@@ -0,0 +1,134 @@
+package postprocess
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// spellCheckers are the command-line spell checkers tried in order. Both speak
+// the ispell pipe protocol selected with -a.
+var spellCheckers = []string{"hunspell", "aspell"}
+
+// ErrNoSpellChecker is returned by SpellCheck when neither hunspell nor
+// aspell is installed
+var ErrNoSpellChecker = errors.New("no spell checker installed (hunspell or aspell)")
+
+// maxSpellDistance is how many letters a suggestion may differ by for the
+// misspelling to count as an obvious typo
+const maxSpellDistance = 2
+
+// spellWord matches the words sent to the spell checker and replaced in text
+var spellWord = regexp.MustCompile(`\p{L}+(?:'\p{L}+)*`)
+
+// SpellCheck fixes obvious typos in text with hunspell or aspell, using the
+// named dictionary (e.g. "en_US", "" for the checker's default). Only lower
+// case words are corrected, and only when the checker's first suggestion is
+// a single word within a couple of letters, so names, acronyms and words the
+// dictionary doesn't know are left alone.
+func SpellCheck(text, dictionary string) (string, error) {
+	if strings.TrimSpace(text) == "" {
+		return text, nil
+	}
+	var command string
+	for _, name := range spellCheckers {
+		if path, err := exec.LookPath(name); err == nil {
+			command = path
+			break
+		}
+	}
+	if command == "" {
+		return text, ErrNoSpellChecker
+	}
+
+	args := []string{"-a"}
+	if dictionary != "" {
+		args = append(args, "-d", dictionary)
+	}
+	cmd := exec.Command(command, args...)
+	// "^" stops a line from being read as a command to the checker
+	var input strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		input.WriteString("^" + line + "\n")
+	}
+	cmd.Stdin = strings.NewReader(input.String())
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return text, fmt.Errorf("%s failed: %w: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return applySpelling(text, parseSpelling(string(out))), nil
+}
+
+// parseSpelling reads the misspellings from ispell pipe output, returning the
+// first suggestion for each misspelled word that has an obvious correction
+func parseSpelling(output string) map[string]string {
+	corrections := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		// "& word count offset: first, second, ..." lists suggestions; "#"
+		// lines are misspellings without any
+		head, suggestions, ok := strings.Cut(scanner.Text(), ": ")
+		fields := strings.Fields(head)
+		if !ok || len(fields) != 4 || fields[0] != "&" {
+			continue
+		}
+		word := fields[1]
+		first, _, _ := strings.Cut(suggestions, ", ")
+		if strings.ContainsAny(first, " -") || hasUpper(word) {
+			continue
+		}
+		if editDistance(strings.ToLower(word), strings.ToLower(first)) <= maxSpellDistance {
+			corrections[word] = first
+		}
+	}
+	return corrections
+}
+
+// applySpelling replaces the misspelled words in text, leaving its spacing
+// and punctuation alone
+func applySpelling(text string, corrections map[string]string) string {
+	if len(corrections) == 0 {
+		return text
+	}
+	return spellWord.ReplaceAllStringFunc(text, func(word string) string {
+		if fixed, ok := corrections[word]; ok {
+			return fixed
+		}
+		return word
+	})
+}
+
+// hasUpper reports whether word contains an upper-case letter
+func hasUpper(word string) bool {
+	return strings.IndexFunc(word, unicode.IsUpper) >= 0
+}
+
+// editDistance returns the Levenshtein distance between a and b in runes
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,76 @@
+package postprocess
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseSpelling tests reading obvious corrections from ispell pipe output
+func TestParseSpelling(t *testing.T) {
+	output := `@(#) International Ispell Version 3.2.06 (but really Hunspell 1.7.2)
+*
+& teh 3 5: the, eh, tea
+*
+& recieve 2 12: receive, relieve
+# xqzt 20
+& Kubernetes 1 26: Kubernetes's
+& definately 3 40: definitely, defiantly, definable
+& alot 2 51: a lot, allot
+& zzplorp 1 56: plop
+
+`
+	want := map[string]string{
+		"teh":        "the",
+		"recieve":    "receive",
+		"definately": "definitely",
+	}
+	if got := parseSpelling(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSpelling() = %v, want %v", got, want)
+	}
+}
+
+// TestApplySpelling tests replacing whole words while keeping punctuation
+func TestApplySpelling(t *testing.T) {
+	corrections := map[string]string{"teh": "the", "recieve": "receive"}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"word replaced", "send teh report", "send the report"},
+		{"punctuation kept", "Did you recieve it? teh end.", "Did you receive it? the end."},
+		{"part of a word kept", "tehran", "tehran"},
+		{"line breaks kept", "teh first\nteh second", "the first\nthe second"},
+		{"nothing to fix", "all good here", "all good here"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applySpelling(tt.input, corrections); got != tt.want {
+				t.Errorf("applySpelling(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEditDistance tests the letter distance used to spot obvious typos
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"teh", "the", 2},
+		{"recieve", "receive", 2},
+		{"kitten", "sitting", 3},
+		{"same", "same", 0},
+		{"", "abc", 3},
+		{"café", "cafe", 1},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package postprocess
+
+import (
+	"sort"
+	"strings"
+)
+
+// DefaultSpokenPunctuation maps spoken commands to the text that replaces them
+var DefaultSpokenPunctuation = map[string]string{
+	"new line":          "\n",
+	"new paragraph":     "\n\n",
+	"comma":             ",",
+	"period":            ".",
+	"full stop":         ".",
+	"question mark":     "?",
+	"exclamation mark":  "!",
+	"exclamation point": "!",
+	"colon":             ":",
+	"semicolon":         ";",
+}
+
+// sentenceEnders are replacements after which the next word is capitalized
+const sentenceEnders = ".?!"
+
+// attachedPunct is punctuation Whisper may have put next to a spoken command
+// ("Hello, comma, world"), dropped so it doesn't double up with the symbol
+const attachedPunct = ",.;:!?"
+
+// ApplySpokenPunctuation replaces spoken commands such as "comma" or "new
+// line" with the symbols they name. Commands are matched as whole words,
+// ignoring case and any punctuation Whisper attached to them. Symbols attach
+// to the preceding word, line breaks drop the surrounding spaces, and the
+// word after a sentence-ending symbol is capitalized. Text without commands is
+// returned unchanged.
+func ApplySpokenPunctuation(text string, mapping map[string]string) string {
+	// Sorted so the result doesn't depend on map order when phrases overlap
+	spoken := make([]string, 0, len(mapping))
+	for phrase := range mapping {
+		spoken = append(spoken, phrase)
+	}
+	sort.Strings(spoken)
+
+	var phrases [][]string
+	var symbols []string
+	for _, phrase := range spoken {
+		if words := strings.Fields(strings.ToLower(phrase)); len(words) > 0 {
+			phrases = append(phrases, words)
+			symbols = append(symbols, mapping[phrase])
+		}
+	}
+	words := strings.Fields(text)
+	if len(phrases) == 0 || len(words) == 0 {
+		return text
+	}
+
+	var out strings.Builder
+	replacedAny := false
+	needSpace := false
+	capitalizeNext := false
+	for i := 0; i < len(words); {
+		n, index := matchPhrase(words[i:], phrases)
+		if n == 0 {
+			word := words[i]
+			if capitalizeNext {
+				word = capitalize(word)
+				capitalizeNext = false
+			}
+			if needSpace {
+				out.WriteByte(' ')
+			}
+			out.WriteString(word)
+			needSpace = true
+			i++
+			continue
+		}
+
+		replacedAny = true
+		i += n
+		symbol := symbols[index]
+		current := out.String()
+		out.Reset()
+		if strings.Contains(symbol, "\n") {
+			// A comma Whisper heard in the pause before "new line" is noise,
+			// a period probably ended the sentence
+			out.WriteString(strings.TrimRight(current, " ,;"))
+			out.WriteString(symbol)
+			needSpace = false
+		} else {
+			out.WriteString(strings.TrimRight(current, attachedPunct))
+			out.WriteString(symbol)
+			needSpace = true
+			if symbol != "" && strings.ContainsAny(symbol[len(symbol)-1:], sentenceEnders) {
+				capitalizeNext = true
+			}
+		}
+	}
+
+	if !replacedAny {
+		return text
+	}
+	return out.String()
+}
@@ -0,0 +1,46 @@
+package postprocess
+
+import "testing"
+
+// TestApplySpokenPunctuation tests replacing spoken commands with symbols
+func TestApplySpokenPunctuation(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"no commands unchanged", "hello  world", "hello  world"},
+		{"comma", "hello comma world", "hello, world"},
+		{"whisper punctuation around command", "Hello, comma, world.", "Hello, world."},
+		{"period capitalizes next word", "done period next one", "done. Next one"},
+		{"question mark", "is it done question mark", "is it done?"},
+		{"new line", "first line new line second line", "first line\nsecond line"},
+		{"new line with whisper punctuation", "Dear Sam. New line. Thanks.", "Dear Sam.\nThanks."},
+		{"comma before new line dropped", "Dear Sam, new line, thanks", "Dear Sam\nthanks"},
+		{"new paragraph", "one new paragraph two", "one\n\ntwo"},
+		{"case-insensitive", "yes COMMA no", "yes, no"},
+		{"longest phrase wins", "wow exclamation point", "wow!"},
+		{"command at start", "comma hello", ", hello"},
+		{"word containing command kept", "commander periodic", "commander periodic"},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ApplySpokenPunctuation(tt.input, DefaultSpokenPunctuation); got != tt.want {
+				t.Errorf("ApplySpokenPunctuation(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestApplySpokenPunctuationCustomMapping tests a user-supplied mapping
+func TestApplySpokenPunctuationCustomMapping(t *testing.T) {
+	mapping := map[string]string{"dash": " -"}
+	if got, want := ApplySpokenPunctuation("a dash b", mapping), "a - b"; got != want {
+		t.Errorf("ApplySpokenPunctuation() = %q, want %q", got, want)
+	}
+	if got, want := ApplySpokenPunctuation("a comma b", mapping), "a comma b"; got != want {
+		t.Errorf("ApplySpokenPunctuation() = %q, want %q (defaults replaced)", got, want)
+	}
+}
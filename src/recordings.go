@@ -0,0 +1,71 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/stephanwesten/go-whisper/src/audio"
+	"github.com/stephanwesten/go-whisper/src/paths"
+)
+
+// recordingsDir holds the last cfg.KeepRecordings recordings
+func recordingsDir() string {
+	return filepath.Join(paths.DataDir(), "recordings")
+}
+
+// keepRecording saves the audio Whisper transcribes to the recordings
+// directory and deletes the oldest files beyond cfg.KeepRecordings. Returns
+// the path of the WAV file, or "" if disabled or it couldn't be saved.
+func keepRecording(samples []float32) string {
+	if cfg().KeepRecordings <= 0 {
+		return ""
+	}
+	dir := recordingsDir()
+	path := filepath.Join(dir, time.Now().Format("2006-01-02_15-04-05.000")+".wav")
+	if err := audio.SaveWAV(path, samples, wavFormat()); err != nil {
+		log.Printf("Warning: Failed to keep the recording: %v", err)
+		return ""
+	}
+	logStage("record", "kept=%s", path)
+	if err := pruneRecordings(dir, cfg().KeepRecordings); err != nil {
+		log.Printf("Warning: Failed to delete old recordings: %v", err)
+	}
+	return path
+}
+
+// pruneRecordings deletes all but the newest keep WAV files in dir. The
+// names start with the time they were saved, so they sort oldest first.
+func pruneRecordings(dir string, keep int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && strings.HasSuffix(e.Name(), ".wav") {
+			names = append(names, e.Name())
+		}
+	}
+	slices.Sort(names)
+
+	for len(names) > keep {
+		if err := os.Remove(filepath.Join(dir, names[0])); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// wavFormat returns the format recordings are saved in, see cfg.WAVFormat
+func wavFormat() audio.WAVFormat {
+	format, err := audio.ParseWAVFormat(cfg().WAVFormat)
+	if err != nil {
+		log.Printf("Warning: %v, using int16", err)
+	}
+	return format
+}
@@ -0,0 +1,56 @@
+package main
+
+import "log"
+
+// A continuous dictation session (config.ActionSession) records with
+// auto-stop after cfg.SessionPauseMs. Each pause runs the dictation and goes
+// from Processing straight back to Recording instead of Idle, until a hotkey
+// press ends the session.
+
+// startSession marks the recording about to start as a continuous session
+func startSession() {
+	stateMu.Lock()
+	sessionActive = true
+	stateMu.Unlock()
+
+	dictationRecorder.SetAutoStop(cfg().AutoStopThreshold, cfg().SessionPause())
+	log.Printf("Continuous dictation started, typing after each %dms pause", cfg().SessionPauseMs)
+}
+
+// endSession ends the continuous session and restores the configured
+// auto-stop. The recording or dictation in progress still finishes. Returns
+// false if no session was running.
+func endSession() bool {
+	stateMu.Lock()
+	wasActive := sessionActive
+	sessionActive = false
+	stateMu.Unlock()
+	if !wasActive {
+		return false
+	}
+
+	dictationRecorder.SetAutoStop(cfg().AutoStopThreshold, cfg().AutoStopSilence())
+	log.Println("Continuous dictation ended")
+	return true
+}
+
+// isSessionActive reports whether a continuous session is running (thread-safe)
+func isSessionActive() bool {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	return sessionActive
+}
+
+// finishAfterPause stops a recording that auto-stop found finished. In a
+// session the recording resumes after the dictation; only a hotkey ends it.
+func finishAfterPause() {
+	if !isSessionActive() {
+		handleHotkey()
+		return
+	}
+	if !tryTransitionState(StateRecording, StateProcessing) {
+		log.Println("Failed to transition to Processing state")
+		return
+	}
+	finishDictation()
+}
@@ -0,0 +1,100 @@
+// Package textcase converts dictated text into identifier-style casings such
+// as snake_case or kebab-case.
+package textcase
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Style is a casing transform
+type Style int
+
+const (
+	None  Style = iota
+	Snake       // my_variable_name
+	Kebab       // my-variable-name
+	Upper       // MY VARIABLE NAME
+	Camel       // myVariableName
+)
+
+func (s Style) String() string {
+	switch s {
+	case Snake:
+		return "snake"
+	case Kebab:
+		return "kebab"
+	case Upper:
+		return "upper"
+	case Camel:
+		return "camel"
+	default:
+		return "none"
+	}
+}
+
+// styleWords maps spoken modifiers to styles. Whisper sometimes hears "kebab"
+// as "kebap", and "slug" is the common name for kebab-case in URLs.
+var styleWords = map[string]Style{
+	"snake": Snake,
+	"kebab": Kebab,
+	"kebap": Kebab,
+	"slug":  Kebab,
+	"upper": Upper,
+	"camel": Camel,
+}
+
+// FromWord returns the style named by a spoken modifier, ignoring case and
+// surrounding punctuation. Returns None if word isn't a modifier.
+func FromWord(word string) Style {
+	cleaned := strings.ToLower(strings.Trim(word, ".,!?;:\"'()[]{}"))
+	return styleWords[cleaned]
+}
+
+// SplitModifier removes a leading style modifier from text and returns it
+// together with the remaining text. If the first word isn't a modifier, it
+// returns None and text unchanged.
+func SplitModifier(text string) (Style, string) {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return None, text
+	}
+	style := FromWord(words[0])
+	if style == None {
+		return None, text
+	}
+	return style, strings.Join(words[1:], " ")
+}
+
+// Apply converts text to the given style. Identifier styles drop punctuation
+// and lowercase the words; Upper only changes the case.
+func Apply(text string, style Style) string {
+	switch style {
+	case Snake:
+		return strings.Join(words(text), "_")
+	case Kebab:
+		return strings.Join(words(text), "-")
+	case Upper:
+		return strings.ToUpper(text)
+	case Camel:
+		parts := words(text)
+		for i := 1; i < len(parts); i++ {
+			runes := []rune(parts[i])
+			runes[0] = unicode.ToUpper(runes[0])
+			parts[i] = string(runes)
+		}
+		return strings.Join(parts, "")
+	default:
+		return text
+	}
+}
+
+// words splits text into lowercase words made of letters and digits. Apostrophes
+// are dropped rather than splitting, so "don't" becomes "dont".
+func words(text string) []string {
+	text = strings.ReplaceAll(text, "'", "")
+	text = strings.ReplaceAll(text, "’", "")
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
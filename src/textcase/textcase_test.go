@@ -0,0 +1,67 @@
+package textcase
+
+import "testing"
+
+// TestApply tests each casing transform on typical Whisper output
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		style Style
+		want  string
+	}{
+		{name: "snake", input: "My variable name.", style: Snake, want: "my_variable_name"},
+		{name: "kebab", input: "Hello world", style: Kebab, want: "hello-world"},
+		{name: "upper", input: "Max retries.", style: Upper, want: "MAX RETRIES."},
+		{name: "camel", input: "user account id", style: Camel, want: "userAccountId"},
+		{name: "none leaves text alone", input: "Keep this, as is.", style: None, want: "Keep this, as is."},
+		{name: "commas and hyphens split words", input: "first, second-third", style: Snake, want: "first_second_third"},
+		{name: "digits are kept", input: "version 2 release", style: Kebab, want: "version-2-release"},
+		{name: "apostrophes are dropped", input: "Don't retry", style: Snake, want: "dont_retry"},
+		{name: "curly apostrophes are dropped", input: "Don’t retry", style: Kebab, want: "dont-retry"},
+		{name: "extra whitespace", input: "  spaced   out  ", style: Snake, want: "spaced_out"},
+		{name: "non-ASCII letters", input: "Café menü", style: Kebab, want: "café-menü"},
+		{name: "empty snake", input: "", style: Snake, want: ""},
+		{name: "empty camel", input: "...", style: Camel, want: ""},
+		{name: "single word camel", input: "Hello.", style: Camel, want: "hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Apply(tt.input, tt.style); got != tt.want {
+				t.Errorf("Apply(%q, %v) = %q, want %q", tt.input, tt.style, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSplitModifier tests detecting a spoken style modifier at the start of text
+func TestSplitModifier(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantStyle Style
+		wantRest  string
+	}{
+		{name: "snake", input: "snake my variable", wantStyle: Snake, wantRest: "my variable"},
+		{name: "capitalized with comma", input: "Snake, my variable", wantStyle: Snake, wantRest: "my variable"},
+		{name: "kebab", input: "kebab page title", wantStyle: Kebab, wantRest: "page title"},
+		{name: "kebap misrecognition", input: "kebap page title", wantStyle: Kebab, wantRest: "page title"},
+		{name: "slug", input: "slug page title", wantStyle: Kebab, wantRest: "page title"},
+		{name: "upper", input: "UPPER max retries", wantStyle: Upper, wantRest: "max retries"},
+		{name: "camel", input: "camel user id", wantStyle: Camel, wantRest: "user id"},
+		{name: "no modifier", input: "copy this text", wantStyle: None, wantRest: "copy this text"},
+		{name: "modifier later is ignored", input: "a snake in the grass", wantStyle: None, wantRest: "a snake in the grass"},
+		{name: "only modifier", input: "snake", wantStyle: Snake, wantRest: ""},
+		{name: "empty", input: "", wantStyle: None, wantRest: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			style, rest := SplitModifier(tt.input)
+			if style != tt.wantStyle || rest != tt.wantRest {
+				t.Errorf("SplitModifier(%q) = (%v, %q), want (%v, %q)", tt.input, style, rest, tt.wantStyle, tt.wantRest)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/stephanwesten/go-whisper/src/whisper"
+)
+
+// audioFileExtensions are the file types Transcribe Audio File accepts. WAV is
+// decoded directly, the rest need ffmpeg.
+var audioFileExtensions = []string{
+	".wav", ".mp3", ".m4a", ".aac", ".flac", ".ogg", ".oga", ".opus",
+	".aif", ".aiff", ".caf", ".webm", ".mp4", ".mov",
+}
+
+// transcribeClipboardFile transcribes the audio file whose path is on the
+// clipboard and replaces the path with the text. Problems with the path or
+// the file are reported in a notification, since the user isn't looking at
+// a text field.
+func transcribeClipboardFile() {
+	content, err := readClipboard()
+	if err != nil {
+		log.Printf("Error reading clipboard: %v", err)
+		injector.ShowNotification("GoWhisper", "Couldn't read the clipboard")
+		return
+	}
+	path, err := clipboardAudioPath(content)
+	if err != nil {
+		log.Printf("Not transcribing clipboard: %v", err)
+		injector.ShowNotification("GoWhisper", "Can't transcribe the clipboard: "+err.Error())
+		return
+	}
+
+	// Processing keeps a config reload and the idle model release away from
+	// the transcriber, as during a dictation
+	if !tryTransitionState(StateIdle, StateProcessing) {
+		log.Println("Not transcribing clipboard: a dictation is in progress")
+		injector.ShowNotification("GoWhisper", "Finish the dictation before transcribing an audio file")
+		return
+	}
+	defer setState(StateIdle)
+
+	log.Printf("Transcribing audio file from clipboard: %s", path)
+	ui.SetStatus("📄 Transcribing " + filepath.Base(path) + "...")
+	ui.ShowStatus()
+	defer ui.HideStatus()
+
+	text, err := transcribeAudioFile(path)
+	if err != nil {
+		log.Printf("Error transcribing %s: %v", path, err)
+		injector.ShowNotification("GoWhisper", fmt.Sprintf("Couldn't transcribe %s: %v", filepath.Base(path), err))
+		return
+	}
+	if text == "" {
+		log.Printf("No speech found in %s", path)
+		injector.ShowNotification("GoWhisper", fmt.Sprintf("No speech found in %s", filepath.Base(path)))
+		return
+	}
+	if err := setClipboardContent(text); err != nil {
+		log.Printf("Error copying transcription: %v", err)
+		injector.ShowNotification("GoWhisper", "Couldn't copy the transcription to the clipboard")
+		return
+	}
+	log.Printf("✓ Transcribed %s (%d characters), copied to the clipboard", path, len(text))
+	injector.ShowNotification("GoWhisper", fmt.Sprintf("Transcribed %s, the text is on the clipboard", filepath.Base(path)))
+}
+
+// transcribeAudioFile decodes the audio file at path and transcribes it with
+// the same backend as dictations
+func transcribeAudioFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	samples, err := decodeAudioData(data)
+	if err != nil {
+		return "", err
+	}
+	transcriber, err := loadTranscriber()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errLoadModel, err)
+	}
+	text, err := transcriber.TranscribeContext(appCtx, samples, false, nil)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(text), nil
+}
+
+// clipboardAudioPath turns clipboard content into the path of an existing
+// audio file. It accepts a plain or quoted path, a "~/" path and a file://
+// URL, as copied from a terminal or a file manager.
+func clipboardAudioPath(content string) (string, error) {
+	path := strings.TrimSpace(content)
+	if path == "" {
+		return "", errors.New("it is empty, copy the path of an audio file first")
+	}
+	if strings.ContainsRune(path, '\n') {
+		return "", errors.New("it holds more than one line, copy the path of a single audio file")
+	}
+	if len(path) >= 2 && (path[0] == '"' || path[0] == '\'') && path[len(path)-1] == path[0] {
+		path = path[1 : len(path)-1]
+	}
+	if strings.HasPrefix(path, "file://") {
+		u, err := url.Parse(path)
+		if err != nil {
+			return "", errors.New("it holds an invalid file URL")
+		}
+		path = u.Path
+	}
+	path, err := whisper.ExpandPath(path)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(path) {
+		return "", errors.New("it doesn't hold the full path of a file")
+	}
+
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%s doesn't exist", path)
+	}
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a folder, not an audio file", path)
+	}
+	if !slices.Contains(audioFileExtensions, strings.ToLower(filepath.Ext(path))) {
+		return "", fmt.Errorf("%s is not an audio file", filepath.Base(path))
+	}
+	return path, nil
+}
@@ -0,0 +1,17 @@
+package main
+
+import "fmt"
+
+// Build information, set at build time by build.sh:
+//
+//	go build -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=..."
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+// versionString describes this build for --version and bug reports
+func versionString() string {
+	return fmt.Sprintf("GoWhisper %s (commit %s, built %s)", version, commit, buildDate)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	// wakeCheckInterval is how often the wall clock is checked for a jump
+	wakeCheckInterval = 10 * time.Second
+	// sleepGapThreshold is how much later than expected a check must run to
+	// count as a wake from sleep rather than a busy system
+	sleepGapThreshold = 30 * time.Second
+)
+
+// watchForWake calls onWake each time the system resumes from sleep. Sleep is
+// detected by the wall clock jumping ahead between two checks, which works
+// without native notifications. Returns when ctx is cancelled.
+func watchForWake(ctx context.Context, onWake func()) {
+	// Round(0) strips the monotonic reading, which doesn't advance during
+	// sleep on macOS and would hide the gap
+	last := time.Now().Round(0)
+	ticker := time.NewTicker(wakeCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now := time.Now().Round(0)
+		if sleptBetween(last, now) {
+			log.Printf("System woke from sleep (%v since last check)", now.Sub(last).Round(time.Second))
+			onWake()
+		}
+		last = now
+	}
+}
+
+// sleptBetween reports whether the gap between two consecutive checks is too
+// long to be explained by anything but the system sleeping
+func sleptBetween(last, now time.Time) bool {
+	return now.Sub(last) > wakeCheckInterval+sleepGapThreshold
+}
+
+// reregisterHotkey unregisters and registers the recording hotkey and the
+// action hotkeys again. A hotkey the user disabled stays disabled.
+func reregisterHotkey() {
+	hotkeyRegMu.Lock()
+	defer hotkeyRegMu.Unlock()
+
+	if !isHotkeyEnabled() {
+		log.Println("Hotkey is disabled, not re-registering after wake")
+		return
+	}
+
+	if err := hk.Unregister(); err != nil {
+		log.Printf("Warning: Failed to unregister hotkey after wake: %v", err)
+	}
+	setActionHotkeysRegistered(false)
+	if err := hk.Register(); err != nil {
+		log.Printf("Error: Failed to re-register hotkey after wake: %v", err)
+		mStatus.SetTitle("Error: Hotkey lost after sleep, use Enable Hotkey")
+		mStatus.Show()
+		// Reflect reality so the menu offers to enable it again
+		setHotkeyEnabled(false)
+		mToggleHotkey.SetTitle("Enable Hotkey")
+		mHotkey.Disable()
+		return
+	}
+	setActionHotkeysRegistered(true)
+	log.Println("Hotkey re-registered after wake")
+}
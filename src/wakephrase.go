@@ -0,0 +1,131 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/stephanwesten/go-whisper/src/audio"
+)
+
+// actionWake is the recording action of a dictation started by the wake
+// phrase. It is handled like config.ActionPlain, after removing the wake
+// phrase and anything said before it. No hotkey can be set to it.
+const actionWake = "wake"
+
+// wakeWord matches the words compared with the wake phrase
+var wakeWord = regexp.MustCompile(`[\p{L}\p{N}]+(?:'[\p{L}\p{N}]+)*`)
+
+// wakeTranscribe transcribes the audio checked for the wake phrase. Always
+// the local model: a round trip to a server every interval would be too slow.
+var wakeTranscribe = func(samples []float32) (string, error) {
+	t, err := acquireTranscriber()
+	if err != nil {
+		return "", err
+	}
+	return t.Transcribe(samples, false)
+}
+
+// wakeListener holds the wake phrase settings read at startup
+type wakeListener struct {
+	phrase    string
+	window    int     // Samples transcribed per check
+	threshold float32 // RMS below which a window is skipped as silent
+}
+
+// startWakeListener listens for cfg.WakePhrase in the audio the recorder
+// keeps while idle, sending actionWake to triggerCh when it is heard, until
+// the app quits
+func startWakeListener(recorder *audio.Recorder, triggerCh chan<- string) {
+	if err := recorder.SetMonitorWindow(cfg().WakeWindow()); err != nil {
+		log.Printf("Warning: wake phrase disabled, %v", err)
+		return
+	}
+	w := wakeListener{
+		phrase:    cfg().WakePhrase,
+		window:    int(cfg().WakeWindow().Seconds() * audio.SampleRate),
+		threshold: cfg().AutoStopThreshold,
+	}
+	interval := cfg().WakeInterval()
+	log.Printf("Listening for the wake phrase %q every %v", w.phrase, interval)
+	if cfg().AutoStopSilenceMs == 0 {
+		log.Println("Warning: autoStopSilenceMs is 0, recordings started by the wake phrase only stop on the hotkey")
+	}
+
+	triggerSenders.Add(1)
+	go func() {
+		defer triggerSenders.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-appCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			if getState() != StateIdle || !isHotkeyEnabled() {
+				continue
+			}
+			if w.heard(recorder.PreRollSnapshot()) {
+				log.Printf("Wake phrase %q heard, starting recording", w.phrase)
+				// Keeps what was said since the phrase, before recording started
+				recorder.PrependMonitorWindow()
+				select {
+				case triggerCh <- actionWake:
+				default:
+				}
+			}
+		}
+	}()
+}
+
+// heard reports whether the last window of samples contains the wake phrase.
+// Silent audio is skipped without transcribing, which keeps a quiet room cheap.
+func (w wakeListener) heard(samples []float32) bool {
+	samples = samples[max(0, len(samples)-w.window):]
+	if len(samples) < minSpeechSamples || audio.RMS(samples) < w.threshold {
+		return false
+	}
+	text, err := wakeTranscribe(samples)
+	if err != nil {
+		log.Printf("Warning: listening for the wake phrase failed: %v", err)
+		return false
+	}
+	return findWakePhrase(text, w.phrase) >= 0
+}
+
+// findWakePhrase returns the end of the first occurrence of phrase in text,
+// comparing words case-insensitively and ignoring punctuation, or -1
+func findWakePhrase(text, phrase string) int {
+	want := wakeWord.FindAllString(phrase, -1)
+	if len(want) == 0 {
+		return -1
+	}
+	words := wakeWord.FindAllStringIndex(text, -1)
+	for i := 0; i+len(want) <= len(words); i++ {
+		matched := true
+		for j, w := range want {
+			word := words[i+j]
+			if !strings.EqualFold(text[word[0]:word[1]], w) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return words[i+len(want)-1][1]
+		}
+	}
+	return -1
+}
+
+// afterWakePhrase removes the wake phrase and whatever was said before it
+// from the start of a recording, which the pre-roll captured too. Text
+// without the phrase is returned unchanged.
+func afterWakePhrase(text, phrase string) string {
+	end := findWakePhrase(text, phrase)
+	if end < 0 {
+		return text
+	}
+	return strings.TrimLeft(text[end:], " ,.!?:;-")
+}
@@ -0,0 +1,136 @@
+package whisper
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrModelCorrupt is returned when the model file exists but can't be used,
+// typically because a download was interrupted
+var ErrModelCorrupt = errors.New("model file is corrupt or incomplete")
+
+const (
+	// ggmlMagic is the first 4 bytes of a whisper.cpp model ("ggml", little-endian)
+	ggmlMagic = 0x67676d6c
+
+	// minModelSize is below the smallest published model (tiny, ~75MB), so a
+	// smaller file can only be a partial download
+	minModelSize = 30 << 20
+
+	// modelBaseURL hosts the ggml models linked in the README
+	modelBaseURL = "https://huggingface.co/ggerganov/whisper.cpp/resolve/main/"
+)
+
+// ExpandPath replaces a leading "~/" with the user's home directory
+func ExpandPath(path string) (string, error) {
+	if !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, path[2:]), nil
+}
+
+// CheckModelFile does a cheap sanity check of a model file before handing it
+// to whisper.cpp, whose own errors don't say what is wrong. Returns an error
+// wrapping os.ErrNotExist if the file is missing, or ErrModelCorrupt.
+func CheckModelFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open model: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat model: %w", err)
+	}
+	if info.Size() < minModelSize {
+		return fmt.Errorf("%w: only %d bytes", ErrModelCorrupt, info.Size())
+	}
+
+	var magic uint32
+	if err := binary.Read(f, binary.LittleEndian, &magic); err != nil {
+		return fmt.Errorf("%w: can't read header: %v", ErrModelCorrupt, err)
+	}
+	if magic != ggmlMagic {
+		return fmt.Errorf("%w: not a ggml model (magic %#x)", ErrModelCorrupt, magic)
+	}
+	return nil
+}
+
+// MemoryNeeded estimates how much RAM whisper.cpp uses with the model at
+// path: the weights plus compute buffers, which grow with the model. Fits
+// the memory usage whisper.cpp lists for its models within about 10%.
+func MemoryNeeded(path string) (uint64, error) {
+	path, err := ExpandPath(path)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return memoryForModelSize(uint64(info.Size())), nil
+}
+
+// memoryForModelSize is MemoryNeeded for a model file of size bytes
+func memoryForModelSize(size uint64) uint64 {
+	return size*13/10 + 200<<20
+}
+
+// ModelURL returns the download URL for a model, based on its file name
+func ModelURL(path string) string {
+	return modelBaseURL + filepath.Base(path)
+}
+
+// DownloadModel downloads the model named by path's file name to path. It
+// writes to a temporary file first so an interrupted download never replaces
+// a model with a partial one.
+func DownloadModel(path string) error {
+	path, err := ExpandPath(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create models directory: %w", err)
+	}
+
+	resp, err := http.Get(ModelURL(path))
+	if err != nil {
+		return fmt.Errorf("failed to download model: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download model: %s", resp.Status)
+	}
+
+	partial := path + ".part"
+	f, err := os.Create(partial)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", partial, err)
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		os.Remove(partial)
+		return fmt.Errorf("download interrupted: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(partial)
+		return fmt.Errorf("failed to write %s: %w", partial, err)
+	}
+
+	if err := CheckModelFile(partial); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	return os.Rename(partial, path)
+}
@@ -0,0 +1,103 @@
+package whisper
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeModel creates a file of the given size starting with magic
+func writeModel(t *testing.T, magic uint32, size int64) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ggml-test.bin")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := binary.Write(f, binary.LittleEndian, magic); err != nil {
+		t.Fatal(err)
+	}
+	// Sparse file, so the test doesn't write tens of megabytes
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestCheckModelFile tests classifying missing, truncated and valid model files
+func TestCheckModelFile(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		if err := CheckModelFile(writeModel(t, ggmlMagic, minModelSize)); err != nil {
+			t.Errorf("CheckModelFile() = %v, want nil", err)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		err := CheckModelFile(filepath.Join(t.TempDir(), "missing.bin"))
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("CheckModelFile() = %v, want os.ErrNotExist", err)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		err := CheckModelFile(writeModel(t, ggmlMagic, 1<<20))
+		if !errors.Is(err, ErrModelCorrupt) {
+			t.Errorf("CheckModelFile() = %v, want ErrModelCorrupt", err)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "empty.bin")
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := CheckModelFile(path); !errors.Is(err, ErrModelCorrupt) {
+			t.Errorf("CheckModelFile() = %v, want ErrModelCorrupt", err)
+		}
+	})
+
+	t.Run("wrong magic", func(t *testing.T) {
+		// An HTML error page saved in place of the model
+		err := CheckModelFile(writeModel(t, 0x4f44213c, minModelSize))
+		if !errors.Is(err, ErrModelCorrupt) {
+			t.Errorf("CheckModelFile() = %v, want ErrModelCorrupt", err)
+		}
+	})
+}
+
+// TestMemoryForModelSize tests the estimate against the memory usage
+// whisper.cpp lists for its models
+func TestMemoryForModelSize(t *testing.T) {
+	tests := []struct {
+		name string
+		size uint64 // Model file size in MB
+		want uint64 // Listed memory usage in MB
+	}{
+		{"tiny", 75, 273},
+		{"base", 142, 388},
+		{"small", 466, 852},
+		{"medium", 1500, 2100},
+		{"large", 2900, 3900},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := memoryForModelSize(tt.size<<20) >> 20
+			if got < tt.want*9/10 || got > tt.want*11/10 {
+				t.Errorf("memoryForModelSize(%dMB) = %dMB, want about %dMB", tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestModelURL tests that downloads use the model's file name
+func TestModelURL(t *testing.T) {
+	got := ModelURL("/Users/me/.go-whisper/models/ggml-small.en.bin")
+	want := "https://huggingface.co/ggerganov/whisper.cpp/resolve/main/ggml-small.en.bin"
+	if got != want {
+		t.Errorf("ModelURL() = %q, want %q", got, want)
+	}
+}
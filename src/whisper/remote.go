@@ -0,0 +1,181 @@
+package whisper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stephanwesten/go-whisper/src/audio"
+)
+
+// RemoteTranscriber transcribes by posting the audio to a whisper.cpp server
+// (examples/server in the whisper.cpp repository), so a faster machine does
+// the work. It has the same methods as Transcriber; the decoding settings are
+// those the server was started with.
+type RemoteTranscriber struct {
+	url    string
+	client *http.Client
+
+	timingsMu   sync.Mutex
+	lastTimings Timings
+
+	segmentSeparator string
+	suppressed       []*regexp.Regexp
+}
+
+// NewRemoteTranscriber creates a transcriber for the server's inference
+// endpoint at url, e.g. "http://desktop.local:8080/inference", giving up on
+// a request after timeout
+func NewRemoteTranscriber(url string, timeout time.Duration) *RemoteTranscriber {
+	return &RemoteTranscriber{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// SetSuppressed sets the text removed from every transcription, see
+// Transcriber.SetSuppressed
+func (t *RemoteTranscriber) SetSuppressed(phrases, patterns []string) error {
+	var err error
+	t.suppressed, err = compileSuppressed(phrases, patterns)
+	return err
+}
+
+// SetSegmentSeparator sets the text put between segments, see
+// Transcriber.SetSegmentSeparator
+func (t *RemoteTranscriber) SetSegmentSeparator(sep string) {
+	t.segmentSeparator = sep
+}
+
+// LastTimings returns the timings of the most recent successful
+// transcription. Processing includes the round trip to the server.
+func (t *RemoteTranscriber) LastTimings() Timings {
+	t.timingsMu.Lock()
+	defer t.timingsMu.Unlock()
+	return t.lastTimings
+}
+
+// LastConfidence always returns 0, unknown: the server's JSON response has
+// no token probabilities
+func (t *RemoteTranscriber) LastConfidence() float32 {
+	return 0
+}
+
+// Transcribe converts audio samples to text on the server. If translate is
+// set, the spoken language is auto-detected and translated to English.
+func (t *RemoteTranscriber) Transcribe(samples []float32, translate bool) (string, error) {
+	return t.TranscribeWithProgress(samples, translate, nil)
+}
+
+// TranscribeWithProgress is Transcribe for the speechTranscriber contract. The
+// server replies with the whole text at once, so onSegment is never called.
+func (t *RemoteTranscriber) TranscribeWithProgress(samples []float32, translate bool, onSegment func(Segment)) (string, error) {
+	return t.TranscribeContext(context.Background(), samples, translate, onSegment)
+}
+
+// TranscribeContext is TranscribeWithProgress, abandoning the request when
+// ctx is cancelled and returning its error
+func (t *RemoteTranscriber) TranscribeContext(ctx context.Context, samples []float32, translate bool, onSegment func(Segment)) (string, error) {
+	if len(samples) == 0 {
+		return "", fmt.Errorf("no audio samples provided")
+	}
+
+	body, contentType, err := inferenceRequest(samples, translate)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, body)
+	if err != nil {
+		return "", fmt.Errorf("invalid whisper server URL: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	start := time.Now()
+	resp, err := t.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("whisper server unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// The server reports failures as {"error": ...}, not always with an error status
+	var result struct {
+		Text  string `json:"text"`
+		Error string `json:"error"`
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read whisper server response: %w", err)
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("whisper server returned %s", resp.Status)
+		}
+		return "", fmt.Errorf("invalid whisper server response: %w", err)
+	}
+	if result.Error != "" {
+		return "", fmt.Errorf("whisper server error: %s", result.Error)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("whisper server returned %s", resp.Status)
+	}
+
+	t.timingsMu.Lock()
+	t.lastTimings = Timings{
+		Audio:      time.Duration(len(samples)) * time.Second / sampleRate,
+		Processing: time.Since(start),
+	}
+	t.timingsMu.Unlock()
+
+	// Segments come back on separate lines with leading spaces; join them the
+	// way the local transcriber does
+	return joinSegments(strings.Split(result.Text, "\n"), t.segmentSeparator, t.suppressed), nil
+}
+
+// inferenceRequest builds the multipart form the server's inference endpoint
+// expects, returning the body and its content type
+func inferenceRequest(samples []float32, translate bool) (io.Reader, string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+
+	file, err := form.CreateFormFile("file", "audio.wav")
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := audio.EncodeWAV(samples)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := file.Write(data); err != nil {
+		return nil, "", err
+	}
+
+	fields := map[string]string{
+		"response_format": "json",
+		"translate":       fmt.Sprint(translate),
+	}
+	if translate {
+		// Translation needs the source language detected rather than assumed
+		fields["language"] = "auto"
+	}
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := form.Close(); err != nil {
+		return nil, "", err
+	}
+	return &body, form.FormDataContentType(), nil
+}
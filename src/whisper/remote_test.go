@@ -0,0 +1,92 @@
+package whisper
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRemoteTranscriber tests transcribing with a fake whisper.cpp server
+func TestRemoteTranscriber(t *testing.T) {
+	tests := []struct {
+		name      string
+		translate bool
+		status    int
+		response  string
+		want      string
+		wantErr   string
+	}{
+		{"transcribed", false, http.StatusOK, `{"text": " Hello there.\n And more.\n"}`, "Hello there. And more.", ""},
+		{"suppressed", false, http.StatusOK, `{"text": " Thanks for watching!\n"}`, "", ""},
+		{"translated", true, http.StatusOK, `{"text": " Good morning."}`, "Good morning.", ""},
+		{"server error field", false, http.StatusOK, `{"error": "failed to read WAV file"}`, "", "failed to read WAV file"},
+		{"error status", false, http.StatusInternalServerError, `oops`, "", "500"},
+		{"invalid JSON", false, http.StatusOK, `oops`, "", "invalid whisper server response"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseMultipartForm(1 << 20); err != nil {
+					t.Errorf("ParseMultipartForm() error = %v", err)
+				}
+				if got := r.FormValue("translate"); got != fmt.Sprint(tt.translate) {
+					t.Errorf("translate = %q, want %v", got, tt.translate)
+				}
+				if got := r.FormValue("response_format"); got != "json" {
+					t.Errorf("response_format = %q, want json", got)
+				}
+				file, _, err := r.FormFile("file")
+				if err != nil {
+					t.Errorf("FormFile() error = %v", err)
+					return
+				}
+				data, _ := io.ReadAll(file)
+				if len(data) != 44+2*sampleRate {
+					t.Errorf("WAV size = %d, want one second of 16-bit audio", len(data))
+				}
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.response)
+			}))
+			defer server.Close()
+
+			tr := NewRemoteTranscriber(server.URL, 5*time.Second)
+			if err := tr.SetSuppressed([]string{"Thanks for watching"}, nil); err != nil {
+				t.Fatalf("SetSuppressed() error = %v", err)
+			}
+
+			got, err := tr.Transcribe(make([]float32, sampleRate), tt.translate)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Transcribe() error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Transcribe() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Transcribe() = %q, want %q", got, tt.want)
+			}
+			if timings := tr.LastTimings(); timings.Audio != time.Second {
+				t.Errorf("LastTimings().Audio = %v, want 1s", timings.Audio)
+			}
+		})
+	}
+}
+
+// TestRemoteTranscriberUnreachable tests the error when no server is listening
+func TestRemoteTranscriberUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	_, err := NewRemoteTranscriber(url, time.Second).Transcribe(make([]float32, 100), false)
+	if err == nil || !strings.Contains(err.Error(), "unreachable") {
+		t.Errorf("Transcribe() error = %v, want server unreachable", err)
+	}
+}
@@ -0,0 +1,87 @@
+package whisper
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// SetSuppressed sets the text removed from every transcription. Phrases only
+// match as a whole sentence, ignoring case and any trailing punctuation, so
+// "Thanks for watching the kids" is left alone; patterns are regular
+// expressions. Whisper tends to produce these during silence or noise, having
+// learned them from YouTube captions. If nothing but punctuation is left after
+// removing them the transcription is discarded.
+//
+// Invalid patterns are skipped and reported in the returned error; the valid
+// ones still apply.
+func (t *Transcriber) SetSuppressed(phrases, patterns []string) error {
+	var err error
+	t.suppressed, err = compileSuppressed(phrases, patterns)
+	return err
+}
+
+// suppressedReplacement replaces a match in removeSuppressed. A phrase match
+// captures the end of the sentence before it and the space after it, which
+// are put back; patterns don't have these groups, so they expand to "".
+const suppressedReplacement = "${sentenceEnd}${space}"
+
+// compileSuppressed compiles the phrases and patterns for SetSuppressed,
+// returning the valid ones along with an error naming the invalid patterns
+func compileSuppressed(phrases, patterns []string) ([]*regexp.Regexp, error) {
+	// Patterns first, so a tag after a phrase doesn't keep it from ending
+	// the sentence
+	var res []*regexp.Regexp
+	var invalid []string
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%q", pattern))
+			continue
+		}
+		res = append(res, re)
+	}
+
+	for _, phrase := range phrases {
+		if phrase = strings.TrimSpace(phrase); phrase != "" {
+			// Starts the text or follows a sentence end, and ends the text
+			// or a sentence
+			res = append(res, regexp.MustCompile(`(?i)(?:^|(?P<sentenceEnd>[.!?]))\s*`+
+				regexp.QuoteMeta(phrase)+`(?:[.!?]+(?P<space>\s+|$)|\s*$)`))
+		}
+	}
+
+	if len(invalid) > 0 {
+		return res, fmt.Errorf("invalid suppress patterns: %s", strings.Join(invalid, ", "))
+	}
+	return res, nil
+}
+
+// removeSuppressed removes every match of res from text, returning "" when no
+// words are left
+func removeSuppressed(text string, res []*regexp.Regexp) string {
+	removed := false
+	for _, re := range res {
+		// Again until nothing changes, a phrase match takes the sentence end
+		// the next repetition of the phrase needs. Every change makes text
+		// shorter, so this ends.
+		for re.MatchString(text) {
+			replaced := re.ReplaceAllString(text, suppressedReplacement)
+			if replaced == text {
+				break
+			}
+			text = replaced
+			removed = true
+		}
+	}
+	if !removed {
+		return text
+	}
+
+	text = strings.Join(strings.Fields(text), " ")
+	if strings.IndexFunc(text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+		return ""
+	}
+	return text
+}
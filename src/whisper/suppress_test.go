@@ -0,0 +1,63 @@
+package whisper
+
+import "testing"
+
+// TestRemoveSuppressed tests removing hallucinated phrases and patterns
+func TestRemoveSuppressed(t *testing.T) {
+	var tr Transcriber
+	if err := tr.SetSuppressed(
+		[]string{"Thanks for watching", " "},
+		[]string{`\[[A-Z_ ]+\]`, `(?i)\([a-z ]*music\)`},
+	); err != nil {
+		t.Fatalf("SetSuppressed() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"nothing to remove", "Send the  report today.", "Send the  report today."},
+		{"whole transcription discarded", "Thanks for watching!", ""},
+		{"phrase ignores case", "thanks for watching.", ""},
+		{"tag only", "[BLANK_AUDIO]", ""},
+		{"leftover punctuation discarded", "(upbeat music) ...", ""},
+		{"removed from the end", "See you tomorrow. Thanks for watching!", "See you tomorrow."},
+		{"removed between sentences", "Hi. Thanks for watching. Thanks for watching. Bye.", "Hi. Bye."},
+		{"part of a sentence kept", "Thanks for watching the kids.", "Thanks for watching the kids."},
+		{"end of a sentence kept", "I said thanks for watching.", "I said thanks for watching."},
+		{"removed from the middle", "Hello [MUSIC] world", "Hello world"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := removeSuppressed(tt.text, tr.suppressed); got != tt.want {
+				t.Errorf("removeSuppressed(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSetSuppressedInvalidPattern tests that a bad pattern is reported but
+// the valid ones still apply
+func TestSetSuppressedInvalidPattern(t *testing.T) {
+	var tr Transcriber
+	if err := tr.SetSuppressed([]string{"bye"}, []string{"(unclosed", `\[MUSIC\]`}); err == nil {
+		t.Error("SetSuppressed() error = nil, want error for invalid pattern")
+	}
+	if got := removeSuppressed("bye [MUSIC]", tr.suppressed); got != "" {
+		t.Errorf("removeSuppressed() = %q, want \"\"", got)
+	}
+}
+
+// TestRemoveSuppressedEmptyMatch tests that a pattern matching the empty
+// string leaves the text alone instead of looping
+func TestRemoveSuppressedEmptyMatch(t *testing.T) {
+	var tr Transcriber
+	if err := tr.SetSuppressed(nil, []string{`x*`}); err != nil {
+		t.Fatalf("SetSuppressed() error = %v", err)
+	}
+	if got := removeSuppressed("a xx b", tr.suppressed); got != "a b" {
+		t.Errorf("removeSuppressed() = %q, want \"a b\"", got)
+	}
+}
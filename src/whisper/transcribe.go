@@ -1,68 +1,300 @@
 package whisper
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	whispergo "github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
 )
 
+// ErrClosed is returned when transcribing with a transcriber that was closed
+var ErrClosed = errors.New("transcriber is closed")
+
+// ErrNotMultilingual is returned when asking an English-only model, such as
+// the .en ones, to translate
+var ErrNotMultilingual = errors.New("translation needs a multilingual model")
+
+// sampleRate is the rate whisper.cpp expects the samples in
+const sampleRate = 16000
+
 // Transcriber handles audio transcription using Whisper
 type Transcriber struct {
-	model whispergo.Model
+	// processMu lets only one transcription use the model at a time, however
+	// it was triggered; whisper.cpp isn't safe to run concurrently on one model.
+	// Overlapping calls queue up behind it.
+	processMu sync.Mutex
+	model     whispergo.Model
+	// englishOnly is set for models that can't detect the language, which
+	// translation needs
+	englishOnly bool
+
+	timingsMu      sync.Mutex
+	lastTimings    Timings
+	lastConfidence float32
+
+	temperature         float32
+	temperatureFallback float32
+
+	singleSegment    bool
+	noContext        bool
+	segmentSeparator string
+
+	suppressed []*regexp.Regexp
+}
+
+// SetSingleSegment makes whisper.cpp produce a single segment, which is faster
+// and avoids cross-segment hallucinations for short command-style dictation.
+//
+// The Go bindings have no setter for this; they turn single-segment mode on
+// whenever a segment callback is passed to Process. So enabling it installs a
+// callback, and only then are segments reported to onSegment while processing.
+func (t *Transcriber) SetSingleSegment(v bool) {
+	t.singleSegment = v
+}
+
+// SetNoContext stops whisper.cpp from using earlier transcribed text as a
+// prompt for later windows, so a misrecognition can't carry over
+func (t *Transcriber) SetNoContext(v bool) {
+	t.noContext = v
+}
+
+// SetSegmentSeparator sets the text put between the segments whisper.cpp
+// splits a transcription into, e.g. "\n" to put each sentence on its own
+// line. "" keeps the default, a single space.
+func (t *Transcriber) SetSegmentSeparator(sep string) {
+	t.segmentSeparator = sep
+}
+
+// Whisper.cpp's default sampling temperature and the step it is raised by
+// when decoding a window fails
+const (
+	DefaultTemperature         = 0
+	DefaultTemperatureFallback = 0.2
+)
+
+// SetTemperature sets the sampling temperature for subsequent transcriptions.
+// 0 always picks the most likely text, so the same audio gives the same
+// result. When a window decodes badly, e.g. repeating itself, whisper.cpp
+// retries it with the temperature raised by fallback until it succeeds or
+// reaches 1; fallback 0 disables these retries, trading robustness on
+// difficult audio for repeatable output.
+func (t *Transcriber) SetTemperature(temperature, fallback float32) error {
+	if temperature < 0 || temperature > 1 {
+		return fmt.Errorf("invalid temperature %g", temperature)
+	}
+	if fallback < 0 || fallback > 1 {
+		return fmt.Errorf("invalid temperature fallback %g", fallback)
+	}
+	t.temperature = temperature
+	t.temperatureFallback = fallback
+	return nil
+}
+
+// Timings describes how long the last transcription took
+type Timings struct {
+	Audio      time.Duration // Length of the transcribed audio
+	Processing time.Duration // Wall time spent in whisper.cpp
+}
+
+// RealTimeFactor returns audio seconds per processing second, so 5 means
+// transcription ran five times faster than real time. Returns 0 if unknown.
+func (t Timings) RealTimeFactor() float64 {
+	if t.Processing <= 0 {
+		return 0
+	}
+	return t.Audio.Seconds() / t.Processing.Seconds()
+}
+
+// LastTimings returns the timings of the most recent successful transcription
+func (t *Transcriber) LastTimings() Timings {
+	t.timingsMu.Lock()
+	defer t.timingsMu.Unlock()
+	return t.lastTimings
+}
+
+// LastConfidence returns the average probability whisper.cpp gave the text
+// tokens of the most recent successful transcription, from 0 to 1. Returns 0
+// if unknown.
+func (t *Transcriber) LastConfidence() float32 {
+	t.timingsMu.Lock()
+	defer t.timingsMu.Unlock()
+	return t.lastConfidence
+}
+
+// Segment is a piece of transcribed text reported while processing is in progress
+type Segment struct {
+	Num        int
+	Start, End time.Duration
+	Text       string
+	Confidence float32 // Average probability of the text tokens, 0 if unknown
+}
+
+// tokenProbabilities returns the sum of the probabilities of the tokens that
+// isText accepts, and how many there were. Timestamps and other special
+// tokens are always near-certain and would hide a garbled segment.
+func tokenProbabilities(tokens []whispergo.Token, isText func(whispergo.Token) bool) (sum float64, n int) {
+	for _, token := range tokens {
+		if isText(token) {
+			sum += float64(token.P)
+			n++
+		}
+	}
+	return sum, n
+}
+
+// meanProbability returns sum/n, or 0 when there were no tokens
+func meanProbability(sum float64, n int) float32 {
+	if n == 0 {
+		return 0
+	}
+	return float32(sum / float64(n))
+}
+
+// newSegment converts a segment from the bindings, using isText to tell the
+// text tokens its confidence is averaged over
+func newSegment(segment whispergo.Segment, isText func(whispergo.Token) bool) Segment {
+	return Segment{
+		Num:        segment.Num,
+		Start:      segment.Start,
+		End:        segment.End,
+		Text:       strings.TrimSpace(segment.Text),
+		Confidence: meanProbability(tokenProbabilities(segment.Tokens, isText)),
+	}
 }
 
 // NewTranscriber creates a new transcriber with the specified model
 func NewTranscriber(modelPath string) (*Transcriber, error) {
 	// Expand home directory if needed
-	if strings.HasPrefix(modelPath, "~/") {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get home directory: %w", err)
-		}
-		modelPath = filepath.Join(home, modelPath[2:])
+	modelPath, err := ExpandPath(modelPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Catch missing and truncated files before whisper.cpp does, its errors are cryptic
+	if err := CheckModelFile(modelPath); err != nil {
+		return nil, err
 	}
 
-	// Load the model
+	// Load the model. The header looked fine, so a failure here means the
+	// rest of the file is damaged.
 	model, err := whispergo.New(modelPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load model: %w", err)
+		return nil, fmt.Errorf("%w: failed to load model: %v", ErrModelCorrupt, err)
 	}
 
 	return &Transcriber{
-		model: model,
+		model:               model,
+		englishOnly:         !model.IsMultilingual(),
+		temperature:         DefaultTemperature,
+		temperatureFallback: DefaultTemperatureFallback,
 	}, nil
 }
 
-// Transcribe converts audio samples to text
-func (t *Transcriber) Transcribe(samples []float32) (string, error) {
+// IsMultilingual reports whether the model can translate, see ErrNotMultilingual
+func (t *Transcriber) IsMultilingual() bool {
+	return !t.englishOnly
+}
+
+// Transcribe converts audio samples to text. If translate is set, the spoken
+// language is auto-detected and the result is translated to English.
+func (t *Transcriber) Transcribe(samples []float32, translate bool) (string, error) {
+	return t.TranscribeWithProgress(samples, translate, nil)
+}
+
+// TranscribeWithProgress converts audio samples to text, calling onSegment for each
+// segment. onSegment may be nil. It is called synchronously on the goroutine
+// running the transcription, before this returns. In single-segment mode it
+// is called as soon as whisper.cpp produces the segment, otherwise once
+// processing is done, since a callback would turn that mode on; see
+// SetSingleSegment.
+func (t *Transcriber) TranscribeWithProgress(samples []float32, translate bool, onSegment func(Segment)) (string, error) {
+	return t.TranscribeContext(context.Background(), samples, translate, onSegment)
+}
+
+// TranscribeContext is TranscribeWithProgress, giving up when ctx is
+// cancelled and returning its error. whisper.cpp only checks before encoding
+// each 30 second window, so a window already running is finished first.
+func (t *Transcriber) TranscribeContext(ctx context.Context, samples []float32, translate bool, onSegment func(Segment)) (string, error) {
 	if len(samples) == 0 {
 		return "", fmt.Errorf("no audio samples provided")
 	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if translate && t.englishOnly {
+		return "", ErrNotMultilingual
+	}
+
+	t.processMu.Lock()
+	defer t.processMu.Unlock()
+	if t.model == nil {
+		return "", ErrClosed
+	}
 
 	// Create a fresh context for each transcription
-	context, err := t.model.NewContext()
+	wctx, err := t.model.NewContext()
 	if err != nil {
 		return "", fmt.Errorf("failed to create context: %w", err)
 	}
 
 	// Configure context parameters
-	context.SetThreads(4) // Use 4 threads for faster processing
-	context.ResetTimings()
+	wctx.SetThreads(4) // Use 4 threads for faster processing
+	wctx.SetTemperature(t.temperature)
+	wctx.SetTemperatureFallback(t.temperatureFallback)
+	if t.noContext {
+		// The bindings don't expose no_context; allowing zero tokens of past
+		// text as decoder prompt has the same effect
+		wctx.SetMaxContext(0)
+	}
+	if translate {
+		// Translation needs the source language detected rather than assumed
+		if err := wctx.SetLanguage("auto"); err != nil {
+			return "", fmt.Errorf("failed to enable language detection: %w", err)
+		}
+		wctx.SetTranslate(true)
+	}
+	wctx.ResetTimings()
+
+	var segmentCallback whispergo.SegmentCallback
+	if t.singleSegment {
+		// Any callback makes the bindings enable single-segment mode
+		segmentCallback = func(segment whispergo.Segment) {
+			if onSegment != nil {
+				onSegment(newSegment(segment, wctx.IsText))
+			}
+		}
+	}
 
 	// Process the audio data
-	if err := context.Process(samples, nil, nil, nil); err != nil {
+	start := time.Now()
+	// Returning false from the encoder callback makes whisper.cpp stop
+	keepGoing := func() bool { return ctx.Err() == nil }
+	if err := wctx.Process(samples, keepGoing, segmentCallback, nil); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
 		return "", fmt.Errorf("failed to process audio: %w", err)
 	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	timings := Timings{
+		Audio:      time.Duration(len(samples)) * time.Second / sampleRate,
+		Processing: time.Since(start),
+	}
 
-	// Collect all segments into a single string
-	var result strings.Builder
+	var segments []string
 	segmentCount := 0
+	var probabilitySum float64
+	var tokenCount int
 	for {
-		segment, err := context.NextSegment()
+		segment, err := wctx.NextSegment()
 		if err == io.EOF {
 			break
 		} else if err != nil {
@@ -70,14 +302,13 @@ func (t *Transcriber) Transcribe(samples []float32) (string, error) {
 		}
 
 		segmentCount++
-		// Trim whitespace and add to result
-		text := strings.TrimSpace(segment.Text)
-		if text != "" {
-			if result.Len() > 0 {
-				result.WriteString(" ")
-			}
-			result.WriteString(text)
+		if onSegment != nil && segmentCallback == nil {
+			onSegment(newSegment(segment, wctx.IsText))
 		}
+		sum, n := tokenProbabilities(segment.Tokens, wctx.IsText)
+		probabilitySum += sum
+		tokenCount += n
+		segments = append(segments, segment.Text)
 	}
 
 	// Log if no segments were returned at all
@@ -85,13 +316,36 @@ func (t *Transcriber) Transcribe(samples []float32) (string, error) {
 		return "", fmt.Errorf("whisper returned no segments")
 	}
 
-	return result.String(), nil
+	t.timingsMu.Lock()
+	t.lastTimings = timings
+	t.lastConfidence = meanProbability(probabilitySum, tokenCount)
+	t.timingsMu.Unlock()
+
+	return joinSegments(segments, t.segmentSeparator, t.suppressed), nil
+}
+
+// joinSegments trims the segments, removes the suppressed text from each and
+// joins the ones left with sep, a single space if empty
+func joinSegments(segments []string, sep string, suppressed []*regexp.Regexp) string {
+	if sep == "" {
+		sep = " "
+	}
+	var texts []string
+	for _, segment := range segments {
+		if text := removeSuppressed(strings.TrimSpace(segment), suppressed); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, sep)
 }
 
-// Close cleans up the transcriber
+// Close cleans up the transcriber, waiting for a running transcription to finish
 func (t *Transcriber) Close() error {
+	t.processMu.Lock()
+	defer t.processMu.Unlock()
 	if t.model != nil {
 		t.model.Close()
+		t.model = nil
 	}
 	return nil
 }
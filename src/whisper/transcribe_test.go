@@ -0,0 +1,330 @@
+package whisper
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	whispergo "github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+)
+
+// TestRealTimeFactor tests the audio/processing ratio used in the timing logs
+func TestRealTimeFactor(t *testing.T) {
+	tests := []struct {
+		name    string
+		timings Timings
+		want    float64
+	}{
+		{"faster than real time", Timings{Audio: 10 * time.Second, Processing: 2 * time.Second}, 5},
+		{"slower than real time", Timings{Audio: time.Second, Processing: 4 * time.Second}, 0.25},
+		{"no processing time", Timings{Audio: time.Second}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.timings.RealTimeFactor(); got != tt.want {
+				t.Errorf("RealTimeFactor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeModel hands out fakeContexts. Methods the transcriber doesn't use are
+// left to the embedded nil interface and would panic if called.
+type fakeModel struct {
+	whispergo.Model
+	running    atomic.Int32 // Process calls currently in progress
+	maxRunning atomic.Int32
+
+	lastContext *fakeContext      // Context of the last Process call, for single-threaded tests
+	tokens      []whispergo.Token // Tokens of the segment returned by every context
+	segments    []string          // Texts of the segments returned by every context, " hello " if nil
+}
+
+func (m *fakeModel) NewContext() (whispergo.Context, error) {
+	return &fakeContext{model: m}, nil
+}
+
+func (m *fakeModel) Close() error { return nil }
+
+// fakeContext returns the model's segments after a short delay in Process
+type fakeContext struct {
+	whispergo.Context
+	model    *fakeModel
+	returned int // Number of segments returned by NextSegment
+
+	maxContext          *int // Set by SetMaxContext
+	hadSegmentCallback  bool
+	temperature         float32
+	temperatureFallback float32
+}
+
+func (c *fakeContext) SetThreads(uint) {}
+func (c *fakeContext) ResetTimings()   {}
+
+func (c *fakeContext) SetTemperature(t float32) {
+	c.temperature = t
+}
+
+func (c *fakeContext) SetTemperatureFallback(t float32) {
+	c.temperatureFallback = t
+}
+
+func (c *fakeContext) SetMaxContext(n int) {
+	c.maxContext = &n
+}
+
+func (c *fakeContext) Process(_ []float32, _ whispergo.EncoderBeginCallback, onSegment whispergo.SegmentCallback, _ whispergo.ProgressCallback) error {
+	c.model.lastContext = c
+	c.hadSegmentCallback = onSegment != nil
+	n := c.model.running.Add(1)
+	defer c.model.running.Add(-1)
+	for {
+		max := c.model.maxRunning.Load()
+		if n <= max || c.model.maxRunning.CompareAndSwap(max, n) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+	return nil
+}
+
+func (c *fakeContext) NextSegment() (whispergo.Segment, error) {
+	segments := c.model.segments
+	if segments == nil {
+		segments = []string{" hello "}
+	}
+	if c.returned == len(segments) {
+		return whispergo.Segment{}, io.EOF
+	}
+	c.returned++
+	return whispergo.Segment{Text: segments[c.returned-1], Tokens: c.model.tokens}, nil
+}
+
+// IsText treats tokens written like "[_BEG_]" as special
+func (c *fakeContext) IsText(token whispergo.Token) bool {
+	return !strings.HasPrefix(token.Text, "[_")
+}
+
+// TestTranscribeSerializesProcess tests that concurrent Transcribe calls never
+// run whisper.cpp at the same time. Run with -race.
+func TestTranscribeSerializesProcess(t *testing.T) {
+	model := &fakeModel{}
+	tr := &Transcriber{model: model}
+	samples := make([]float32, sampleRate)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			text, err := tr.Transcribe(samples, false)
+			if err != nil || text != "hello" {
+				t.Errorf("Transcribe() = %q, %v, want \"hello\"", text, err)
+			}
+			_ = tr.LastTimings()
+		}()
+	}
+	wg.Wait()
+
+	if got := model.maxRunning.Load(); got != 1 {
+		t.Errorf("up to %d transcriptions ran at once, want 1", got)
+	}
+}
+
+// TestLastConfidence tests that the confidence averages the text tokens only
+func TestLastConfidence(t *testing.T) {
+	tests := []struct {
+		name   string
+		tokens []whispergo.Token
+		want   float32
+	}{
+		{"no tokens", nil, 0},
+		{"text tokens", []whispergo.Token{{Text: " hel", P: 0.5}, {Text: "lo", P: 0.75}}, 0.625},
+		{"special tokens ignored", []whispergo.Token{{Text: "[_BEG_]", P: 1}, {Text: " hello", P: 0.25}, {Text: "[_TT_50]", P: 1}}, 0.25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transcriber{model: &fakeModel{tokens: tt.tokens}}
+			if _, err := tr.Transcribe(make([]float32, sampleRate), false); err != nil {
+				t.Fatalf("Transcribe() error = %v", err)
+			}
+			if got := tr.LastConfidence(); got != tt.want {
+				t.Errorf("LastConfidence() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTranscribeAfterClose tests that a closed transcriber reports ErrClosed
+func TestTranscribeAfterClose(t *testing.T) {
+	tr := &Transcriber{model: &fakeModel{}}
+	tr.Close()
+
+	if _, err := tr.Transcribe(make([]float32, sampleRate), false); !errors.Is(err, ErrClosed) {
+		t.Errorf("Transcribe() after Close error = %v, want ErrClosed", err)
+	}
+}
+
+// TestTranslateEnglishOnly tests that an English-only model refuses to
+// translate without running whisper.cpp
+func TestTranslateEnglishOnly(t *testing.T) {
+	model := &fakeModel{}
+	tr := &Transcriber{model: model, englishOnly: true}
+
+	if _, err := tr.Transcribe(make([]float32, sampleRate), true); !errors.Is(err, ErrNotMultilingual) {
+		t.Errorf("Transcribe() translating error = %v, want ErrNotMultilingual", err)
+	}
+	if model.lastContext != nil {
+		t.Error("whisper.cpp ran for a translation the model can't do")
+	}
+	if _, err := tr.Transcribe(make([]float32, sampleRate), false); err != nil {
+		t.Errorf("Transcribe() error = %v", err)
+	}
+}
+
+// TestSingleSegmentAndNoContext tests how the options are mapped onto the
+// whisper.cpp context
+func TestSingleSegmentAndNoContext(t *testing.T) {
+	samples := make([]float32, sampleRate)
+
+	tests := []struct {
+		name              string
+		singleSegment     bool
+		noContext         bool
+		wantCallback      bool
+		wantMaxContextSet bool
+	}{
+		{"defaults", false, false, false, false},
+		{"single segment", true, false, true, false},
+		{"no context", false, true, false, true},
+		{"both", true, true, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := &fakeModel{}
+			tr := &Transcriber{model: model}
+			tr.SetSingleSegment(tt.singleSegment)
+			tr.SetNoContext(tt.noContext)
+
+			if _, err := tr.Transcribe(samples, false); err != nil {
+				t.Fatalf("Transcribe() error = %v", err)
+			}
+			ctx := model.lastContext
+			if ctx.hadSegmentCallback != tt.wantCallback {
+				t.Errorf("segment callback passed = %v, want %v", ctx.hadSegmentCallback, tt.wantCallback)
+			}
+			if (ctx.maxContext != nil) != tt.wantMaxContextSet {
+				t.Errorf("SetMaxContext called = %v, want %v", ctx.maxContext != nil, tt.wantMaxContextSet)
+			}
+			if ctx.maxContext != nil && *ctx.maxContext != 0 {
+				t.Errorf("SetMaxContext(%d), want 0", *ctx.maxContext)
+			}
+		})
+	}
+}
+
+// TestOnSegmentKeepsSegmentMode tests that asking for segments doesn't turn
+// on single-segment mode, and that they are still all reported
+func TestOnSegmentKeepsSegmentMode(t *testing.T) {
+	for _, singleSegment := range []bool{false, true} {
+		model := &fakeModel{segments: []string{" One.", " Two. "}}
+		tr := &Transcriber{model: model}
+		tr.SetSingleSegment(singleSegment)
+
+		var got []string
+		if _, err := tr.TranscribeWithProgress(make([]float32, sampleRate), false, func(segment Segment) {
+			got = append(got, segment.Text)
+		}); err != nil {
+			t.Fatalf("TranscribeWithProgress() error = %v", err)
+		}
+		if ctx := model.lastContext; ctx.hadSegmentCallback != singleSegment {
+			t.Errorf("singleSegment %v: segment callback passed = %v, want %v", singleSegment, ctx.hadSegmentCallback, singleSegment)
+		}
+		// The fake never calls the segment callback, so in single-segment
+		// mode nothing is reported
+		if !singleSegment && !slices.Equal(got, []string{"One.", "Two."}) {
+			t.Errorf("segments reported = %q, want [\"One.\" \"Two.\"]", got)
+		}
+	}
+}
+
+// TestSegmentSeparator tests joining the segments of a transcription
+func TestSegmentSeparator(t *testing.T) {
+	segments := []string{" First item.", " Thanks for watching!", " Second item. "}
+
+	tests := []struct {
+		name      string
+		separator string
+		want      string
+	}{
+		{"default", "", "First item. Second item."},
+		{"newline", "\n", "First item.\nSecond item."},
+		{"custom", " / ", "First item. / Second item."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transcriber{model: &fakeModel{segments: segments}}
+			tr.SetSegmentSeparator(tt.separator)
+			if err := tr.SetSuppressed([]string{"Thanks for watching"}, nil); err != nil {
+				t.Fatalf("SetSuppressed() error = %v", err)
+			}
+
+			got, err := tr.Transcribe(make([]float32, sampleRate), false)
+			if err != nil {
+				t.Fatalf("Transcribe() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Transcribe() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSetTemperature tests validating the temperature settings and passing
+// them to the whisper.cpp context
+func TestSetTemperature(t *testing.T) {
+	tests := []struct {
+		name        string
+		temperature float32
+		fallback    float32
+		wantErr     bool
+	}{
+		{"defaults", DefaultTemperature, DefaultTemperatureFallback, false},
+		{"no fallback", 0, 0, false},
+		{"warmer", 0.4, 0.1, false},
+		{"negative temperature", -0.1, 0.2, true},
+		{"temperature too high", 1.5, 0.2, true},
+		{"negative fallback", 0, -0.2, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := &fakeModel{}
+			tr := &Transcriber{model: model}
+			err := tr.SetTemperature(tt.temperature, tt.fallback)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetTemperature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if _, err := tr.Transcribe(make([]float32, sampleRate), false); err != nil {
+				t.Fatalf("Transcribe() error = %v", err)
+			}
+			ctx := model.lastContext
+			if ctx.temperature != tt.temperature || ctx.temperatureFallback != tt.fallback {
+				t.Errorf("context temperature = %v, fallback %v, want %v, %v", ctx.temperature, ctx.temperatureFallback, tt.temperature, tt.fallback)
+			}
+		})
+	}
+}
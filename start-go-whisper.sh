@@ -32,7 +32,7 @@ pkill -f "/exe/main" 2>/dev/null
 
 # Start the application in the background
 echo "Starting GoWhisper..."
-go run src/main.go > /tmp/go-whisper.log 2>&1 &
+go run ./src > /tmp/go-whisper.log 2>&1 &
 
 # Wait a moment and check if it started
 sleep 2